@@ -0,0 +1,155 @@
+package loadshedder
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultGradientInterval   = 500 * time.Millisecond
+	defaultGradientTolerance  = 1.5
+	defaultGradientSmoothing  = 0.2
+	defaultGradientLongWindow = time.Minute
+
+	// The gradient is bounded so a single update can at most halve the limit.
+	minGradient = 0.5
+)
+
+// GradientConfig configures the adaptive limit, see Config.Adaptive.
+// The algorithm follows Gradient2 from Netflix concurrency-limits: the
+// average request duration (Stats.AvgDuration) is compared to a long-term
+// baseline, and the limit shrinks as the ratio degrades:
+//
+//	gradient = clamp(Tolerance * baseline / average, 0.5, 1)
+//	limit    = limit * gradient + sqrt(limit)
+//
+// The sqrt(limit) term lets the limit grow while the latency is stable, and
+// each new limit is smoothed with the previous one.
+type GradientConfig struct {
+	// MinLimit is the lowest adaptive limit.
+	// Optional, default to 1.
+	MinLimit int64
+
+	// Interval is the period between limit updates.
+	// Optional, default to 500ms.
+	Interval time.Duration
+
+	// Tolerance is the ratio of the average duration over the baseline
+	// tolerated before the limit is lowered.
+	// Optional, default to 1.5, must be at least 1.
+	Tolerance float64
+
+	// Smoothing is the weight of each new limit, in (0, 1].
+	// Optional, default to 0.2.
+	Smoothing float64
+
+	// LongWindow is the time constant of the baseline duration average.
+	// Optional, default to 1m.
+	LongWindow time.Duration
+}
+
+// gradientController adjusts the adaptive limit at most once per interval,
+// on the Acquire path.
+type gradientController struct {
+	minLimit   float64
+	interval   int64        // nanoseconds
+	nextUpdate atomic.Int64 // unix nanoseconds
+	tolerance  float64
+	smoothing  float64
+	longWeight float64 // weight of each update in the baseline average
+
+	// guarded by Loadshedder.limitMu
+	baseline float64 // nanoseconds
+	limit    float64
+}
+
+func newGradientController(cfg Config) *gradientController {
+	g := cfg.Gradient
+	return &gradientController{
+		minLimit:   float64(g.MinLimit),
+		interval:   int64(g.Interval),
+		tolerance:  g.Tolerance,
+		smoothing:  g.Smoothing,
+		longWeight: min(1, float64(g.Interval)/float64(g.LongWindow)),
+		limit:      float64(cfg.Limit),
+	}
+}
+
+func applyGradientDefaults(g GradientConfig, limit int64) GradientConfig {
+	if g.MinLimit <= 0 {
+		g.MinLimit = 1
+	}
+	g.MinLimit = min(g.MinLimit, limit)
+	if g.Interval <= 0 {
+		g.Interval = defaultGradientInterval
+	}
+	if g.Tolerance == 0 {
+		g.Tolerance = defaultGradientTolerance
+	}
+	if g.Tolerance < 1 {
+		panic("loadshedder: GradientConfig.Tolerance must be at least 1")
+	}
+	if g.Smoothing == 0 {
+		g.Smoothing = defaultGradientSmoothing
+	}
+	if g.Smoothing < 0 || g.Smoothing > 1 {
+		panic("loadshedder: GradientConfig.Smoothing must be in (0, 1]")
+	}
+	if g.LongWindow <= 0 {
+		g.LongWindow = defaultGradientLongWindow
+	}
+	return g
+}
+
+// updateAdaptiveLimit recomputes the adaptive limit if the interval elapsed.
+func (l *Loadshedder) updateAdaptiveLimit(now time.Time) {
+	g := l.gradient
+	next := g.nextUpdate.Load()
+	if now.UnixNano() < next || !g.nextUpdate.CompareAndSwap(next, now.UnixNano()+g.interval) {
+		return
+	}
+
+	average := float64(l.avgDuration.Load())
+	if average == 0 {
+		return // no request completed yet
+	}
+
+	l.limitMu.Lock()
+	defer l.limitMu.Unlock()
+
+	if g.baseline == 0 {
+		g.baseline = average
+	} else {
+		g.baseline += (average - g.baseline) * g.longWeight
+	}
+	// After a sustained latency drop, let the baseline catch up faster, so an
+	// outdated slow baseline does not inflate the limit.
+	if g.baseline > 2*average {
+		g.baseline *= 0.95
+	}
+
+	gradient := max(minGradient, min(1, g.tolerance*g.baseline/average))
+	limit := g.limit*gradient + math.Sqrt(g.limit)
+
+	// Don't grow the limit while it is not used, it would not be backed by
+	// any latency measurement.
+	if limit > g.limit && float64(l.slots.inUse.Load()) < g.limit/2 {
+		return
+	}
+
+	limit = g.limit*(1-g.smoothing) + limit*g.smoothing
+	g.limit = max(g.minLimit, min(float64(max(1, l.limit.Load()+l.donated)), limit))
+
+	l.adaptiveLimit = int64(g.limit)
+	l.updateEffectiveLimit()
+}
+
+// AdaptiveLimit returns the limit computed by the adaptive mode, or 0 if
+// Config.Adaptive is not set. The effective limit may be lower, see Clamp.
+func (l *Loadshedder) AdaptiveLimit() int64 {
+	l.limitMu.Lock()
+	defer l.limitMu.Unlock()
+
+	return l.adaptiveLimit
+}
@@ -0,0 +1,73 @@
+package loadshedder
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLoadshedder_Adaptive(t *testing.T) {
+	ls := New(Config{
+		Limit:    100,
+		Adaptive: true,
+		Gradient: GradientConfig{MinLimit: 10, Smoothing: 1},
+	})
+
+	if got := ls.AdaptiveLimit(); got != 100 {
+		t.Fatalf("expected the adaptive limit to start at the configured limit, got %d", got)
+	}
+
+	now := time.Now()
+	update := func(avg time.Duration) int64 {
+		now = now.Add(time.Second)
+		ls.avgDuration.Store(int64(avg))
+		ls.updateAdaptiveLimit(now)
+		return ls.AdaptiveLimit()
+	}
+
+	// Establish the baseline: the limit is capped by the configured limit
+	if got := update(10 * time.Millisecond); got != 100 {
+		t.Errorf("expected the limit to stay at 100 with a stable latency, got %d", got)
+	}
+
+	// Latency doubles beyond the tolerance: the limit shrinks
+	previous := int64(100)
+	for range 3 {
+		got := update(40 * time.Millisecond)
+		if got >= previous {
+			t.Fatalf("expected the limit to shrink below %d, got %d", previous, got)
+		}
+		previous = got
+	}
+	if got := ls.Stats().EffectiveLimit; got != previous {
+		t.Errorf("expected the effective limit to follow the adaptive limit %d, got %d", previous, got)
+	}
+
+	// The limit never drops below MinLimit
+	for range 20 {
+		update(time.Second)
+	}
+	if got := ls.AdaptiveLimit(); got != 10 {
+		t.Errorf("expected the limit to bottom at MinLimit, got %d", got)
+	}
+
+	// Latency recovers, but the limit only grows while it is used
+	if got := update(10 * time.Millisecond); got != 10 {
+		t.Errorf("expected an unused limit not to grow, got %d", got)
+	}
+
+	for range 10 {
+		_, token := ls.Acquire(context.Background())
+		defer ls.Release(token)
+	}
+	if got := update(10 * time.Millisecond); got <= 10 {
+		t.Errorf("expected a used limit to grow with a stable latency, got %d", got)
+	}
+}
+
+func TestLoadshedder_AdaptiveDisabled(t *testing.T) {
+	ls := New(Config{Limit: 10})
+	if got := ls.AdaptiveLimit(); got != 0 {
+		t.Errorf("expected no adaptive limit, got %d", got)
+	}
+}
@@ -0,0 +1,159 @@
+package loadshedder
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AdminConfig configures NewAdminHandler and NewBulkHandler.
+type AdminConfig struct {
+	// Authorize authenticates the POST requests changing the loadshedders,
+	// e.g. BearerAuth. Without it, the handlers refuse them: NewAdminHandler
+	// is read-only.
+	// The state served on GET is not authenticated, like NewStatsHandler:
+	// mount the handler behind the admin access controls of the service.
+	// Optional.
+	Authorize func(*http.Request) bool
+}
+
+// AdminResponse is the JSON document served by NewAdminHandler: the
+// StatsResponse of NewStatsHandler and the state of the admin controls.
+type AdminResponse struct {
+	StatsResponse
+
+	WaitingLimit int64 `json:"waiting_limit"`
+	Paused       bool  `json:"paused"`
+	Shadow       bool  `json:"shadow"`
+	Draining     bool  `json:"draining"`
+}
+
+// BearerAuth returns an AdminConfig.Authorize accepting the requests with
+// the header Authorization: Bearer <token>. Panics if token is empty.
+func BearerAuth(token string) func(*http.Request) bool {
+	if token == "" {
+		panic("loadshedder: BearerAuth token cannot be empty")
+	}
+	expected := []byte(token)
+
+	return func(r *http.Request) bool {
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		return ok && subtle.ConstantTimeCompare([]byte(provided), expected) == 1
+	}
+}
+
+// NewAdminHandler serves a control plane for the loadshedder, for operators:
+//
+//	GET                                  the AdminResponse (supports ?since=, see NewStatsHandler)
+//	POST ?op=limit&value=<n>             SetLimit
+//	POST ?op=waiting_limit&value=<n>     SetWaitingLimit
+//	POST ?op=pause, ?op=resume           Pause, Resume
+//	POST ?op=drain[&timeout=<duration>]  Drain, waiting until drained or the timeout
+//
+// POSTs must pass cfg.Authorize, and respond with the AdminResponse after
+// the change. A drain that did not complete in time responds 504: the
+// loadshedder keeps draining, and the drain can be polled with GET.
+func NewAdminHandler(ls *Loadshedder, cfg AdminConfig) http.Handler {
+	epoch := newStatsEpoch()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Validated before any change
+		since := r.URL.Query().Get("since")
+		if since != "" {
+			if _, _, err := parseCheckpoint(since); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead:
+		case http.MethodPost:
+			if cfg.Authorize == nil {
+				http.Error(w, "loadshedder: admin actions are disabled", http.StatusForbidden)
+				return
+			}
+			if !cfg.Authorize(r) {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if status, err := ls.applyAdminOp(r); err != nil {
+				http.Error(w, err.Error(), status)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, HEAD, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		stats, err := newStatsResponse(ls, epoch, since)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		_ = json.NewEncoder(w).Encode(AdminResponse{
+			StatsResponse: stats,
+			WaitingLimit:  ls.settings.Load().waitingLimit,
+			Paused:        ls.Paused(),
+			Shadow:        ls.Shadow(),
+			Draining:      ls.Draining(),
+		})
+	})
+}
+
+// applyAdminOp applies the operation of an admin POST, returning the
+// response status on error.
+func (l *Loadshedder) applyAdminOp(r *http.Request) (int, error) {
+	query := r.URL.Query()
+
+	switch op := query.Get("op"); op {
+	case "limit":
+		value, err := strconv.ParseInt(query.Get("value"), 10, 64)
+		if err != nil || value <= 0 {
+			return http.StatusBadRequest, fmt.Errorf("loadshedder: limit must be a positive integer, got %q", query.Get("value"))
+		}
+		l.SetLimit(value)
+
+	case "waiting_limit":
+		value, err := strconv.ParseInt(query.Get("value"), 10, 64)
+		if err != nil || value < 0 {
+			return http.StatusBadRequest, fmt.Errorf("loadshedder: waiting limit must be a non-negative integer, got %q", query.Get("value"))
+		}
+		l.SetWaitingLimit(value)
+
+	case "pause":
+		l.Pause()
+
+	case "resume":
+		l.Resume()
+
+	case "drain":
+		ctx := r.Context()
+		if timeout := query.Get("timeout"); timeout != "" {
+			d, err := time.ParseDuration(timeout)
+			if err != nil || d <= 0 {
+				return http.StatusBadRequest, fmt.Errorf("loadshedder: invalid timeout %q", timeout)
+			}
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, d)
+			defer cancel()
+		}
+		if err := l.Drain(ctx); err != nil {
+			return http.StatusGatewayTimeout, fmt.Errorf("loadshedder: still draining: %w", err)
+		}
+
+	default:
+		return http.StatusBadRequest, fmt.Errorf("loadshedder: unknown admin operation %q", op)
+	}
+	return 0, nil
+}
@@ -0,0 +1,150 @@
+package loadshedder
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAdminHandler(t *testing.T) {
+	ls := New(Config{Name: "api", Limit: 2, WaitingLimit: 2})
+	handler := NewAdminHandler(ls, AdminConfig{Authorize: BearerAuth("secret")})
+
+	do := func(method, target string) (AdminResponse, int) {
+		req := httptest.NewRequest(method, target, http.NoBody)
+		req.Header.Set("Authorization", "Bearer secret")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		var resp AdminResponse
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp, rec.Code
+	}
+
+	_, token := ls.Acquire(context.Background())
+
+	resp, code := do(http.MethodGet, "/admin")
+	if code != http.StatusOK || resp.Name != "api" || resp.Running != 1 || resp.Limit != 2 || resp.WaitingLimit != 2 {
+		t.Errorf("unexpected response %d: %+v", code, resp)
+	}
+	if resp.Counters.Accepted != 1 || resp.Checkpoint == "" {
+		t.Errorf("expected the counters and a checkpoint, got %+v", resp)
+	}
+
+	if resp, code = do(http.MethodPost, "/admin?op=limit&value=5"); code != http.StatusOK || resp.ConfiguredLimit != 5 {
+		t.Errorf("expected the limit changed, got %d: %+v", code, resp)
+	}
+	if resp, code = do(http.MethodPost, "/admin?op=waiting_limit&value=0"); code != http.StatusOK || resp.WaitingLimit != 0 {
+		t.Errorf("expected the waiting limit changed, got %d: %+v", code, resp)
+	}
+
+	if resp, code = do(http.MethodPost, "/admin?op=pause"); code != http.StatusOK || !resp.Paused {
+		t.Errorf("expected paused, got %d: %+v", code, resp)
+	}
+	if _, rejected := ls.Acquire(context.Background()); rejected.Accepted() {
+		t.Error("expected rejection while paused")
+	}
+	if resp, code = do(http.MethodPost, "/admin?op=resume"); code != http.StatusOK || resp.Paused {
+		t.Errorf("expected resumed, got %d: %+v", code, resp)
+	}
+
+	// Drain times out while a request is running
+	if _, code = do(http.MethodPost, "/admin?op=drain&timeout=10ms"); code != http.StatusGatewayTimeout {
+		t.Errorf("expected 504 for an incomplete drain, got %d", code)
+	}
+	if resp, _ = do(http.MethodGet, "/admin"); !resp.Draining {
+		t.Errorf("expected the loadshedder to keep draining, got %+v", resp)
+	}
+	ls.Release(token)
+	if resp, code = do(http.MethodPost, "/admin?op=drain&timeout=1s"); code != http.StatusOK || !resp.Draining || resp.Running != 0 {
+		t.Errorf("expected drained, got %d: %+v", code, resp)
+	}
+}
+
+func TestAdminHandler_Errors(t *testing.T) {
+	ls := New(Config{Limit: 2})
+	handler := NewAdminHandler(ls, AdminConfig{Authorize: BearerAuth("secret")})
+
+	tests := []struct {
+		name          string
+		method        string
+		target        string
+		authorization string
+		code          int
+	}{
+		{"no credentials", http.MethodPost, "/?op=pause", "", http.StatusUnauthorized},
+		{"wrong token", http.MethodPost, "/?op=pause", "Bearer wrong", http.StatusUnauthorized},
+		{"unknown op", http.MethodPost, "/?op=explode", "Bearer secret", http.StatusBadRequest},
+		{"invalid limit", http.MethodPost, "/?op=limit&value=0", "Bearer secret", http.StatusBadRequest},
+		{"invalid timeout", http.MethodPost, "/?op=drain&timeout=soon", "Bearer secret", http.StatusBadRequest},
+		{"invalid checkpoint", http.MethodPost, "/?op=pause&since=bad", "Bearer secret", http.StatusBadRequest},
+		{"method", http.MethodDelete, "/", "Bearer secret", http.StatusMethodNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, http.NoBody)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.code {
+				t.Errorf("expected %d, got %d: %s", tt.code, rec.Code, rec.Body)
+			}
+		})
+	}
+
+	if ls.Paused() || ls.Draining() || ls.Config().Limit != 2 {
+		t.Error("expected no change from the failed requests")
+	}
+}
+
+func TestAdminHandler_ReadOnly(t *testing.T) {
+	ls := New(Config{Limit: 2})
+	handler := NewAdminHandler(ls, AdminConfig{})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/?op=pause", http.NoBody))
+	if rec.Code != http.StatusForbidden || ls.Paused() {
+		t.Errorf("expected 403 without Authorize, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected the state served without Authorize, got %d", rec.Code)
+	}
+}
+
+func TestBearerAuth(t *testing.T) {
+	authorize := BearerAuth("secret")
+
+	for header, expected := range map[string]bool{
+		"Bearer secret":  true,
+		"Bearer secret2": false,
+		"bearer secret":  false,
+		"secret":         false,
+		"":               false,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/", http.NoBody)
+		req.Header.Set("Authorization", header)
+		if authorize(req) != expected {
+			t.Errorf("expected %v for %q", expected, header)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for an empty token")
+		}
+	}()
+	BearerAuth("")
+}
@@ -0,0 +1,17 @@
+package loadshedder
+
+import "sync/atomic"
+
+// cacheLineSize is a conservative cache line size, large enough for the
+// common 64 and 128 byte lines.
+const cacheLineSize = 128
+
+// paddedInt64 is an atomic.Int64 alone on its cache line.
+// The counters written on every Acquire/Release are padded so that they don't
+// invalidate each other, nor the read-mostly fields Stats loads next to them:
+// scraping Stats at a high rate then never contends with the hot path.
+type paddedInt64 struct {
+	_ [cacheLineSize - 8]byte
+	atomic.Int64
+	_ [cacheLineSize - 8]byte
+}
@@ -0,0 +1,123 @@
+// Command benchreport reads the output of the comparison benchmarks on stdin
+// and prints a markdown table of ns/op per implementation and contention
+// level, with the overhead of each implementation relative to the first one
+// (the channel baseline):
+//
+//	go test -run=^$ -bench=. -count=5 | go run ./cmd/benchreport
+//
+// Repeated runs (-count) are averaged.
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// benchLine matches a result line, capturing the benchmark name without its
+// GOMAXPROCS suffix and the ns/op value.
+var benchLine = regexp.MustCompile(`^(Benchmark\S+?)(?:-\d+)?\s+\d+\s+([\d.]+) ns/op`)
+
+type sample struct {
+	total float64
+	count int
+}
+
+func (s sample) mean() float64 {
+	return s.total / float64(s.count)
+}
+
+// report holds the samples by case (the benchmark name without its last
+// element) and implementation (the last element), in order of appearance.
+type report struct {
+	cases   []string
+	impls   []string
+	samples map[string]map[string]*sample
+}
+
+func parse(r io.Reader) (*report, error) {
+	rep := &report{samples: map[string]map[string]*sample{}}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		m := benchLine.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		i := strings.LastIndexByte(m[1], '/')
+		if i < 0 {
+			continue
+		}
+		name, impl := m[1][:i], m[1][i+1:]
+		nsPerOp, err := strconv.ParseFloat(m[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ns/op in %q: %w", scanner.Text(), err)
+		}
+
+		byImpl, ok := rep.samples[name]
+		if !ok {
+			byImpl = map[string]*sample{}
+			rep.samples[name] = byImpl
+			rep.cases = append(rep.cases, name)
+		}
+		s, ok := byImpl[impl]
+		if !ok {
+			s = &sample{}
+			byImpl[impl] = s
+			if !slices.Contains(rep.impls, impl) {
+				rep.impls = append(rep.impls, impl)
+			}
+		}
+		s.total += nsPerOp
+		s.count++
+	}
+	return rep, scanner.Err()
+}
+
+func (rep *report) write(w io.Writer) {
+	fmt.Fprintf(w, "| case |")
+	for _, impl := range rep.impls {
+		fmt.Fprintf(w, " %s |", impl)
+	}
+	fmt.Fprintf(w, "\n|---|")
+	for range rep.impls {
+		fmt.Fprintf(w, "---:|")
+	}
+	fmt.Fprintln(w)
+
+	baseline := rep.impls[0]
+	for _, name := range rep.cases {
+		fmt.Fprintf(w, "| %s |", strings.TrimPrefix(name, "Benchmark"))
+		base, hasBase := rep.samples[name][baseline]
+		for _, impl := range rep.impls {
+			s, ok := rep.samples[name][impl]
+			switch {
+			case !ok:
+				fmt.Fprintf(w, " - |")
+			case impl == baseline || !hasBase:
+				fmt.Fprintf(w, " %.1f ns |", s.mean())
+			default:
+				fmt.Fprintf(w, " %.1f ns (%.2fx) |", s.mean(), s.mean()/base.mean())
+			}
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func main() {
+	rep, err := parse(os.Stdin)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "benchreport:", err)
+		os.Exit(1)
+	}
+	if len(rep.cases) == 0 {
+		fmt.Fprintln(os.Stderr, "benchreport: no benchmark results on stdin")
+		os.Exit(1)
+	}
+	rep.write(os.Stdout)
+}
@@ -0,0 +1,112 @@
+// Package benchmarks compares the loadshedder hot path with naive
+// concurrency limiters, see README.md.
+package benchmarks
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/pior/loadshedder"
+	"golang.org/x/sync/semaphore"
+)
+
+// limiter is the acquire/release cycle measured for each implementation.
+// acquire blocks until a slot is free, so every implementation does the
+// same work: the loadshedder is configured to wait rather than reject.
+type limiter interface {
+	acquire(ctx context.Context) (release func())
+}
+
+type chanLimiter chan struct{}
+
+func (c chanLimiter) acquire(ctx context.Context) func() {
+	select {
+	case c <- struct{}{}:
+		return func() { <-c }
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+type weightedLimiter struct {
+	sem *semaphore.Weighted
+}
+
+func (w weightedLimiter) acquire(ctx context.Context) func() {
+	if w.sem.Acquire(ctx, 1) != nil {
+		return nil
+	}
+	return func() { w.sem.Release(1) }
+}
+
+type loadshedderLimiter struct {
+	ls *loadshedder.Loadshedder
+}
+
+func (l loadshedderLimiter) acquire(ctx context.Context) func() {
+	_, token := l.ls.Acquire(ctx)
+	if !token.Accepted() {
+		return nil
+	}
+	return func() { l.ls.Release(token) }
+}
+
+// implementations are the limiters compared, in report order. The first one
+// is the baseline of the overhead ratios computed by cmd/benchreport.
+var implementations = []struct {
+	name string
+	new  func(limit int) limiter
+}{
+	{"chan", func(limit int) limiter { return make(chanLimiter, limit) }},
+	{"semaphore", func(limit int) limiter { return weightedLimiter{semaphore.NewWeighted(int64(limit))} }},
+	{"loadshedder", func(limit int) limiter {
+		// A waiting queue as large as the number of goroutines: nothing is rejected
+		return loadshedderLimiter{loadshedder.New(loadshedder.Config{Limit: int64(limit), WaitingLimit: 1 << 20})}
+	}},
+}
+
+// BenchmarkAcquireRelease measures an acquire/release cycle across contention
+// levels: the number of goroutines (parallelism times GOMAXPROCS) relative to
+// the limit. With a large limit, it measures the uncontended hot path; with a
+// small one, the waiting queue.
+func BenchmarkAcquireRelease(b *testing.B) {
+	for _, limit := range []int{4, 64, 4096} {
+		for _, parallelism := range []int{1, 8} {
+			for _, impl := range implementations {
+				name := fmt.Sprintf("limit=%d/parallelism=%d/%s", limit, parallelism, impl.name)
+				b.Run(name, func(b *testing.B) {
+					benchmarkLimiter(b, impl.new(limit), parallelism)
+				})
+			}
+		}
+	}
+}
+
+func benchmarkLimiter(b *testing.B, l limiter, parallelism int) {
+	ctx := context.Background()
+
+	b.ReportAllocs()
+	b.SetParallelism(parallelism)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			release := l.acquire(ctx)
+			if release == nil {
+				b.Error("unexpected rejection")
+				return
+			}
+			release()
+		}
+	})
+}
+
+// BenchmarkCounterOnly measures the loadshedder without a waiting queue, which
+// rejects at the limit instead of waiting: its limit is never reached here.
+func BenchmarkCounterOnly(b *testing.B) {
+	ls := loadshedder.New(loadshedder.Config{Limit: 1 << 20})
+	for _, parallelism := range []int{1, 8} {
+		b.Run(fmt.Sprintf("parallelism=%d/loadshedder", parallelism), func(b *testing.B) {
+			benchmarkLimiter(b, loadshedderLimiter{ls}, parallelism)
+		})
+	}
+}
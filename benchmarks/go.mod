@@ -0,0 +1,10 @@
+module github.com/pior/loadshedder/benchmarks
+
+go 1.24.0
+
+require (
+	github.com/pior/loadshedder v0.1.0
+	golang.org/x/sync v0.10.0
+)
+
+replace github.com/pior/loadshedder => ../
@@ -0,0 +1,186 @@
+package loadshedder
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// BulkOp is an admin operation applied to every loadshedder of a Registry,
+// see Registry.Apply.
+type BulkOp string
+
+const (
+	// BulkPause pauses every loadshedder, see Loadshedder.Pause.
+	BulkPause BulkOp = "pause"
+
+	// BulkResume resumes every loadshedder, see Loadshedder.Resume.
+	BulkResume BulkOp = "resume"
+
+	// BulkScaleLimits multiplies the configured limit of every loadshedder
+	// (the target of a ramp in progress) by BulkAction.Factor, rounded and at
+	// least 1. The limits change like with SetLimit, ramping over
+	// Config.LimitRamp.
+	BulkScaleLimits BulkOp = "scale"
+
+	// BulkShadow enables the shadow mode of every loadshedder, see Loadshedder.SetShadow.
+	BulkShadow BulkOp = "shadow"
+
+	// BulkUnshadow disables the shadow mode of every loadshedder.
+	BulkUnshadow BulkOp = "unshadow"
+)
+
+// BulkAction describes an operation applied by Registry.Apply.
+type BulkAction struct {
+	Op BulkOp
+
+	// Factor is the limit multiplier of BulkScaleLimits, must be positive.
+	Factor float64
+
+	// DryRun reports the changes without applying them.
+	DryRun bool
+}
+
+// BulkChange is the change of one loadshedder by a bulk operation.
+// Previous and Next are bools for pause and shadow, int64 limits for scale.
+type BulkChange struct {
+	Name     string `json:"name"`
+	Previous any    `json:"previous"`
+	Next     any    `json:"next"`
+}
+
+// BulkResult reports the changes of a bulk operation. Loadshedders already in
+// the requested state are not listed.
+type BulkResult struct {
+	Op      BulkOp       `json:"op"`
+	DryRun  bool         `json:"dry_run"`
+	Changes []BulkChange `json:"changes"`
+}
+
+// Apply applies the action to every registered loadshedder, so incident
+// response takes one operation rather than one per pool. The operation is
+// atomic: it is validated before any change, and applied while holding the
+// limit locks of all the loadshedders, so limit changes, donations and other
+// bulk operations never observe it half applied. Loadshedders cannot be
+// registered meanwhile.
+// With DryRun, the changes are reported but not applied.
+func (r *Registry) Apply(action BulkAction) (BulkResult, error) {
+	change, err := action.changeFunc()
+	if err != nil {
+		return BulkResult{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Locked in name order, like transferCapacity, to avoid deadlocks
+	all := r.sorted()
+	for _, ls := range all {
+		ls.limitMu.Lock()
+	}
+	defer func() {
+		for _, ls := range all {
+			ls.limitMu.Unlock()
+		}
+	}()
+
+	result := BulkResult{Op: action.Op, DryRun: action.DryRun, Changes: []BulkChange{}}
+	for _, ls := range all {
+		previous, next, apply := change(ls)
+		if previous == next {
+			continue
+		}
+		result.Changes = append(result.Changes, BulkChange{Name: ls.name, Previous: previous, Next: next})
+		if !action.DryRun {
+			apply()
+		}
+	}
+	return result, nil
+}
+
+// changeFunc validates the action and returns the function computing its
+// change on a loadshedder, whose limitMu is held.
+func (a BulkAction) changeFunc() (func(*Loadshedder) (previous, next any, apply func()), error) {
+	switch a.Op {
+	case BulkPause, BulkResume:
+		paused := a.Op == BulkPause
+		return func(ls *Loadshedder) (any, any, func()) {
+			return ls.Paused(), paused, func() { ls.updateSettings(func(s *settings) { s.paused = paused }) }
+		}, nil
+
+	case BulkShadow, BulkUnshadow:
+		shadow := a.Op == BulkShadow
+		return func(ls *Loadshedder) (any, any, func()) {
+			return ls.Shadow(), shadow, func() { ls.updateSettings(func(s *settings) { s.shadow = shadow }) }
+		}, nil
+
+	case BulkScaleLimits:
+		if a.Factor <= 0 || math.IsInf(a.Factor, 0) || math.IsNaN(a.Factor) {
+			return nil, fmt.Errorf("loadshedder: scale factor must be positive, got %v", a.Factor)
+		}
+		return func(ls *Loadshedder) (any, any, func()) {
+			previous := ls.limit.Load()
+			if r := ls.settings.Load().ramp; r != nil {
+				previous = r.to
+			}
+			next := max(1, int64(math.Round(float64(previous)*a.Factor)))
+			return previous, next, func() { ls.rampLimit(next, ls.config.LimitRamp) }
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("loadshedder: unknown bulk operation %q", a.Op)
+	}
+}
+
+// NewBulkHandler serves Registry.Apply over HTTP, for incident response
+// tooling: POST ?op=<op>[&factor=<factor>][&dry_run=true] responds with the
+// BulkResult as JSON. Ops are pause, resume, scale, shadow and unshadow.
+// Requests must pass cfg.Authorize, like the POSTs of NewAdminHandler:
+// without it, the handler refuses every request.
+func NewBulkHandler(registry *Registry, cfg AdminConfig) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if cfg.Authorize == nil {
+			http.Error(w, "loadshedder: bulk actions are disabled", http.StatusForbidden)
+			return
+		}
+		if !cfg.Authorize(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		query := r.URL.Query()
+		action := BulkAction{Op: BulkOp(query.Get("op"))}
+
+		var err error
+		if factor := query.Get("factor"); factor != "" {
+			if action.Factor, err = strconv.ParseFloat(factor, 64); err != nil {
+				http.Error(w, fmt.Sprintf("loadshedder: invalid factor %q", factor), http.StatusBadRequest)
+				return
+			}
+		}
+		if dryRun := query.Get("dry_run"); dryRun != "" {
+			if action.DryRun, err = strconv.ParseBool(dryRun); err != nil {
+				http.Error(w, fmt.Sprintf("loadshedder: invalid dry_run %q", dryRun), http.StatusBadRequest)
+				return
+			}
+		}
+
+		result, err := registry.Apply(action)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		_ = json.NewEncoder(w).Encode(result)
+	})
+}
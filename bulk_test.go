@@ -0,0 +1,155 @@
+package loadshedder
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newBulkRegistry(t *testing.T) (*Registry, *Loadshedder, *Loadshedder) {
+	t.Helper()
+	registry := NewRegistry()
+	api := New(Config{Name: "api", Limit: 10})
+	batch := New(Config{Name: "batch", Limit: 3})
+	for _, ls := range []*Loadshedder{api, batch} {
+		if err := registry.Register(ls); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	return registry, api, batch
+}
+
+func TestRegistry_Apply(t *testing.T) {
+	registry, api, batch := newBulkRegistry(t)
+
+	result, err := registry.Apply(BulkAction{Op: BulkScaleLimits, Factor: 0.5, DryRun: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := []BulkChange{{"api", int64(10), int64(5)}, {"batch", int64(3), int64(2)}}
+	if !result.DryRun || len(result.Changes) != 2 || result.Changes[0] != expected[0] || result.Changes[1] != expected[1] {
+		t.Errorf("expected %v, got %+v", expected, result)
+	}
+	if api.Stats().Limit != 10 || batch.Stats().Limit != 3 {
+		t.Error("expected a dry run not to change the limits")
+	}
+
+	if _, err := registry.Apply(BulkAction{Op: BulkScaleLimits, Factor: 0.1}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if api.Stats().Limit != 1 || batch.Stats().Limit != 1 {
+		t.Errorf("expected the limits scaled down to 1, got %d and %d", api.Stats().Limit, batch.Stats().Limit)
+	}
+
+	batch.Pause()
+	result, _ = registry.Apply(BulkAction{Op: BulkPause})
+	if len(result.Changes) != 1 || result.Changes[0].Name != "api" || !api.Paused() {
+		t.Errorf("expected only api to change, got %+v", result)
+	}
+
+	if _, err := registry.Apply(BulkAction{Op: BulkShadow}); err != nil || !api.Shadow() || !batch.Shadow() {
+		t.Errorf("expected the shadow mode enabled everywhere, got %v", err)
+	}
+}
+
+func TestRegistry_Apply_Invalid(t *testing.T) {
+	registry, api, _ := newBulkRegistry(t)
+
+	for _, action := range []BulkAction{{Op: "explode"}, {Op: BulkScaleLimits}, {Op: BulkScaleLimits, Factor: -1}} {
+		if _, err := registry.Apply(action); err == nil {
+			t.Errorf("expected an error for %+v", action)
+		}
+	}
+	if api.Stats().Limit != 10 {
+		t.Error("expected invalid actions not to change anything")
+	}
+}
+
+func TestBulkHandler(t *testing.T) {
+	registry, api, batch := newBulkRegistry(t)
+	handler := NewBulkHandler(registry, AdminConfig{Authorize: BearerAuth("secret")})
+	post := func(target string) *http.Request {
+		r := httptest.NewRequest(http.MethodPost, target, http.NoBody)
+		r.Header.Set("Authorization", "Bearer secret")
+		return r
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, post("/?op=pause&dry_run=true"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	var result BulkResult
+	if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Op != BulkPause || !result.DryRun || len(result.Changes) != 2 || result.Changes[0].Next != true {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if api.Paused() || batch.Paused() {
+		t.Error("expected a dry run not to pause")
+	}
+
+	for _, tt := range []struct {
+		request *http.Request
+		code    int
+	}{
+		{httptest.NewRequest(http.MethodGet, "/?op=pause", http.NoBody), http.StatusMethodNotAllowed},
+		{httptest.NewRequest(http.MethodPost, "/?op=pause", http.NoBody), http.StatusUnauthorized},
+		{post("/?op=scale&factor=abc"), http.StatusBadRequest},
+		{post("/?op=scale&factor=0"), http.StatusBadRequest},
+		{post("/?op=scale&factor=2"), http.StatusOK},
+	} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, tt.request)
+		if rec.Code != tt.code {
+			t.Errorf("%s %s: expected %d, got %d", tt.request.Method, tt.request.URL, tt.code, rec.Code)
+		}
+	}
+	if api.Stats().Limit != 20 {
+		t.Errorf("expected the limit doubled, got %d", api.Stats().Limit)
+	}
+}
+
+func TestBulkHandler_Disabled(t *testing.T) {
+	registry, api, _ := newBulkRegistry(t)
+	handler := NewBulkHandler(registry, AdminConfig{})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/?op=pause", http.NoBody))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 without Authorize, got %d", rec.Code)
+	}
+	if api.Paused() {
+		t.Error("expected a refused request not to pause")
+	}
+}
+
+func TestRegistry_Apply_ScaleRamps(t *testing.T) {
+	registry := NewRegistry()
+	ls := New(Config{Name: "api", Limit: 100, LimitRamp: time.Hour})
+	if err := registry.Register(ls); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Scales the target of the ramp in progress, and ramps to the new one
+	ls.SetLimit(40)
+	result, err := registry.Apply(BulkAction{Op: BulkScaleLimits, Factor: 0.5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Changes) != 1 || result.Changes[0].Previous != int64(40) || result.Changes[0].Next != int64(20) {
+		t.Errorf("expected the ramp target scaled from 40 to 20, got %+v", result.Changes)
+	}
+
+	stats := ls.Stats()
+	if stats.RampTarget != 20 {
+		t.Errorf("expected a ramp to 20, got %d", stats.RampTarget)
+	}
+	if stats.Limit != 100 {
+		t.Errorf("expected the limit to ramp rather than step, got %d", stats.Limit)
+	}
+}
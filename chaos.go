@@ -0,0 +1,178 @@
+package loadshedder
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+const defaultChaosFlapFactor = 0.5
+
+// ChaosConfig configures EnableChaos.
+type ChaosConfig struct {
+	// Seed seeds the random decisions: a run with the same seed and the same
+	// sequence of acquisitions makes the same decisions.
+	// Optional.
+	Seed uint64
+
+	// RejectRate is the fraction of the acquisitions rejected regardless of
+	// the capacity, in [0, 1].
+	// Optional.
+	RejectRate float64
+
+	// LatencyRate is the fraction of the acquisitions delayed before taking
+	// a slot, in [0, 1]. The delay counts as queue wait: Stats.WaitTime
+	// includes it, and the request context can give up on it.
+	// Optional, requires MaxLatency.
+	LatencyRate float64
+
+	// MaxLatency bounds the injected delay, drawn uniformly in (0, MaxLatency].
+	// Optional.
+	MaxLatency time.Duration
+
+	// FlapInterval is the interval at which the limit may flap: at each
+	// interval, the effective limit is reduced to FlapFactor of its value
+	// with a probability of one half, and restored otherwise.
+	// Optional, default to 0 (no flaps).
+	FlapInterval time.Duration
+
+	// FlapFactor is the fraction of the effective limit enforced during a flap.
+	// Optional, default to 0.5.
+	FlapFactor float64
+}
+
+// EnableChaos makes the loadshedder misbehave on purpose, for integration
+// tests: spurious rejections, latency injected in the queue, and limit
+// flaps. Downstream services can then verify their retry and backoff
+// behavior against a misbehaving loadshedder. Not for production.
+// Enabling chaos again replaces the configuration, ending its flaps.
+// Call the returned function to restore the normal behavior.
+func (l *Loadshedder) EnableChaos(cfg ChaosConfig) (stop func()) {
+	if cfg.RejectRate < 0 || cfg.RejectRate > 1 || cfg.LatencyRate < 0 || cfg.LatencyRate > 1 {
+		panic("loadshedder: ChaosConfig.RejectRate and ChaosConfig.LatencyRate must be in [0, 1]")
+	}
+	if cfg.LatencyRate > 0 && cfg.MaxLatency <= 0 {
+		panic("loadshedder: ChaosConfig.MaxLatency must be positive with ChaosConfig.LatencyRate")
+	}
+	if cfg.FlapFactor == 0 {
+		cfg.FlapFactor = defaultChaosFlapFactor
+	}
+	if cfg.FlapFactor < 0 || cfg.FlapFactor > 1 {
+		panic("loadshedder: ChaosConfig.FlapFactor must be in (0, 1]")
+	}
+
+	c := &chaosController{cfg: cfg, rng: rand.New(rand.NewPCG(cfg.Seed, cfg.Seed)), done: make(chan struct{})}
+	l.limitMu.Lock()
+	previous := l.settings.Load().chaos
+	l.updateSettings(func(s *settings) { s.chaos = c })
+	// A flap of the replaced configuration ends with it
+	l.chaosFactor = 0
+	l.updateEffectiveLimit()
+	l.limitMu.Unlock()
+
+	if previous != nil {
+		previous.stop()
+	}
+
+	var wg sync.WaitGroup
+	if cfg.FlapInterval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ticker := time.NewTicker(cfg.FlapInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-c.done:
+					return
+				case <-ticker.C:
+					factor := 0.0
+					if c.flap() {
+						factor = cfg.FlapFactor
+					}
+					l.setChaosFactor(c, factor)
+				}
+			}
+		}()
+	}
+
+	return func() {
+		c.stop()
+		wg.Wait()
+
+		l.limitMu.Lock()
+		defer l.limitMu.Unlock()
+
+		if l.settings.Load().chaos == c {
+			l.updateSettings(func(s *settings) { s.chaos = nil })
+			l.chaosFactor = 0
+			l.updateEffectiveLimit()
+		}
+	}
+}
+
+// setChaosFactor applies a limit flap of the controller, or ends it with 0.
+// Ignored once the controller is replaced or stopped.
+func (l *Loadshedder) setChaosFactor(c *chaosController, factor float64) {
+	l.limitMu.Lock()
+	defer l.limitMu.Unlock()
+
+	if l.settings.Load().chaos != c {
+		return
+	}
+	l.chaosFactor = factor
+	l.updateEffectiveLimit()
+}
+
+// chaosController draws the chaos decisions.
+type chaosController struct {
+	cfg ChaosConfig
+
+	done     chan struct{} // closed when stopped or replaced, ending the flaps
+	stopOnce sync.Once
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// stop ends the flaps of the controller.
+func (c *chaosController) stop() {
+	c.stopOnce.Do(func() { close(c.done) })
+}
+
+// decide returns whether to reject an acquisition, and the delay to inject.
+// The same number of values is drawn for each acquisition, so that the
+// decisions only depend on the seed and the sequence of acquisitions.
+func (c *chaosController) decide() (reject bool, delay time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	reject = c.rng.Float64() < c.cfg.RejectRate
+	delayed := c.rng.Float64() < c.cfg.LatencyRate
+	latency := c.rng.Float64()
+	if delayed && !reject {
+		delay = max(1, time.Duration(latency*float64(c.cfg.MaxLatency)))
+	}
+	return reject, delay
+}
+
+// flap returns whether the limit flaps for the next interval.
+func (c *chaosController) flap() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.rng.Float64() < 0.5
+}
+
+// sleepContext waits for d, returning early when ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
@@ -0,0 +1,134 @@
+package loadshedder
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLoadshedder_ChaosRejections(t *testing.T) {
+	ls := New(Config{Limit: 100})
+	stop := ls.EnableChaos(ChaosConfig{Seed: 42, RejectRate: 0.5})
+
+	decisions := func() []bool {
+		var accepted []bool
+		for range 100 {
+			_, token := ls.Acquire(context.Background())
+			accepted = append(accepted, token.Accepted())
+			ls.Release(token)
+		}
+		return accepted
+	}
+
+	first := decisions()
+	rejected := 0
+	for _, accepted := range first {
+		if !accepted {
+			rejected++
+		}
+	}
+	if rejected < 30 || rejected > 70 {
+		t.Errorf("expected about half of the acquisitions rejected, got %d", rejected)
+	}
+
+	// The same seed makes the same decisions
+	stop()
+	stop = ls.EnableChaos(ChaosConfig{Seed: 42, RejectRate: 0.5})
+	defer stop()
+	second := decisions()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("expected the same decisions with the same seed, differ at %d", i)
+		}
+	}
+}
+
+func TestLoadshedder_ChaosStop(t *testing.T) {
+	ls := New(Config{Limit: 1})
+	stop := ls.EnableChaos(ChaosConfig{RejectRate: 1})
+
+	if _, token := ls.Acquire(context.Background()); token.Accepted() {
+		t.Fatal("expected a spurious rejection")
+	}
+
+	stop()
+	_, token := ls.Acquire(context.Background())
+	defer ls.Release(token)
+	if !token.Accepted() {
+		t.Error("expected the normal behavior after stop")
+	}
+}
+
+func TestLoadshedder_ChaosLatency(t *testing.T) {
+	ls := New(Config{Limit: 1, WaitingLimit: 1})
+	defer ls.EnableChaos(ChaosConfig{LatencyRate: 1, MaxLatency: 20 * time.Millisecond})()
+
+	stats, token := ls.Acquire(context.Background())
+	ls.Release(token)
+	if !token.Accepted() || !token.Queued() || stats.WaitTime <= 0 || stats.WaitTime > time.Second {
+		t.Errorf("expected an acquisition delayed in the queue, got %+v", stats)
+	}
+
+	// The context can give up on the delay
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, token := ls.Acquire(ctx); token.Accepted() {
+		t.Error("expected the cancelled acquisition rejected")
+	}
+}
+
+func TestLoadshedder_ChaosFlaps(t *testing.T) {
+	ls := New(Config{Limit: 10})
+	stop := ls.EnableChaos(ChaosConfig{FlapInterval: time.Millisecond, FlapFactor: 0.3})
+
+	waitForStats(t, ls, func(s Stats) bool { return s.EffectiveLimit == 3 })
+	waitForStats(t, ls, func(s Stats) bool { return s.EffectiveLimit == 10 })
+
+	stop()
+	if limit := ls.Stats().EffectiveLimit; limit != 10 {
+		t.Errorf("expected the limit restored after stop, got %d", limit)
+	}
+}
+
+func TestLoadshedder_ChaosFlapsReplaced(t *testing.T) {
+	ls := New(Config{Limit: 10})
+	stopFlaps := ls.EnableChaos(ChaosConfig{FlapInterval: time.Millisecond, FlapFactor: 0.3})
+	defer stopFlaps()
+
+	waitForStats(t, ls, func(s Stats) bool { return s.EffectiveLimit == 3 })
+
+	// The replacement ends the flap, and the replaced controller stops flapping
+	stop := ls.EnableChaos(ChaosConfig{})
+	if limit := ls.Stats().EffectiveLimit; limit != 10 {
+		t.Errorf("expected the flap to end on replacement, got %d", limit)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if limit := ls.Stats().EffectiveLimit; limit != 10 {
+		t.Errorf("expected the replaced controller not to flap, got %d", limit)
+	}
+
+	stop()
+	if limit := ls.Stats().EffectiveLimit; limit != 10 {
+		t.Errorf("expected the limit restored after stop, got %d", limit)
+	}
+}
+
+func TestLoadshedder_ChaosValidation(t *testing.T) {
+	ls := New(Config{Limit: 1})
+
+	for name, cfg := range map[string]ChaosConfig{
+		"reject rate":  {RejectRate: 2},
+		"latency rate": {LatencyRate: -1},
+		"max latency":  {LatencyRate: 0.5},
+		"flap factor":  {FlapFactor: 1.5},
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected a panic")
+				}
+			}()
+			ls.EnableChaos(cfg)
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package loadshedder
+
+import (
+	"errors"
+	"time"
+)
+
+const defaultCoDelInterval = 100 * time.Millisecond
+
+// errDropped is returned by slots.acquire for a waiter dropped from the queue,
+// by CoDel or by lowering the waiting limit.
+var errDropped = errors.New("loadshedder: dropped from the waiting queue")
+
+// CoDelDrops returns the number of waiting requests rejected by CoDel, see
+// Config.CoDelTarget. They are also counted as rejected.
+func (l *Loadshedder) CoDelDrops() int64 {
+	return l.slots.dropped.Load()
+}
+
+// codel implements controlled delay (CoDel) on the waiting queue, see
+// Config.CoDelTarget. Each waiter leaving the queue reports its queueing
+// delay: once the delay stayed above the target for a whole interval, the
+// queue is standing rather than absorbing a burst, and the waiters leaving it
+// with a delay above the target are dropped, until one leaves under the
+// target. Its state is guarded by slots.mu.
+type codel struct {
+	target   time.Duration
+	interval time.Duration
+
+	firstAbove time.Time // end of the interval the delay must stay above the target, zero if under
+	dropping   bool
+}
+
+// drop records the queueing delay of a waiter leaving the queue and returns
+// true if the waiter must be dropped.
+func (c *codel) drop(now time.Time, delay time.Duration) bool {
+	if delay < c.target {
+		c.firstAbove = time.Time{}
+		c.dropping = false
+		return false
+	}
+
+	if c.dropping {
+		return true
+	}
+	if c.firstAbove.IsZero() {
+		c.firstAbove = now.Add(c.interval)
+		return false
+	}
+	if now.Before(c.firstAbove) {
+		return false
+	}
+
+	c.dropping = true
+	return true
+}
@@ -0,0 +1,78 @@
+package loadshedder
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCoDel_Drop(t *testing.T) {
+	c := &codel{target: 5 * time.Millisecond, interval: 100 * time.Millisecond}
+	now := time.Now()
+
+	steps := []struct {
+		elapsed time.Duration
+		delay   time.Duration
+		drop    bool
+	}{
+		{0, time.Millisecond, false},                           // under the target
+		{10 * time.Millisecond, 20 * time.Millisecond, false},  // above: the interval starts
+		{50 * time.Millisecond, 20 * time.Millisecond, false},  // still within the interval
+		{120 * time.Millisecond, 20 * time.Millisecond, true},  // above for the whole interval
+		{121 * time.Millisecond, 6 * time.Millisecond, true},   // dropping while above the target
+		{122 * time.Millisecond, 2 * time.Millisecond, false},  // under: stop dropping
+		{123 * time.Millisecond, 20 * time.Millisecond, false}, // a new interval starts
+	}
+
+	for i, step := range steps {
+		if drop := c.drop(now.Add(step.elapsed), step.delay); drop != step.drop {
+			t.Errorf("step %d: expected drop=%v, got %v", i, step.drop, drop)
+		}
+	}
+}
+
+func TestLoadshedder_CoDel(t *testing.T) {
+	ctx := context.Background()
+	ls := New(Config{Limit: 1, WaitingLimit: 10, CoDelTarget: 5 * time.Millisecond, CoDelInterval: 20 * time.Millisecond})
+
+	_, blocker := ls.Acquire(ctx)
+
+	tokens := make(chan *Token, 3)
+	for i := range 3 {
+		go func() {
+			_, token := ls.Acquire(ctx)
+			tokens <- token
+		}()
+		waitFor(t, func() bool { return ls.Stats().Waiting == int64(i+1) })
+	}
+
+	// The first waiter is admitted late: the delay is above the target
+	time.Sleep(30 * time.Millisecond)
+	ls.Release(blocker)
+	first := <-tokens
+	if !first.Accepted() {
+		t.Fatal("expected the first waiter to be admitted")
+	}
+
+	// Still above the target after the interval: the standing queue is dropped
+	time.Sleep(30 * time.Millisecond)
+	ls.Release(first)
+	for range 2 {
+		if token := <-tokens; token.Accepted() || token.WaitTime() == 0 || token.RejectReason() != RejectDropped {
+			t.Error("expected the stale waiters to be dropped after waiting")
+		}
+	}
+
+	if drops := ls.CoDelDrops(); drops != 2 {
+		t.Errorf("expected 2 drops, got %d", drops)
+	}
+	if stats := ls.Stats(); stats.Running != 0 || stats.Waiting != 0 {
+		t.Errorf("expected an empty loadshedder, got %+v", stats)
+	}
+
+	_, token := ls.Acquire(ctx)
+	if !token.Accepted() {
+		t.Error("expected new requests to be admitted once the queue is gone")
+	}
+	ls.Release(token)
+}
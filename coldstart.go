@@ -0,0 +1,126 @@
+package loadshedder
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultColdStartTolerance   = 2
+	defaultColdStartLimitFactor = 0.5
+	defaultColdStartMaxDuration = 5 * time.Minute
+
+	// coldStartCheckInterval is the period between checks of the average
+	// duration, on the Acquire path.
+	coldStartCheckInterval = 100 * time.Millisecond
+
+	// coldStartMinSamples is the number of completed requests before the
+	// average duration is trusted to end the cold start.
+	coldStartMinSamples = 10
+)
+
+// ColdStartConfig configures the cold start detection, see Config.ColdStart.
+// After a deploy, request durations are inflated while caches and connection
+// pools warm up: admitting the full limit then overloads the instance, whose
+// rejections and retries keep it cold. A cold instance enforces a reduced
+// limit until its average duration (Stats.AvgDuration) is back near the
+// steady state.
+type ColdStartConfig struct {
+	// SteadyDuration is the average request duration of a warm instance,
+	// e.g. from the metrics of the previous deployment. Setting it enables the
+	// cold start detection.
+	// Optional.
+	SteadyDuration time.Duration
+
+	// Tolerance is the ratio of the average duration over SteadyDuration
+	// under which the instance is warm.
+	// Optional, default to 2, must be at least 1.
+	Tolerance float64
+
+	// LimitFactor is the fraction of the configured limit enforced while
+	// cold, in (0, 1].
+	// Optional, default to 0.5.
+	LimitFactor float64
+
+	// MaxDuration bounds the cold start from New: the limit is restored after
+	// it even if the durations are still high, they are the new steady state.
+	// Optional, default to 5m.
+	MaxDuration time.Duration
+}
+
+func applyColdStartDefaults(c ColdStartConfig) ColdStartConfig {
+	if c.SteadyDuration < 0 {
+		panic("loadshedder: ColdStartConfig.SteadyDuration cannot be negative")
+	}
+	if c.Tolerance == 0 {
+		c.Tolerance = defaultColdStartTolerance
+	}
+	if c.Tolerance < 1 {
+		panic("loadshedder: ColdStartConfig.Tolerance must be at least 1")
+	}
+	if c.LimitFactor == 0 {
+		c.LimitFactor = defaultColdStartLimitFactor
+	}
+	if c.LimitFactor < 0 || c.LimitFactor > 1 {
+		panic("loadshedder: ColdStartConfig.LimitFactor must be in (0, 1]")
+	}
+	if c.MaxDuration <= 0 {
+		c.MaxDuration = defaultColdStartMaxDuration
+	}
+	return c
+}
+
+// coldStartController ends the cold start once the average duration is
+// back under the tolerance, checked at most once per interval on the Acquire
+// path. A loadshedder never becomes cold again.
+type coldStartController struct {
+	maxAverage  int64 // nanoseconds
+	limitFactor float64
+	deadline    time.Time
+	nextCheck   atomic.Int64 // unix nanoseconds
+	cold        atomic.Bool  // written under Loadshedder.limitMu
+}
+
+func newColdStartController(c ColdStartConfig, now time.Time) *coldStartController {
+	cs := &coldStartController{
+		maxAverage:  int64(float64(c.SteadyDuration) * c.Tolerance),
+		limitFactor: c.LimitFactor,
+		deadline:    now.Add(c.MaxDuration),
+	}
+	cs.cold.Store(true)
+	return cs
+}
+
+// limit returns the limit enforced while cold. Must hold limitMu.
+func (c *coldStartController) limit(configured int64) int64 {
+	return max(1, int64(float64(configured)*c.limitFactor))
+}
+
+// updateColdStart ends the cold start if the durations are back to normal
+// or the maximum duration elapsed.
+func (l *Loadshedder) updateColdStart(now time.Time) {
+	c := l.coldStart
+	next := c.nextCheck.Load()
+	if now.UnixNano() < next || !c.nextCheck.CompareAndSwap(next, now.UnixNano()+int64(coldStartCheckInterval)) {
+		return
+	}
+
+	average := l.avgDuration.Load()
+	warm := l.released.Load() >= coldStartMinSamples && average > 0 && average <= c.maxAverage
+	if !warm && now.Before(c.deadline) {
+		return
+	}
+
+	l.limitMu.Lock()
+	defer l.limitMu.Unlock()
+
+	c.cold.Store(false)
+	l.updateEffectiveLimit()
+	l.updateSettings(func(*settings) {})
+}
+
+// coldStarting returns true while the effective limit is reduced by
+// Config.ColdStart.
+func (l *Loadshedder) coldStarting() bool {
+	return l.coldStart != nil && l.coldStart.cold.Load()
+}
@@ -0,0 +1,112 @@
+package loadshedder
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLoadshedder_ColdStart(t *testing.T) {
+	ls := New(Config{Limit: 10, ColdStart: ColdStartConfig{SteadyDuration: 10 * time.Millisecond}})
+
+	if stats := ls.Stats(); !stats.ColdStart || stats.EffectiveLimit != 5 || stats.ConfiguredLimit != 10 {
+		t.Fatalf("expected a reduced limit while cold, got %+v", stats)
+	}
+
+	// The limit follows the configured limit
+	ls.SetLimit(20)
+	if limit := ls.Stats().EffectiveLimit; limit != 10 {
+		t.Errorf("expected half of the new limit while cold, got %d", limit)
+	}
+
+	check := func() Stats {
+		ls.coldStart.nextCheck.Store(0)
+		_, token := ls.Acquire(context.Background())
+		ls.Release(token)
+		return ls.Stats()
+	}
+
+	// Durations above the tolerance keep it cold
+	ls.released.Store(coldStartMinSamples)
+	ls.avgDuration.Store(int64(50 * time.Millisecond))
+	if stats := check(); !stats.ColdStart {
+		t.Errorf("expected cold with slow requests, got %+v", stats)
+	}
+
+	// Back under twice the steady duration
+	ls.avgDuration.Store(int64(15 * time.Millisecond))
+	stats := check()
+	if stats.ColdStart || stats.EffectiveLimit != 20 {
+		t.Errorf("expected the full limit once warm, got %+v", stats)
+	}
+
+	// Never cold again
+	ls.avgDuration.Store(int64(time.Second))
+	if stats := check(); stats.ColdStart {
+		t.Errorf("expected to stay warm, got %+v", stats)
+	}
+}
+
+func TestLoadshedder_ColdStart_MinSamples(t *testing.T) {
+	ls := New(Config{Limit: 10, ColdStart: ColdStartConfig{SteadyDuration: time.Second}})
+
+	// A few fast requests are not enough to end the cold start
+	for range coldStartMinSamples - 1 {
+		ls.coldStart.nextCheck.Store(0)
+		_, token := ls.Acquire(context.Background())
+		ls.Release(token)
+	}
+	if !ls.Stats().ColdStart {
+		t.Error("expected cold until enough requests completed")
+	}
+
+	ls.coldStart.nextCheck.Store(0)
+	_, token := ls.Acquire(context.Background())
+	ls.Release(token)
+	ls.coldStart.nextCheck.Store(0)
+	ls.Acquire(context.Background())
+	if ls.Stats().ColdStart {
+		t.Error("expected warm after enough fast requests")
+	}
+}
+
+func TestLoadshedder_ColdStart_MaxDuration(t *testing.T) {
+	ls := New(Config{Limit: 10, ColdStart: ColdStartConfig{
+		SteadyDuration: time.Millisecond,
+		LimitFactor:    0.2,
+		MaxDuration:    time.Millisecond,
+	}})
+	if limit := ls.Stats().EffectiveLimit; limit != 2 {
+		t.Errorf("expected the limit factor applied, got %d", limit)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	ls.avgDuration.Store(int64(time.Second))
+	_, token := ls.Acquire(context.Background())
+	defer ls.Release(token)
+
+	if stats := ls.Stats(); stats.ColdStart || stats.EffectiveLimit != 10 {
+		t.Errorf("expected the full limit after MaxDuration, got %+v", stats)
+	}
+}
+
+func TestLoadshedder_ColdStartValidation(t *testing.T) {
+	for name, cfg := range map[string]ColdStartConfig{
+		"negative steady duration": {SteadyDuration: -1},
+		"tolerance":                {SteadyDuration: time.Second, Tolerance: 0.5},
+		"limit factor":             {SteadyDuration: time.Second, LimitFactor: 1.5},
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected a panic")
+				}
+			}()
+			New(Config{Limit: 1, ColdStart: cfg})
+		})
+	}
+
+	if stats := New(Config{Limit: 10}).Stats(); stats.ColdStart || stats.EffectiveLimit != 10 {
+		t.Errorf("expected no cold start by default, got %+v", stats)
+	}
+}
@@ -0,0 +1,60 @@
+package loadshedder
+
+import (
+	"net/http"
+	"time"
+)
+
+// CompletionInfo describes how the handler of an admitted request completed.
+type CompletionInfo struct {
+	Duration     time.Duration // Time spent in the handler, excluding the wait for a slot
+	StatusCode   int           // Status of the response: 200 if the handler wrote no header, 0 if it panicked before writing or hijacked the connection
+	BytesWritten int64         // Size of the response body written by the handler
+	Hijacked     bool          // The handler hijacked the connection (e.g. WebSocket)
+	Panicked     bool          // The handler panicked (the panic propagates after the report)
+}
+
+// CompletionReporter is a Reporter also notified when the handler of an
+// accepted request returns, e.g. to track the latency and status of the
+// admitted requests next to the shedding decisions. The middleware detects
+// it on the reporter: the Reporter interface stays stable.
+type CompletionReporter interface {
+	Reporter
+
+	// Completed is called when the handler of an accepted request returns,
+	// with the Stats passed to Accepted, before the slot is released.
+	Completed(*http.Request, Stats, CompletionInfo)
+}
+
+// serveCompleted runs the handler and reports its completion. Server errors
+// and panics fail the token, see Config.ErrorRate.
+func (m *Middleware) serveCompleted(serve func(http.ResponseWriter), w http.ResponseWriter, r *http.Request, token *Token, stats Stats) {
+	cw := &statusCapturingWriter{ResponseWriter: w}
+	start := time.Now()
+	returned := false
+
+	defer func() {
+		info := CompletionInfo{
+			Duration:     time.Since(start),
+			StatusCode:   cw.statusCode,
+			BytesWritten: cw.bytesWritten,
+			Hijacked:     cw.hijacked,
+			Panicked:     !returned,
+		}
+		if info.StatusCode == 0 && returned && !cw.hijacked {
+			info.StatusCode = http.StatusOK
+		}
+		if info.Panicked || info.StatusCode >= http.StatusInternalServerError {
+			token.Fail()
+		}
+		if m.completion == nil {
+			return
+		}
+		m.report("completed", func() {
+			m.completion.Completed(r, stats, info)
+		})
+	}()
+
+	serve(cw.wrap())
+	returned = true
+}
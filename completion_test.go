@@ -0,0 +1,120 @@
+package loadshedder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type completionRecordingReporter struct {
+	NullReporter
+
+	mu          sync.Mutex
+	stats       []Stats
+	completions []CompletionInfo
+}
+
+func (r *completionRecordingReporter) Completed(_ *http.Request, stats Stats, info CompletionInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stats = append(r.stats, stats)
+	r.completions = append(r.completions, info)
+}
+
+func TestMiddleware_Completed(t *testing.T) {
+	ls := New(Config{Limit: 1})
+	reporter := &completionRecordingReporter{}
+	mw := NewMiddleware(ls, reporter, nil)
+
+	handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(5 * time.Millisecond)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("hello"))
+		_, _ = w.Write([]byte(" world"))
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", http.NoBody))
+
+	if len(reporter.completions) != 1 {
+		t.Fatalf("expected 1 completion, got %d", len(reporter.completions))
+	}
+	info := reporter.completions[0]
+	if info.StatusCode != http.StatusCreated || info.BytesWritten != 11 || info.Panicked {
+		t.Errorf("unexpected completion: %+v", info)
+	}
+	if info.Duration < 5*time.Millisecond {
+		t.Errorf("expected the handler duration, got %v", info.Duration)
+	}
+	if reporter.stats[0].TokenID == 0 {
+		t.Errorf("expected the Stats of the acceptance, got %+v", reporter.stats[0])
+	}
+}
+
+func TestMiddleware_CompletedImplicitStatus(t *testing.T) {
+	reporter := &completionRecordingReporter{}
+	handler := NewMiddleware(New(Config{Limit: 1}), reporter, nil).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	if info := reporter.completions[0]; info.StatusCode != http.StatusOK || info.BytesWritten != 0 {
+		t.Errorf("expected an implicit 200, got %+v", info)
+	}
+}
+
+func TestMiddleware_CompletedPanic(t *testing.T) {
+	ls := New(Config{Limit: 1})
+	reporter := &completionRecordingReporter{}
+	handler := NewMiddleware(ls, reporter, nil).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected the handler panic to propagate")
+			}
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	}()
+
+	if info := reporter.completions[0]; !info.Panicked || info.StatusCode != 0 {
+		t.Errorf("expected a panicked completion, got %+v", info)
+	}
+	if stats := ls.Stats(); stats.Running != 0 {
+		t.Errorf("expected the token released, got %+v", stats)
+	}
+}
+
+func TestMiddleware_CompletedNotCalledOnRejection(t *testing.T) {
+	ls := New(Config{Limit: 1})
+	reporter := &completionRecordingReporter{}
+	handler := NewMiddleware(ls, reporter, nil).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	_, token := ls.Acquire(t.Context())
+	defer ls.Release(token)
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	if len(reporter.completions) != 0 {
+		t.Errorf("expected no completion for a rejected request, got %+v", reporter.completions)
+	}
+}
+
+func TestMiddleware_CompletedWithDegradedCache(t *testing.T) {
+	reporter := &completionRecordingReporter{}
+	mw := NewMiddleware(New(Config{Limit: 1}), reporter, nil, WithDegradedCache(NewLRUCache(10, time.Minute)))
+	handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("cached"))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	if info := reporter.completions[0]; info.StatusCode != http.StatusOK || info.BytesWritten != 6 {
+		t.Errorf("unexpected completion: %+v", info)
+	}
+	if rec.Body.String() != "cached" {
+		t.Errorf("unexpected body: %q", rec.Body.String())
+	}
+}
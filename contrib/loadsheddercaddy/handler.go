@@ -0,0 +1,269 @@
+// Package loadsheddercaddy provides a Caddy v2 HTTP handler module for loadshedder.
+package loadsheddercaddy
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/caddyconfig/httpcaddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+	"github.com/pior/loadshedder"
+	"github.com/pior/loadshedder/contrib/loadshedderprom"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func init() {
+	caddy.RegisterModule(Handler{})
+	httpcaddyfile.RegisterHandlerDirective("loadshedder", parseCaddyfile)
+	httpcaddyfile.RegisterDirectiveOrder("loadshedder", httpcaddyfile.Before, "reverse_proxy")
+}
+
+// limiters holds the loadshedders by name, so the handlers sharing a name
+// share one, and it survives config reloads with its running requests.
+var limiters = caddy.NewUsagePool()
+
+// reporters holds the Prometheus reporters by namespace and name for the life
+// of the process: their collectors stay registered on the default registerer,
+// so a name removed by a reload and added back later reuses them.
+var (
+	reportersMu sync.Mutex
+	reporters   = make(map[reporterKey]*loadshedderprom.Reporter)
+)
+
+type reporterKey struct {
+	namespace, name string
+}
+
+// Handler limits the concurrency of the requests going through it with a
+// loadshedder. Handlers with the same name share the loadshedder, and
+// reloading the config applies the new limits to it.
+type Handler struct {
+	// Name identifies the loadshedder, shared by the handlers with the same
+	// name. Required.
+	Name string `json:"name,omitempty"`
+
+	// Limit is the maximum number of concurrent requests. Required.
+	Limit int64 `json:"limit,omitempty"`
+
+	// Waiting is the maximum number of requests waiting for a slot.
+	// Default to 0 (reject right away).
+	Waiting int64 `json:"waiting,omitempty"`
+
+	// MaxWait bounds the time a request waits for a slot.
+	// Default to 0 (only the request context bounds the wait).
+	MaxWait caddy.Duration `json:"max_wait,omitempty"`
+
+	// RetryAfter is the Retry-After of the 429 responses to rejected
+	// requests, rounded up to the second. Default to a Retry-After computed
+	// from the projected time to drain the queue, within 1s and 1m, see
+	// loadshedder.NewAdaptiveRejectionHandler.
+	RetryAfter caddy.Duration `json:"retry_after,omitempty"`
+
+	// MetricsNamespace enables the Prometheus metrics of loadshedderprom,
+	// with this namespace and a loadshedder label set to the name, on the
+	// default Prometheus registerer. Default to "" (no metrics).
+	MetricsNamespace string `json:"metrics_namespace,omitempty"`
+
+	loadshedder *loadshedder.Loadshedder
+	middleware  *loadshedder.Middleware
+}
+
+// CaddyModule returns the Caddy module information.
+func (Handler) CaddyModule() caddy.ModuleInfo {
+	return caddy.ModuleInfo{
+		ID:  "http.handlers.loadshedder",
+		New: func() caddy.Module { return new(Handler) },
+	}
+}
+
+// Validate checks the configuration.
+func (h *Handler) Validate() error {
+	switch {
+	case h.Name == "":
+		return errors.New("loadshedder: name is required")
+	case h.Limit <= 0:
+		return errors.New("loadshedder: limit must be positive")
+	case h.Waiting < 0:
+		return errors.New("loadshedder: waiting cannot be negative")
+	case h.MaxWait < 0 || h.RetryAfter < 0:
+		return errors.New("loadshedder: max_wait and retry_after cannot be negative")
+	}
+	return nil
+}
+
+// Provision gets the loadshedder of the name, applies the limits and builds
+// the middleware.
+func (h *Handler) Provision(ctx caddy.Context) error {
+	// Provision runs before Validate
+	if err := h.Validate(); err != nil {
+		return err
+	}
+
+	value, _, err := limiters.LoadOrNew(h.Name, func() (caddy.Destructor, error) {
+		return newLimiter(h), nil
+	})
+	if err != nil {
+		return err
+	}
+	h.loadshedder = value.(*limiter).loadshedder
+
+	// Apply the limits of a reloaded config. A waiting limit of zero switches
+	// the loadshedder to the counter-only mode, any other back to the queue.
+	h.loadshedder.SetLimit(h.Limit)
+	h.loadshedder.SetWaitingLimit(h.Waiting)
+
+	var rejectionHandler loadshedder.RejectionHandler // adaptive by default
+	if retryAfter := time.Duration(h.RetryAfter); retryAfter > 0 {
+		rejectionHandler = loadshedder.NewRejectionHandler(int(math.Ceil(retryAfter.Seconds())))
+	}
+
+	var opts []loadshedder.MiddlewareOption
+	if maxWait := time.Duration(h.MaxWait); maxWait > 0 {
+		opts = append(opts, loadshedder.WithRequestOptions(func(*http.Request) []loadshedder.AcquireOption {
+			return []loadshedder.AcquireOption{loadshedder.WithMaxWait(maxWait)}
+		}))
+	}
+	var reporter loadshedder.Reporter
+	if h.MetricsNamespace != "" {
+		reporter = reporterFor(h.MetricsNamespace, h.Name)
+	}
+	h.middleware = loadshedder.NewMiddleware(h.loadshedder, reporter, rejectionHandler, opts...)
+
+	return nil
+}
+
+// Cleanup releases the loadshedder of the name, dropped once no config uses it.
+func (h *Handler) Cleanup() error {
+	_, err := limiters.Delete(h.Name)
+	return err
+}
+
+// ServeHTTP serves the request through the loadshedder. Errors returned by
+// the next handlers are handled by Caddy after the slot is released.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request, next caddyhttp.Handler) error {
+	var err error
+	h.middleware.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		err = next.ServeHTTP(w, r)
+	})).ServeHTTP(w, r)
+	return err
+}
+
+// limiter is the loadshedder of a name.
+type limiter struct {
+	loadshedder *loadshedder.Loadshedder
+}
+
+func newLimiter(h *Handler) *limiter {
+	return &limiter{
+		loadshedder: loadshedder.New(loadshedder.Config{Name: h.Name, Limit: h.Limit, WaitingLimit: h.Waiting}),
+	}
+}
+
+// reporterFor returns the reporter of the loadshedder name with the metrics
+// namespace, created and registered on first use.
+func reporterFor(namespace, name string) *loadshedderprom.Reporter {
+	reportersMu.Lock()
+	defer reportersMu.Unlock()
+
+	key := reporterKey{namespace: namespace, name: name}
+	reporter, ok := reporters[key]
+	if !ok {
+		reporter = loadshedderprom.NewReporter(namespace,
+			loadshedderprom.WithConstLabels(prometheus.Labels{"loadshedder": name}))
+		reporters[key] = reporter
+	}
+	return reporter
+}
+
+// Destruct implements caddy.Destructor.
+func (l *limiter) Destruct() error {
+	return nil
+}
+
+// UnmarshalCaddyfile sets up the handler from Caddyfile tokens:
+//
+//	loadshedder <name> {
+//		limit       <n>
+//		waiting     <n>
+//		max_wait    <duration>
+//		retry_after <duration>
+//		metrics     [<namespace>]
+//	}
+func (h *Handler) UnmarshalCaddyfile(d *caddyfile.Dispenser) error {
+	d.Next() // directive name
+
+	if !d.Args(&h.Name) {
+		return d.ArgErr()
+	}
+	if d.NextArg() {
+		return d.ArgErr()
+	}
+
+	for d.NextBlock(0) {
+		switch subdirective := d.Val(); subdirective {
+		case "limit", "waiting":
+			var value string
+			if !d.Args(&value) {
+				return d.ArgErr()
+			}
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return d.Errf("parsing %s: %v", subdirective, err)
+			}
+			if subdirective == "limit" {
+				h.Limit = n
+			} else {
+				h.Waiting = n
+			}
+
+		case "max_wait", "retry_after":
+			var value string
+			if !d.Args(&value) {
+				return d.ArgErr()
+			}
+			duration, err := caddy.ParseDuration(value)
+			if err != nil {
+				return d.Errf("parsing %s: %v", subdirective, err)
+			}
+			if subdirective == "max_wait" {
+				h.MaxWait = caddy.Duration(duration)
+			} else {
+				h.RetryAfter = caddy.Duration(duration)
+			}
+
+		case "metrics":
+			h.MetricsNamespace = "caddy_loadshedder"
+			if d.NextArg() {
+				h.MetricsNamespace = d.Val()
+			}
+			if d.NextArg() {
+				return d.ArgErr()
+			}
+
+		default:
+			return d.Errf("unrecognized subdirective %q", subdirective)
+		}
+	}
+	return nil
+}
+
+func parseCaddyfile(helper httpcaddyfile.Helper) (caddyhttp.MiddlewareHandler, error) {
+	var h Handler
+	err := h.UnmarshalCaddyfile(helper.Dispenser)
+	return &h, err
+}
+
+// Interface guards
+var (
+	_ caddy.Provisioner           = (*Handler)(nil)
+	_ caddy.Validator             = (*Handler)(nil)
+	_ caddy.CleanerUpper          = (*Handler)(nil)
+	_ caddyhttp.MiddlewareHandler = (*Handler)(nil)
+	_ caddyfile.Unmarshaler       = (*Handler)(nil)
+)
@@ -0,0 +1,196 @@
+package loadsheddercaddy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/caddyserver/caddy/v2"
+	"github.com/caddyserver/caddy/v2/caddyconfig/caddyfile"
+	"github.com/caddyserver/caddy/v2/modules/caddyhttp"
+)
+
+func TestUnmarshalCaddyfile(t *testing.T) {
+	d := caddyfile.NewTestDispenser(`
+	loadshedder api {
+		limit 100
+		waiting 20
+		max_wait 500ms
+		retry_after 2s
+		metrics myapp
+	}`)
+
+	var h Handler
+	if err := h.UnmarshalCaddyfile(d); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Handler{
+		Name:             "api",
+		Limit:            100,
+		Waiting:          20,
+		MaxWait:          caddy.Duration(500 * time.Millisecond),
+		RetryAfter:       caddy.Duration(2 * time.Second),
+		MetricsNamespace: "myapp",
+	}
+	if h.Name != expected.Name || h.Limit != expected.Limit || h.Waiting != expected.Waiting ||
+		h.MaxWait != expected.MaxWait || h.RetryAfter != expected.RetryAfter || h.MetricsNamespace != expected.MetricsNamespace {
+		t.Errorf("expected %+v, got %+v", expected, h)
+	}
+}
+
+func TestUnmarshalCaddyfile_Errors(t *testing.T) {
+	tests := map[string]string{
+		"missing name":      `loadshedder`,
+		"invalid limit":     `loadshedder api { limit many }`,
+		"invalid duration":  `loadshedder api { max_wait soon }`,
+		"unknown directive": `loadshedder api { burst 10 }`,
+	}
+
+	for name, input := range tests {
+		var h Handler
+		if err := h.UnmarshalCaddyfile(caddyfile.NewTestDispenser(input)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestHandler_ServeHTTP(t *testing.T) {
+	ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+	defer cancel()
+
+	h := &Handler{Name: t.Name(), Limit: 1, RetryAfter: caddy.Duration(2 * time.Second)}
+	if err := h.Provision(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Cleanup()
+
+	var rejected *httptest.ResponseRecorder
+	next := caddyhttp.HandlerFunc(func(w http.ResponseWriter, r *http.Request) error {
+		// The only slot is taken: a concurrent request is rejected
+		rejected = httptest.NewRecorder()
+		err := h.ServeHTTP(rejected, httptest.NewRequest(http.MethodGet, "/", http.NoBody), caddyhttp.HandlerFunc(func(http.ResponseWriter, *http.Request) error {
+			return nil
+		}))
+		if err != nil {
+			t.Error(err)
+		}
+		return caddyhttp.Error(http.StatusBadGateway, nil)
+	})
+
+	err := h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody), next)
+
+	if handlerErr, ok := err.(caddyhttp.HandlerError); !ok || handlerErr.StatusCode != http.StatusBadGateway {
+		t.Errorf("expected the error of the next handler, got %v", err)
+	}
+	if rejected.Code != http.StatusTooManyRequests || rejected.Header().Get("Retry-After") != "2" {
+		t.Errorf("expected a 429 with Retry-After: 2, got %d and %q", rejected.Code, rejected.Header().Get("Retry-After"))
+	}
+}
+
+func TestHandler_SharedAcrossReloads(t *testing.T) {
+	ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+	defer cancel()
+
+	first := &Handler{Name: t.Name(), Limit: 1}
+	if err := first.Provision(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer first.Cleanup()
+
+	// A reloaded config raises the limit of the same loadshedder
+	second := &Handler{Name: t.Name(), Limit: 5, Waiting: 2}
+	if err := second.Provision(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer second.Cleanup()
+
+	value, loaded := limiters.References(t.Name())
+	if !loaded || value != 2 {
+		t.Fatalf("expected 2 references to the loadshedder, got %d", value)
+	}
+
+	cfg := first.loadshedder.Config()
+	if cfg.Limit != 5 || cfg.WaitingLimit != 2 {
+		t.Errorf("expected the reloaded limits, got %d and %d", cfg.Limit, cfg.WaitingLimit)
+	}
+
+	// And back to no waiting queue
+	third := &Handler{Name: t.Name(), Limit: 5}
+	if err := third.Provision(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer third.Cleanup()
+
+	if cfg := first.loadshedder.Config(); cfg.WaitingLimit != 0 {
+		t.Errorf("expected no waiting limit, got %d", cfg.WaitingLimit)
+	}
+}
+
+func TestHandler_ReAddedWithMetrics(t *testing.T) {
+	ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+	defer cancel()
+
+	first := &Handler{Name: t.Name(), Limit: 1, MetricsNamespace: "caddytest"}
+	if err := first.Provision(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := first.Cleanup(); err != nil {
+		t.Fatal(err)
+	}
+	if _, loaded := limiters.References(t.Name()); loaded {
+		t.Fatal("expected the loadshedder to be dropped with its last handler")
+	}
+
+	// Removed by a reload then added back: the collectors are reused
+	second := &Handler{Name: t.Name(), Limit: 1, MetricsNamespace: "caddytest"}
+	if err := second.Provision(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer second.Cleanup()
+
+	if reporterFor("caddytest", t.Name()) != reporterFor("caddytest", t.Name()) {
+		t.Error("expected one reporter per namespace and name")
+	}
+}
+
+func TestHandler_MetricsEnabledOnReload(t *testing.T) {
+	ctx, cancel := caddy.NewContext(caddy.Context{Context: context.Background()})
+	defer cancel()
+
+	first := &Handler{Name: t.Name(), Limit: 1}
+	if err := first.Provision(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer first.Cleanup()
+
+	second := &Handler{Name: t.Name(), Limit: 1, MetricsNamespace: "caddytest"}
+	if err := second.Provision(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer second.Cleanup()
+
+	reportersMu.Lock()
+	_, ok := reporters[reporterKey{namespace: "caddytest", name: t.Name()}]
+	reportersMu.Unlock()
+	if !ok {
+		t.Error("expected the metrics enabled by the reloaded config")
+	}
+}
+
+func TestHandler_Validate(t *testing.T) {
+	tests := map[string]Handler{
+		"missing name":     {Limit: 1},
+		"missing limit":    {Name: "api"},
+		"negative waiting": {Name: "api", Limit: 1, Waiting: -1},
+		"negative wait":    {Name: "api", Limit: 1, MaxWait: -1},
+	}
+
+	for name, h := range tests {
+		if err := h.Validate(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
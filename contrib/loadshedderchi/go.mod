@@ -0,0 +1,10 @@
+module github.com/pior/loadshedder/contrib/loadshedderchi
+
+go 1.24.0
+
+require (
+	github.com/go-chi/chi/v5 v5.2.1
+	github.com/pior/loadshedder v0.1.0
+)
+
+replace github.com/pior/loadshedder => ../../
@@ -0,0 +1,49 @@
+// Package loadshedderchi provides a chi middleware for loadshedder, labeling
+// each request with its chi route pattern for per-route metrics.
+package loadshedderchi
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/pior/loadshedder"
+)
+
+// UnmatchedRoute is the label of the requests matching no route, or served
+// outside a chi router.
+const UnmatchedRoute = "unmatched"
+
+// Wrap is loadshedder.Wrap with each request labeled by its route pattern
+// (e.g. "/users/{id}"), see loadshedder.WithRequestLabel: CountersByLabel and
+// the Stats.Label passed to the reporter are then per route, for per-route
+// accept and reject metrics without a custom reporter (e.g.
+// loadshedderprom.WithLabels). Install it in the router, with Use, With,
+// Group or Route:
+//
+//	r := chi.NewRouter()
+//	r.Use(loadshedderchi.Wrap(ls, loadshedder.WithReporter(reporter)))
+func Wrap(ls *loadshedder.Loadshedder, opts ...loadshedder.MiddlewareOption) func(http.Handler) http.Handler {
+	return loadshedder.Wrap(ls, append([]loadshedder.MiddlewareOption{loadshedder.WithRequestLabel(RoutePattern)}, opts...)...)
+}
+
+// RoutePattern returns the chi route pattern of the request, with the
+// prefixes of the mounted routers (e.g. "/api/users/{id}"), or UnmatchedRoute.
+// Middlewares installed with Router.Use run before the routing: the route is
+// resolved ahead, from the root router of the request.
+func RoutePattern(r *http.Request) string {
+	rctx := chi.RouteContext(r.Context())
+	if rctx == nil || rctx.Routes == nil {
+		return UnmatchedRoute
+	}
+
+	path := r.URL.RawPath
+	if path == "" {
+		path = r.URL.Path
+	}
+
+	match := chi.NewRouteContext()
+	if !rctx.Routes.Match(match, r.Method, path) {
+		return UnmatchedRoute
+	}
+	return match.RoutePattern()
+}
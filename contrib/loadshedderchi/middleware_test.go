@@ -0,0 +1,113 @@
+package loadshedderchi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/pior/loadshedder"
+)
+
+type recordingReporter struct {
+	mu                 sync.Mutex
+	accepted, rejected []loadshedder.Stats
+}
+
+func (r *recordingReporter) Accepted(_ *http.Request, stats loadshedder.Stats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.accepted = append(r.accepted, stats)
+}
+
+func (r *recordingReporter) Rejected(_ *http.Request, stats loadshedder.Stats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rejected = append(r.rejected, stats)
+}
+
+func ok(w http.ResponseWriter, r *http.Request) {}
+
+func TestWrap(t *testing.T) {
+	ls := loadshedder.New(loadshedder.Config{Limit: 10})
+
+	r := chi.NewRouter()
+	r.Use(Wrap(ls))
+	r.Get("/users/{id}", ok)
+	r.Route("/api", func(r chi.Router) {
+		r.Get("/items/{id}", ok)
+	})
+
+	admin := chi.NewRouter()
+	admin.Get("/stats", ok)
+	r.Mount("/admin", admin)
+
+	for _, path := range []string{"/users/1", "/users/2", "/api/items/3", "/admin/stats", "/missing"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, http.NoBody))
+	}
+
+	expected := map[string]int64{"/users/{id}": 2, "/api/items/{id}": 1, "/admin/stats": 1, UnmatchedRoute: 1}
+	counters := ls.CountersByLabel()
+	if len(counters) != len(expected) {
+		t.Errorf("expected %d routes, got %v", len(expected), counters)
+	}
+	for route, accepted := range expected {
+		if counters[route].Accepted != accepted {
+			t.Errorf("%s: expected %d accepted requests, got %+v", route, accepted, counters[route])
+		}
+	}
+}
+
+func TestWrap_Reporter(t *testing.T) {
+	ls := loadshedder.New(loadshedder.Config{Limit: 1})
+	reporter := &recordingReporter{}
+
+	r := chi.NewRouter()
+	r.Route("/api", func(r chi.Router) {
+		r.Use(Wrap(ls, loadshedder.WithReporter(reporter)))
+		r.Get("/items/{id}", ok)
+	})
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/items/1", http.NoBody))
+
+	// Fill the limit
+	_, token := ls.Acquire(context.Background())
+	defer ls.Release(token)
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/items/2", http.NoBody))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected a 429, got %d", rec.Code)
+	}
+
+	if len(reporter.accepted) != 1 || reporter.accepted[0].Label != "/api/items/{id}" {
+		t.Errorf("expected the accepted request to be labeled by route, got %+v", reporter.accepted)
+	}
+	if len(reporter.rejected) != 1 || reporter.rejected[0].Label != "/api/items/{id}" {
+		t.Errorf("expected the rejected request to be labeled by route, got %+v", reporter.rejected)
+	}
+}
+
+func TestRoutePattern(t *testing.T) {
+	var patterns []string
+	record := func(w http.ResponseWriter, r *http.Request) {
+		patterns = append(patterns, RoutePattern(r))
+	}
+
+	r := chi.NewRouter()
+	r.With(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			record(w, r)
+			next.ServeHTTP(w, r)
+		})
+	}).Get("/users/{id}", ok)
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", http.NoBody))
+	record(nil, httptest.NewRequest(http.MethodGet, "/users/1", http.NoBody))
+
+	if len(patterns) != 2 || patterns[0] != "/users/{id}" || patterns[1] != UnmatchedRoute {
+		t.Errorf("expected the route pattern, then unmatched outside the router, got %v", patterns)
+	}
+}
@@ -0,0 +1,115 @@
+// Package loadshedderdd provides Datadog APM integration for loadshedder.
+package loadshedderdd
+
+import (
+	"net/http"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	"github.com/pior/loadshedder"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+// Span tags set on the active request span.
+const (
+	TagDecision    = "loadshedder.decision" // "accepted" or "rejected"
+	TagName        = "loadshedder.name"
+	TagWaitTime    = "loadshedder.wait_time_ms"
+	TagUtilization = "loadshedder.utilization"
+	TagRunning     = "loadshedder.running"
+	TagWaiting     = "loadshedder.waiting"
+	TagLimit       = "loadshedder.limit"
+	TagTokenID     = "loadshedder.token_id" // accepted requests only, see loadshedder.TokenID
+)
+
+// Reporter implements the loadshedder.Reporter interface by tagging the
+// active dd-trace span of each request with the shedding decision, and
+// optionally sending metrics with a DogStatsD client.
+//
+// The span is the one started by the dd-trace-go HTTP integration: install
+// it outside of the loadshedder middleware, so the request context carries
+// the span when the loadshedder decides.
+type Reporter struct {
+	statsd statsd.ClientInterface
+	tags   []string
+}
+
+// Option configures a Reporter.
+type Option func(*Reporter)
+
+// WithStatsd sends the loadshedder metrics with the client, e.g. the one
+// sending the application metrics to the Datadog agent:
+//   - loadshedder.requests (count, tagged decision:accepted or decision:rejected)
+//   - loadshedder.wait_time (distribution, in milliseconds)
+//   - loadshedder.running, loadshedder.waiting, loadshedder.limit and
+//     loadshedder.utilization (gauges)
+func WithStatsd(client statsd.ClientInterface) Option {
+	return func(r *Reporter) {
+		r.statsd = client
+	}
+}
+
+// WithTags adds tags (e.g. "service:api") to the metrics sent with WithStatsd.
+// Metrics of a named loadshedder are also tagged loadshedder:<name>.
+func WithTags(tags ...string) Option {
+	return func(r *Reporter) {
+		r.tags = append(r.tags, tags...)
+	}
+}
+
+// NewReporter creates a Datadog reporter.
+func NewReporter(opts ...Option) *Reporter {
+	r := &Reporter{}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Accepted is called when a request is accepted.
+func (r *Reporter) Accepted(req *http.Request, stats loadshedder.Stats) {
+	r.report(req, "accepted", stats)
+}
+
+// Rejected is called when a request is rejected.
+func (r *Reporter) Rejected(req *http.Request, stats loadshedder.Stats) {
+	r.report(req, "rejected", stats)
+}
+
+func (r *Reporter) report(req *http.Request, decision string, stats loadshedder.Stats) {
+	utilization := 0.0
+	if stats.Limit > 0 {
+		utilization = float64(stats.Running) / float64(stats.Limit)
+	}
+	waitTime := float64(stats.WaitTime.Microseconds()) / 1000
+
+	if span, ok := tracer.SpanFromContext(req.Context()); ok {
+		span.SetTag(TagDecision, decision)
+		if stats.Name != "" {
+			span.SetTag(TagName, stats.Name)
+		}
+		span.SetTag(TagWaitTime, waitTime)
+		span.SetTag(TagUtilization, utilization)
+		span.SetTag(TagRunning, stats.Running)
+		span.SetTag(TagWaiting, stats.Waiting)
+		span.SetTag(TagLimit, stats.Limit)
+		if stats.TokenID != 0 {
+			span.SetTag(TagTokenID, stats.TokenID.String())
+		}
+	}
+
+	if r.statsd == nil {
+		return
+	}
+
+	tags := r.tags
+	if stats.Name != "" {
+		tags = append(tags[:len(tags):len(tags)], "loadshedder:"+stats.Name)
+	}
+
+	_ = r.statsd.Incr("loadshedder.requests", append(tags[:len(tags):len(tags)], "decision:"+decision), 1)
+	_ = r.statsd.Distribution("loadshedder.wait_time", waitTime, tags, 1)
+	_ = r.statsd.Gauge("loadshedder.running", float64(stats.Running), tags, 1)
+	_ = r.statsd.Gauge("loadshedder.waiting", float64(stats.Waiting), tags, 1)
+	_ = r.statsd.Gauge("loadshedder.limit", float64(stats.Limit), tags, 1)
+	_ = r.statsd.Gauge("loadshedder.utilization", utilization, tags, 1)
+}
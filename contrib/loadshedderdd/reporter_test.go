@@ -0,0 +1,84 @@
+package loadshedderdd
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-go/v5/statsd"
+	"github.com/pior/loadshedder"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/mocktracer"
+	"gopkg.in/DataDog/dd-trace-go.v1/ddtrace/tracer"
+)
+
+type recordingStatsd struct {
+	*statsd.NoOpClient
+	counts map[string][]string // metric -> tags
+	gauges map[string]float64
+}
+
+func newRecordingStatsd() *recordingStatsd {
+	return &recordingStatsd{NoOpClient: &statsd.NoOpClient{}, counts: map[string][]string{}, gauges: map[string]float64{}}
+}
+
+func (s *recordingStatsd) Incr(name string, tags []string, _ float64) error {
+	s.counts[name] = tags
+	return nil
+}
+
+func (s *recordingStatsd) Gauge(name string, value float64, _ []string, _ float64) error {
+	s.gauges[name] = value
+	return nil
+}
+
+func TestReporter_TagsSpan(t *testing.T) {
+	mt := mocktracer.Start()
+	defer mt.Stop()
+
+	span, ctx := tracer.StartSpanFromContext(t.Context(), "http.request")
+	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody).WithContext(ctx)
+
+	reporter := NewReporter()
+	reporter.Accepted(req, loadshedder.Stats{Name: "api", Running: 5, Limit: 10, WaitTime: 1500 * time.Microsecond, TokenID: 42})
+	span.Finish()
+
+	finished := mt.FinishedSpans()
+	if len(finished) != 1 {
+		t.Fatalf("expected 1 span, got %d", len(finished))
+	}
+	tags := finished[0].Tags()
+	if tags[TagDecision] != "accepted" || tags[TagName] != "api" {
+		t.Errorf("expected the decision and name tags, got %v", tags)
+	}
+	if tags[TagWaitTime] != 1.5 || tags[TagUtilization] != 0.5 {
+		t.Errorf("expected wait time and utilization tags, got %v", tags)
+	}
+	if tags[TagTokenID] != "16" {
+		t.Errorf("expected the token ID tag, got %v", tags)
+	}
+}
+
+func TestReporter_WithoutSpan(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+
+	// No span in the context: nothing to tag, no panic
+	NewReporter().Rejected(req, loadshedder.Stats{Limit: 10})
+}
+
+func TestReporter_WithStatsd(t *testing.T) {
+	client := newRecordingStatsd()
+	reporter := NewReporter(WithStatsd(client), WithTags("service:api"))
+	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+
+	reporter.Rejected(req, loadshedder.Stats{Name: "read", Running: 10, Waiting: 2, Limit: 10})
+
+	expected := []string{"service:api", "loadshedder:read", "decision:rejected"}
+	if tags := client.counts["loadshedder.requests"]; !slices.Equal(tags, expected) {
+		t.Errorf("expected tags %v, got %v", expected, tags)
+	}
+	if client.gauges["loadshedder.utilization"] != 1 || client.gauges["loadshedder.waiting"] != 2 {
+		t.Errorf("unexpected gauges: %v", client.gauges)
+	}
+}
@@ -0,0 +1,205 @@
+// Package loadshedderfiber provides a Fiber middleware for loadshedder, for
+// applications served by fasthttp rather than net/http.
+package loadshedderfiber
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
+	"github.com/pior/loadshedder"
+)
+
+// Source is the traffic source of Fiber acquisitions, see loadshedder.WithSource.
+const Source = "fiber"
+
+// Option configures the middleware.
+type Option func(*config)
+
+type config struct {
+	reporter         Reporter
+	rejectionHandler loadshedder.RejectionHandler
+	headerPriority   bool
+	acquireOptions   func(*fiber.Ctx) []loadshedder.AcquireOption
+	deadline         func(*fiber.Ctx) (time.Time, bool)
+	bypass           func(*fiber.Ctx) bool
+}
+
+// Reporter provides observability hooks for the middleware, the Fiber
+// counterpart of loadshedder.Reporter. Fiber reuses its contexts: reporters
+// must not keep the *fiber.Ctx, or values read from it, after returning.
+// Reporter panics are caught and logged, they do not fail the request.
+type Reporter interface {
+	// Accepted is called when a request is accepted and will be processed.
+	Accepted(c *fiber.Ctx, stats loadshedder.Stats)
+
+	// Rejected is called when a request is rejected.
+	Rejected(c *fiber.Ctx, stats loadshedder.Stats)
+}
+
+// WithReporter reports accepted and rejected requests to the reporter.
+func WithReporter(reporter Reporter) Option {
+	return func(c *config) {
+		c.reporter = reporter
+	}
+}
+
+// WithRejectionHandler sets the response to rejected requests, e.g.
+// loadshedder.NewJSONRejectionHandler. The net/http handler is adapted to
+// fasthttp on the rejection path only.
+// Optional, default to loadshedder.NewAdaptiveRejectionHandler(time.Second, time.Minute).
+func WithRejectionHandler(rejectionHandler loadshedder.RejectionHandler) Option {
+	return func(c *config) {
+		c.rejectionHandler = rejectionHandler
+	}
+}
+
+// WithHeaderPriority sets the priority of each request from its headers, see
+// loadshedder.PriorityFromHeader. Headers are set by clients: only enable it
+// for internal callers.
+func WithHeaderPriority() Option {
+	return func(c *config) {
+		c.headerPriority = true
+	}
+}
+
+// WithAcquireOptions adds the acquire options returned by fn for each
+// request, e.g. loadshedder.WithMaxWait or loadshedder.WithWeight.
+func WithAcquireOptions(fn func(*fiber.Ctx) []loadshedder.AcquireOption) Option {
+	return func(c *config) {
+		c.acquireOptions = fn
+	}
+}
+
+// WithDeadline bounds each request with the deadline returned by fn, e.g.
+// DeadlineFromHeader, like loadshedder.WithDeadline: the wait for a slot
+// ends with the deadline, and the handler reads it from c.UserContext().
+func WithDeadline(fn func(*fiber.Ctx) (time.Time, bool)) Option {
+	return func(c *config) {
+		c.deadline = fn
+	}
+}
+
+// WithBypass serves the requests matching fn without the loadshedder, e.g.
+// health checks.
+func WithBypass(fn func(*fiber.Ctx) bool) Option {
+	return func(c *config) {
+		c.bypass = fn
+	}
+}
+
+// DeadlineFromHeader returns a WithDeadline function reading the timeout of
+// the request from a header, as an integer count of unit, like
+// loadshedder.DeadlineFromHeader. Requests without a valid header get no
+// deadline. Panics if unit is not positive.
+func DeadlineFromHeader(header string, unit time.Duration) func(*fiber.Ctx) (time.Time, bool) {
+	if unit <= 0 {
+		panic("loadshedderfiber: DeadlineFromHeader unit must be positive")
+	}
+	return func(c *fiber.Ctx) (time.Time, bool) {
+		timeout, err := strconv.ParseInt(c.Get(header), 10, 64)
+		if err != nil || timeout < 0 || timeout > math.MaxInt64/int64(unit) {
+			return time.Time{}, false
+		}
+		return time.Now().Add(time.Duration(timeout) * unit), true
+	}
+}
+
+// New creates a Fiber middleware limiting the concurrency of the requests
+// with the loadshedder.
+//
+// The slot is acquired with c.UserContext(), and released when the next
+// handlers return: fasthttp buffers the response, so this covers the work of
+// the request, but not the body streams (SetBodyStreamWriter) written after.
+// fasthttp does not cancel requests when clients disconnect: bound the wait
+// for a slot with Config.MaxQueueWait or WithDeadline.
+//
+// The handlers of accepted requests find the token, the Stats and the
+// priority in c.UserContext(), see loadshedder.TokenFromContext.
+// Handler panics propagate after the token is released.
+func New(ls *loadshedder.Loadshedder, opts ...Option) fiber.Handler {
+	cfg := config{rejectionHandler: loadshedder.NewAdaptiveRejectionHandler(time.Second, time.Minute)}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return func(c *fiber.Ctx) error {
+		if cfg.bypass != nil && cfg.bypass(c) {
+			return c.Next()
+		}
+
+		ctx := c.UserContext()
+		if cfg.deadline != nil {
+			if deadline, ok := cfg.deadline(c); ok {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithDeadline(ctx, deadline)
+				defer cancel()
+			}
+		}
+
+		stats, token := ls.Acquire(ctx, cfg.acquire(c)...)
+		defer ls.Release(token)
+
+		cfg.report(c, stats, token.Accepted())
+		if !token.Accepted() {
+			return adaptor.HTTPHandler(cfg.rejectionHandler(stats))(c)
+		}
+
+		// Let outgoing requests and nested acquisitions inherit the priority
+		ctx = loadshedder.ContextWithToken(loadshedder.ContextWithStats(ctx, stats), token)
+		if p := token.Priority(); p != loadshedder.PriorityFromContext(ctx) {
+			ctx = loadshedder.ContextWithPriority(ctx, p)
+		}
+		c.SetUserContext(ctx)
+
+		return c.Next()
+	}
+}
+
+func (cfg config) acquire(c *fiber.Ctx) []loadshedder.AcquireOption {
+	opts := []loadshedder.AcquireOption{loadshedder.WithSource(Source)}
+
+	if cfg.headerPriority {
+		opts = append(opts, loadshedder.WithPriority(headerPriority(c)))
+	}
+	if cfg.acquireOptions != nil {
+		opts = append(opts, cfg.acquireOptions(c)...)
+	}
+
+	return opts
+}
+
+// headerPriority is loadshedder.PriorityFromHeader for the fasthttp headers.
+func headerPriority(c *fiber.Ctx) loadshedder.Priority {
+	h := http.Header{}
+	for _, name := range []string{loadshedder.PriorityHeader, loadshedder.RFC9218PriorityHeader} {
+		if value := c.Get(name); value != "" {
+			h.Set(name, value)
+		}
+	}
+	return loadshedder.PriorityFromHeader(h)
+}
+
+// report calls the reporter, if any, isolating its panics.
+func (cfg config) report(c *fiber.Ctx, stats loadshedder.Stats, accepted bool) {
+	if cfg.reporter == nil {
+		return
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			slog.Default().Error("loadshedderfiber: reporter panic", "path", c.Path(), "error", err)
+		}
+	}()
+
+	if accepted {
+		cfg.reporter.Accepted(c, stats)
+	} else {
+		cfg.reporter.Rejected(c, stats)
+	}
+}
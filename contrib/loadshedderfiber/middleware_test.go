@@ -0,0 +1,155 @@
+package loadshedderfiber
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/pior/loadshedder"
+)
+
+type recordingReporter struct {
+	accepted, rejected []loadshedder.Stats
+}
+
+func (r *recordingReporter) Accepted(_ *fiber.Ctx, stats loadshedder.Stats) {
+	r.accepted = append(r.accepted, stats)
+}
+
+func (r *recordingReporter) Rejected(_ *fiber.Ctx, stats loadshedder.Stats) {
+	r.rejected = append(r.rejected, stats)
+}
+
+func newApp(ls *loadshedder.Loadshedder, opts ...Option) *fiber.App {
+	app := fiber.New()
+	app.Use(New(ls, opts...))
+	app.Get("/", func(c *fiber.Ctx) error {
+		token := loadshedder.TokenFromContext(c.UserContext())
+		return c.SendString(token.Priority().String())
+	})
+	return app
+}
+
+func serve(t *testing.T, app *fiber.App, r *http.Request) (*http.Response, string) {
+	t.Helper()
+
+	resp, err := app.Test(r, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(body)
+}
+
+func TestNew(t *testing.T) {
+	ls := loadshedder.New(loadshedder.Config{Limit: 1})
+	reporter := &recordingReporter{}
+	app := newApp(ls, WithReporter(reporter))
+
+	resp, body := serve(t, app, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	if resp.StatusCode != http.StatusOK || body != "default" {
+		t.Errorf("expected a 200 with the token in the context, got %d %q", resp.StatusCode, body)
+	}
+	if ls.Stats().Running != 0 {
+		t.Error("expected the slot to be released")
+	}
+
+	// Fill the limit
+	_, token := ls.Acquire(context.Background())
+	defer ls.Release(token)
+
+	resp, _ = serve(t, app, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "1" {
+		t.Errorf("expected a 429 with Retry-After: 1, got %d and %q", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+	if len(reporter.accepted) != 1 || len(reporter.rejected) != 1 {
+		t.Errorf("expected 1 accepted and 1 rejected report, got %d and %d", len(reporter.accepted), len(reporter.rejected))
+	}
+}
+
+func TestNew_RejectionHandler(t *testing.T) {
+	ls := loadshedder.New(loadshedder.Config{Limit: 1})
+	app := newApp(ls, WithRejectionHandler(loadshedder.NewJSONRejectionHandler(time.Second, time.Minute)))
+
+	_, token := ls.Acquire(context.Background())
+	defer ls.Release(token)
+
+	r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	r.Header.Set("Accept", "application/json")
+	resp, body := serve(t, app, r)
+	if resp.StatusCode != http.StatusTooManyRequests || !strings.Contains(body, `"reason":"queue_full"`) {
+		t.Errorf("expected a JSON 429, got %d %q", resp.StatusCode, body)
+	}
+}
+
+func TestNew_Deadline(t *testing.T) {
+	ls := loadshedder.New(loadshedder.Config{Limit: 1, WaitingLimit: 1})
+	reporter := &recordingReporter{}
+	app := newApp(ls, WithReporter(reporter), WithDeadline(DeadlineFromHeader("X-Timeout-Ms", time.Millisecond)))
+
+	_, token := ls.Acquire(context.Background())
+	defer ls.Release(token)
+
+	// The request waits until its deadline, not forever
+	r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	r.Header.Set("X-Timeout-Ms", "20")
+	start := time.Now()
+	resp, _ := serve(t, app, r)
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected a 429, got %d", resp.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the wait to end with the deadline, waited %v", elapsed)
+	}
+	if len(reporter.rejected) != 1 || reporter.rejected[0].RejectReason != loadshedder.RejectCanceled {
+		t.Errorf("expected a rejection of the deadline, got %+v", reporter.rejected)
+	}
+}
+
+func TestNew_HeaderPriority(t *testing.T) {
+	ls := loadshedder.New(loadshedder.Config{Limit: 1})
+	app := newApp(ls, WithHeaderPriority())
+
+	r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	r.Header.Set(loadshedder.PriorityHeader, "critical")
+	if _, body := serve(t, app, r); body != "critical" {
+		t.Errorf("expected the critical priority, got %q", body)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	r.Header.Set(loadshedder.RFC9218PriorityHeader, "u=0")
+	if _, body := serve(t, app, r); body != "critical" {
+		t.Errorf("expected the critical priority of the RFC 9218 header, got %q", body)
+	}
+}
+
+func TestNew_Bypass(t *testing.T) {
+	ls := loadshedder.New(loadshedder.Config{Limit: 1})
+	app := fiber.New()
+	app.Use(New(ls, WithBypass(func(c *fiber.Ctx) bool { return c.Path() == "/health" })))
+	app.Get("/health", func(c *fiber.Ctx) error { return c.SendString("ok") })
+
+	_, token := ls.Acquire(context.Background())
+	defer ls.Release(token)
+
+	if resp, body := serve(t, app, httptest.NewRequest(http.MethodGet, "/health", http.NoBody)); resp.StatusCode != http.StatusOK || body != "ok" {
+		t.Errorf("expected the bypassed request to be served, got %d %q", resp.StatusCode, body)
+	}
+}
+
+func TestDeadlineFromHeader_InvalidUnit(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	DeadlineFromHeader("X-Timeout-Ms", 0)
+}
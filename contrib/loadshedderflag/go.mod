@@ -0,0 +1,15 @@
+module github.com/pior/loadshedder/contrib/loadshedderflag
+
+go 1.24.0
+
+require (
+	github.com/open-feature/go-sdk v1.14.1
+	github.com/pior/loadshedder v0.1.0
+)
+
+require (
+	github.com/go-logr/logr v1.4.2 // indirect
+	golang.org/x/exp v0.0.0-20240506185415-9bf2ced13842 // indirect
+)
+
+replace github.com/pior/loadshedder => ../../
@@ -0,0 +1,48 @@
+// Package loadshedderflag drives loadshedder limits from OpenFeature flags.
+package loadshedderflag
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	"github.com/pior/loadshedder"
+)
+
+// evaluationTimeout bounds each flag evaluation, so a slow provider cannot
+// stall the limit watcher.
+const evaluationTimeout = 5 * time.Second
+
+// IntEvaluator evaluates integer flags, implemented by *openfeature.Client.
+type IntEvaluator interface {
+	IntValue(ctx context.Context, flag string, defaultValue int64, evalCtx openfeature.EvaluationContext, options ...openfeature.Option) (int64, error)
+}
+
+// LimitSource returns a source for loadshedder.LimitWatchConfig evaluating
+// the integer flag with the client. When the evaluation fails, the error is
+// logged and the source returns 0, so the current limit is kept.
+func LimitSource(client IntEvaluator, flag string, evalCtx openfeature.EvaluationContext) func() int64 {
+	return func() int64 {
+		ctx, cancel := context.WithTimeout(context.Background(), evaluationTimeout)
+		defer cancel()
+
+		limit, err := client.IntValue(ctx, flag, 0, evalCtx)
+		if err != nil {
+			slog.Default().Warn("loadshedderflag: flag evaluation failed", "flag", flag, "error", err)
+			return 0
+		}
+		return limit
+	}
+}
+
+// WatchLimit makes the limit of the loadshedder follow the integer flag,
+// polled every interval (0 for the loadshedder.WatchLimit default), and calls
+// onChange (optional) after each change. Call the returned function to stop.
+func WatchLimit(ls *loadshedder.Loadshedder, client IntEvaluator, flag string, interval time.Duration, onChange func(previous, limit int64)) (stop func()) {
+	return ls.WatchLimit(loadshedder.LimitWatchConfig{
+		Source:   LimitSource(client, flag, openfeature.NewTargetlessEvaluationContext(map[string]any{"loadshedder": ls.Stats().Name})),
+		Interval: interval,
+		OnChange: onChange,
+	})
+}
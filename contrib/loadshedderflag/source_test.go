@@ -0,0 +1,60 @@
+package loadshedderflag
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/open-feature/go-sdk/openfeature"
+	"github.com/pior/loadshedder"
+)
+
+type fakeEvaluator struct {
+	values map[string]int64
+	err    error
+	ctx    openfeature.EvaluationContext
+}
+
+func (f *fakeEvaluator) IntValue(_ context.Context, flag string, defaultValue int64, evalCtx openfeature.EvaluationContext, _ ...openfeature.Option) (int64, error) {
+	f.ctx = evalCtx
+	if f.err != nil {
+		return defaultValue, f.err
+	}
+	return f.values[flag], nil
+}
+
+func TestLimitSource(t *testing.T) {
+	client := &fakeEvaluator{values: map[string]int64{"api-limit": 42}}
+	source := LimitSource(client, "api-limit", openfeature.EvaluationContext{})
+
+	if limit := source(); limit != 42 {
+		t.Errorf("expected the flag value, got %d", limit)
+	}
+
+	client.err = errors.New("provider not ready")
+	if limit := source(); limit != 0 {
+		t.Errorf("expected 0 on evaluation errors, got %d", limit)
+	}
+}
+
+func TestWatchLimit(t *testing.T) {
+	ls := loadshedder.New(loadshedder.Config{Name: "api", Limit: 10})
+	client := &fakeEvaluator{values: map[string]int64{"api-limit": 25}}
+
+	var changes [][2]int64
+	stop := WatchLimit(ls, client, "api-limit", time.Hour, func(previous, limit int64) {
+		changes = append(changes, [2]int64{previous, limit})
+	})
+	defer stop()
+
+	if limit := ls.Stats().ConfiguredLimit; limit != 25 {
+		t.Errorf("expected the limit from the flag, got %d", limit)
+	}
+	if len(changes) != 1 || changes[0] != [2]int64{10, 25} {
+		t.Errorf("expected one change from 10 to 25, got %v", changes)
+	}
+	if name := client.ctx.Attribute("loadshedder"); name != "api" {
+		t.Errorf("expected the loadshedder name in the evaluation context, got %v", name)
+	}
+}
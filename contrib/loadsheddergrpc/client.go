@@ -0,0 +1,38 @@
+package loadsheddergrpc
+
+import (
+	"context"
+
+	"github.com/pior/loadshedder"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// UnaryClientInterceptor sets the x-request-priority metadata of outgoing
+// calls from the priority of their context (see loadshedder.ContextWithPriority),
+// so the criticality of a request is preserved across hops. Calls with an
+// explicit x-request-priority or the default priority are sent unchanged.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(outgoingPriority(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor is UnaryClientInterceptor for streaming calls.
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(outgoingPriority(ctx), desc, cc, method, opts...)
+	}
+}
+
+func outgoingPriority(ctx context.Context) context.Context {
+	p := loadshedder.PriorityFromContext(ctx)
+	if p == loadshedder.PriorityDefault {
+		return ctx
+	}
+
+	if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md.Get(loadshedder.PriorityMetadataKey)) > 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, loadshedder.PriorityMetadataKey, p.String())
+}
@@ -0,0 +1,55 @@
+package loadsheddergrpc
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pior/loadshedder"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	ls, tokens := sampledTokens(10)
+	client := startServerWithClient(t,
+		[]grpc.DialOption{grpc.WithUnaryInterceptor(UnaryClientInterceptor())},
+		grpc.UnaryInterceptor(UnaryServerInterceptor(ls, WithMetadataPriority())),
+	)
+
+	// The priority of the context is propagated
+	ctx := loadshedder.ContextWithPriority(context.Background(), loadshedder.PriorityHigh)
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("expected the call to succeed, got %v", err)
+	}
+	if token := <-tokens; token.Priority() != loadshedder.PriorityHigh {
+		t.Errorf("expected high priority, got %s", token.Priority())
+	}
+
+	// Explicit metadata wins
+	ctx = metadata.AppendToOutgoingContext(ctx, loadshedder.PriorityMetadataKey, "sheddable")
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("expected the call to succeed, got %v", err)
+	}
+	if token := <-tokens; token.Priority() != loadshedder.PrioritySheddable {
+		t.Errorf("expected sheddable priority, got %s", token.Priority())
+	}
+}
+
+func TestUnaryServerInterceptor_InheritPriority(t *testing.T) {
+	ls := loadshedder.New(loadshedder.Config{Limit: 1})
+	interceptor := UnaryServerInterceptor(ls, WithMetadataPriority())
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(loadshedder.PriorityMetadataKey, "critical"))
+	var inherited loadshedder.Priority
+	_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{}, func(ctx context.Context, req any) (any, error) {
+		inherited = loadshedder.PriorityFromContext(ctx)
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inherited != loadshedder.PriorityCritical {
+		t.Errorf("expected the handler context to carry the critical priority, got %s", inherited)
+	}
+}
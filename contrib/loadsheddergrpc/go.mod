@@ -0,0 +1,18 @@
+module github.com/pior/loadshedder/contrib/loadsheddergrpc
+
+go 1.24.0
+
+require (
+	github.com/pior/loadshedder v0.1.0
+	google.golang.org/grpc v1.71.0
+)
+
+require (
+	golang.org/x/net v0.34.0 // indirect
+	golang.org/x/sys v0.29.0 // indirect
+	golang.org/x/text v0.21.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20250115164207-1a7da9e5054f // indirect
+	google.golang.org/protobuf v1.36.4 // indirect
+)
+
+replace github.com/pior/loadshedder => ../../
@@ -0,0 +1,153 @@
+// Package loadsheddergrpc provides gRPC interceptors for loadshedder,
+// and a Shared helper accounting HTTP and gRPC traffic in a single limiter.
+package loadsheddergrpc
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/pior/loadshedder"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Source is the traffic source of gRPC acquisitions, see loadshedder.WithSource.
+const Source = "grpc"
+
+// Option configures the interceptors.
+type Option func(*config)
+
+type config struct {
+	metadataPriority bool
+	reporter         Reporter
+}
+
+// Reporter provides observability hooks for the server interceptors,
+// the gRPC counterpart of loadshedder.Reporter.
+// Reporter panics are caught and logged, they do not fail the call.
+type Reporter interface {
+	// Accepted is called when a call is accepted, with its full method name
+	// (e.g. "/package.Service/Method").
+	Accepted(ctx context.Context, method string, stats loadshedder.Stats)
+
+	// Rejected is called when a call is rejected.
+	Rejected(ctx context.Context, method string, stats loadshedder.Stats)
+}
+
+// WithReporter reports accepted and rejected calls to the reporter.
+func WithReporter(reporter Reporter) Option {
+	return func(c *config) {
+		c.reporter = reporter
+	}
+}
+
+// WithMetadataPriority sets the priority of each call from the
+// x-request-priority metadata, see loadshedder.PriorityFromMetadata.
+// Metadata is set by clients: only enable it for internal callers.
+func WithMetadataPriority() Option {
+	return func(c *config) {
+		c.metadataPriority = true
+	}
+}
+
+func newConfig(opts []Option) config {
+	var c config
+	for _, opt := range opts {
+		opt(&c)
+	}
+	return c
+}
+
+// UnaryServerInterceptor limits the concurrency of unary calls.
+// Rejected calls fail with codes.ResourceExhausted.
+func UnaryServerInterceptor(ls *loadshedder.Loadshedder, opts ...Option) grpc.UnaryServerInterceptor {
+	c := newConfig(opts)
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		stats, token := ls.Acquire(ctx, c.acquireOptions(ctx)...)
+		defer ls.Release(token)
+
+		c.report(ctx, info.FullMethod, stats, token.Accepted())
+		if !token.Accepted() {
+			return nil, errRejected
+		}
+
+		return handler(inheritPriority(ctx, token), req)
+	}
+}
+
+// StreamServerInterceptor limits the concurrency of streaming calls, holding
+// the slot for the lifetime of the stream.
+// Rejected calls fail with codes.ResourceExhausted.
+func StreamServerInterceptor(ls *loadshedder.Loadshedder, opts ...Option) grpc.StreamServerInterceptor {
+	c := newConfig(opts)
+
+	return func(srv any, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := stream.Context()
+
+		stats, token := ls.Acquire(ctx, c.acquireOptions(ctx)...)
+		defer ls.Release(token)
+
+		c.report(ctx, info.FullMethod, stats, token.Accepted())
+		if !token.Accepted() {
+			return errRejected
+		}
+
+		if inherited := inheritPriority(ctx, token); inherited != ctx {
+			stream = &contextStream{ServerStream: stream, ctx: inherited}
+		}
+		return handler(srv, stream)
+	}
+}
+
+// inheritPriority sets the call priority in the handler context, so outgoing
+// calls and nested acquisitions inherit it, see loadshedder.ContextWithPriority.
+func inheritPriority(ctx context.Context, token *loadshedder.Token) context.Context {
+	if p := token.Priority(); p != loadshedder.PriorityFromContext(ctx) {
+		return loadshedder.ContextWithPriority(ctx, p)
+	}
+	return ctx
+}
+
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+var errRejected = status.Error(codes.ResourceExhausted, "loadshedder: too many requests")
+
+func (c config) acquireOptions(ctx context.Context) []loadshedder.AcquireOption {
+	opts := []loadshedder.AcquireOption{loadshedder.WithSource(Source)}
+
+	if c.metadataPriority {
+		md, _ := metadata.FromIncomingContext(ctx)
+		opts = append(opts, loadshedder.WithPriority(loadshedder.PriorityFromMetadata(md)))
+	}
+
+	return opts
+}
+
+// report calls the reporter, if any, isolating its panics.
+func (c config) report(ctx context.Context, method string, stats loadshedder.Stats, accepted bool) {
+	if c.reporter == nil {
+		return
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			slog.Default().Error("loadsheddergrpc: reporter panic", "method", method, "error", err)
+		}
+	}()
+
+	if accepted {
+		c.reporter.Accepted(ctx, method, stats)
+	} else {
+		c.reporter.Rejected(ctx, method, stats)
+	}
+}
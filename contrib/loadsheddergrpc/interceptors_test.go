@@ -0,0 +1,201 @@
+package loadsheddergrpc
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pior/loadshedder"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// startServer serves the health service behind the server options, and
+// returns a connected client.
+func startServer(t *testing.T, opts ...grpc.ServerOption) healthpb.HealthClient {
+	t.Helper()
+	return startServerWithClient(t, nil, opts...)
+}
+
+// startServerWithClient is startServer with client dial options.
+func startServerWithClient(t *testing.T, dialOpts []grpc.DialOption, opts ...grpc.ServerOption) healthpb.HealthClient {
+	t.Helper()
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(opts...)
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	dialOpts = append([]grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}, dialOpts...)
+	conn, err := grpc.NewClient("passthrough:///bufnet", dialOpts...)
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return healthpb.NewHealthClient(conn)
+}
+
+// sampledTokens returns a loadshedder passing every admitted token to the channel.
+func sampledTokens(limit int64) (*loadshedder.Loadshedder, chan *loadshedder.Token) {
+	tokens := make(chan *loadshedder.Token, 10)
+	ls := loadshedder.New(loadshedder.Config{
+		Limit:              limit,
+		SampleRate:         1,
+		SampleMaxPerSecond: 1000000,
+		SampleHook: func(_ loadshedder.Stats, token *loadshedder.Token) {
+			tokens <- token
+		},
+	})
+	return ls, tokens
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	ls, tokens := sampledTokens(1)
+	client := startServer(t, grpc.UnaryInterceptor(UnaryServerInterceptor(ls)))
+	ctx := context.Background()
+
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("expected the call to succeed, got %v", err)
+	}
+	if token := <-tokens; token.Source() != Source {
+		t.Errorf("expected source %q, got %q", Source, token.Source())
+	}
+
+	// Saturate the limiter
+	_, token := ls.Acquire(ctx)
+	defer ls.Release(token)
+	<-tokens
+
+	_, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("expected ResourceExhausted, got %v", err)
+	}
+
+	if stats := ls.Stats(); stats.Running != 1 || stats.Waiting != 0 {
+		t.Errorf("expected only the saturating request, got %+v", stats)
+	}
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	ls := loadshedder.New(loadshedder.Config{Limit: 1})
+	client := startServer(t, grpc.StreamInterceptor(StreamServerInterceptor(ls)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("failed to start stream: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("expected a status update, got %v", err)
+	}
+
+	// The stream holds the slot for its lifetime
+	if stats := ls.Stats(); stats.Running != 1 {
+		t.Errorf("expected the stream to hold a slot, got %+v", stats)
+	}
+
+	rejected, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("failed to start stream: %v", err)
+	}
+	if _, err := rejected.Recv(); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("expected ResourceExhausted, got %v", err)
+	}
+
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for ls.Stats().Running != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the slot to be released with the stream, got %+v", ls.Stats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWithMetadataPriority(t *testing.T) {
+	ls, tokens := sampledTokens(10)
+	client := startServer(t, grpc.UnaryInterceptor(UnaryServerInterceptor(ls, WithMetadataPriority())))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), loadshedder.PriorityMetadataKey, "critical")
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("expected the call to succeed, got %v", err)
+	}
+
+	if token := <-tokens; token.Priority() != loadshedder.PriorityCritical {
+		t.Errorf("expected critical priority, got %s", token.Priority())
+	}
+}
+
+type methodReporter struct {
+	mu       sync.Mutex
+	accepted []string
+	rejected []string
+}
+
+func (r *methodReporter) Accepted(_ context.Context, method string, _ loadshedder.Stats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.accepted = append(r.accepted, method)
+}
+
+func (r *methodReporter) Rejected(_ context.Context, method string, _ loadshedder.Stats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rejected = append(r.rejected, method)
+}
+
+type panickingReporter struct{}
+
+func (panickingReporter) Accepted(context.Context, string, loadshedder.Stats) { panic("boom") }
+func (panickingReporter) Rejected(context.Context, string, loadshedder.Stats) { panic("boom") }
+
+func TestWithReporter(t *testing.T) {
+	ls := loadshedder.New(loadshedder.Config{Limit: 1})
+	reporter := &methodReporter{}
+	client := startServer(t, grpc.UnaryInterceptor(UnaryServerInterceptor(ls, WithReporter(reporter))))
+	ctx := context.Background()
+
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("expected the call to succeed, got %v", err)
+	}
+
+	_, token := ls.Acquire(ctx)
+	defer ls.Release(token)
+	_, _ = client.Check(ctx, &healthpb.HealthCheckRequest{})
+
+	const method = "/grpc.health.v1.Health/Check"
+	reporter.mu.Lock()
+	defer reporter.mu.Unlock()
+	if len(reporter.accepted) != 1 || reporter.accepted[0] != method {
+		t.Errorf("expected the accepted call reported with its method, got %v", reporter.accepted)
+	}
+	if len(reporter.rejected) != 1 || reporter.rejected[0] != method {
+		t.Errorf("expected the rejected call reported with its method, got %v", reporter.rejected)
+	}
+}
+
+func TestWithReporter_Panics(t *testing.T) {
+	ls := loadshedder.New(loadshedder.Config{Limit: 1})
+	client := startServer(t, grpc.UnaryInterceptor(UnaryServerInterceptor(ls, WithReporter(panickingReporter{}))))
+
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Errorf("expected the call to succeed despite the reporter panic, got %v", err)
+	}
+}
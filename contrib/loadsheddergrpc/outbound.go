@@ -0,0 +1,75 @@
+package loadsheddergrpc
+
+import (
+	"context"
+	"sync"
+
+	"github.com/pior/loadshedder"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ClientSource is the traffic source of outbound call acquisitions, see loadshedder.WithSource.
+const ClientSource = "grpc-client"
+
+// UnaryClientLimiter limits the concurrency of outgoing unary calls, e.g. to
+// protect a fragile dependency. Calls wait in the loadshedder queue like
+// incoming requests, with the priority of their context; rejected calls fail
+// with codes.ResourceExhausted without reaching the server.
+// WithReporter reports the calls; WithMetadataPriority has no effect.
+func UnaryClientLimiter(ls *loadshedder.Loadshedder, opts ...Option) grpc.UnaryClientInterceptor {
+	c := newConfig(opts)
+
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, callOpts ...grpc.CallOption) error {
+		stats, token := ls.Acquire(ctx, loadshedder.WithSource(ClientSource))
+		defer ls.Release(token)
+
+		c.report(ctx, method, stats, token.Accepted())
+		if !token.Accepted() {
+			return errClientRejected
+		}
+
+		return invoker(ctx, method, req, reply, cc, callOpts...)
+	}
+}
+
+// StreamClientLimiter is UnaryClientLimiter for streaming calls. The slot is
+// held until the stream ends: RecvMsg returns an error (including io.EOF) or
+// the context of the call is done.
+func StreamClientLimiter(ls *loadshedder.Loadshedder, opts ...Option) grpc.StreamClientInterceptor {
+	c := newConfig(opts)
+
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, callOpts ...grpc.CallOption) (grpc.ClientStream, error) {
+		stats, token := ls.Acquire(ctx, loadshedder.WithSource(ClientSource), loadshedder.WithReleaseOnDone())
+
+		c.report(ctx, method, stats, token.Accepted())
+		if !token.Accepted() {
+			return nil, errClientRejected
+		}
+
+		stream, err := streamer(ctx, desc, cc, method, callOpts...)
+		if err != nil {
+			ls.Release(token)
+			return nil, err
+		}
+		return &releasingStream{ClientStream: stream, release: func() { ls.Release(token) }}, nil
+	}
+}
+
+// releasingStream releases the slot of a client stream once it ends.
+type releasingStream struct {
+	grpc.ClientStream
+	release func()
+	once    sync.Once
+}
+
+func (s *releasingStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.once.Do(s.release) // callers may keep receiving after the end
+	}
+	return err
+}
+
+var errClientRejected = status.Error(codes.ResourceExhausted, "loadshedder: too many outgoing calls")
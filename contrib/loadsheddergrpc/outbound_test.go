@@ -0,0 +1,65 @@
+package loadsheddergrpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pior/loadshedder"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryClientLimiter(t *testing.T) {
+	ls := loadshedder.New(loadshedder.Config{Limit: 1})
+	client := startServerWithClient(t, []grpc.DialOption{grpc.WithUnaryInterceptor(UnaryClientLimiter(ls))})
+	ctx := context.Background()
+
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("expected the call to succeed, got %v", err)
+	}
+	if stats := ls.Stats(); stats.Running != 0 {
+		t.Errorf("expected the slot to be released after the call, got %+v", stats)
+	}
+
+	// Saturate the limiter
+	_, token := ls.Acquire(ctx)
+	defer ls.Release(token)
+
+	_, err := client.Check(ctx, &healthpb.HealthCheckRequest{})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("expected ResourceExhausted, got %v", err)
+	}
+}
+
+func TestStreamClientLimiter(t *testing.T) {
+	ls := loadshedder.New(loadshedder.Config{Limit: 1})
+	client := startServerWithClient(t, []grpc.DialOption{grpc.WithStreamInterceptor(StreamClientLimiter(ls))})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatalf("failed to start stream: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("expected a status update, got %v", err)
+	}
+
+	// The stream holds the slot for its lifetime
+	if _, err := client.Watch(ctx, &healthpb.HealthCheckRequest{}); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("expected ResourceExhausted, got %v", err)
+	}
+
+	cancel()
+	deadline := time.Now().Add(time.Second)
+	for ls.Stats().Running != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the slot to be released with the stream, got %+v", ls.Stats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,72 @@
+package loadsheddergrpc
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/pior/loadshedder"
+	"google.golang.org/grpc"
+)
+
+// Shared accounts HTTP and gRPC traffic in a single loadshedder, for servers
+// exposing both protocols (e.g. gRPC with a gRPC-gateway). Acquisitions are
+// tagged with their protocol: "http" for HTTP requests and Source for gRPC calls.
+type Shared struct {
+	loadshedder *loadshedder.Loadshedder
+	middleware  *loadshedder.Middleware
+	options     []Option
+}
+
+// NewShared creates a Shared limiter. The reporter, rejectionHandler and
+// middlewareOptions configure the HTTP middleware, see loadshedder.NewMiddleware;
+// opts configure the gRPC interceptors.
+func NewShared(ls *loadshedder.Loadshedder, reporter loadshedder.Reporter, rejectionHandler loadshedder.RejectionHandler, middlewareOptions []loadshedder.MiddlewareOption, opts ...Option) *Shared {
+	return &Shared{
+		loadshedder: ls,
+		middleware:  loadshedder.NewMiddleware(ls, reporter, rejectionHandler, middlewareOptions...),
+		options:     opts,
+	}
+}
+
+// Loadshedder returns the shared loadshedder.
+func (s *Shared) Loadshedder() *loadshedder.Loadshedder {
+	return s.loadshedder
+}
+
+// Handler wraps an HTTP handler.
+func (s *Shared) Handler(next http.Handler) http.Handler {
+	return s.middleware.Handler(next)
+}
+
+// UnaryServerInterceptor limits unary gRPC calls.
+func (s *Shared) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return UnaryServerInterceptor(s.loadshedder, s.options...)
+}
+
+// StreamServerInterceptor limits streaming gRPC calls.
+func (s *Shared) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return StreamServerInterceptor(s.loadshedder, s.options...)
+}
+
+// ServerOptions returns the grpc.ServerOptions installing both interceptors.
+func (s *Shared) ServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(s.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(s.StreamServerInterceptor()),
+	}
+}
+
+// Route serves gRPC requests with grpcHandler (typically a *grpc.Server,
+// limited by its interceptors) and other requests with httpHandler wrapped
+// by the HTTP middleware, so each request is accounted exactly once.
+func (s *Shared) Route(grpcHandler, httpHandler http.Handler) http.Handler {
+	limited := s.Handler(httpHandler)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+			grpcHandler.ServeHTTP(w, r)
+			return
+		}
+		limited.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,87 @@
+package loadsheddergrpc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pior/loadshedder"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestShared(t *testing.T) {
+	ls, tokens := sampledTokens(1)
+	shared := NewShared(ls, nil, nil, nil)
+	client := startServer(t, shared.ServerOptions()...)
+
+	if shared.Loadshedder() != ls {
+		t.Error("expected the shared loadshedder")
+	}
+
+	// An HTTP request holds the only slot
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	handler := shared.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-finish
+	}))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	}()
+	<-started
+
+	if token := <-tokens; token.Source() != "http" {
+		t.Errorf("expected source http, got %q", token.Source())
+	}
+
+	// gRPC calls share the budget
+	_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("expected ResourceExhausted while HTTP holds the slot, got %v", err)
+	}
+
+	close(finish)
+	<-done
+
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Errorf("expected the call to succeed, got %v", err)
+	}
+	if token := <-tokens; token.Source() != Source {
+		t.Errorf("expected source %q, got %q", Source, token.Source())
+	}
+}
+
+func TestShared_Route(t *testing.T) {
+	ls := loadshedder.New(loadshedder.Config{Limit: 1})
+	shared := NewShared(ls, nil, nil, nil)
+
+	var grpcCalls, httpCalls int
+	handler := shared.Route(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { grpcCalls++ }),
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { httpCalls++ }),
+	)
+
+	// Saturate the limiter: only HTTP requests go through the middleware
+	_, token := ls.Acquire(context.Background())
+	defer ls.Release(token)
+
+	grpcReq := httptest.NewRequest(http.MethodPost, "/grpc.health.v1.Health/Check", http.NoBody)
+	grpcReq.ProtoMajor = 2
+	grpcReq.Header.Set("Content-Type", "application/grpc+proto")
+	handler.ServeHTTP(httptest.NewRecorder(), grpcReq)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	if grpcCalls != 1 {
+		t.Errorf("expected the gRPC request to reach the gRPC handler, got %d calls", grpcCalls)
+	}
+	if httpCalls != 0 || rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected the HTTP request to be limited, got %d calls and status %d", httpCalls, rec.Code)
+	}
+}
@@ -0,0 +1,232 @@
+// Package loadshedderotel provides OpenTelemetry tracing integration for loadshedder.
+package loadshedderotel
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pior/loadshedder"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope of the queue spans and metrics.
+const ScopeName = "github.com/pior/loadshedder/contrib/loadshedderotel"
+
+// QueueSpanName is the name of the span covering the wait in the queue.
+const QueueSpanName = "loadshedder.queue"
+
+// Attributes set on the active request span.
+const (
+	AttrDecision    = attribute.Key("loadshedder.decision") // "accepted" or "rejected"
+	AttrRejected    = attribute.Key("loadshedder.rejected")
+	AttrName        = attribute.Key("loadshedder.name")
+	AttrWaitTime    = attribute.Key("loadshedder.wait_time_ms")
+	AttrUtilization = attribute.Key("loadshedder.utilization")
+	AttrRunning     = attribute.Key("loadshedder.running")
+	AttrWaiting     = attribute.Key("loadshedder.waiting")
+	AttrLimit       = attribute.Key("loadshedder.limit")
+	AttrPriority    = attribute.Key("loadshedder.priority")
+	AttrTokenID     = attribute.Key("loadshedder.token_id") // accepted requests only, see loadshedder.TokenID
+	AttrReason      = attribute.Key("loadshedder.reason")   // rejected requests only, see loadshedder.RejectReason
+)
+
+// Reporter implements the loadshedder.Reporter interface by annotating the
+// active span of each request with the shedding decision, and recording the
+// time spent in the waiting queue as a child span.
+//
+// The span is the one started by the OpenTelemetry HTTP instrumentation
+// (otelhttp): install it outside of the loadshedder middleware, so the
+// request context carries the span when the loadshedder decides.
+//
+// The reporter also records metrics of the requests that waited for a slot
+// but were not admitted (cancelled, timed out or dropped): the wait time of
+// the admitted requests alone hides them.
+//   - loadshedder.queue.abandoned (counter)
+//   - loadshedder.queue.abandoned.wait_time (histogram, in seconds)
+//   - loadshedder.queue.abandonment_ratio (gauge, share of the recent
+//     requests that waited for a slot but were not admitted)
+//
+// Metrics of a named loadshedder have the loadshedder.name attribute.
+type Reporter struct {
+	tracer trace.Tracer
+
+	abandoned         metric.Int64Counter
+	abandonedWaitTime metric.Float64Histogram
+
+	mu          sync.Mutex
+	abandonment map[string]float64 // smoothed abandonment ratio by loadshedder name
+}
+
+// abandonmentSmoothing is the weight of each queued request in the
+// abandonment ratio: the ratio follows the last few dozen queued requests.
+const abandonmentSmoothing = 0.05
+
+// Option configures a Reporter.
+type Option func(*options)
+
+type options struct {
+	provider      trace.TracerProvider
+	meterProvider metric.MeterProvider
+}
+
+// WithTracerProvider creates the queue spans with the provider instead of
+// the global one.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(o *options) {
+		o.provider = provider
+	}
+}
+
+// WithMeterProvider creates the metrics with the provider instead of the
+// global one.
+func WithMeterProvider(provider metric.MeterProvider) Option {
+	return func(o *options) {
+		o.meterProvider = provider
+	}
+}
+
+// NewReporter creates an OpenTelemetry reporter.
+func NewReporter(opts ...Option) *Reporter {
+	o := options{provider: otel.GetTracerProvider(), meterProvider: otel.GetMeterProvider()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	r := &Reporter{
+		tracer:      o.provider.Tracer(ScopeName),
+		abandonment: map[string]float64{},
+	}
+
+	// Errors are reported to the global handler, and leave no-op instruments
+	meter := o.meterProvider.Meter(ScopeName)
+	var err error
+	r.abandoned, err = meter.Int64Counter("loadshedder.queue.abandoned",
+		metric.WithDescription("Requests that waited for a slot but were not admitted (cancelled, timed out or dropped)"),
+		metric.WithUnit("{request}"))
+	if err != nil {
+		otel.Handle(err)
+	}
+	r.abandonedWaitTime, err = meter.Float64Histogram("loadshedder.queue.abandoned.wait_time",
+		metric.WithDescription("Time spent waiting for a slot by the requests that were not admitted"),
+		metric.WithUnit("s"))
+	if err != nil {
+		otel.Handle(err)
+	}
+	_, err = meter.Float64ObservableGauge("loadshedder.queue.abandonment_ratio",
+		metric.WithDescription("Share of the recent requests that waited for a slot but were not admitted"),
+		metric.WithFloat64Callback(r.observeAbandonment))
+	if err != nil {
+		otel.Handle(err)
+	}
+
+	return r
+}
+
+// Accepted is called when a request is accepted.
+func (r *Reporter) Accepted(req *http.Request, stats loadshedder.Stats) {
+	r.Record(req.Context(), true, stats)
+}
+
+// Rejected is called when a request is rejected.
+func (r *Reporter) Rejected(req *http.Request, stats loadshedder.Stats) {
+	r.Record(req.Context(), false, stats)
+}
+
+// Record annotates the span of ctx with an acquisition, for integrations
+// other than the HTTP middleware (e.g. a gRPC reporter).
+// A request that waited for a slot gets a child span covering the wait,
+// ending with the decision: the queue then shows in the trace timeline.
+// Rejected requests are also marked with a span event.
+func (r *Reporter) Record(ctx context.Context, accepted bool, stats loadshedder.Stats) {
+	if stats.WaitTime > 0 {
+		r.recordQueueOutcome(ctx, accepted, stats)
+	}
+
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+
+	decision := "rejected"
+	if accepted {
+		decision = "accepted"
+	}
+	utilization := 0.0
+	if stats.Limit > 0 {
+		utilization = float64(stats.Running) / float64(stats.Limit)
+	}
+
+	attrs := []attribute.KeyValue{
+		AttrDecision.String(decision),
+		AttrRejected.Bool(!accepted),
+		AttrWaitTime.Float64(float64(stats.WaitTime.Microseconds()) / 1000),
+		AttrUtilization.Float64(utilization),
+		AttrRunning.Int64(stats.Running),
+		AttrWaiting.Int64(stats.Waiting),
+		AttrLimit.Int64(stats.Limit),
+		AttrPriority.String(stats.Priority.String()),
+	}
+	if stats.Name != "" {
+		attrs = append(attrs, AttrName.String(stats.Name))
+	}
+	if stats.TokenID != 0 {
+		attrs = append(attrs, AttrTokenID.String(stats.TokenID.String()))
+	}
+	if !accepted {
+		attrs = append(attrs, AttrReason.String(stats.RejectReason.String()))
+	}
+	span.SetAttributes(attrs...)
+
+	if stats.WaitTime > 0 {
+		end := time.Now()
+		_, queue := r.tracer.Start(ctx, QueueSpanName,
+			trace.WithTimestamp(end.Add(-stats.WaitTime)),
+			trace.WithAttributes(AttrDecision.String(decision)),
+		)
+		queue.End(trace.WithTimestamp(end))
+	}
+
+	if !accepted {
+		span.AddEvent("loadshedder.rejected", trace.WithAttributes(AttrWaitTime.Float64(float64(stats.WaitTime.Microseconds())/1000)))
+	}
+}
+
+// recordQueueOutcome records the metrics of a request that waited for a slot.
+func (r *Reporter) recordQueueOutcome(ctx context.Context, accepted bool, stats loadshedder.Stats) {
+	outcome := 1.0
+	if accepted {
+		outcome = 0
+	} else {
+		attrs := metric.WithAttributes(nameAttributes(stats.Name)...)
+		r.abandoned.Add(ctx, 1, attrs)
+		r.abandonedWaitTime.Record(ctx, stats.WaitTime.Seconds(), attrs)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.abandonment[stats.Name] += abandonmentSmoothing * (outcome - r.abandonment[stats.Name])
+}
+
+// observeAbandonment reports the abandonment ratio of each loadshedder.
+func (r *Reporter) observeAbandonment(_ context.Context, observer metric.Float64Observer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for name, ratio := range r.abandonment {
+		observer.Observe(ratio, metric.WithAttributes(nameAttributes(name)...))
+	}
+	return nil
+}
+
+// nameAttributes returns the metric attributes of a loadshedder.
+func nameAttributes(name string) []attribute.KeyValue {
+	if name == "" {
+		return nil
+	}
+	return []attribute.KeyValue{AttrName.String(name)}
+}
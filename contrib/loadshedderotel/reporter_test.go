@@ -0,0 +1,151 @@
+package loadshedderotel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pior/loadshedder"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newTestReporter() (*Reporter, *sdktrace.TracerProvider, *tracetest.SpanRecorder) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	return NewReporter(WithTracerProvider(provider)), provider, recorder
+}
+
+func attributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	return attrs
+}
+
+func TestReporter_Accepted(t *testing.T) {
+	reporter, provider, recorder := newTestReporter()
+
+	ctx, span := provider.Tracer("test").Start(t.Context(), "http.request")
+	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody).WithContext(ctx)
+	reporter.Accepted(req, loadshedder.Stats{Name: "api", Running: 5, Limit: 10, TokenID: 42, Priority: loadshedder.PriorityHigh})
+	span.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("expected only the request span without waiting, got %d spans", len(spans))
+	}
+	attrs := attributes(spans[0])
+	if attrs[AttrDecision].AsString() != "accepted" || attrs[AttrRejected].AsBool() {
+		t.Errorf("expected the accepted decision, got %v", attrs)
+	}
+	if attrs[AttrName].AsString() != "api" || attrs[AttrUtilization].AsFloat64() != 0.5 || attrs[AttrPriority].AsString() != "high" {
+		t.Errorf("unexpected attributes: %v", attrs)
+	}
+	if attrs[AttrTokenID].AsString() != "16" {
+		t.Errorf("expected the token ID, got %v", attrs[AttrTokenID])
+	}
+}
+
+func TestReporter_QueueSpan(t *testing.T) {
+	reporter, provider, recorder := newTestReporter()
+
+	ctx, span := provider.Tracer("test").Start(t.Context(), "http.request")
+	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody).WithContext(ctx)
+	reporter.Rejected(req, loadshedder.Stats{Limit: 10, WaitTime: 20 * time.Millisecond, RejectReason: loadshedder.RejectQueueTimeout})
+	span.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected a queue span and the request span, got %d spans", len(spans))
+	}
+
+	queue := spans[0]
+	if queue.Name() != QueueSpanName || queue.Parent().SpanID() != span.SpanContext().SpanID() {
+		t.Errorf("expected the queue span as a child of the request span, got %q", queue.Name())
+	}
+	if d := queue.EndTime().Sub(queue.StartTime()); d != 20*time.Millisecond {
+		t.Errorf("expected the queue span to cover the wait, got %v", d)
+	}
+	if attributes(queue)[AttrDecision].AsString() != "rejected" {
+		t.Errorf("expected the decision on the queue span, got %v", queue.Attributes())
+	}
+
+	request := spans[1]
+	if !attributes(request)[AttrRejected].AsBool() || attributes(request)[AttrReason].AsString() != "queue_timeout" {
+		t.Errorf("expected the request span marked rejected with the reason, got %v", request.Attributes())
+	}
+	if events := request.Events(); len(events) != 1 || events[0].Name != "loadshedder.rejected" {
+		t.Errorf("expected a rejection event, got %v", events)
+	}
+}
+
+func TestReporter_NoSpan(t *testing.T) {
+	reporter, _, recorder := newTestReporter()
+
+	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	reporter.Rejected(req, loadshedder.Stats{WaitTime: time.Millisecond})
+
+	if spans := recorder.Ended(); len(spans) != 0 {
+		t.Errorf("expected no span without a request span, got %d", len(spans))
+	}
+}
+
+func TestReporter_Middleware(t *testing.T) {
+	reporter, provider, recorder := newTestReporter()
+
+	ls := loadshedder.New(loadshedder.Config{Limit: 1})
+	handler := loadshedder.NewMiddleware(ls, reporter, nil).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	ctx, span := provider.Tracer("test").Start(t.Context(), "http.request")
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody).WithContext(ctx))
+	span.End()
+
+	if spans := recorder.Ended(); len(spans) != 1 || attributes(spans[0])[AttrDecision].AsString() != "accepted" {
+		t.Errorf("expected the request span annotated, got %v", spans)
+	}
+}
+
+func TestReporter_QueueAbandonment(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	reporter := NewReporter(WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))))
+
+	// Recorded without a request span
+	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	reporter.Rejected(req, loadshedder.Stats{Name: "api"})                                   // hard rejection, never queued
+	reporter.Accepted(req, loadshedder.Stats{Name: "api", WaitTime: 10 * time.Millisecond})  // admitted after waiting
+	reporter.Rejected(req, loadshedder.Stats{Name: "api", WaitTime: 500 * time.Millisecond}) // abandoned
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(t.Context(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	metrics := map[string]metricdata.Aggregation{}
+	for _, scope := range rm.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			metrics[m.Name] = m.Data
+		}
+	}
+
+	abandoned, ok := metrics["loadshedder.queue.abandoned"].(metricdata.Sum[int64])
+	if !ok || len(abandoned.DataPoints) != 1 || abandoned.DataPoints[0].Value != 1 {
+		t.Errorf("expected 1 abandoned request, got %+v", metrics["loadshedder.queue.abandoned"])
+	} else if name, _ := abandoned.DataPoints[0].Attributes.Value(AttrName); name.AsString() != "api" {
+		t.Errorf("expected the name attribute, got %v", abandoned.DataPoints[0].Attributes)
+	}
+
+	waitTime, ok := metrics["loadshedder.queue.abandoned.wait_time"].(metricdata.Histogram[float64])
+	if !ok || len(waitTime.DataPoints) != 1 || waitTime.DataPoints[0].Sum != 0.5 {
+		t.Errorf("expected the abandoned wait time, got %+v", metrics["loadshedder.queue.abandoned.wait_time"])
+	}
+
+	ratio, ok := metrics["loadshedder.queue.abandonment_ratio"].(metricdata.Gauge[float64])
+	if !ok || len(ratio.DataPoints) != 1 || ratio.DataPoints[0].Value != abandonmentSmoothing {
+		t.Errorf("expected an abandonment ratio of %v, got %+v", abandonmentSmoothing, metrics["loadshedder.queue.abandonment_ratio"])
+	}
+}
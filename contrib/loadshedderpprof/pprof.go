@@ -0,0 +1,64 @@
+// Package loadshedderpprof guards the net/http/pprof endpoints with a tiny
+// dedicated loadshedder, so profiling during an incident cannot worsen the
+// overload: profiles are expensive (a CPU profile runs for 30s by default),
+// and concurrent profiles are rejected instead of piling up.
+//
+// Importing net/http/pprof registers its unguarded handlers on
+// http.DefaultServeMux: do not serve DefaultServeMux to the outside.
+package loadshedderpprof
+
+import (
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/pior/loadshedder"
+)
+
+// Config configures the guard.
+type Config struct {
+	// Limit is the number of profiling requests served concurrently. Further
+	// requests are rejected without waiting.
+	// Optional, default to 1.
+	Limit int64
+
+	// Name is the name of the loadshedder, for its Stats and reporters.
+	// Optional, default to "pprof".
+	Name string
+
+	// Reporter receives the decisions of the guard.
+	// Optional.
+	Reporter loadshedder.Reporter
+}
+
+// Handler returns the net/http/pprof handlers guarded by a loadshedder,
+// serving the /debug/pprof/ paths like http.DefaultServeMux does after
+// importing net/http/pprof.
+func Handler(cfg Config) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return Guard(mux, cfg)
+}
+
+// Guard wraps a handler with a dedicated loadshedder, e.g. custom profiling
+// or debugging endpoints.
+func Guard(next http.Handler, cfg Config) http.Handler {
+	if cfg.Limit <= 0 {
+		cfg.Limit = 1
+	}
+	if cfg.Name == "" {
+		cfg.Name = "pprof"
+	}
+
+	// No waiting queue: a profile holds its slot for seconds
+	ls := loadshedder.New(loadshedder.Config{Name: cfg.Name, Limit: cfg.Limit})
+	return loadshedder.NewMiddleware(ls, cfg.Reporter, nil).Handler(next)
+}
+
+// Register serves the guarded pprof handlers on the /debug/pprof/ paths of mux.
+func Register(mux *http.ServeMux, cfg Config) {
+	mux.Handle("/debug/pprof/", Handler(cfg))
+}
@@ -0,0 +1,73 @@
+package loadshedderpprof
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	Register(mux, Config{})
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/goroutine?debug=1", http.NoBody))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected the goroutine profile, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/cmdline", http.NoBody))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected the command line, got %d", rec.Code)
+	}
+}
+
+func TestGuard(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := Guard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}), Config{})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	}()
+	<-started
+
+	// A second profile is rejected while the first one runs
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected a rejection, got %d", rec.Code)
+	}
+
+	close(release)
+	<-done
+}
+
+func TestGuard_Limit(t *testing.T) {
+	running := make(chan struct{}, 2)
+	release := make(chan struct{})
+	handler := Guard(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		running <- struct{}{}
+		<-release
+	}), Config{Limit: 2})
+
+	done := make(chan struct{}, 2)
+	for range 2 {
+		go func() {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+			done <- struct{}{}
+		}()
+	}
+	<-running
+	<-running
+
+	close(release)
+	<-done
+	<-done
+}
@@ -3,6 +3,9 @@ package loadshedderprom
 
 import (
 	"net/http"
+	"slices"
+	"sync"
+	"time"
 
 	"github.com/pior/loadshedder"
 	"github.com/prometheus/client_golang/prometheus"
@@ -15,57 +18,283 @@ type Reporter struct {
 	// Counter metrics
 	requestsAccepted prometheus.Counter
 	requestsRejected prometheus.Counter
+	admissions       *prometheus.CounterVec
+	rejections       *prometheus.CounterVec
 
 	// Gauge for current state
 	concurrencyRunning prometheus.Gauge
 	concurrencyWaiting prometheus.Gauge
 	concurrencyLimit   prometheus.Gauge
+	configuredLimit    prometheus.Gauge
 	utilizationRatio   prometheus.Gauge
 
 	// Histogram for wait time distribution
 	waitTimeSeconds prometheus.Histogram
+
+	// Requests that waited for a slot but were not admitted
+	queueAbandoned           prometheus.Counter
+	abandonedWaitTimeSeconds prometheus.Histogram
+	queueAbandonmentRatio    prometheus.Gauge
+	abandonmentMu            sync.Mutex
+	abandonment              float64
+
+	// Time spent in the middleware itself, see loadshedder.OverheadReporter
+	overheadSeconds prometheus.Histogram
+
+	// Breakdown by priority, see WithPriorities
+	priorities              []loadshedder.Priority
+	priorityAccepted        *prometheus.CounterVec
+	priorityRejected        *prometheus.CounterVec
+	priorityWaitTimeSeconds *prometheus.HistogramVec
+
+	// Breakdown by label, see WithLabels
+	labelAccepted *prometheus.CounterVec
+	labelRejected *prometheus.CounterVec
+}
+
+// abandonmentSmoothing is the weight of each queued request in the
+// abandonment ratio: the ratio follows the last few dozen queued requests.
+const abandonmentSmoothing = 0.05
+
+// OtherPriority is the priority label of the priorities not listed in
+// WithPriorities, keeping the label set bounded.
+const OtherPriority = "other"
+
+// Option configures a Reporter.
+type Option func(*options)
+
+type options struct {
+	priorities  []loadshedder.Priority
+	labelName   string
+	subsystem   string
+	constLabels prometheus.Labels
+	registerer  prometheus.Registerer
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithPriorities adds metrics labeled by priority, for the listed priorities
+// (the standard ones if none are listed): the other priorities are labeled
+// OtherPriority. Use it with Config.PriorityAdmission, to verify that the
+// less important traffic is shed first.
+func WithPriorities(priorities ...loadshedder.Priority) Option {
+	return func(o *options) {
+		if len(priorities) == 0 {
+			priorities = []loadshedder.Priority{
+				loadshedder.PrioritySheddable,
+				loadshedder.PriorityDefault,
+				loadshedder.PriorityHigh,
+				loadshedder.PriorityCritical,
+			}
+		}
+		o.priorities = priorities
+	}
+}
+
+// WithLabels adds metrics labeled by the label of the requests
+// (loadshedder.Stats.Label, e.g. the route set by loadshedder.WithRequestLabel),
+// under the label name (e.g. "route"), for per-route accept and reject
+// metrics. Requests without a label are not counted. The labels are bounded
+// by loadshedder.Config.MaxLabels.
+func WithLabels(name string) Option {
+	return func(o *options) {
+		o.labelName = name
+	}
+}
+
+// WithSubsystem sets the subsystem of the metric names, between the
+// namespace and the name (e.g. "http" -> "myapp_http_concurrency_running").
+func WithSubsystem(subsystem string) Option {
+	return func(o *options) {
+		o.subsystem = subsystem
+	}
+}
+
+// WithConstLabels adds constant labels to all metrics (e.g. handler="api").
+// With the namespace, the subsystem and the labels of the application HTTP
+// metrics (e.g. instrumented with promhttp), the loadshedder series sit
+// next to the request series in the existing dashboards and queries.
+func WithConstLabels(labels prometheus.Labels) Option {
+	return func(o *options) {
+		o.constLabels = labels
+	}
+}
+
+// WithRegisterer registers the metrics with the registerer, e.g. the
+// registry of the application HTTP metrics.
+// Optional, default to prometheus.DefaultRegisterer.
+func WithRegisterer(registerer prometheus.Registerer) Option {
+	return func(o *options) {
+		o.registerer = registerer
+	}
 }
 
 // NewReporter creates a new Prometheus-based reporter with loadshedder metrics.
 // The namespace parameter is used to prefix all metric names (e.g., "myapp" -> "myapp_requests_accepted_total").
-func NewReporter(namespace string) *Reporter {
+func NewReporter(namespace string, opts ...Option) *Reporter {
+	registerer := newOptions(opts).registerer
+	if registerer == nil {
+		registerer = prometheus.DefaultRegisterer
+	}
+	return newReporter(promauto.With(registerer), namespace, opts...)
+}
+
+func newReporter(factory promauto.Factory, namespace string, opts ...Option) *Reporter {
+	o := newOptions(opts)
+
 	r := &Reporter{
-		requestsAccepted: promauto.NewCounter(prometheus.CounterOpts{
-			Namespace: namespace,
-			Name:      "requests_accepted_total",
-			Help:      "Total number of requests accepted by the loadshedder",
+		requestsAccepted: factory.NewCounter(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   o.subsystem,
+			ConstLabels: o.constLabels,
+			Name:        "requests_accepted_total",
+			Help:        "Total number of requests accepted by the loadshedder",
+		}),
+		requestsRejected: factory.NewCounter(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   o.subsystem,
+			ConstLabels: o.constLabels,
+			Name:        "requests_rejected_total",
+			Help:        "Total number of requests rejected by the loadshedder due to capacity",
 		}),
-		requestsRejected: promauto.NewCounter(prometheus.CounterOpts{
-			Namespace: namespace,
-			Name:      "requests_rejected_total",
-			Help:      "Total number of requests rejected by the loadshedder due to capacity",
+		admissions: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   o.subsystem,
+			ConstLabels: o.constLabels,
+			Name:        "admissions_total",
+			Help:        "Total number of requests accepted, by path: fast (without waiting) or queued (after waiting for a slot)",
+		}, []string{"path"}),
+		rejections: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   o.subsystem,
+			ConstLabels: o.constLabels,
+			Name:        "rejections_total",
+			Help:        "Total number of requests rejected, by reason (see loadshedder.RejectReason): queue_full, queue_timeout, canceled, deadline, dropped or stopped",
+		}, []string{"reason"}),
+		concurrencyRunning: factory.NewGauge(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   o.subsystem,
+			ConstLabels: o.constLabels,
+			Name:        "concurrency_running",
+			Help:        "Current number of running requests",
 		}),
-		concurrencyRunning: promauto.NewGauge(prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "concurrency_running",
-			Help:      "Current number of running requests",
+		concurrencyWaiting: factory.NewGauge(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   o.subsystem,
+			ConstLabels: o.constLabels,
+			Name:        "concurrency_waiting",
+			Help:        "Current number of requests waiting for a slot",
 		}),
-		concurrencyWaiting: promauto.NewGauge(prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "concurrency_waiting",
-			Help:      "Current number of requests waiting for a slot",
+		concurrencyLimit: factory.NewGauge(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   o.subsystem,
+			ConstLabels: o.constLabels,
+			Name:        "concurrency_limit",
+			Help:        "Concurrency limit currently enforced",
 		}),
-		concurrencyLimit: promauto.NewGauge(prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "concurrency_limit",
-			Help:      "Configured concurrency limit",
+		configuredLimit: factory.NewGauge(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   o.subsystem,
+			ConstLabels: o.constLabels,
+			Name:        "concurrency_limit_configured",
+			Help:        "Configured concurrency limit",
 		}),
-		utilizationRatio: promauto.NewGauge(prometheus.GaugeOpts{
-			Namespace: namespace,
-			Name:      "utilization_ratio",
-			Help:      "Current utilization ratio (running / limit)",
+		utilizationRatio: factory.NewGauge(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   o.subsystem,
+			ConstLabels: o.constLabels,
+			Name:        "utilization_ratio",
+			Help:        "Current utilization ratio (running / limit)",
 		}),
-		waitTimeSeconds: promauto.NewHistogram(prometheus.HistogramOpts{
+		waitTimeSeconds: factory.NewHistogram(prometheus.HistogramOpts{
 			Namespace:                   namespace,
+			Subsystem:                   o.subsystem,
+			ConstLabels:                 o.constLabels,
 			Name:                        "wait_time_seconds",
 			Help:                        "Time spent waiting for a slot (0 for immediate acceptance/rejection)",
 			NativeHistogramBucketFactor: 1.1,
 		}),
+		queueAbandoned: factory.NewCounter(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   o.subsystem,
+			ConstLabels: o.constLabels,
+			Name:        "queue_abandoned_total",
+			Help:        "Total number of requests that waited for a slot but were not admitted (cancelled, timed out or dropped)",
+		}),
+		abandonedWaitTimeSeconds: factory.NewHistogram(prometheus.HistogramOpts{
+			Namespace:                   namespace,
+			Subsystem:                   o.subsystem,
+			ConstLabels:                 o.constLabels,
+			Name:                        "abandoned_wait_time_seconds",
+			Help:                        "Time spent waiting for a slot by the requests that were not admitted",
+			NativeHistogramBucketFactor: 1.1,
+		}),
+		queueAbandonmentRatio: factory.NewGauge(prometheus.GaugeOpts{
+			Namespace:   namespace,
+			Subsystem:   o.subsystem,
+			ConstLabels: o.constLabels,
+			Name:        "queue_abandonment_ratio",
+			Help:        "Share of the recent requests that waited for a slot but were not admitted",
+		}),
+		overheadSeconds: factory.NewHistogram(prometheus.HistogramOpts{
+			Namespace:   namespace,
+			Subsystem:   o.subsystem,
+			ConstLabels: o.constLabels,
+			Name:        "overhead_seconds",
+			Help:        "Time spent in the loadshedder middleware itself, excluding the handler and the wait for a slot",
+			// From 1µs to 16ms: the overhead is expected in microseconds
+			Buckets:                     prometheus.ExponentialBuckets(1e-6, 2, 15),
+			NativeHistogramBucketFactor: 1.1,
+		}),
+	}
+
+	if o.priorities != nil {
+		r.priorities = o.priorities
+		r.priorityAccepted = factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   o.subsystem,
+			ConstLabels: o.constLabels,
+			Name:        "priority_requests_accepted_total",
+			Help:        "Total number of requests accepted by the loadshedder, by priority",
+		}, []string{"priority"})
+		r.priorityRejected = factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   o.subsystem,
+			ConstLabels: o.constLabels,
+			Name:        "priority_requests_rejected_total",
+			Help:        "Total number of requests rejected by the loadshedder, by priority",
+		}, []string{"priority"})
+		r.priorityWaitTimeSeconds = factory.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace:                   namespace,
+			Subsystem:                   o.subsystem,
+			ConstLabels:                 o.constLabels,
+			Name:                        "priority_wait_time_seconds",
+			Help:                        "Time spent waiting for a slot (0 for immediate acceptance/rejection), by priority",
+			NativeHistogramBucketFactor: 1.1,
+		}, []string{"priority"})
+	}
+
+	if o.labelName != "" {
+		r.labelAccepted = factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   o.subsystem,
+			ConstLabels: o.constLabels,
+			Name:        "labeled_requests_accepted_total",
+			Help:        "Total number of requests accepted by the loadshedder, by label",
+		}, []string{o.labelName})
+		r.labelRejected = factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace:   namespace,
+			Subsystem:   o.subsystem,
+			ConstLabels: o.constLabels,
+			Name:        "labeled_requests_rejected_total",
+			Help:        "Total number of requests rejected by the loadshedder, by label and reason",
+		}, []string{o.labelName, "reason"})
 	}
 
 	return r
@@ -74,21 +303,77 @@ func NewReporter(namespace string) *Reporter {
 // Accepted is called when a request is accepted.
 func (r *Reporter) Accepted(req *http.Request, stats loadshedder.Stats) {
 	r.requestsAccepted.Inc()
+	if stats.WaitTime > 0 {
+		r.admissions.WithLabelValues("queued").Inc()
+		r.recordQueueOutcome(false)
+	} else {
+		r.admissions.WithLabelValues("fast").Inc()
+	}
 	r.waitTimeSeconds.Observe(stats.WaitTime.Seconds())
+	if r.priorities != nil {
+		priority := r.priorityLabel(stats.Priority)
+		r.priorityAccepted.WithLabelValues(priority).Inc()
+		r.priorityWaitTimeSeconds.WithLabelValues(priority).Observe(stats.WaitTime.Seconds())
+	}
+	if r.labelAccepted != nil && stats.Label != "" {
+		r.labelAccepted.WithLabelValues(stats.Label).Inc()
+	}
 	r.updateGauges(stats)
 }
 
 // Rejected is called when a request is rejected.
 func (r *Reporter) Rejected(req *http.Request, stats loadshedder.Stats) {
 	r.requestsRejected.Inc()
+	r.rejections.WithLabelValues(stats.RejectReason.String()).Inc()
 	r.waitTimeSeconds.Observe(stats.WaitTime.Seconds())
+	if stats.WaitTime > 0 {
+		r.queueAbandoned.Inc()
+		r.abandonedWaitTimeSeconds.Observe(stats.WaitTime.Seconds())
+		r.recordQueueOutcome(true)
+	}
+	if r.priorities != nil {
+		priority := r.priorityLabel(stats.Priority)
+		r.priorityRejected.WithLabelValues(priority).Inc()
+		r.priorityWaitTimeSeconds.WithLabelValues(priority).Observe(stats.WaitTime.Seconds())
+	}
+	if r.labelRejected != nil && stats.Label != "" {
+		r.labelRejected.WithLabelValues(stats.Label, stats.RejectReason.String()).Inc()
+	}
 	r.updateGauges(stats)
 }
 
+// Overhead is called with the time spent in the middleware for a request,
+// see loadshedder.OverheadReporter.
+func (r *Reporter) Overhead(req *http.Request, overhead time.Duration) {
+	r.overheadSeconds.Observe(overhead.Seconds())
+}
+
+// recordQueueOutcome updates the abandonment ratio with the outcome of a
+// request that waited for a slot.
+func (r *Reporter) recordQueueOutcome(abandoned bool) {
+	outcome := 0.0
+	if abandoned {
+		outcome = 1
+	}
+
+	r.abandonmentMu.Lock()
+	defer r.abandonmentMu.Unlock()
+	r.abandonment += abandonmentSmoothing * (outcome - r.abandonment)
+	r.queueAbandonmentRatio.Set(r.abandonment)
+}
+
+func (r *Reporter) priorityLabel(p loadshedder.Priority) string {
+	if slices.Contains(r.priorities, p) {
+		return p.String()
+	}
+	return OtherPriority
+}
+
 func (r *Reporter) updateGauges(stats loadshedder.Stats) {
 	r.concurrencyRunning.Set(float64(stats.Running))
 	r.concurrencyWaiting.Set(float64(stats.Waiting))
 	r.concurrencyLimit.Set(float64(stats.Limit))
+	r.configuredLimit.Set(float64(stats.ConfiguredLimit))
 
 	if stats.Limit > 0 {
 		r.utilizationRatio.Set(float64(stats.Running) / float64(stats.Limit))
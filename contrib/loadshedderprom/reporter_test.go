@@ -3,11 +3,13 @@ package loadshedderprom
 import (
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/pior/loadshedder"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
@@ -15,48 +17,10 @@ func TestReporter_Accepted(t *testing.T) {
 	// Use a custom registry to avoid conflicts with global metrics
 	registry := prometheus.NewRegistry()
 
-	// Create reporter with custom registry
-	reporter := &Reporter{
-		requestsAccepted: prometheus.NewCounter(prometheus.CounterOpts{
-			Namespace: "test",
-			Name:      "requests_accepted_total",
-		}),
-		requestsRejected: prometheus.NewCounter(prometheus.CounterOpts{
-			Namespace: "test",
-			Name:      "requests_rejected_total",
-		}),
-		concurrencyRunning: prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace: "test",
-			Name:      "concurrency_running",
-		}),
-		concurrencyWaiting: prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace: "test",
-			Name:      "concurrency_waiting",
-		}),
-		concurrencyLimit: prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace: "test",
-			Name:      "concurrency_limit",
-		}),
-		utilizationRatio: prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace: "test",
-			Name:      "utilization_ratio",
-		}),
-		waitTimeSeconds: prometheus.NewHistogram(prometheus.HistogramOpts{
-			Namespace: "test",
-			Name:      "wait_time_seconds",
-		}),
-	}
-
-	registry.MustRegister(reporter.requestsAccepted)
-	registry.MustRegister(reporter.requestsRejected)
-	registry.MustRegister(reporter.concurrencyRunning)
-	registry.MustRegister(reporter.concurrencyWaiting)
-	registry.MustRegister(reporter.concurrencyLimit)
-	registry.MustRegister(reporter.utilizationRatio)
-	registry.MustRegister(reporter.waitTimeSeconds)
+	reporter := newReporter(promauto.With(registry), "test")
 
 	req := httptest.NewRequest(http.MethodGet, "/test", http.NoBody)
-	stats := loadshedder.Stats{Running: 5, Waiting: 2, Limit: 10, WaitTime: 50 * time.Millisecond}
+	stats := loadshedder.Stats{Running: 5, Waiting: 2, Limit: 10, ConfiguredLimit: 20, EffectiveLimit: 10, WaitTime: 50 * time.Millisecond}
 
 	reporter.Accepted(req, stats)
 
@@ -75,10 +39,22 @@ func TestReporter_Accepted(t *testing.T) {
 	if limit := testutil.ToFloat64(reporter.concurrencyLimit); limit != 10 {
 		t.Errorf("expected concurrencyLimit = 10, got %f", limit)
 	}
+	if limit := testutil.ToFloat64(reporter.configuredLimit); limit != 20 {
+		t.Errorf("expected configuredLimit = 20, got %f", limit)
+	}
 	if util := testutil.ToFloat64(reporter.utilizationRatio); util != 0.5 {
 		t.Errorf("expected utilizationRatio = 0.5, got %f", util)
 	}
 
+	// The request waited: it came through the queued path
+	if count := testutil.ToFloat64(reporter.admissions.WithLabelValues("queued")); count != 1 {
+		t.Errorf("expected 1 queued admission, got %f", count)
+	}
+	reporter.Accepted(req, loadshedder.Stats{Limit: 10})
+	if count := testutil.ToFloat64(reporter.admissions.WithLabelValues("fast")); count != 1 {
+		t.Errorf("expected 1 fast admission, got %f", count)
+	}
+
 	// Verify wait time histogram was updated (verify count is 1)
 	if count := testutil.CollectAndCount(reporter.waitTimeSeconds); count != 1 {
 		t.Errorf("expected 1 histogram metric, got %d", count)
@@ -89,47 +65,10 @@ func TestReporter_Rejected(t *testing.T) {
 	// Use a custom registry to avoid conflicts with global metrics
 	registry := prometheus.NewRegistry()
 
-	reporter := &Reporter{
-		requestsAccepted: prometheus.NewCounter(prometheus.CounterOpts{
-			Namespace: "test",
-			Name:      "requests_accepted_total",
-		}),
-		requestsRejected: prometheus.NewCounter(prometheus.CounterOpts{
-			Namespace: "test",
-			Name:      "requests_rejected_total",
-		}),
-		concurrencyRunning: prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace: "test",
-			Name:      "concurrency_running",
-		}),
-		concurrencyWaiting: prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace: "test",
-			Name:      "concurrency_waiting",
-		}),
-		concurrencyLimit: prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace: "test",
-			Name:      "concurrency_limit",
-		}),
-		utilizationRatio: prometheus.NewGauge(prometheus.GaugeOpts{
-			Namespace: "test",
-			Name:      "utilization_ratio",
-		}),
-		waitTimeSeconds: prometheus.NewHistogram(prometheus.HistogramOpts{
-			Namespace: "test",
-			Name:      "wait_time_seconds",
-		}),
-	}
-
-	registry.MustRegister(reporter.requestsAccepted)
-	registry.MustRegister(reporter.requestsRejected)
-	registry.MustRegister(reporter.concurrencyRunning)
-	registry.MustRegister(reporter.concurrencyWaiting)
-	registry.MustRegister(reporter.concurrencyLimit)
-	registry.MustRegister(reporter.utilizationRatio)
-	registry.MustRegister(reporter.waitTimeSeconds)
+	reporter := newReporter(promauto.With(registry), "test")
 
 	req := httptest.NewRequest(http.MethodPost, "/api/data", http.NoBody)
-	stats := loadshedder.Stats{Running: 10, Waiting: 5, Limit: 10, WaitTime: 0}
+	stats := loadshedder.Stats{Running: 10, Waiting: 5, Limit: 10, WaitTime: 0, RejectReason: loadshedder.RejectQueueFull}
 
 	reporter.Rejected(req, stats)
 
@@ -137,6 +76,9 @@ func TestReporter_Rejected(t *testing.T) {
 	if count := testutil.ToFloat64(reporter.requestsRejected); count != 1 {
 		t.Errorf("expected requestsRejected = 1, got %f", count)
 	}
+	if count := testutil.ToFloat64(reporter.rejections.WithLabelValues("queue_full")); count != 1 {
+		t.Errorf("expected rejections{reason=queue_full} = 1, got %f", count)
+	}
 
 	// Verify gauges were updated
 	if running := testutil.ToFloat64(reporter.concurrencyRunning); running != 10 {
@@ -174,3 +116,165 @@ func TestReporter_Integration(t *testing.T) {
 		t.Errorf("expected status 200, got %d", rec.Code)
 	}
 }
+
+func TestReporter_WithPriorities(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	reporter := newReporter(promauto.With(registry), "test", WithPriorities())
+
+	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	reporter.Accepted(req, loadshedder.Stats{Limit: 10, Priority: loadshedder.PriorityHigh, WaitTime: 10 * time.Millisecond})
+	reporter.Accepted(req, loadshedder.Stats{Limit: 10})
+	reporter.Rejected(req, loadshedder.Stats{Limit: 10, Priority: loadshedder.PrioritySheddable})
+	reporter.Rejected(req, loadshedder.Stats{Limit: 10, Priority: loadshedder.Priority(-5)})
+
+	for priority, expected := range map[string]float64{"high": 1, "default": 1, "sheddable": 0} {
+		if count := testutil.ToFloat64(reporter.priorityAccepted.WithLabelValues(priority)); count != expected {
+			t.Errorf("expected %v accepted %s requests, got %v", expected, priority, count)
+		}
+	}
+	for priority, expected := range map[string]float64{"sheddable": 1, OtherPriority: 1, "high": 0} {
+		if count := testutil.ToFloat64(reporter.priorityRejected.WithLabelValues(priority)); count != expected {
+			t.Errorf("expected %v rejected %s requests, got %v", expected, priority, count)
+		}
+	}
+	if count := testutil.CollectAndCount(reporter.priorityWaitTimeSeconds); count != 4 {
+		t.Errorf("expected a wait time histogram for 4 priorities, got %d", count)
+	}
+}
+
+func TestReporter_WithPriorities_Listed(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	reporter := newReporter(promauto.With(registry), "test", WithPriorities(loadshedder.PriorityHigh, loadshedder.Priority(5)))
+
+	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	reporter.Accepted(req, loadshedder.Stats{Priority: loadshedder.Priority(5)})
+	reporter.Accepted(req, loadshedder.Stats{Priority: loadshedder.PriorityDefault})
+
+	if count := testutil.ToFloat64(reporter.priorityAccepted.WithLabelValues("5")); count != 1 {
+		t.Errorf("expected 1 accepted request of priority 5, got %v", count)
+	}
+	if count := testutil.ToFloat64(reporter.priorityAccepted.WithLabelValues(OtherPriority)); count != 1 {
+		t.Errorf("expected 1 accepted request of an unlisted priority, got %v", count)
+	}
+}
+
+func TestReporter_WithoutPriorities(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	reporter := newReporter(promauto.With(registry), "test")
+
+	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	reporter.Accepted(req, loadshedder.Stats{Priority: loadshedder.PriorityHigh})
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if strings.Contains(family.GetName(), "priority") {
+			t.Errorf("unexpected metric %s", family.GetName())
+		}
+	}
+}
+
+func TestReporter_WithLabels(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	reporter := newReporter(promauto.With(registry), "test", WithLabels("route"))
+
+	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	reporter.Accepted(req, loadshedder.Stats{Limit: 10, Label: "/users/{id}"})
+	reporter.Accepted(req, loadshedder.Stats{Limit: 10})
+	reporter.Rejected(req, loadshedder.Stats{Limit: 10, Label: "/users/{id}", RejectReason: loadshedder.RejectQueueFull})
+
+	if count := testutil.ToFloat64(reporter.labelAccepted.WithLabelValues("/users/{id}")); count != 1 {
+		t.Errorf("expected 1 accepted request of the route, got %v", count)
+	}
+	if count := testutil.ToFloat64(reporter.labelRejected.WithLabelValues("/users/{id}", "queue_full")); count != 1 {
+		t.Errorf("expected 1 rejected request of the route, got %v", count)
+	}
+	if count := testutil.CollectAndCount(reporter.labelAccepted); count != 1 {
+		t.Errorf("expected the unlabeled request not to be counted, got %d series", count)
+	}
+}
+
+func TestReporter_SharedNamespace(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	labels := prometheus.Labels{"handler": "api"}
+
+	// The application HTTP metrics, instrumented with promhttp
+	inFlight := promauto.With(registry).NewGauge(prometheus.GaugeOpts{
+		Namespace:   "myapp",
+		Subsystem:   "http",
+		Name:        "in_flight_requests",
+		Help:        "Current number of requests being served",
+		ConstLabels: labels,
+	})
+	inFlight.Set(1)
+
+	reporter := NewReporter("myapp", WithSubsystem("http"), WithConstLabels(labels), WithRegisterer(registry))
+	reporter.Accepted(httptest.NewRequest(http.MethodGet, "/", http.NoBody), loadshedder.Stats{Running: 1, Limit: 10})
+
+	expected := `
+# HELP myapp_http_concurrency_running Current number of running requests
+# TYPE myapp_http_concurrency_running gauge
+myapp_http_concurrency_running{handler="api"} 1
+# HELP myapp_http_in_flight_requests Current number of requests being served
+# TYPE myapp_http_in_flight_requests gauge
+myapp_http_in_flight_requests{handler="api"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "myapp_http_concurrency_running", "myapp_http_in_flight_requests"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReporter_QueueAbandonment(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	reporter := newReporter(promauto.With(registry), "test")
+
+	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	reporter.Rejected(req, loadshedder.Stats{Limit: 10})                                   // hard rejection, never queued
+	reporter.Accepted(req, loadshedder.Stats{Limit: 10, WaitTime: 10 * time.Millisecond})  // admitted after waiting
+	reporter.Rejected(req, loadshedder.Stats{Limit: 10, WaitTime: 500 * time.Millisecond}) // abandoned
+
+	if count := testutil.ToFloat64(reporter.queueAbandoned); count != 1 {
+		t.Errorf("expected 1 abandoned request, got %v", count)
+	}
+	if count := testutil.CollectAndCount(reporter.abandonedWaitTimeSeconds); count != 1 {
+		t.Errorf("expected the abandoned wait time histogram, got %d", count)
+	}
+	if ratio := testutil.ToFloat64(reporter.queueAbandonmentRatio); ratio != abandonmentSmoothing {
+		t.Errorf("expected an abandonment ratio of %v, got %v", abandonmentSmoothing, ratio)
+	}
+
+	// The ratio converges to the share of abandoned requests
+	for range 500 {
+		reporter.Rejected(req, loadshedder.Stats{Limit: 10, WaitTime: time.Millisecond})
+	}
+	if ratio := testutil.ToFloat64(reporter.queueAbandonmentRatio); ratio < 0.99 {
+		t.Errorf("expected an abandonment ratio close to 1, got %v", ratio)
+	}
+}
+
+func TestReporter_Overhead(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	reporter := newReporter(promauto.With(registry), "test")
+
+	mw := loadshedder.NewMiddleware(loadshedder.New(loadshedder.Config{Limit: 1}), reporter, nil)
+	handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for range 3 {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var count uint64
+	for _, family := range families {
+		if family.GetName() == "test_overhead_seconds" {
+			count = family.GetMetric()[0].GetHistogram().GetSampleCount()
+		}
+	}
+	if count != 3 {
+		t.Errorf("expected 3 overhead observations, got %d", count)
+	}
+}
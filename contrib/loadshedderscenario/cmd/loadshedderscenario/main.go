@@ -0,0 +1,104 @@
+// Command loadshedderscenario generates a load-test scenario crossing the
+// capacity of a loadshedder, for k6 or vegeta, or the checklist of the
+// behaviors to expect:
+//
+//	go run ./cmd/loadshedderscenario -limit=100 -waiting=20 -max-wait=500ms \
+//		-latency=50ms -target=http://localhost:8080/api -format=k6 > scenario.js
+//	k6 run scenario.js
+//
+// The limits can also be read from a pool of a TopologyConfig JSON file with
+// -topology and -pool.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pior/loadshedder"
+	"github.com/pior/loadshedder/contrib/loadshedderscenario"
+)
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run() error {
+	var (
+		cfg         loadshedder.Config
+		opts        loadshedderscenario.Options
+		topology    = flag.String("topology", "", "TopologyConfig JSON file to read the limits from")
+		pool        = flag.String("pool", "", "pool of the topology, default to the first one")
+		format      = flag.String("format", "k6", "output: k6, vegeta or checklist")
+		multipliers = flag.String("multipliers", "0.5,1,1.5,2", "stage rates, relative to the capacity")
+	)
+	flag.Int64Var(&cfg.Limit, "limit", 0, "concurrency limit")
+	flag.Int64Var(&cfg.WaitingLimit, "waiting", 0, "waiting limit")
+	flag.DurationVar(&cfg.MaxQueueWait, "max-wait", 0, "maximum queue wait")
+	flag.StringVar(&opts.Target, "target", "", "URL of the endpoint under test (required)")
+	flag.DurationVar(&opts.Latency, "latency", 0, "expected latency of the endpoint without contention (required)")
+	flag.DurationVar(&opts.StageDuration, "stage", 30*time.Second, "duration of each stage")
+	flag.DurationVar(&opts.Ramp, "ramp", 10*time.Second, "ramp between the stages (k6 only)")
+	flag.Parse()
+
+	if *topology != "" {
+		var err error
+		if cfg, err = readPool(*topology, *pool); err != nil {
+			return err
+		}
+	}
+
+	for _, value := range strings.Split(*multipliers, ",") {
+		multiplier, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return fmt.Errorf("invalid multiplier %q: %w", value, err)
+		}
+		opts.Multipliers = append(opts.Multipliers, multiplier)
+	}
+
+	scenario, err := loadshedderscenario.New(cfg, opts)
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "k6":
+		return scenario.WriteK6(os.Stdout)
+	case "vegeta":
+		return scenario.WriteVegeta(os.Stdout)
+	case "checklist":
+		return scenario.WriteChecklist(os.Stdout)
+	}
+	return fmt.Errorf("unknown format %q", *format)
+}
+
+// readPool reads the limits of a pool of a TopologyConfig JSON file.
+func readPool(path, name string) (loadshedder.Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return loadshedder.Config{}, err
+	}
+	var topology loadshedder.TopologyConfig
+	if err := json.Unmarshal(data, &topology); err != nil {
+		return loadshedder.Config{}, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	for _, spec := range topology.Pools {
+		if name == "" || spec.Name == name {
+			return loadshedder.Config{
+				Name:         spec.Name,
+				Limit:        spec.Limit,
+				WaitingLimit: spec.WaitingLimit,
+				MaxQueueWait: time.Duration(spec.MaxQueueWait),
+			}, nil
+		}
+	}
+	return loadshedder.Config{}, fmt.Errorf("pool %q not found in %s", name, path)
+}
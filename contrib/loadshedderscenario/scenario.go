@@ -0,0 +1,266 @@
+// Package loadshedderscenario generates load-test scenarios from a
+// loadshedder configuration, for k6 or vegeta, with the checklist of the
+// shedding behaviors to expect, so a deployment can be validated
+// empirically before production.
+package loadshedderscenario
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/pior/loadshedder"
+)
+
+// Options configures the generated scenario.
+type Options struct {
+	// Target is the URL of the endpoint under test.
+	// Required.
+	Target string
+
+	// Latency is the expected processing time of a request of the endpoint
+	// without contention. With the limit, it sets the capacity of the
+	// loadshedder (Little's law: Limit / Latency requests per second).
+	// Required.
+	Latency time.Duration
+
+	// Multipliers are the rates of the stages, relative to the capacity.
+	// Optional, default to 0.5, 1, 1.5 and 2.
+	Multipliers []float64
+
+	// StageDuration is how long each stage holds its rate.
+	// Optional, default to 30s.
+	StageDuration time.Duration
+
+	// Ramp is the time to ramp from a stage to the next (k6 only, vegeta
+	// switches right away).
+	// Optional, default to 10s.
+	Ramp time.Duration
+}
+
+// Stage is a constant rate of the scenario.
+type Stage struct {
+	Multiplier float64       // Relative to the capacity
+	Rate       int           // Requests per second
+	Duration   time.Duration // Hold time
+}
+
+// Scenario is a load test crossing the capacity of a loadshedder.
+type Scenario struct {
+	Config  loadshedder.Config
+	Target  string
+	Latency time.Duration
+	Ramp    time.Duration
+
+	// Capacity is the rate the loadshedder admits at its limit, in requests
+	// per second.
+	Capacity float64
+
+	Stages []Stage
+}
+
+// New builds the scenario of the configuration.
+func New(cfg loadshedder.Config, opts Options) (*Scenario, error) {
+	switch {
+	case opts.Target == "":
+		return nil, errors.New("loadshedderscenario: Target is required")
+	case opts.Latency <= 0:
+		return nil, errors.New("loadshedderscenario: Latency must be positive")
+	case cfg.Limit <= 0:
+		return nil, errors.New("loadshedderscenario: Config.Limit must be positive")
+	case opts.StageDuration < 0 || opts.Ramp < 0:
+		return nil, errors.New("loadshedderscenario: StageDuration and Ramp cannot be negative")
+	}
+	if len(opts.Multipliers) == 0 {
+		opts.Multipliers = []float64{0.5, 1, 1.5, 2}
+	}
+	if opts.StageDuration == 0 {
+		opts.StageDuration = 30 * time.Second
+	}
+	if opts.Ramp == 0 {
+		opts.Ramp = 10 * time.Second
+	}
+
+	s := &Scenario{
+		Config:   cfg,
+		Target:   opts.Target,
+		Latency:  opts.Latency,
+		Ramp:     opts.Ramp,
+		Capacity: float64(cfg.Limit) / opts.Latency.Seconds(),
+	}
+	for _, multiplier := range opts.Multipliers {
+		if multiplier <= 0 {
+			return nil, fmt.Errorf("loadshedderscenario: multiplier %v must be positive", multiplier)
+		}
+		s.Stages = append(s.Stages, Stage{
+			Multiplier: multiplier,
+			Rate:       max(1, int(math.Ceil(multiplier*s.Capacity))),
+			Duration:   opts.StageDuration,
+		})
+	}
+	return s, nil
+}
+
+// peakRate returns the highest rate of the stages.
+func (s *Scenario) peakRate() int {
+	peak := 0
+	for _, stage := range s.Stages {
+		peak = max(peak, stage.Rate)
+	}
+	return peak
+}
+
+// timeout returns the longest expected response time: the latency plus the
+// longest wait in the queue, with a margin.
+func (s *Scenario) timeout() time.Duration {
+	timeout := 2 * s.Latency
+	if s.Config.WaitingLimit > 0 && s.Config.MaxQueueWait > 0 {
+		timeout += s.Config.MaxQueueWait
+	}
+	return max(timeout, time.Second)
+}
+
+// Checklist returns the behaviors to expect during the scenario, to compare
+// with the metrics of the loadshedder and the load-test report.
+func (s *Scenario) Checklist() []string {
+	cfg := s.Config
+	capacity := int(s.Capacity)
+
+	checks := []string{
+		fmt.Sprintf("Below %d req/s, no request is rejected and the latency stays near %v.", capacity, s.Latency),
+		fmt.Sprintf("Rejections (429) start near %d req/s, the accepted rate plateaus near %d req/s, and the excess is rejected (about %.0f%% at %d req/s).",
+			capacity, capacity, 100*(1-1/max(1, s.Stages[len(s.Stages)-1].Multiplier)), s.Stages[len(s.Stages)-1].Rate),
+		fmt.Sprintf("Running never exceeds the limit (%d).", cfg.Limit),
+	}
+
+	switch {
+	case cfg.WaitingLimit == 0:
+		checks = append(checks, "Waiting stays at 0 and the rejections are immediate (no queue).")
+	case cfg.MaxQueueWait > 0:
+		checks = append(checks, fmt.Sprintf("Waiting never exceeds the waiting limit (%d), and the accepted latency stays below %v (latency plus the maximum queue wait).",
+			cfg.WaitingLimit, s.Latency+cfg.MaxQueueWait))
+	default:
+		checks = append(checks, fmt.Sprintf("Waiting never exceeds the waiting limit (%d); without a maximum queue wait, the accepted latency grows up to about %v.",
+			cfg.WaitingLimit, s.Latency+time.Duration(cfg.WaitingLimit)*s.Latency/time.Duration(cfg.Limit)))
+	}
+
+	checks = append(checks, "Every rejection carries a Retry-After header.")
+
+	if len(cfg.Signals) > 0 {
+		checks = append(checks, "The overload signals can shed before the capacity: rejections below it must match a signal above its threshold.")
+	}
+	if len(cfg.PriorityAdmission) > 0 {
+		checks = append(checks, "The low priority requests are rejected first, the high priority ones keep being admitted longer.")
+	}
+
+	return append(checks, "Once the load stops, Waiting drops to 0 and the rejections stop within a few seconds.")
+}
+
+// WriteChecklist writes the checklist as a markdown list.
+func (s *Scenario) WriteChecklist(w io.Writer) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Expected behaviors of %s (capacity %.1f req/s):\n\n", s.name(), s.Capacity)
+	for _, check := range s.Checklist() {
+		fmt.Fprintf(&b, "- [ ] %s\n", check)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteK6 writes a k6 script, ramping the arrival rate through the stages.
+func (s *Scenario) WriteK6(w io.Writer) error {
+	var b strings.Builder
+	s.writeComment(&b, "//")
+
+	vus := max(10, int(math.Ceil(float64(s.peakRate())*s.timeout().Seconds())))
+
+	fmt.Fprintf(&b, `import http from 'k6/http';
+import { check } from 'k6';
+
+export const options = {
+  scenarios: {
+    shedding: {
+      executor: 'ramping-arrival-rate',
+      startRate: 0,
+      timeUnit: '1s',
+      preAllocatedVUs: %d,
+      maxVUs: %d,
+      stages: [
+`, vus, 2*vus)
+	for _, stage := range s.Stages {
+		fmt.Fprintf(&b, "        { target: %d, duration: '%s' }, // %gx capacity\n", stage.Rate, k6Duration(s.Ramp), stage.Multiplier)
+		fmt.Fprintf(&b, "        { target: %d, duration: '%s' },\n", stage.Rate, k6Duration(stage.Duration))
+	}
+	fmt.Fprintf(&b, "        { target: 0, duration: '%s' },\n", k6Duration(s.Ramp))
+	fmt.Fprintf(&b, `      ],
+    },
+  },
+};
+
+// Rejections are expected, not failures
+http.setResponseCallback(http.expectedStatuses({ min: 200, max: 399 }, 429));
+
+export default function () {
+  const res = http.get(%q, { timeout: '%s' });
+  check(res, {
+    'admitted or rejected': (r) => r.status < 400 || r.status === 429,
+    'rejection with Retry-After': (r) => r.status !== 429 || r.headers['Retry-After'] !== undefined,
+  });
+}
+`, s.Target, k6Duration(s.timeout()))
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteVegeta writes a shell script running vegeta at the rate of each
+// stage, reporting each stage.
+func (s *Scenario) WriteVegeta(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+	s.writeComment(&b, "#")
+	b.WriteString("set -e\n\n")
+	fmt.Fprintf(&b, "TARGET='%s'\n", strings.ReplaceAll(s.Target, "'", `'\''`))
+
+	for i, stage := range s.Stages {
+		fmt.Fprintf(&b, "\necho \"Stage %d: %d req/s (%gx capacity)\"\n", i+1, stage.Rate, stage.Multiplier)
+		fmt.Fprintf(&b, "echo \"GET $TARGET\" | vegeta attack -rate=%d/s -duration=%s -timeout=%s > stage-%d.bin\n",
+			stage.Rate, stage.Duration, s.timeout(), i+1)
+		fmt.Fprintf(&b, "vegeta report stage-%d.bin\n", i+1)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeComment writes the description and the checklist of the scenario as
+// a comment.
+func (s *Scenario) writeComment(b *strings.Builder, prefix string) {
+	cfg := s.Config
+	fmt.Fprintf(b, "%s Load test of %s: limit %d, waiting limit %d, max queue wait %v, latency %v.\n",
+		prefix, s.name(), cfg.Limit, cfg.WaitingLimit, cfg.MaxQueueWait, s.Latency)
+	fmt.Fprintf(b, "%s Capacity: %.1f req/s.\n%s\n%s Expected:\n", prefix, s.Capacity, prefix, prefix)
+	for _, check := range s.Checklist() {
+		fmt.Fprintf(b, "%s - %s\n", prefix, check)
+	}
+	b.WriteString("\n")
+}
+
+func (s *Scenario) name() string {
+	if s.Config.Name == "" {
+		return "the loadshedder"
+	}
+	return s.Config.Name
+}
+
+// k6Duration formats the duration for k6, which does not accept the
+// fractional units of time.Duration.String.
+func k6Duration(d time.Duration) string {
+	if d%time.Second == 0 {
+		return fmt.Sprintf("%ds", d/time.Second)
+	}
+	return fmt.Sprintf("%dms", d.Milliseconds())
+}
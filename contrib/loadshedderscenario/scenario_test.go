@@ -0,0 +1,124 @@
+package loadshedderscenario
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pior/loadshedder"
+)
+
+func TestNew(t *testing.T) {
+	s, err := New(loadshedder.Config{Limit: 100}, Options{Target: "http://localhost/", Latency: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if s.Capacity != 2000 {
+		t.Errorf("expected a capacity of 2000 req/s, got %v", s.Capacity)
+	}
+	expected := []int{1000, 2000, 3000, 4000}
+	if len(s.Stages) != len(expected) {
+		t.Fatalf("expected %d stages, got %d", len(expected), len(s.Stages))
+	}
+	for i, stage := range s.Stages {
+		if stage.Rate != expected[i] || stage.Duration != 30*time.Second {
+			t.Errorf("stage %d: expected %d req/s for 30s, got %d for %v", i, expected[i], stage.Rate, stage.Duration)
+		}
+	}
+}
+
+func TestNew_Errors(t *testing.T) {
+	valid := Options{Target: "http://localhost/", Latency: time.Millisecond}
+
+	tests := map[string]struct {
+		cfg  loadshedder.Config
+		opts Options
+	}{
+		"missing limit":       {loadshedder.Config{}, valid},
+		"missing target":      {loadshedder.Config{Limit: 1}, Options{Latency: time.Millisecond}},
+		"missing latency":     {loadshedder.Config{Limit: 1}, Options{Target: "http://localhost/"}},
+		"negative multiplier": {loadshedder.Config{Limit: 1}, Options{Target: "http://localhost/", Latency: time.Millisecond, Multipliers: []float64{-1}}},
+	}
+
+	for name, tt := range tests {
+		if _, err := New(tt.cfg, tt.opts); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestScenario_Checklist(t *testing.T) {
+	tests := map[string]struct {
+		cfg      loadshedder.Config
+		expected string
+	}{
+		"no queue":       {loadshedder.Config{Limit: 10}, "rejections are immediate"},
+		"max queue wait": {loadshedder.Config{Limit: 10, WaitingLimit: 5, MaxQueueWait: 200 * time.Millisecond}, "stays below 300ms"},
+		"unbounded wait": {loadshedder.Config{Limit: 10, WaitingLimit: 5}, "grows up to about 150ms"},
+	}
+
+	for name, tt := range tests {
+		s, err := New(tt.cfg, Options{Target: "http://localhost/", Latency: 100 * time.Millisecond})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		checklist := strings.Join(s.Checklist(), "\n")
+		for _, want := range []string{"Below 100 req/s", "about 50% at 200 req/s", "exceeds the limit (10)", tt.expected} {
+			if !strings.Contains(checklist, want) {
+				t.Errorf("%s: expected %q in the checklist:\n%s", name, want, checklist)
+			}
+		}
+	}
+}
+
+func TestScenario_WriteK6(t *testing.T) {
+	s, err := New(loadshedder.Config{Name: "api", Limit: 10, WaitingLimit: 5, MaxQueueWait: 500 * time.Millisecond},
+		Options{Target: "http://localhost:8080/api", Latency: 100 * time.Millisecond, Multipliers: []float64{1, 2}, Ramp: 1500 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	if err := s.WriteK6(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"// Load test of api: limit 10, waiting limit 5",
+		"executor: 'ramping-arrival-rate'",
+		"preAllocatedVUs: 200,",
+		"{ target: 100, duration: '1500ms' }, // 1x capacity",
+		"{ target: 200, duration: '30s' },",
+		"{ target: 0, duration: '1500ms' },",
+		`http.get("http://localhost:8080/api", { timeout: '1s' })`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("expected %q in the script:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestScenario_WriteVegeta(t *testing.T) {
+	s, err := New(loadshedder.Config{Limit: 10}, Options{Target: "http://localhost/it's", Latency: 100 * time.Millisecond, Multipliers: []float64{0.5}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	if err := s.WriteVegeta(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{
+		"#!/bin/sh\n# Load test of the loadshedder",
+		`TARGET='http://localhost/it'\''s'`,
+		`echo "GET $TARGET" | vegeta attack -rate=50/s -duration=30s -timeout=1s > stage-1.bin`,
+		"vegeta report stage-1.bin",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("expected %q in the script:\n%s", want, b.String())
+		}
+	}
+}
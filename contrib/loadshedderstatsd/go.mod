@@ -0,0 +1,7 @@
+module github.com/pior/loadshedder/contrib/loadshedderstatsd
+
+go 1.24.0
+
+require github.com/pior/loadshedder v0.1.0
+
+replace github.com/pior/loadshedder => ../../
@@ -0,0 +1,196 @@
+// Package loadshedderstatsd provides a StatsD/DogStatsD reporter for
+// loadshedder, for the deployments without Prometheus.
+package loadshedderstatsd
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pior/loadshedder"
+)
+
+// TagFormat is the wire format of the tags.
+type TagFormat int
+
+const (
+	// TagsDogStatsD appends the tags to the metrics as |#key:value,...,
+	// the format of the Datadog agent, Telegraf and statsd_exporter.
+	TagsDogStatsD TagFormat = iota
+
+	// TagsNone drops the tags, for the StatsD servers without tag support.
+	TagsNone
+)
+
+// Reporter implements the loadshedder.Reporter interface by sending the
+// metrics of each decision over StatsD, in a single packet:
+//   - <prefix>requests (counter, tagged outcome:accepted or outcome:rejected)
+//   - <prefix>wait_time (timing, in milliseconds)
+//   - <prefix>running, <prefix>waiting, <prefix>limit and
+//     <prefix>utilization (gauges)
+//
+// Metrics of a named loadshedder are also tagged loadshedder:<name>.
+// Sending errors are ignored: metrics are best effort, like the UDP they
+// are usually sent over.
+type Reporter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	closer io.Closer
+
+	prefix    string
+	tags      []string
+	format    TagFormat
+	routeTag  bool
+	methodTag bool
+}
+
+// Option configures a Reporter.
+type Option func(*Reporter)
+
+// WithPrefix sets the prefix of the metric names.
+// Optional, default to "loadshedder.".
+func WithPrefix(prefix string) Option {
+	return func(r *Reporter) {
+		r.prefix = prefix
+	}
+}
+
+// WithTags adds constant tags (e.g. "service:api") to all metrics.
+func WithTags(tags ...string) Option {
+	return func(r *Reporter) {
+		r.tags = append(r.tags, tags...)
+	}
+}
+
+// WithTagFormat sets the wire format of the tags.
+// Optional, default to TagsDogStatsD.
+func WithTagFormat(format TagFormat) Option {
+	return func(r *Reporter) {
+		r.format = format
+	}
+}
+
+// WithRouteTag tags the metrics with route:<pattern>, the pattern of the
+// http.ServeMux route matching the request. The pattern is only known when
+// the loadshedder middleware is installed inside the mux, per route;
+// requests without a pattern are tagged route:unmatched.
+func WithRouteTag() Option {
+	return func(r *Reporter) {
+		r.routeTag = true
+	}
+}
+
+// WithMethodTag tags the metrics with method:<HTTP method>.
+func WithMethodTag() Option {
+	return func(r *Reporter) {
+		r.methodTag = true
+	}
+}
+
+// NewReporter creates a reporter sending the metrics over UDP to addr,
+// e.g. "127.0.0.1:8125". Call Close to release the socket.
+func NewReporter(addr string, opts ...Option) (*Reporter, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	r := NewWriterReporter(conn, opts...)
+	r.closer = conn
+	return r, nil
+}
+
+// NewWriterReporter creates a reporter writing each packet to w, e.g. a
+// Unix datagram socket or a buffer in tests.
+func NewWriterReporter(w io.Writer, opts ...Option) *Reporter {
+	r := &Reporter{w: w, prefix: "loadshedder."}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Close closes the socket opened by NewReporter.
+func (r *Reporter) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// Accepted is called when a request is accepted.
+func (r *Reporter) Accepted(req *http.Request, stats loadshedder.Stats) {
+	r.report(req, "accepted", stats)
+}
+
+// Rejected is called when a request is rejected.
+func (r *Reporter) Rejected(req *http.Request, stats loadshedder.Stats) {
+	r.report(req, "rejected", stats)
+}
+
+func (r *Reporter) report(req *http.Request, outcome string, stats loadshedder.Stats) {
+	utilization := 0.0
+	if stats.Limit > 0 {
+		utilization = float64(stats.Running) / float64(stats.Limit)
+	}
+	waitTime := float64(stats.WaitTime.Microseconds()) / 1000
+
+	tags := r.tags
+	if r.format != TagsNone {
+		tags = tags[:len(tags):len(tags)]
+		if stats.Name != "" {
+			tags = append(tags, "loadshedder:"+sanitize(stats.Name))
+		}
+		if r.routeTag {
+			route := req.Pattern
+			if route == "" {
+				route = "unmatched"
+			}
+			tags = append(tags, "route:"+sanitize(route))
+		}
+		if r.methodTag {
+			tags = append(tags, "method:"+sanitize(req.Method))
+		}
+	}
+
+	var b []byte
+	b = r.appendMetric(b, "requests", "1", "c", append(tags[:len(tags):len(tags)], "outcome:"+outcome))
+	b = r.appendMetric(b, "wait_time", formatFloat(waitTime), "ms", tags)
+	b = r.appendMetric(b, "running", strconv.FormatInt(stats.Running, 10), "g", tags)
+	b = r.appendMetric(b, "waiting", strconv.FormatInt(stats.Waiting, 10), "g", tags)
+	b = r.appendMetric(b, "limit", strconv.FormatInt(stats.Limit, 10), "g", tags)
+	b = r.appendMetric(b, "utilization", formatFloat(utilization), "g", tags)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, _ = r.w.Write(b[:len(b)-1]) // without the trailing newline
+}
+
+// appendMetric appends a metric line, terminated by a newline.
+func (r *Reporter) appendMetric(b []byte, name, value, kind string, tags []string) []byte {
+	b = append(b, r.prefix...)
+	b = append(b, name...)
+	b = append(b, ':')
+	b = append(b, value...)
+	b = append(b, '|')
+	b = append(b, kind...)
+	if r.format == TagsDogStatsD && len(tags) > 0 {
+		b = append(b, "|#"...)
+		b = append(b, strings.Join(tags, ",")...)
+	}
+	return append(b, '\n')
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// tagReplacer replaces the characters separating the fields and the tags
+// of the DogStatsD format.
+var tagReplacer = strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_", " ", "_")
+
+func sanitize(value string) string {
+	return tagReplacer.Replace(value)
+}
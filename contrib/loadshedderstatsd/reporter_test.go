@@ -0,0 +1,110 @@
+package loadshedderstatsd
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pior/loadshedder"
+)
+
+func TestReporter(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewWriterReporter(&buf, WithTags("service:api"))
+
+	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	r.Accepted(req, loadshedder.Stats{Name: "api", Running: 5, Waiting: 2, Limit: 10, WaitTime: 1500 * time.Microsecond})
+
+	expected := strings.Join([]string{
+		"loadshedder.requests:1|c|#service:api,loadshedder:api,outcome:accepted",
+		"loadshedder.wait_time:1.5|ms|#service:api,loadshedder:api",
+		"loadshedder.running:5|g|#service:api,loadshedder:api",
+		"loadshedder.waiting:2|g|#service:api,loadshedder:api",
+		"loadshedder.limit:10|g|#service:api,loadshedder:api",
+		"loadshedder.utilization:0.5|g|#service:api,loadshedder:api",
+	}, "\n")
+	if buf.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestReporter_Rejected(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewWriterReporter(&buf, WithPrefix("app.shed."))
+
+	r.Rejected(httptest.NewRequest(http.MethodGet, "/", http.NoBody), loadshedder.Stats{Running: 10, Limit: 10})
+
+	line, _, _ := strings.Cut(buf.String(), "\n")
+	if line != "app.shed.requests:1|c|#outcome:rejected" {
+		t.Errorf("unexpected counter: %q", line)
+	}
+}
+
+func TestReporter_RequestTags(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewWriterReporter(&buf, WithRouteTag(), WithMethodTag())
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /users/{id}", func(w http.ResponseWriter, req *http.Request) {
+		r.Accepted(req, loadshedder.Stats{})
+	})
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", http.NoBody))
+
+	line, _, _ := strings.Cut(buf.String(), "\n")
+	if line != "loadshedder.requests:1|c|#route:GET_/users/{id},method:GET,outcome:accepted" {
+		t.Errorf("unexpected counter: %q", line)
+	}
+
+	// Outside of a mux route
+	buf.Reset()
+	r.Accepted(httptest.NewRequest(http.MethodPost, "/", http.NoBody), loadshedder.Stats{})
+
+	line, _, _ = strings.Cut(buf.String(), "\n")
+	if line != "loadshedder.requests:1|c|#route:unmatched,method:POST,outcome:accepted" {
+		t.Errorf("unexpected counter: %q", line)
+	}
+}
+
+func TestReporter_TagsNone(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewWriterReporter(&buf, WithTagFormat(TagsNone), WithTags("service:api"), WithMethodTag())
+
+	r.Accepted(httptest.NewRequest(http.MethodGet, "/", http.NoBody), loadshedder.Stats{Name: "api"})
+
+	if strings.Contains(buf.String(), "|#") {
+		t.Errorf("expected no tags, got:\n%s", buf.String())
+	}
+}
+
+func TestReporter_UDP(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	r, err := NewReporter(server.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	ls := loadshedder.New(loadshedder.Config{Limit: 1})
+	mw := loadshedder.NewMiddleware(ls, r, nil)
+	mw.Handler(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {})).
+		ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	_ = server.SetReadDeadline(time.Now().Add(time.Second))
+	packet := make([]byte, 1500)
+	n, _, err := server.ReadFrom(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(packet[:n]), "loadshedder.requests:1|c|#outcome:accepted\n") {
+		t.Errorf("unexpected packet:\n%s", packet[:n])
+	}
+}
@@ -0,0 +1,115 @@
+package loadshedder
+
+import (
+	"cmp"
+	"context"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// DeadlineRejections returns the number of requests rejected because their
+// deadline was earlier than their projected wait, see Config.DeadlineAware.
+// They are also counted as rejected.
+func (l *Loadshedder) DeadlineRejections() int64 {
+	return l.deadlineRejections.Load()
+}
+
+// missesDeadline returns true if the request would still be waiting at its
+// deadline, projected from the requests ahead of it, including itself in
+// current, and the average request duration.
+func (l *Loadshedder) missesDeadline(ctx context.Context, maxWait time.Duration, current, limit int64, now time.Time) bool {
+	deadline, ok := ctx.Deadline()
+	if maxWait > 0 && (!ok || now.Add(maxWait).Before(deadline)) {
+		deadline, ok = now.Add(maxWait), true
+	}
+	if !ok {
+		return false
+	}
+
+	waiting := current - l.slots.inUse.Load()
+	wait := projectedWait(waiting, limit, time.Duration(l.avgDuration.Load()))
+	return wait > 0 && now.Add(wait).After(deadline)
+}
+
+// DeadlineBand is the queue policy of the requests with a remaining budget
+// of at least MinBudget, see Config.DeadlineBands.
+type DeadlineBand struct {
+	MinBudget time.Duration // Lower bound of the remaining budget
+	MaxWait   time.Duration // Maximum queue wait, 0 to reject right away
+}
+
+// validateDeadlineBands returns a copy of the bands sorted by MinBudget,
+// panicking on invalid bands.
+func validateDeadlineBands(bands []DeadlineBand) []DeadlineBand {
+	if len(bands) == 0 {
+		return nil
+	}
+
+	bands = slices.Clone(bands)
+	slices.SortFunc(bands, func(a, b DeadlineBand) int {
+		return cmp.Compare(a.MinBudget, b.MinBudget)
+	})
+	for i, band := range bands {
+		if band.MinBudget < 0 || band.MaxWait < 0 {
+			panic("loadshedder: Config.DeadlineBands cannot have negative durations")
+		}
+		if i > 0 && band.MinBudget == bands[i-1].MinBudget {
+			panic("loadshedder: Config.DeadlineBands cannot have two bands with the same MinBudget")
+		}
+	}
+	return bands
+}
+
+// applyDeadlineBand sets the maximum wait from the band of the remaining
+// budget of the request, if it has a deadline. The bands are sorted by
+// MinBudget.
+func (o *acquireOptions) applyDeadlineBand(ctx context.Context, bands []DeadlineBand, now time.Time) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return
+	}
+	budget := deadline.Sub(now)
+
+	maxWait := time.Duration(0)
+	for i := len(bands) - 1; i >= 0; i-- {
+		if budget >= bands[i].MinBudget {
+			maxWait = bands[i].MaxWait
+			break
+		}
+	}
+
+	o.maxWait = maxWait
+	o.noWait = maxWait == 0
+}
+
+// WithDeadline sets the deadline of the request context from the request,
+// e.g. from a timeout header set by the client or a proxy, see
+// DeadlineFromHeader. HTTP requests have no deadline otherwise:
+// Config.DeadlineBands and Config.DeadlineAware then see the budget of the
+// request, and the handler respects it. The function returns false for
+// requests without deadline.
+func WithDeadline(deadline func(*http.Request) (time.Time, bool)) MiddlewareOption {
+	return func(m *Middleware) {
+		m.deadline = deadline
+	}
+}
+
+// DeadlineFromHeader returns the deadline function of WithDeadline reading a
+// timeout from the header, as an integer number of unit from the arrival of
+// the request, e.g. DeadlineFromHeader("X-Envoy-Expected-Rq-Timeout-Ms",
+// time.Millisecond). Missing and invalid values yield no deadline.
+func DeadlineFromHeader(header string, unit time.Duration) func(*http.Request) (time.Time, bool) {
+	if unit <= 0 {
+		panic("loadshedder: DeadlineFromHeader unit must be positive")
+	}
+	return func(r *http.Request) (time.Time, bool) {
+		timeout, err := strconv.ParseInt(r.Header.Get(header), 10, 64)
+		if err != nil || timeout < 0 || timeout > math.MaxInt64/int64(unit) {
+			return time.Time{}, false
+		}
+		return time.Now().Add(time.Duration(timeout) * unit), true
+	}
+}
@@ -0,0 +1,137 @@
+package loadshedder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLoadshedder_DeadlineAware(t *testing.T) {
+	ls := New(Config{Limit: 1, WaitingLimit: 5, DeadlineAware: true})
+	ls.avgDuration.Store(int64(time.Second))
+
+	_, blocker := ls.Acquire(context.Background())
+
+	// Projected to wait about 1s: cannot succeed within 50ms
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, token := ls.Acquire(ctx); token.Accepted() || token.WaitTime() != 0 {
+		t.Error("expected an immediate rejection")
+	}
+	if elapsed := time.Since(start); elapsed > 25*time.Millisecond {
+		t.Errorf("expected the rejection not to wait, took %s", elapsed)
+	}
+
+	if _, token := ls.Acquire(context.Background(), WithMaxWait(50*time.Millisecond)); token.Accepted() {
+		t.Error("expected WithMaxWait to bound the deadline")
+	}
+
+	if n := ls.DeadlineRejections(); n != 2 {
+		t.Errorf("expected 2 deadline rejections, got %d", n)
+	}
+	if counters := ls.Counters(); counters.Rejected != 2 {
+		t.Errorf("expected the deadline rejections to count as rejections, got %+v", counters)
+	}
+
+	// A deadline after the projected wait waits for the slot
+	time.AfterFunc(20*time.Millisecond, func() { ls.Release(blocker) })
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, token := ls.Acquire(ctx)
+	if !token.Accepted() || !token.Queued() {
+		t.Error("expected a request with enough time to wait for the slot")
+	}
+	ls.Release(token)
+}
+
+func TestLoadshedder_DeadlineBands(t *testing.T) {
+	ls := New(Config{Limit: 1, WaitingLimit: 5, MaxQueueWait: 10 * time.Millisecond, DeadlineBands: []DeadlineBand{
+		{MinBudget: 2 * time.Second, MaxWait: 40 * time.Millisecond},
+		{MinBudget: 200 * time.Millisecond, MaxWait: 20 * time.Millisecond},
+	}})
+
+	_, held := ls.Acquire(context.Background())
+	defer ls.Release(held)
+
+	tests := []struct {
+		name    string
+		timeout time.Duration // 0 for no deadline
+		opts    []AcquireOption
+		minWait time.Duration
+		maxWait time.Duration
+	}{
+		{"below the bands", 100 * time.Millisecond, nil, 0, 0},
+		{"low band", time.Second, nil, 20 * time.Millisecond, 40 * time.Millisecond},
+		{"high band", time.Minute, nil, 40 * time.Millisecond, time.Second},
+		{"no deadline", 0, nil, 10 * time.Millisecond, 20 * time.Millisecond},
+		{"WithMaxWait", time.Minute, []AcquireOption{WithMaxWait(5 * time.Millisecond)}, 5 * time.Millisecond, 20 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		ctx := context.Background()
+		if tt.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+			defer cancel()
+		}
+
+		_, token := ls.Acquire(ctx, tt.opts...)
+		if token.Accepted() {
+			t.Fatalf("%s: expected a rejection", tt.name)
+		}
+		if wait := token.WaitTime(); wait < tt.minWait || (tt.maxWait == 0 && wait != 0) || (tt.maxWait > 0 && wait >= tt.maxWait) {
+			t.Errorf("%s: expected to wait in [%v, %v), waited %v", tt.name, tt.minWait, tt.maxWait, wait)
+		}
+	}
+}
+
+func TestDeadlineBandsValidation(t *testing.T) {
+	tests := map[string][]DeadlineBand{
+		"negative budget": {{MinBudget: -time.Second}},
+		"negative wait":   {{MaxWait: -time.Second}},
+		"duplicate":       {{MinBudget: time.Second}, {MinBudget: time.Second, MaxWait: time.Millisecond}},
+	}
+
+	for name, bands := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected a panic")
+				}
+			}()
+			New(Config{Limit: 1, DeadlineBands: bands})
+		})
+	}
+}
+
+func TestMiddleware_WithDeadline(t *testing.T) {
+	ls := New(Config{Limit: 1})
+	mw := NewMiddleware(ls, nil, nil, WithDeadline(DeadlineFromHeader("X-Timeout-Ms", time.Millisecond)))
+
+	var deadline time.Time
+	var ok bool
+	handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, ok = r.Context().Deadline()
+	}))
+
+	for _, value := range []string{"", "soon", "-1"} {
+		r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+		r.Header.Set("X-Timeout-Ms", value)
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		if ok {
+			t.Errorf("%q: expected no deadline", value)
+		}
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	r.Header.Set("X-Timeout-Ms", "1500")
+	start := time.Now()
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	if budget := deadline.Sub(start); !ok || budget < 1500*time.Millisecond || budget > 2*time.Second {
+		t.Errorf("expected a deadline in 1.5s, got %v", budget)
+	}
+}
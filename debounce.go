@@ -0,0 +1,41 @@
+package loadshedder
+
+import "time"
+
+// debouncer is a two-state switch with debounced transitions, to keep state
+// transitions from flapping on noisy inputs. It turns on once the on
+// condition held for onDelay, and off once the off condition held for
+// offDelay. Hysteresis comes from the conditions themselves: when neither
+// holds (e.g. a value between the two thresholds), the state is kept and any
+// pending transition is cancelled.
+// Not safe for concurrent use.
+type debouncer struct {
+	onDelay  time.Duration
+	offDelay time.Duration
+
+	on           bool
+	pendingSince time.Time // start of the pending transition, zero if none
+}
+
+// update observes the conditions at now and returns the resulting state.
+func (d *debouncer) update(now time.Time, on, off bool) bool {
+	var delay time.Duration
+	switch {
+	case !d.on && on:
+		delay = d.onDelay
+	case d.on && off:
+		delay = d.offDelay
+	default:
+		d.pendingSince = time.Time{}
+		return d.on
+	}
+
+	if d.pendingSince.IsZero() {
+		d.pendingSince = now
+	}
+	if now.Sub(d.pendingSince) >= delay {
+		d.on = !d.on
+		d.pendingSince = time.Time{}
+	}
+	return d.on
+}
@@ -0,0 +1,126 @@
+package loadshedder
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	defaultElevatedUtilization = 0.8
+	defaultCriticalQueueAge    = 100 * time.Millisecond
+)
+
+// DegradationLevel is a coarse view of the load, for handlers disabling
+// expensive features (recommendations, heavy rendering) before any request
+// is rejected, see Loadshedder.DegradationLevel.
+type DegradationLevel int
+
+const (
+	// DegradationNormal: requests are admitted without waiting, with headroom.
+	DegradationNormal DegradationLevel = iota
+
+	// DegradationElevated: the running requests approach the limit, or
+	// requests are waiting. Handlers should skip optional work.
+	DegradationElevated
+
+	// DegradationCritical: requests wait for long, or new requests are
+	// rejected. Handlers should serve the bare minimum.
+	DegradationCritical
+)
+
+// String returns "normal", "elevated" or "critical".
+func (d DegradationLevel) String() string {
+	switch d {
+	case DegradationNormal:
+		return "normal"
+	case DegradationElevated:
+		return "elevated"
+	case DegradationCritical:
+		return "critical"
+	default:
+		return "unknown"
+	}
+}
+
+// DegradationConfig configures the thresholds of the DegradationLevel, see
+// Config.Degradation.
+type DegradationConfig struct {
+	// ElevatedUtilization is the fraction of the effective limit running
+	// from which the level is DegradationElevated, in (0, 1].
+	// Optional, default to 0.8.
+	ElevatedUtilization float64
+
+	// CriticalQueueAge is the wait of the oldest waiting request from which
+	// the level is DegradationCritical.
+	// Optional, default to 100ms.
+	CriticalQueueAge time.Duration
+}
+
+func applyDegradationDefaults(d DegradationConfig) DegradationConfig {
+	if d.ElevatedUtilization == 0 {
+		d.ElevatedUtilization = defaultElevatedUtilization
+	}
+	if d.ElevatedUtilization < 0 || d.ElevatedUtilization > 1 {
+		panic("loadshedder: DegradationConfig.ElevatedUtilization must be in (0, 1]")
+	}
+	if d.CriticalQueueAge == 0 {
+		d.CriticalQueueAge = defaultCriticalQueueAge
+	}
+	if d.CriticalQueueAge < 0 {
+		panic("loadshedder: DegradationConfig.CriticalQueueAge cannot be negative")
+	}
+	return d
+}
+
+// DegradationLevel returns the current DegradationLevel:
+//   - critical when new requests are rejected (see Severity), or the oldest
+//     waiting request waited for Degradation.CriticalQueueAge
+//   - elevated when requests are waiting, or the running requests reach
+//     Degradation.ElevatedUtilization of the effective limit
+//   - normal otherwise.
+func (l *Loadshedder) DegradationLevel() DegradationLevel {
+	severity := l.Severity()
+	if severity >= 1 {
+		return DegradationCritical
+	}
+	if severity > 0 {
+		if l.slots.oldestWait(time.Now()) >= l.config.Degradation.CriticalQueueAge {
+			return DegradationCritical
+		}
+		return DegradationElevated
+	}
+
+	utilization := float64(l.slots.inUse.Load()) / float64(l.effectiveLimit.Load())
+	if utilization >= l.config.Degradation.ElevatedUtilization {
+		return DegradationElevated
+	}
+	return DegradationNormal
+}
+
+type degradationLevelContextKey struct{}
+
+// ContextWithDegradationLevel returns a copy of ctx carrying the level. The
+// middleware sets it for admitted requests with WithDegradationLevel.
+func ContextWithDegradationLevel(ctx context.Context, level DegradationLevel) context.Context {
+	return context.WithValue(ctx, degradationLevelContextKey{}, level)
+}
+
+// DegradationLevelFromContext returns the level set with
+// ContextWithDegradationLevel, or DegradationNormal.
+func DegradationLevelFromContext(ctx context.Context) DegradationLevel {
+	level, _ := ctx.Value(degradationLevelContextKey{}).(DegradationLevel)
+	return level
+}
+
+// WithDegradationLevel sets the DegradationLevel of the loadshedder in the
+// context of the admitted requests, as of their admission, see
+// DegradationLevelFromContext:
+//
+//	if loadshedder.DegradationLevelFromContext(r.Context()) == loadshedder.DegradationNormal {
+//		renderRecommendations(w, r)
+//	}
+func WithDegradationLevel() MiddlewareOption {
+	return func(m *Middleware) {
+		m.degradationLevel = true
+	}
+}
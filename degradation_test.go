@@ -0,0 +1,93 @@
+package loadshedder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLoadshedder_DegradationLevel(t *testing.T) {
+	ls := New(Config{Limit: 5, WaitingLimit: 2, Degradation: DegradationConfig{CriticalQueueAge: 20 * time.Millisecond}})
+
+	var tokens []*Token
+	acquire := func() {
+		_, token := ls.Acquire(context.Background())
+		tokens = append(tokens, token)
+	}
+	defer func() {
+		for _, token := range tokens {
+			ls.Release(token)
+		}
+	}()
+
+	for range 3 {
+		acquire()
+	}
+	if level := ls.DegradationLevel(); level != DegradationNormal {
+		t.Errorf("expected normal with headroom, got %s", level)
+	}
+
+	acquire() // 4 out of 5
+	if level := ls.DegradationLevel(); level != DegradationElevated {
+		t.Errorf("expected elevated near the limit, got %s", level)
+	}
+
+	acquire()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ls.Acquire(ctx)
+	waitForStats(t, ls, func(s Stats) bool { return s.Waiting == 1 })
+	if level := ls.DegradationLevel(); level != DegradationElevated {
+		t.Errorf("expected elevated while a request waits, got %s", level)
+	}
+
+	time.Sleep(25 * time.Millisecond)
+	if level := ls.DegradationLevel(); level != DegradationCritical {
+		t.Errorf("expected critical once the queue is old, got %s", level)
+	}
+
+	ls.Pause()
+	defer ls.Resume()
+	cancel()
+	waitForStats(t, ls, func(s Stats) bool { return s.Waiting == 0 })
+	if level := ls.DegradationLevel(); level != DegradationCritical {
+		t.Errorf("expected critical while rejecting, got %s", level)
+	}
+}
+
+func TestMiddleware_WithDegradationLevel(t *testing.T) {
+	ls := New(Config{Limit: 1})
+	mw := NewMiddleware(ls, nil, nil, WithDegradationLevel())
+
+	var level DegradationLevel
+	handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		level = DegradationLevelFromContext(r.Context())
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	// The request itself uses the only slot
+	if level != DegradationCritical {
+		t.Errorf("expected critical, got %s", level)
+	}
+	if level := DegradationLevelFromContext(context.Background()); level != DegradationNormal {
+		t.Errorf("expected normal without a level, got %s", level)
+	}
+}
+
+func TestDegradationConfigValidation(t *testing.T) {
+	for name, cfg := range map[string]DegradationConfig{
+		"utilization": {ElevatedUtilization: 1.5},
+		"queue age":   {CriticalQueueAge: -time.Second},
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected a panic")
+				}
+			}()
+			New(Config{Limit: 1, Degradation: cfg})
+		})
+	}
+}
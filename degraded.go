@@ -0,0 +1,251 @@
+package loadshedder
+
+import (
+	"bufio"
+	"bytes"
+	"container/list"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxDegradedBodySize caps the response body size captured for the degraded cache.
+const maxDegradedBodySize = 1 << 20
+
+// degradedWarning is the Warning header value set on responses served from the degraded cache.
+const degradedWarning = `110 - "Response is Stale"`
+
+// CachedResponse is a response recorded by the middleware for serving while overloaded.
+type CachedResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	StoredAt   time.Time
+}
+
+// DegradedCache stores recent responses that the middleware serves instead of
+// rejecting requests when over capacity.
+// Implementations must be safe for concurrent use.
+type DegradedCache interface {
+	// Get returns a cached response for the request, if any.
+	Get(r *http.Request) (*CachedResponse, bool)
+
+	// Put stores the response of a successfully processed request, only
+	// called for the responses that can be served to other clients.
+	Put(r *http.Request, resp *CachedResponse)
+}
+
+// LRUCache is an in-memory DegradedCache keyed by method and URL,
+// evicting the least recently used entries beyond its capacity.
+type LRUCache struct {
+	capacity int
+	maxAge   time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type lruEntry struct {
+	key  string
+	resp *CachedResponse
+}
+
+// NewLRUCache creates an in-memory cache holding up to capacity responses.
+// Responses older than maxAge are not served. If maxAge is zero, responses never expire.
+func NewLRUCache(capacity int, maxAge time.Duration) *LRUCache {
+	if capacity <= 0 {
+		panic("loadshedder: LRUCache capacity must be positive")
+	}
+
+	return &LRUCache{
+		capacity: capacity,
+		maxAge:   maxAge,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Get returns the cached response for the request, if present and fresh.
+func (c *LRUCache) Get(r *http.Request) (*CachedResponse, bool) {
+	key := cacheKey(r)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry := elem.Value.(*lruEntry)
+	if c.maxAge > 0 && time.Since(entry.resp.StoredAt) > c.maxAge {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	return entry.resp, true
+}
+
+// Put stores the response, evicting the least recently used entry if full.
+func (c *LRUCache) Put(r *http.Request, resp *CachedResponse) {
+	key := cacheKey(r)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*lruEntry).resp = resp
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, resp: resp})
+
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// Len returns the number of cached responses.
+func (c *LRUCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+func cacheKey(r *http.Request) string {
+	return r.Method + " " + r.URL.String()
+}
+
+// serveDegraded writes a cached response for a request that would otherwise be rejected.
+// Returns false if no cached response is available.
+func (m *Middleware) serveDegraded(w http.ResponseWriter, r *http.Request) bool {
+	resp, ok := m.degradedCache.Get(r)
+	if !ok {
+		return false
+	}
+
+	header := w.Header()
+	for key, values := range resp.Header {
+		// Cookies are never replayed to another client
+		if key != "Set-Cookie" {
+			header[key] = values
+		}
+	}
+	header.Add("Warning", degradedWarning)
+	w.WriteHeader(resp.StatusCode)
+	_, _ = w.Write(resp.Body)
+	return true
+}
+
+// serveAndRecord runs the handler and stores the successful responses of the
+// cacheable requests in the degraded cache: GET requests without credentials,
+// answered without cookies, Vary header or private Cache-Control.
+func (m *Middleware) serveAndRecord(next http.Handler, w http.ResponseWriter, r *http.Request, token *Token) {
+	if !cacheableRequest(r) {
+		m.serve(next, w, r, token)
+		return
+	}
+
+	recorder := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+	m.serve(next, wrapWriter(recorder), r, token)
+
+	if recorder.statusCode == http.StatusOK && !recorder.overflow && cacheableResponse(w.Header()) {
+		m.degradedCache.Put(r, &CachedResponse{
+			StatusCode: recorder.statusCode,
+			Header:     w.Header().Clone(),
+			Body:       recorder.body.Bytes(),
+			StoredAt:   time.Now(),
+		})
+	}
+}
+
+// cacheableRequest reports whether the response to the request can be served
+// to other clients: the responses to requests with credentials may be private.
+func cacheableRequest(r *http.Request) bool {
+	return r.Method == http.MethodGet && r.Header.Get("Authorization") == "" && r.Header.Get("Cookie") == ""
+}
+
+// cacheableResponse reports whether a response with the header can be served
+// to other clients: not setting cookies, not varying with the request headers,
+// and not marked private or no-store.
+func cacheableResponse(header http.Header) bool {
+	if len(header.Values("Set-Cookie")) > 0 || len(header.Values("Vary")) > 0 {
+		return false
+	}
+
+	for _, value := range header.Values("Cache-Control") {
+		for directive := range strings.SplitSeq(value, ",") {
+			name, _, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if strings.EqualFold(name, "private") || strings.EqualFold(name, "no-store") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// responseRecorder captures the status code and a bounded copy of the body.
+// The handler receives it through wrapWriter, with the optional interfaces of
+// the underlying ResponseWriter.
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+	body        bytes.Buffer
+	overflow    bool
+}
+
+func (rr *responseRecorder) WriteHeader(statusCode int) {
+	if !rr.wroteHeader {
+		rr.statusCode = statusCode
+		rr.wroteHeader = true
+	}
+	rr.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	rr.wroteHeader = true
+	if !rr.overflow {
+		if rr.body.Len()+len(b) > maxDegradedBodySize {
+			rr.overflow = true
+			rr.body.Reset()
+		} else {
+			rr.body.Write(b)
+		}
+	}
+	return rr.ResponseWriter.Write(b)
+}
+
+// FlushError flushes the underlying ResponseWriter, for http.ResponseController.
+func (rr *responseRecorder) FlushError() error {
+	rr.wroteHeader = true
+	return http.NewResponseController(rr.ResponseWriter).Flush()
+}
+
+// hijack hijacks the connection of the underlying ResponseWriter. The
+// response of a hijacked connection is not cached.
+func (rr *responseRecorder) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	rr.overflow = true
+	rr.body.Reset()
+	return http.NewResponseController(rr.ResponseWriter).Hijack()
+}
+
+// readFrom copies r with Write, to capture the body.
+func (rr *responseRecorder) readFrom(r io.Reader) (int64, error) {
+	return io.Copy(struct{ io.Writer }{rr}, r)
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController.
+func (rr *responseRecorder) Unwrap() http.ResponseWriter {
+	return rr.ResponseWriter
+}
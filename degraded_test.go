@@ -0,0 +1,236 @@
+package loadshedder
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLRUCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache := NewLRUCache(2, 0)
+
+	reqA := httptest.NewRequest(http.MethodGet, "/a", http.NoBody)
+	reqB := httptest.NewRequest(http.MethodGet, "/b", http.NoBody)
+	reqC := httptest.NewRequest(http.MethodGet, "/c", http.NoBody)
+
+	cache.Put(reqA, &CachedResponse{StatusCode: http.StatusOK, Body: []byte("a"), StoredAt: time.Now()})
+	cache.Put(reqB, &CachedResponse{StatusCode: http.StatusOK, Body: []byte("b"), StoredAt: time.Now()})
+
+	// Touch A so that B becomes the least recently used
+	if _, ok := cache.Get(reqA); !ok {
+		t.Fatal("expected /a to be cached")
+	}
+
+	cache.Put(reqC, &CachedResponse{StatusCode: http.StatusOK, Body: []byte("c"), StoredAt: time.Now()})
+
+	if cache.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", cache.Len())
+	}
+	if _, ok := cache.Get(reqB); ok {
+		t.Error("expected /b to be evicted")
+	}
+	if _, ok := cache.Get(reqA); !ok {
+		t.Error("expected /a to be kept")
+	}
+	if _, ok := cache.Get(reqC); !ok {
+		t.Error("expected /c to be kept")
+	}
+}
+
+func TestLRUCache_MaxAge(t *testing.T) {
+	cache := NewLRUCache(10, time.Minute)
+
+	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	cache.Put(req, &CachedResponse{StatusCode: http.StatusOK, StoredAt: time.Now().Add(-2 * time.Minute)})
+
+	if _, ok := cache.Get(req); ok {
+		t.Error("expected expired response not to be served")
+	}
+	if cache.Len() != 0 {
+		t.Errorf("expected expired entry to be dropped, got %d entries", cache.Len())
+	}
+}
+
+func TestMiddleware_DegradedCache(t *testing.T) {
+	limiter := New(Config{Limit: 1})
+	reporter := &testReporter{}
+	cache := NewLRUCache(10, time.Minute)
+	mw := NewMiddleware(limiter, reporter, nil, WithDegradedCache(cache))
+
+	blocker := make(chan struct{})
+	started := make(chan struct{})
+	handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(started)
+			<-blocker
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = w.Write([]byte("fresh " + r.URL.Path))
+	}))
+
+	// Populate the cache
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/data", http.NoBody))
+	if rec.Code != http.StatusOK || rec.Body.String() != "fresh /data" {
+		t.Fatalf("unexpected first response: %d %q", rec.Code, rec.Body.String())
+	}
+
+	// Fill the limit
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", http.NoBody))
+	}()
+	<-started
+
+	// Cached path is served with a Warning header
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/data", http.NoBody))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected cached status 200, got %d", rec.Code)
+	}
+	if rec.Body.String() != "fresh /data" {
+		t.Errorf("expected cached body, got %q", rec.Body.String())
+	}
+	if rec.Header().Get("Warning") == "" {
+		t.Error("expected Warning header on degraded response")
+	}
+	if rec.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("expected cached Content-Type, got %q", rec.Header().Get("Content-Type"))
+	}
+
+	// Uncached path falls back to the rejection handler
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/other", http.NoBody))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected status 429 for uncached path, got %d", rec.Code)
+	}
+
+	close(blocker)
+	<-done
+
+	if rejected := reporter.rejected.Load(); rejected != 2 {
+		t.Errorf("expected 2 rejections reported, got %d", rejected)
+	}
+}
+
+func TestMiddleware_DegradedCacheSkipsErrors(t *testing.T) {
+	limiter := New(Config{Limit: 1})
+	cache := NewLRUCache(10, 0)
+	mw := NewMiddleware(limiter, nil, nil, WithDegradedCache(cache))
+
+	handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", http.NoBody))
+
+	if cache.Len() != 0 {
+		t.Errorf("expected failed and non-GET responses not to be cached, got %d entries", cache.Len())
+	}
+}
+
+func TestMiddleware_DegradedCacheSkipsPrivateResponses(t *testing.T) {
+	tests := []struct {
+		name   string
+		header http.Header
+		reply  http.Header
+	}{
+		{name: "authorization", header: http.Header{"Authorization": {"Bearer secret"}}},
+		{name: "cookie", header: http.Header{"Cookie": {"session=secret"}}},
+		{name: "set-cookie", reply: http.Header{"Set-Cookie": {"session=secret"}}},
+		{name: "vary", reply: http.Header{"Vary": {"Accept-Language"}}},
+		{name: "private", reply: http.Header{"Cache-Control": {"max-age=60, private"}}},
+		{name: "no-store", reply: http.Header{"Cache-Control": {"No-Store"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewLRUCache(10, 0)
+			mw := NewMiddleware(New(Config{Limit: 1}), nil, nil, WithDegradedCache(cache))
+
+			handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for key, values := range tt.reply {
+					w.Header()[key] = values
+				}
+				_, _ = w.Write([]byte("private"))
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+			for key, values := range tt.header {
+				req.Header[key] = values
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if cache.Len() != 0 {
+				t.Errorf("expected the response not to be cached, got %d entries", cache.Len())
+			}
+		})
+	}
+}
+
+func TestMiddleware_DegradedCacheNeverReplaysCookies(t *testing.T) {
+	limiter := New(Config{Limit: 1})
+	cache := NewLRUCache(10, 0)
+	mw := NewMiddleware(limiter, nil, nil, WithDegradedCache(cache))
+	handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	cache.Put(req, &CachedResponse{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Set-Cookie": {"session=secret"}, "Content-Type": {"text/plain"}},
+		StoredAt:   time.Now(),
+	})
+
+	// Fill the limit
+	_, token := limiter.Acquire(context.Background())
+	defer limiter.Release(token)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Header().Get("Warning") == "" {
+		t.Fatal("expected a degraded response")
+	}
+	if cookie := rec.Header().Get("Set-Cookie"); cookie != "" {
+		t.Errorf("expected no Set-Cookie to be replayed, got %q", cookie)
+	}
+	if rec.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("expected the cached Content-Type, got %q", rec.Header().Get("Content-Type"))
+	}
+}
+
+func TestMiddleware_DegradedCacheFlush(t *testing.T) {
+	mw := NewMiddleware(New(Config{Limit: 1}), nil, nil, WithDegradedCache(NewLRUCache(10, 0)))
+
+	handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("chunk"))
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			t.Fatal("expected the writer to implement http.Flusher")
+		}
+		flusher.Flush()
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	if !rec.Flushed {
+		t.Error("expected the response to be flushed")
+	}
+}
+
+func TestResponseRecorder_Interfaces(t *testing.T) {
+	// The underlying writer cannot flush
+	recorder := &responseRecorder{ResponseWriter: struct{ http.ResponseWriter }{httptest.NewRecorder()}}
+	w := wrapWriter(recorder)
+
+	if _, ok := w.(http.Flusher); ok {
+		t.Error("expected the writer not to implement http.Flusher")
+	}
+	if err := http.NewResponseController(w).Flush(); !errors.Is(err, http.ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported from Flush, got %v", err)
+	}
+}
@@ -0,0 +1,44 @@
+package loadshedder
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrShed matches, with errors.Is, the errors of operations rejected by the
+// loadshedder, see ShedError.
+var ErrShed = errors.New("loadshedder: shed")
+
+// ShedError is the error of an operation rejected by the loadshedder, e.g. by
+// Do or a Transport. It matches ErrShed.
+type ShedError struct {
+	Stats Stats // Stats at the rejection
+}
+
+func (e *ShedError) Error() string {
+	return ErrShed.Error()
+}
+
+// Is reports whether target is ErrShed.
+func (e *ShedError) Is(target error) bool {
+	return target == ErrShed
+}
+
+// Do acquires a slot, runs fn and releases the slot, even if fn panics.
+// If the acquisition is rejected, fn is not called and Do returns a
+// *ShedError; otherwise it returns the error of fn.
+// This wraps work outside of HTTP handlers, such as queue consumers or
+// scheduled tasks. The context passed to fn carries the priority of the
+// acquisition, see ContextWithPriority.
+func (l *Loadshedder) Do(ctx context.Context, fn func(context.Context) error, opts ...AcquireOption) error {
+	stats, token := l.Acquire(ctx, opts...)
+	if !token.Accepted() {
+		return &ShedError{Stats: stats}
+	}
+	defer l.Release(token)
+
+	if p := token.Priority(); p != PriorityFromContext(ctx) {
+		ctx = ContextWithPriority(ctx, p)
+	}
+	return fn(ctx)
+}
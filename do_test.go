@@ -0,0 +1,52 @@
+package loadshedder
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestLoadshedder_Do(t *testing.T) {
+	ls := New(Config{Limit: 1})
+	failure := errors.New("failure")
+
+	var inherited Priority
+	err := ls.Do(context.Background(), func(ctx context.Context) error {
+		inherited = PriorityFromContext(ctx)
+
+		// The only slot is taken
+		nested := ls.Do(ctx, func(context.Context) error {
+			t.Error("expected fn not to run when shed")
+			return nil
+		})
+
+		var shed *ShedError
+		if !errors.Is(nested, ErrShed) || !errors.As(nested, &shed) || shed.Stats.Running != 1 {
+			t.Errorf("expected a ShedError with the stats, got %v", nested)
+		}
+		return failure
+	}, WithPriority(PriorityHigh))
+
+	if !errors.Is(err, failure) {
+		t.Errorf("expected the error of fn, got %v", err)
+	}
+	if inherited != PriorityHigh {
+		t.Errorf("expected fn to inherit the priority, got %s", inherited)
+	}
+	if stats := ls.Stats(); stats.Running != 0 {
+		t.Errorf("expected the slot released, got %+v", stats)
+	}
+}
+
+func TestLoadshedder_DoReleasesOnPanic(t *testing.T) {
+	ls := New(Config{Limit: 1})
+
+	func() {
+		defer func() { _ = recover() }()
+		_ = ls.Do(context.Background(), func(context.Context) error { panic("boom") })
+	}()
+
+	if stats := ls.Stats(); stats.Running != 0 {
+		t.Errorf("expected the slot released after a panic, got %+v", stats)
+	}
+}
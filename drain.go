@@ -0,0 +1,169 @@
+package loadshedder
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+)
+
+const drainPollInterval = 5 * time.Millisecond
+
+// ErrDraining is the cause of the cancellation of requests cancelled by Drain,
+// see Config.CancelOnDrain.
+var ErrDraining = errors.New("loadshedder: draining")
+
+// Drain stops admitting new requests and waits until the running and waiting
+// requests are done, or ctx is done. Waiting requests keep their place in the
+// queue and are admitted as running requests finish.
+// New acquisitions are rejected from the first call on; a loadshedder does
+// not resume admitting once drained.
+// Running requests matching Config.CancelOnDrain are cancelled, including
+// queued requests once admitted.
+func (l *Loadshedder) Drain(ctx context.Context) error {
+	l.stopAdmitting()
+
+	if l.current.Load() == 0 {
+		return nil
+	}
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if l.current.Load() == 0 {
+				return nil
+			}
+		}
+	}
+}
+
+// SourceIn matches the tokens acquired with one of the sources, see WithSource.
+// Intended for Config.CancelOnDrain.
+func SourceIn(sources ...string) func(*Token) bool {
+	return func(t *Token) bool {
+		return slices.Contains(sources, t.Source())
+	}
+}
+
+// PriorityBelow matches the tokens less important than p, see WithPriority.
+// Intended for Config.CancelOnDrain.
+func PriorityBelow(p Priority) func(*Token) bool {
+	return func(t *Token) bool {
+		return t.Priority() < p
+	}
+}
+
+// trackCancellable registers a running token, or cancels it right away if the
+// loadshedder is draining and the token matches Config.CancelOnDrain.
+func (l *Loadshedder) trackCancellable(t *Token, cancel context.CancelCauseFunc) {
+	t.cancel = cancel
+
+	l.cancelMu.Lock()
+	if l.cancellable == nil {
+		l.cancellable = make(map[*Token]struct{})
+	}
+	l.cancellable[t] = struct{}{}
+	l.cancelMu.Unlock()
+
+	if l.settings.Load().draining && l.config.CancelOnDrain(t) {
+		cancel(ErrDraining)
+	}
+}
+
+func (l *Loadshedder) untrackCancellable(t *Token) {
+	l.cancelMu.Lock()
+	delete(l.cancellable, t)
+	l.cancelMu.Unlock()
+}
+
+// stopAdmitting rejects new acquisitions and cancels the running tokens
+// matching Config.CancelOnDrain.
+func (l *Loadshedder) stopAdmitting() {
+	l.limitMu.Lock()
+	l.updateSettings(func(s *settings) { s.draining = true })
+	l.limitMu.Unlock()
+
+	if l.config.CancelOnDrain == nil {
+		return
+	}
+
+	l.cancelMu.Lock()
+	var cancels []context.CancelCauseFunc
+	for t := range l.cancellable {
+		if l.config.CancelOnDrain(t) {
+			cancels = append(cancels, t.cancel)
+		}
+	}
+	l.cancelMu.Unlock()
+
+	for _, cancel := range cancels {
+		cancel(ErrDraining)
+	}
+}
+
+// Draining returns true once Drain was called.
+func (l *Loadshedder) Draining() bool {
+	return l.settings.Load().draining
+}
+
+// ConfigureServer wires the loadshedders into the server shutdown, and returns
+// the function to call instead of srv.Shutdown. The shutdown function:
+//
+//  1. stops admitting new requests in all the loadshedders,
+//  2. drains them, letting queued and running requests finish,
+//  3. shuts the server down.
+//
+// If ctx is done before the shutdown completes, the contexts of the requests
+// still running are cancelled (through srv.BaseContext) and ctx.Err() is
+// returned. ConfigureServer must be called before the server starts.
+// Calling srv.Shutdown directly still stops admitting, via RegisterOnShutdown.
+func ConfigureServer(srv *http.Server, loadshedders ...*Loadshedder) (shutdown func(context.Context) error) {
+	baseCtx, cancelBase := context.WithCancel(context.Background())
+
+	baseContext := srv.BaseContext
+	srv.BaseContext = func(listener net.Listener) context.Context {
+		if baseContext == nil {
+			return baseCtx
+		}
+		// Keep the configured base context, cancelled along with ours
+		ctx, cancel := context.WithCancel(baseContext(listener))
+		context.AfterFunc(baseCtx, cancel)
+		return ctx
+	}
+
+	srv.RegisterOnShutdown(func() {
+		for _, ls := range loadshedders {
+			ls.stopAdmitting()
+		}
+	})
+
+	return func(ctx context.Context) error {
+		for _, ls := range loadshedders {
+			ls.stopAdmitting()
+		}
+
+		var err error
+		for _, ls := range loadshedders {
+			if err = ls.Drain(ctx); err != nil {
+				break
+			}
+		}
+
+		// Close the listeners even if draining timed out
+		if shutdownErr := srv.Shutdown(ctx); err == nil {
+			err = shutdownErr
+		}
+
+		if err != nil {
+			cancelBase()
+		}
+		return err
+	}
+}
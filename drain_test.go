@@ -0,0 +1,233 @@
+package loadshedder
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLoadshedder_Drain(t *testing.T) {
+	ctx := context.Background()
+	ls := New(Config{Limit: 1, WaitingLimit: 1})
+
+	_, running := ls.Acquire(ctx)
+
+	// A request already waiting is admitted during the drain
+	queued := make(chan *Token)
+	go func() {
+		_, token := ls.Acquire(ctx)
+		queued <- token
+	}()
+	waitForStats(t, ls, func(s Stats) bool { return s.Waiting == 1 })
+
+	drained := make(chan error)
+	go func() {
+		drained <- ls.Drain(ctx)
+	}()
+	waitFor(t, ls.Draining)
+
+	// New requests are rejected
+	_, token := ls.Acquire(ctx)
+	if token.Accepted() {
+		t.Error("expected new request to be rejected while draining")
+	}
+
+	ls.Release(running)
+	token = <-queued
+	if !token.Accepted() {
+		t.Fatal("expected the queued request to be admitted")
+	}
+
+	select {
+	case <-drained:
+		t.Fatal("expected Drain to wait for the queued request")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	ls.Release(token)
+	if err := <-drained; err != nil {
+		t.Errorf("expected Drain to succeed, got %v", err)
+	}
+}
+
+func TestLoadshedder_DrainTimeout(t *testing.T) {
+	ls := New(Config{Limit: 1})
+
+	_, token := ls.Acquire(context.Background())
+	defer ls.Release(token)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := ls.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestConfigureServer(t *testing.T) {
+	ls := New(Config{Limit: 10})
+	mw := NewMiddleware(ls, nil, nil)
+
+	started := make(chan struct{})
+	cancelled := make(chan struct{})
+	srv := httptest.NewUnstartedServer(mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+		close(cancelled)
+	})))
+	shutdown := ConfigureServer(srv.Config, ls)
+	srv.Start()
+	defer srv.Close()
+
+	go func() {
+		resp, err := http.Get(srv.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	// The running request never finishes on its own
+	if err := shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected DeadlineExceeded, got %v", err)
+	}
+	if !ls.Draining() {
+		t.Error("expected the loadshedder to stop admitting")
+	}
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("expected the running request context to be cancelled")
+	}
+}
+
+func TestConfigureServer_DirectShutdown(t *testing.T) {
+	ls := New(Config{Limit: 10})
+	srv := &http.Server{}
+	ConfigureServer(srv, ls)
+
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	waitFor(t, ls.Draining)
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func waitForStats(t *testing.T, ls *Loadshedder, condition func(Stats) bool) {
+	t.Helper()
+	waitFor(t, func() bool { return condition(ls.Stats()) })
+}
+
+func TestLoadshedder_DrainCancelsMatchingRequests(t *testing.T) {
+	ls := New(Config{Limit: 2, WaitingLimit: 1, CancelOnDrain: SourceIn("batch")})
+
+	batchCtx, cancelBatch := context.WithCancelCause(context.Background())
+	defer cancelBatch(nil)
+	_, batch := ls.Acquire(batchCtx, WithSource("batch"), WithCancel(cancelBatch))
+
+	interactiveCtx, cancelInteractive := context.WithCancelCause(context.Background())
+	defer cancelInteractive(nil)
+	_, interactive := ls.Acquire(interactiveCtx, WithSource("http"), WithCancel(cancelInteractive))
+
+	// A batch request queued before the drain is cancelled once admitted
+	queuedCtx, cancelQueued := context.WithCancelCause(context.Background())
+	defer cancelQueued(nil)
+	queued := make(chan *Token)
+	go func() {
+		_, token := ls.Acquire(queuedCtx, WithSource("batch"), WithCancel(cancelQueued))
+		queued <- token
+	}()
+	waitForStats(t, ls, func(s Stats) bool { return s.Waiting == 1 })
+
+	drained := make(chan error)
+	go func() {
+		drained <- ls.Drain(context.Background())
+	}()
+
+	<-batchCtx.Done()
+	if cause := context.Cause(batchCtx); !errors.Is(cause, ErrDraining) {
+		t.Errorf("expected ErrDraining cause, got %v", cause)
+	}
+	if interactiveCtx.Err() != nil {
+		t.Error("expected the interactive request to keep running")
+	}
+
+	ls.Release(batch)
+	token := <-queued
+	<-queuedCtx.Done()
+	ls.Release(token)
+
+	ls.Release(interactive)
+	if err := <-drained; err != nil {
+		t.Errorf("expected Drain to succeed, got %v", err)
+	}
+	if interactiveCtx.Err() != nil {
+		t.Error("expected the interactive request to finish uncancelled")
+	}
+}
+
+func TestMiddleware_DrainCancelsMatchingRequests(t *testing.T) {
+	ls := New(Config{Limit: 10, CancelOnDrain: PriorityBelow(PriorityDefault)})
+	mw := NewMiddleware(ls, nil, nil, WithRequestOptions(func(r *http.Request) []AcquireOption {
+		return []AcquireOption{WithPriority(PriorityFromHeader(r.Header))}
+	}))
+
+	started := make(chan struct{}, 2)
+	finish := make(chan struct{})
+	results := make(chan error, 2)
+	handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		select {
+		case <-r.Context().Done():
+			results <- context.Cause(r.Context())
+		case <-finish:
+			results <- nil
+		}
+	}))
+
+	for _, priority := range []string{"sheddable", "high"} {
+		go func() {
+			req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+			req.Header.Set(PriorityHeader, priority)
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+		}()
+	}
+	<-started
+	<-started
+
+	drained := make(chan error)
+	go func() {
+		drained <- ls.Drain(context.Background())
+	}()
+
+	if err := <-results; !errors.Is(err, ErrDraining) {
+		t.Errorf("expected the sheddable request to be cancelled, got %v", err)
+	}
+
+	close(finish)
+	if err := <-results; err != nil {
+		t.Errorf("expected the high priority request to finish, got %v", err)
+	}
+	if err := <-drained; err != nil {
+		t.Errorf("expected Drain to succeed, got %v", err)
+	}
+}
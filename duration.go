@@ -0,0 +1,28 @@
+package loadshedder
+
+import "time"
+
+// durationEMAShift sets the weight of new samples in the average duration
+// to 1/8, as in TCP round-trip time estimation (RFC 6298).
+const durationEMAShift = 3
+
+// recordDuration updates the exponential moving average of request durations
+// with the duration of a released token.
+func (l *Loadshedder) recordDuration(t *Token, now time.Time) {
+	start := t.acceptedAt
+	if l.config.DurationIncludesWait {
+		start = t.arrivedAt
+	}
+	sample := int64(monoOf(now).Sub(start))
+
+	for {
+		avg := l.avgDuration.Load()
+		next := sample
+		if avg > 0 {
+			next = avg + (sample-avg)>>durationEMAShift
+		}
+		if l.avgDuration.CompareAndSwap(avg, max(next, 1)) {
+			return
+		}
+	}
+}
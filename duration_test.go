@@ -0,0 +1,125 @@
+package loadshedder
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLoadshedder_AvgDuration(t *testing.T) {
+	ls := New(Config{Limit: 10})
+
+	if avg := ls.Stats().AvgDuration; avg != 0 {
+		t.Errorf("expected AvgDuration=0 before any request, got %v", avg)
+	}
+
+	now := time.Now()
+	token := &Token{accepted: true, arrivedAt: monoOf(now.Add(-300 * time.Millisecond)), acceptedAt: monoOf(now.Add(-100 * time.Millisecond))}
+
+	// The first sample initializes the average
+	ls.recordDuration(token, now)
+	if avg := ls.Stats().AvgDuration; avg != 100*time.Millisecond {
+		t.Errorf("expected AvgDuration=100ms, got %v", avg)
+	}
+
+	// Then each sample moves it by 1/8 of the difference
+	token.acceptedAt = monoOf(now.Add(-900 * time.Millisecond))
+	ls.recordDuration(token, now)
+	if avg := ls.Stats().AvgDuration; avg != 200*time.Millisecond {
+		t.Errorf("expected AvgDuration=200ms, got %v", avg)
+	}
+}
+
+func TestLoadshedder_AvgDurationExcludesWait(t *testing.T) {
+	now := time.Now()
+	token := &Token{accepted: true, arrivedAt: monoOf(now.Add(-300 * time.Millisecond)), acceptedAt: monoOf(now.Add(-100 * time.Millisecond))}
+
+	handlerOnly := New(Config{Limit: 10})
+	handlerOnly.recordDuration(token, now)
+	if avg := handlerOnly.Stats().AvgDuration; avg != 100*time.Millisecond {
+		t.Errorf("expected the handler time only by default, got %v", avg)
+	}
+
+	total := New(Config{Limit: 10, DurationIncludesWait: true})
+	total.recordDuration(token, now)
+	if avg := total.Stats().AvgDuration; avg != 300*time.Millisecond {
+		t.Errorf("expected the total time with DurationIncludesWait, got %v", avg)
+	}
+}
+
+func TestLoadshedder_AvgDurationOnRelease(t *testing.T) {
+	ls := New(Config{Limit: 10})
+
+	_, token := ls.Acquire(context.Background())
+	time.Sleep(10 * time.Millisecond)
+	stats := ls.Release(token)
+
+	if stats.AvgDuration < 10*time.Millisecond {
+		t.Errorf("expected AvgDuration>=10ms after release, got %v", stats.AvgDuration)
+	}
+
+	// Rejected tokens and double releases are not measured
+	ls.Release(token)
+	ls.Release(&Token{})
+	if avg := ls.Stats().AvgDuration; avg != stats.AvgDuration {
+		t.Errorf("expected AvgDuration to be unchanged, got %v", avg)
+	}
+}
+
+func TestProjectedWait(t *testing.T) {
+	tests := []struct {
+		waiting, limit int64
+		expected       time.Duration
+	}{
+		{0, 10, 0},
+		{1, 10, 100 * time.Millisecond},
+		{10, 10, 100 * time.Millisecond},
+		{11, 10, 200 * time.Millisecond},
+		{25, 10, 300 * time.Millisecond},
+		{3, 1, 300 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		if got := projectedWait(tt.waiting, tt.limit, 100*time.Millisecond); got != tt.expected {
+			t.Errorf("projectedWait(%d, %d) = %v, expected %v", tt.waiting, tt.limit, got, tt.expected)
+		}
+	}
+}
+
+func TestLoadshedder_ProjectedWait(t *testing.T) {
+	ctx := context.Background()
+	ls := New(Config{Limit: 2, WaitingLimit: 5})
+
+	now := time.Now()
+	ls.recordDuration(&Token{accepted: true, acceptedAt: monoOf(now.Add(-50 * time.Millisecond))}, now)
+
+	_, first := ls.Acquire(ctx)
+	_, second := ls.Acquire(ctx)
+	defer ls.Release(first)
+	defer ls.Release(second)
+
+	if wait := ls.Stats().ProjectedWait; wait != 0 {
+		t.Errorf("expected no projected wait without a queue, got %v", wait)
+	}
+	if retryAfter := ls.Stats().RetryAfter; retryAfter != 50*time.Millisecond {
+		t.Errorf("expected RetryAfter=50ms (the average duration) without a queue, got %v", retryAfter)
+	}
+
+	for range 3 {
+		go func() {
+			queueCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+			defer cancel()
+			_, token := ls.Acquire(queueCtx)
+			ls.Release(token)
+		}()
+	}
+	waitForStats(t, ls, func(s Stats) bool { return s.Waiting == 3 })
+
+	// 3 waiting requests served 2 at a time: 2 waves
+	if wait := ls.Stats().ProjectedWait; wait != 100*time.Millisecond {
+		t.Errorf("expected ProjectedWait=100ms, got %v", wait)
+	}
+	if retryAfter := ls.Stats().RetryAfter; retryAfter != 100*time.Millisecond {
+		t.Errorf("expected RetryAfter=100ms, got %v", retryAfter)
+	}
+}
@@ -0,0 +1,191 @@
+package loadshedder
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultErrorRateWindow      = 10 * time.Second
+	defaultErrorRateMinRequests = 20
+	defaultErrorRateDecrease    = 0.9
+	defaultErrorRateRecovery    = 0.05
+
+	// errorRateBuckets is the number of steps of the sliding window: the
+	// window slides, and the limit is updated, once per Window/errorRateBuckets.
+	errorRateBuckets = 10
+)
+
+// ErrorRateConfig configures the error-rate driven shedding, see
+// Config.ErrorRate. A failing dependency often shows as a spike of errors
+// before any latency or resource signal: the requests fail fast, and keep
+// hammering the dependency. While the fraction of failed requests over the
+// sliding Window exceeds the Threshold, the effective limit is lowered by
+// Decrease at each step of the window; once the error rate is back under the
+// Threshold, it recovers by Recovery of the limit at each step.
+//
+// The middleware counts the responses with a 5xx status and the handler
+// panics as failures; other callers report them with Token.Fail.
+type ErrorRateConfig struct {
+	// Threshold is the fraction of failed requests over the Window above
+	// which the limit is lowered, in (0, 1]. Setting it enables the
+	// error-rate driven shedding.
+	// Optional.
+	Threshold float64
+
+	// Window is the duration of the sliding window of the error rate. The
+	// limit is updated at each tenth of it, on the Acquire path.
+	// Optional, default to 10s.
+	Window time.Duration
+
+	// MinRequests is the number of requests completed over the Window
+	// before the error rate is trusted.
+	// Optional, default to 20.
+	MinRequests int64
+
+	// Decrease is the factor applied to the limit at each step while the
+	// error rate exceeds the Threshold, in (0, 1).
+	// Optional, default to 0.9.
+	Decrease float64
+
+	// Recovery is the fraction of the limit restored at each step once the
+	// error rate is back under the Threshold, in (0, 1].
+	// Optional, default to 0.05 (full recovery in two windows).
+	Recovery float64
+
+	// MinLimit is the lowest limit enforced because of errors.
+	// Optional, default to 1.
+	MinLimit int64
+}
+
+func applyErrorRateDefaults(e ErrorRateConfig, limit int64) ErrorRateConfig {
+	if e.Threshold < 0 || e.Threshold > 1 {
+		panic("loadshedder: ErrorRateConfig.Threshold must be in (0, 1]")
+	}
+	if e.Window <= 0 {
+		e.Window = defaultErrorRateWindow
+	}
+	if e.MinRequests <= 0 {
+		e.MinRequests = defaultErrorRateMinRequests
+	}
+	if e.Decrease == 0 {
+		e.Decrease = defaultErrorRateDecrease
+	}
+	if e.Decrease < 0 || e.Decrease >= 1 {
+		panic("loadshedder: ErrorRateConfig.Decrease must be in (0, 1)")
+	}
+	if e.Recovery == 0 {
+		e.Recovery = defaultErrorRateRecovery
+	}
+	if e.Recovery < 0 || e.Recovery > 1 {
+		panic("loadshedder: ErrorRateConfig.Recovery must be in (0, 1]")
+	}
+	if e.MinLimit <= 0 {
+		e.MinLimit = 1
+	}
+	e.MinLimit = min(e.MinLimit, limit)
+	return e
+}
+
+// errorRateController tracks the outcome of the released tokens, and adjusts
+// the error-rate limit at most once per step, on the Acquire path.
+type errorRateController struct {
+	cfg      ErrorRateConfig
+	step     int64        // nanoseconds
+	nextStep atomic.Int64 // unix nanoseconds
+
+	// outcomes of the current step, recorded on release
+	completed atomic.Int64
+	failed    atomic.Int64
+
+	// guarded by Loadshedder.limitMu
+	buckets [errorRateBuckets]struct{ completed, failed int64 }
+	next    int     // bucket of the current step
+	rate    float64 // error rate over the window, 0 until MinRequests completed
+	limit   float64 // 0 while not shedding
+}
+
+func newErrorRateController(cfg ErrorRateConfig) *errorRateController {
+	return &errorRateController{cfg: cfg, step: int64(cfg.Window / errorRateBuckets)}
+}
+
+// record accounts the outcome of a released token.
+func (e *errorRateController) record(failed bool) {
+	e.completed.Add(1)
+	if failed {
+		e.failed.Add(1)
+	}
+}
+
+// updateErrorRateLimit slides the window and recomputes the error-rate limit
+// if the step elapsed.
+func (l *Loadshedder) updateErrorRateLimit(now time.Time) {
+	e := l.errorRate
+	next := e.nextStep.Load()
+	if now.UnixNano() < next || !e.nextStep.CompareAndSwap(next, now.UnixNano()+e.step) {
+		return
+	}
+
+	l.limitMu.Lock()
+	defer l.limitMu.Unlock()
+
+	e.buckets[e.next].completed = e.completed.Swap(0)
+	e.buckets[e.next].failed = e.failed.Swap(0)
+	e.next = (e.next + 1) % errorRateBuckets
+
+	var completed, failed int64
+	for _, b := range e.buckets {
+		completed += b.completed
+		failed += b.failed
+	}
+	e.rate = 0
+	if completed >= e.cfg.MinRequests {
+		e.rate = float64(failed) / float64(completed)
+	}
+
+	limit := float64(max(1, l.limit.Load()+l.donated))
+	switch {
+	case e.rate > e.cfg.Threshold:
+		if e.limit == 0 {
+			e.limit = limit
+		}
+		e.limit = max(float64(e.cfg.MinLimit), e.limit*e.cfg.Decrease)
+	case e.limit > 0:
+		e.limit += limit * e.cfg.Recovery
+		if e.limit >= limit {
+			e.limit = 0
+		}
+	}
+
+	l.errorRateLimit = int64(e.limit)
+	l.updateEffectiveLimit()
+}
+
+// ErrorRate returns the fraction of failed requests over the window of
+// Config.ErrorRate, 0 until enough requests completed or if it is not set.
+func (l *Loadshedder) ErrorRate() float64 {
+	if l.errorRate == nil {
+		return 0
+	}
+
+	l.limitMu.Lock()
+	defer l.limitMu.Unlock()
+
+	return l.errorRate.rate
+}
+
+// ErrorRateLimit returns the limit enforced because of errors, or 0 while
+// the error rate does not lower the limit, see Config.ErrorRate.
+func (l *Loadshedder) ErrorRateLimit() int64 {
+	l.limitMu.Lock()
+	defer l.limitMu.Unlock()
+
+	return l.errorRateLimit
+}
+
+// Fail marks the work done under the token as failed, for the error-rate
+// driven shedding (see Config.ErrorRate). Call it before Release; the
+// middleware does it for the responses with a 5xx status.
+func (t *Token) Fail() {
+	t.failed.Store(true)
+}
@@ -0,0 +1,93 @@
+package loadshedder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLoadshedder_ErrorRate(t *testing.T) {
+	ls := New(Config{Limit: 10, ErrorRate: ErrorRateConfig{Threshold: 0.5, MinRequests: 4, Recovery: 0.1}})
+
+	complete := func(n int, failed bool) {
+		for range n {
+			_, token := ls.Acquire(context.Background())
+			if failed {
+				token.Fail()
+			}
+			ls.Release(token)
+		}
+	}
+	step := func() Stats {
+		ls.errorRate.nextStep.Store(0)
+		ls.updateErrorRateLimit(time.Now())
+		return ls.Stats()
+	}
+
+	// Under MinRequests, the error rate is not trusted
+	complete(3, true)
+	if stats := step(); stats.EffectiveLimit != 10 || ls.ErrorRate() != 0 {
+		t.Fatalf("expected no shedding under MinRequests, got %+v (rate %v)", stats, ls.ErrorRate())
+	}
+
+	complete(1, true)
+	if stats := step(); stats.EffectiveLimit != 9 || ls.ErrorRate() != 1 || ls.ErrorRateLimit() != 9 {
+		t.Fatalf("expected the limit lowered by the errors, got %+v (rate %v)", stats, ls.ErrorRate())
+	}
+
+	// The errors stay in the window
+	if stats := step(); stats.EffectiveLimit != 8 {
+		t.Errorf("expected the limit lowered again, got %+v", stats)
+	}
+
+	// Recovers gradually once the error rate is under the threshold
+	complete(8, false)
+	if stats := step(); stats.EffectiveLimit != 9 || ls.ErrorRate() != 1.0/3 {
+		t.Errorf("expected a gradual recovery with a 1/3 error rate, got %+v (rate %v)", stats, ls.ErrorRate())
+	}
+	if stats := step(); stats.EffectiveLimit != 10 || ls.ErrorRateLimit() != 0 {
+		t.Errorf("expected the full limit once recovered, got %+v", stats)
+	}
+}
+
+func TestMiddleware_ErrorRate(t *testing.T) {
+	ls := New(Config{Limit: 10, ErrorRate: ErrorRateConfig{Threshold: 0.5, MinRequests: 4}})
+	mw := NewMiddleware(ls, nil, nil)
+
+	status := http.StatusServiceUnavailable
+	handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	for range 4 {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	}
+	status = http.StatusNotFound
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	if completed, failed := ls.errorRate.completed.Load(), ls.errorRate.failed.Load(); completed != 5 || failed != 4 {
+		t.Errorf("expected 4 failures out of 5 requests, got %d out of %d", failed, completed)
+	}
+}
+
+func TestLoadshedder_ErrorRateValidation(t *testing.T) {
+	for name, cfg := range map[string]ErrorRateConfig{
+		"threshold": {Threshold: 1.5},
+		"decrease":  {Threshold: 0.5, Decrease: 1},
+		"recovery":  {Threshold: 0.5, Recovery: -0.5},
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected a panic")
+				}
+			}()
+			New(Config{Limit: 1, ErrorRate: cfg})
+		})
+	}
+
+	if ls := New(Config{Limit: 10}); ls.errorRate != nil || ls.ErrorRate() != 0 {
+		t.Error("expected no error-rate shedding by default")
+	}
+}
@@ -5,5 +5,3 @@ go 1.24.0
 replace github.com/pior/loadshedder => ../..
 
 require github.com/pior/loadshedder v0.0.0-00010101000000-000000000000
-
-require golang.org/x/sync v0.17.0 // indirect
@@ -0,0 +1,32 @@
+package loadshedder
+
+import (
+	"expvar"
+	"fmt"
+)
+
+// PublishExpvar publishes the state of the loadshedder with the expvar
+// package, served as JSON by the standard /debug/vars endpoint:
+//
+//	"loadshedder": {"running": 3, "waiting": 0, "limit": 100, "accepted_total": 1234, "rejected_total": 5}
+//
+// A named loadshedder is published as "loadshedder.<name>", so several
+// loadshedders can be published. The values are read when served.
+// Panics if the name is already published, like expvar.Publish.
+func PublishExpvar(ls *Loadshedder) {
+	name := "loadshedder"
+	if ls.name != "" {
+		name += "." + ls.name
+	}
+	if expvar.Get(name) != nil {
+		panic(fmt.Sprintf("loadshedder: expvar %q is already published", name))
+	}
+
+	vars := new(expvar.Map)
+	vars.Set("running", expvar.Func(func() any { return ls.Stats().Running }))
+	vars.Set("waiting", expvar.Func(func() any { return ls.Stats().Waiting }))
+	vars.Set("limit", expvar.Func(func() any { return ls.Stats().EffectiveLimit }))
+	vars.Set("accepted_total", expvar.Func(func() any { return ls.accepted.Load() }))
+	vars.Set("rejected_total", expvar.Func(func() any { return ls.rejected.Load() }))
+	expvar.Publish(name, vars)
+}
@@ -0,0 +1,44 @@
+package loadshedder
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"testing"
+)
+
+func TestPublishExpvar(t *testing.T) {
+	ls := New(Config{Name: "expvar-test", Limit: 1})
+	PublishExpvar(ls)
+
+	_, token := ls.Acquire(context.Background())
+	defer ls.Release(token)
+	ls.Acquire(context.Background())
+
+	v := expvar.Get("loadshedder.expvar-test")
+	if v == nil {
+		t.Fatal("expected the expvar to be published")
+	}
+
+	var vars map[string]int64
+	if err := json.Unmarshal([]byte(v.String()), &vars); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]int64{"running": 1, "waiting": 0, "limit": 1, "accepted_total": 1, "rejected_total": 1}
+	for key, value := range expected {
+		if vars[key] != value {
+			t.Errorf("expected %s=%d, got %v", key, value, vars)
+		}
+	}
+}
+
+func TestPublishExpvar_Duplicate(t *testing.T) {
+	PublishExpvar(New(Config{Name: "expvar-duplicate", Limit: 1}))
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	PublishExpvar(New(Config{Name: "expvar-duplicate", Limit: 1}))
+}
@@ -0,0 +1,173 @@
+package loadshedder
+
+import (
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+const defaultFairnessInterval = time.Minute
+
+// FairnessConfig configures the fairness reports, see ReportFairness.
+type FairnessConfig struct {
+	// Interval is the time slice covered by each report.
+	// Optional, default to 1m.
+	Interval time.Duration
+
+	// OnReport receives each report, e.g. to publish it as an event.
+	// Optional.
+	OnReport func(FairnessReport)
+
+	// Logger logs each report.
+	// Optional, default to slog.Default() if OnReport is not set.
+	Logger *slog.Logger
+}
+
+// FairnessReport summarizes the admissions of each label (see WithLabel)
+// over a time slice.
+type FairnessReport struct {
+	Name   string
+	Start  time.Time
+	End    time.Time
+	Labels map[string]Counters // Counters accumulated during the slice
+
+	// AcceptedGini is the Gini coefficient of the accepted requests across
+	// labels: 0 when every label got as much, close to 1 when a single label
+	// got everything.
+	AcceptedGini float64
+
+	// RejectedGini is the Gini coefficient of the rejected requests across labels.
+	RejectedGini float64
+}
+
+// RejectionRate returns the fraction of the acquisitions of the label that
+// were rejected during the slice.
+func (r FairnessReport) RejectionRate(label string) float64 {
+	c := r.Labels[label]
+	if total := c.Accepted + c.Rejected; total > 0 {
+		return float64(c.Rejected) / float64(total)
+	}
+	return 0
+}
+
+// ReportFairness starts a goroutine reporting, every interval, the
+// admissions per label and how skewed they are, as evidence of how the
+// capacity was shared between tenants or routes.
+// Call the returned function to stop reporting.
+func (l *Loadshedder) ReportFairness(cfg FairnessConfig) (stop func()) {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultFairnessInterval
+	}
+	if cfg.Logger == nil && cfg.OnReport == nil {
+		cfg.Logger = slog.Default()
+	}
+
+	// The first slice starts now, not when the goroutine gets scheduled
+	start := time.Now()
+	previous := l.CountersByLabel()
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case end := <-ticker.C:
+				current := l.CountersByLabel()
+				report := l.fairnessReport(previous, current, start, end)
+				previous, start = current, end
+
+				if cfg.OnReport != nil {
+					cfg.OnReport(report)
+				}
+				if cfg.Logger != nil {
+					logFairnessReport(cfg.Logger, report)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
+
+// fairnessReport computes the report of a slice from the label counters at
+// its start and end. Labels without activity during the slice are omitted.
+func (l *Loadshedder) fairnessReport(previous, current map[string]Counters, start, end time.Time) FairnessReport {
+	report := FairnessReport{Name: l.name, Start: start, End: end, Labels: make(map[string]Counters)}
+
+	var accepted, rejected []int64
+	for label, c := range current {
+		p := previous[label]
+		delta := Counters{
+			Accepted: c.Accepted - p.Accepted,
+			Rejected: c.Rejected - p.Rejected,
+			Released: c.Released - p.Released,
+		}
+		if delta == (Counters{}) {
+			continue
+		}
+
+		report.Labels[label] = delta
+		accepted = append(accepted, delta.Accepted)
+		rejected = append(rejected, delta.Rejected)
+	}
+
+	report.AcceptedGini = gini(accepted)
+	report.RejectedGini = gini(rejected)
+	return report
+}
+
+// gini returns the Gini coefficient of the values: 0 for a perfectly equal
+// distribution, (n-1)/n when a single value holds everything.
+func gini(values []int64) float64 {
+	n := len(values)
+	if n < 2 {
+		return 0
+	}
+
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+
+	var sum, weighted float64
+	for i, v := range sorted {
+		sum += float64(v)
+		weighted += float64(i+1) * float64(v)
+	}
+	if sum == 0 {
+		return 0
+	}
+	return 2*weighted/(float64(n)*sum) - float64(n+1)/float64(n)
+}
+
+func logFairnessReport(logger *slog.Logger, report FairnessReport) {
+	mostRejected, maxRate := "", 0.0
+	for label := range report.Labels {
+		if rate := report.RejectionRate(label); rate > maxRate {
+			mostRejected, maxRate = label, rate
+		}
+	}
+
+	logger.Info("loadshedder: fairness report",
+		"name", report.Name,
+		"interval", report.End.Sub(report.Start).Round(time.Millisecond),
+		"labels", len(report.Labels),
+		"accepted_gini", report.AcceptedGini,
+		"rejected_gini", report.RejectedGini,
+		"most_rejected", mostRejected,
+		"most_rejected_rate", maxRate,
+	)
+}
@@ -0,0 +1,91 @@
+package loadshedder
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGini(t *testing.T) {
+	tests := []struct {
+		values   []int64
+		expected float64
+	}{
+		{nil, 0},
+		{[]int64{10}, 0},
+		{[]int64{0, 0}, 0},
+		{[]int64{5, 5, 5, 5}, 0},
+		{[]int64{0, 0, 0, 100}, 0.75},
+		{[]int64{1, 3}, 0.25},
+	}
+
+	for _, tt := range tests {
+		if got := gini(tt.values); math.Abs(got-tt.expected) > 1e-9 {
+			t.Errorf("gini(%v): expected %f, got %f", tt.values, tt.expected, got)
+		}
+	}
+}
+
+func TestLoadshedder_FairnessReport(t *testing.T) {
+	ls := New(Config{Name: "api", Limit: 10})
+	previous := map[string]Counters{
+		"tenant-a": {Accepted: 10, Rejected: 0, Released: 10},
+		"tenant-b": {Accepted: 5, Rejected: 5, Released: 5},
+		"idle":     {Accepted: 3, Released: 3},
+	}
+	current := map[string]Counters{
+		"tenant-a": {Accepted: 40, Rejected: 0, Released: 40},
+		"tenant-b": {Accepted: 15, Rejected: 35, Released: 15},
+		"idle":     {Accepted: 3, Released: 3},
+		"new":      {Accepted: 10, Released: 10},
+	}
+
+	start := time.Now()
+	report := ls.fairnessReport(previous, current, start, start.Add(time.Minute))
+
+	if report.Name != "api" || len(report.Labels) != 3 {
+		t.Fatalf("expected 3 active labels, got %+v", report)
+	}
+	if got := report.Labels["tenant-b"]; got != (Counters{Accepted: 10, Rejected: 30, Released: 10}) {
+		t.Errorf("expected the counters of the slice, got %+v", got)
+	}
+	if rate := report.RejectionRate("tenant-b"); rate != 0.75 {
+		t.Errorf("expected a 75%% rejection rate, got %f", rate)
+	}
+	if rate := report.RejectionRate("missing"); rate != 0 {
+		t.Errorf("expected no rejection rate for a missing label, got %f", rate)
+	}
+	if report.AcceptedGini <= 0 || report.RejectedGini <= report.AcceptedGini {
+		t.Errorf("expected rejections more skewed than admissions, got %+v", report)
+	}
+}
+
+func TestLoadshedder_ReportFairness(t *testing.T) {
+	ls := New(Config{Limit: 10})
+	reports := make(chan FairnessReport, 10)
+	logs := &syncBuffer{}
+
+	stop := ls.ReportFairness(FairnessConfig{
+		Interval: 5 * time.Millisecond,
+		OnReport: func(r FairnessReport) { reports <- r },
+		Logger:   slog.New(slog.NewTextHandler(logs, nil)),
+	})
+	defer stop()
+
+	_, token := ls.Acquire(context.Background(), WithLabel("tenant-a"))
+	ls.Release(token)
+
+	for report := range reports {
+		if report.Labels["tenant-a"].Accepted == 1 {
+			break
+		}
+	}
+	stop()
+
+	if !strings.Contains(logs.String(), "fairness report") {
+		t.Errorf("expected the report to be logged, got %s", logs.String())
+	}
+}
@@ -0,0 +1,162 @@
+package loadshedder
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultHealthCheckThreshold = 0.1
+	defaultHealthCheckWindow    = time.Second
+)
+
+// HealthCheckConfig configures the health check fast path, see WithHealthChecks.
+type HealthCheckConfig struct {
+	// Match identifies health check requests, e.g. HealthCheckPaths("/healthz").
+	// Required.
+	Match func(*http.Request) bool
+
+	// Handler responds to diverted health checks with the loadshedder state.
+	// Optional, default to a JSON summary of the Stats, with a 503 status
+	// while the loadshedder is draining.
+	Handler func(Stats) http.HandlerFunc
+
+	// Threshold is the fraction of the traffic above which health checks are
+	// diverted even when the loadshedder is not saturated, as probes then
+	// consume significant capacity.
+	// Optional, default to 0.1.
+	Threshold float64
+
+	// Window is the period over which the health check fraction is measured.
+	// Optional, default to 1s.
+	Window time.Duration
+}
+
+// WithHealthChecks diverts health checks to a lightweight handler that
+// bypasses the loadshedder and reports its state, when the loadshedder is
+// saturated (probes would be queued or shed, making load balancers pull a
+// busy but healthy instance) or when probes amount to more than Threshold of
+// the traffic (probes would consume capacity). Otherwise health checks go
+// through the loadshedder like any request.
+// See DivertedHealthChecks.
+func WithHealthChecks(cfg HealthCheckConfig) MiddlewareOption {
+	hc := newHealthChecks(cfg)
+	return func(m *Middleware) {
+		m.healthChecks = hc
+	}
+}
+
+// HealthCheckPaths matches requests for any of the URL paths.
+func HealthCheckPaths(paths ...string) func(*http.Request) bool {
+	return func(r *http.Request) bool {
+		return slices.Contains(paths, r.URL.Path)
+	}
+}
+
+// DivertedHealthChecks returns the number of health checks served by the
+// fast path, see WithHealthChecks.
+func (m *Middleware) DivertedHealthChecks() int64 {
+	if m.healthChecks == nil {
+		return 0
+	}
+	return m.healthChecks.diverted.Load()
+}
+
+type healthChecks struct {
+	match     func(*http.Request) bool
+	handler   func(Stats) http.HandlerFunc
+	threshold float64
+	window    int64 // nanoseconds
+
+	windowEnd atomic.Int64  // unix nanoseconds
+	total     atomic.Int64  // requests in the current window
+	probes    atomic.Int64  // health checks in the current window
+	fraction  atomic.Uint64 // math.Float64bits of the previous window fraction
+	diverted  atomic.Int64
+}
+
+func newHealthChecks(cfg HealthCheckConfig) *healthChecks {
+	if cfg.Match == nil {
+		panic("loadshedder: HealthCheckConfig.Match is required")
+	}
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = defaultHealthCheckThreshold
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = defaultHealthCheckWindow
+	}
+
+	return &healthChecks{
+		match:     cfg.Match,
+		handler:   cfg.Handler,
+		threshold: cfg.Threshold,
+		window:    int64(cfg.Window),
+	}
+}
+
+// serveDiverted serves the request with the fast path if it is a health check
+// that should not go through the loadshedder. Returns false otherwise.
+func (h *healthChecks) serveDiverted(w http.ResponseWriter, r *http.Request, ls *Loadshedder) bool {
+	h.rollWindow(time.Now())
+
+	h.total.Add(1)
+	if !h.match(r) {
+		return false
+	}
+	h.probes.Add(1)
+
+	stats := ls.Stats()
+	draining := ls.Draining()
+	saturated := stats.Waiting > 0 || stats.Running >= stats.EffectiveLimit || draining
+	amplified := math.Float64frombits(h.fraction.Load()) >= h.threshold
+	if !saturated && !amplified {
+		return false
+	}
+
+	h.diverted.Add(1)
+	if h.handler != nil {
+		h.handler(stats).ServeHTTP(w, r)
+		return true
+	}
+	writeHealthCheck(w, stats, draining)
+	return true
+}
+
+// rollWindow records the health check fraction of the window once it ended.
+func (h *healthChecks) rollWindow(now time.Time) {
+	end := h.windowEnd.Load()
+	if now.UnixNano() < end || !h.windowEnd.CompareAndSwap(end, now.UnixNano()+h.window) {
+		return
+	}
+
+	total, probes := h.total.Swap(0), h.probes.Swap(0)
+	var fraction float64
+	if total > 0 {
+		fraction = float64(probes) / float64(total)
+	}
+	h.fraction.Store(math.Float64bits(fraction))
+}
+
+// writeHealthCheck responds with a JSON summary of the loadshedder state.
+func writeHealthCheck(w http.ResponseWriter, stats Stats, draining bool) {
+	status := http.StatusOK
+	state := "ok"
+	if draining {
+		status = http.StatusServiceUnavailable
+		state = "draining"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"status":  state,
+		"name":    stats.Name,
+		"running": stats.Running,
+		"waiting": stats.Waiting,
+		"limit":   stats.EffectiveLimit,
+	})
+}
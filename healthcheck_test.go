@@ -0,0 +1,117 @@
+package loadshedder
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMiddleware_WithHealthChecks(t *testing.T) {
+	ls := New(Config{Name: "api", Limit: 1})
+	mw := NewMiddleware(ls, nil, NewRejectionHandler(1), WithHealthChecks(HealthCheckConfig{
+		Match:  HealthCheckPaths("/healthz"),
+		Window: time.Hour,
+	}))
+
+	var handled int
+	handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handled++
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	request := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, http.NoBody))
+		return rec
+	}
+
+	// Health checks go through the loadshedder while it has capacity
+	if rec := request("/healthz"); rec.Code != http.StatusOK || handled != 1 {
+		t.Fatalf("expected the health check to reach the handler, got %d (handled=%d)", rec.Code, handled)
+	}
+	if got := mw.DivertedHealthChecks(); got != 0 {
+		t.Errorf("expected no diverted health check, got %d", got)
+	}
+
+	// Saturate the limiter: other requests are shed, health checks are not
+	_, token := ls.Acquire(context.Background())
+	defer ls.Release(token)
+
+	if rec := request("/"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected a regular request to be rejected, got %d", rec.Code)
+	}
+
+	rec := request("/healthz")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the diverted health check to succeed, got %d", rec.Code)
+	}
+	if handled != 1 {
+		t.Errorf("expected the diverted health check to bypass the handler")
+	}
+	var body map[string]any
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body["name"] != "api" || body["running"] != 1.0 || body["limit"] != 1.0 {
+		t.Errorf("unexpected health check body: %v", body)
+	}
+	if got := mw.DivertedHealthChecks(); got != 1 {
+		t.Errorf("expected 1 diverted health check, got %d", got)
+	}
+	if got := ls.Counters().Accepted; got != 2 {
+		t.Errorf("expected the diverted health check not to be admitted, got %d admissions", got)
+	}
+}
+
+func TestMiddleware_WithHealthChecksAmplified(t *testing.T) {
+	ls := New(Config{Limit: 10})
+	mw := NewMiddleware(ls, nil, nil, WithHealthChecks(HealthCheckConfig{
+		Match:     HealthCheckPaths("/healthz"),
+		Threshold: 0.5,
+		Window:    20 * time.Millisecond,
+	}))
+
+	handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	request := func(path string) {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, http.NoBody))
+	}
+
+	// Health checks dominate the traffic of a window
+	request("/")
+	request("/healthz")
+	request("/healthz")
+	if got := mw.DivertedHealthChecks(); got != 0 {
+		t.Fatalf("expected no diverted health check in the first window, got %d", got)
+	}
+
+	// From the next window, health checks bypass the loadshedder
+	time.Sleep(30 * time.Millisecond)
+	request("/healthz")
+	if got := mw.DivertedHealthChecks(); got != 1 {
+		t.Errorf("expected the health check to be diverted, got %d", got)
+	}
+}
+
+func TestMiddleware_WithHealthChecksDraining(t *testing.T) {
+	ls := New(Config{Limit: 10})
+	mw := NewMiddleware(ls, nil, nil, WithHealthChecks(HealthCheckConfig{
+		Match: HealthCheckPaths("/healthz"),
+	}))
+	handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	if err := ls.Drain(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", http.NoBody))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 while draining, got %d", rec.Code)
+	}
+}
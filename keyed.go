@@ -0,0 +1,172 @@
+package loadshedder
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// KeyFunc extracts the key of a request, e.g. a tenant ID, an API key or
+// RemoteIPKey.
+type KeyFunc func(*http.Request) string
+
+// KeyedConfig configures a KeyedLoadshedder.
+type KeyedConfig struct {
+	// Config configures the loadshedder created for each key, named after
+	// the key. The loadshedder shared by requests without a key (or beyond
+	// MaxKeys) keeps Config.Name.
+	// Required.
+	Config Config
+
+	// Key extracts the key of a request, see Classifier.
+	// Required.
+	Key KeyFunc
+
+	// IdleTimeout is the time after which the loadshedder of a key without
+	// running or waiting requests is evicted.
+	// Optional, default to 5m.
+	IdleTimeout time.Duration
+
+	// MaxKeys bounds the number of keys with their own loadshedder; while
+	// the table is full of active keys, new keys share the loadshedder of
+	// requests without a key.
+	// Optional, default to 10000.
+	MaxKeys int
+}
+
+// KeyedLoadshedder maintains one loadshedder per key, created on first use,
+// so a single noisy tenant is shed without affecting the others.
+type KeyedLoadshedder struct {
+	config      Config
+	key         KeyFunc
+	idleTimeout time.Duration
+	maxKeys     int
+	shared      *Loadshedder // requests without a key or beyond maxKeys
+
+	mu        sync.Mutex
+	entries   map[string]*keyedEntry
+	lastSweep monotime
+}
+
+type keyedEntry struct {
+	ls       *Loadshedder
+	lastUsed atomic.Int64 // monotime
+}
+
+// NewKeyed creates a KeyedLoadshedder.
+func NewKeyed(cfg KeyedConfig) *KeyedLoadshedder {
+	if cfg.Key == nil {
+		panic("loadshedder: KeyedConfig.Key is required")
+	}
+	if cfg.IdleTimeout <= 0 {
+		cfg.IdleTimeout = 5 * time.Minute
+	}
+	if cfg.MaxKeys <= 0 {
+		cfg.MaxKeys = 10000
+	}
+
+	return &KeyedLoadshedder{
+		config:      cfg.Config,
+		key:         cfg.Key,
+		idleTimeout: cfg.IdleTimeout,
+		maxKeys:     cfg.MaxKeys,
+		shared:      New(cfg.Config),
+		entries:     make(map[string]*keyedEntry),
+		lastSweep:   monoOf(time.Now()),
+	}
+}
+
+// Get returns the loadshedder of the key, creating it if needed.
+// An empty key returns the shared loadshedder.
+func (k *KeyedLoadshedder) Get(key string) *Loadshedder {
+	if key == "" {
+		return k.shared
+	}
+
+	now := monoOf(time.Now())
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if now.Sub(k.lastSweep) >= k.idleTimeout {
+		k.evictIdle(now)
+	}
+
+	entry, ok := k.entries[key]
+	if !ok {
+		if len(k.entries) >= k.maxKeys {
+			k.evictIdle(now)
+			if len(k.entries) >= k.maxKeys {
+				return k.shared
+			}
+		}
+
+		cfg := k.config
+		cfg.Name = key
+		entry = &keyedEntry{ls: New(cfg)}
+		k.entries[key] = entry
+	}
+	entry.lastUsed.Store(int64(now))
+	return entry.ls
+}
+
+// evictIdle removes the loadshedders without running or waiting requests
+// that were not used within the idle timeout. Must hold mu.
+func (k *KeyedLoadshedder) evictIdle(now monotime) {
+	k.lastSweep = now
+	for key, entry := range k.entries {
+		idle := now.Sub(monotime(entry.lastUsed.Load())) >= k.idleTimeout
+		if idle && entry.ls.current.Load() == 0 {
+			delete(k.entries, key)
+		}
+	}
+}
+
+// Len returns the number of keys with their own loadshedder.
+func (k *KeyedLoadshedder) Len() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return len(k.entries)
+}
+
+// Stats returns the aggregate statistics of the loadshedders of all keys and
+// the shared one: running and waiting requests and limits are summed, the
+// durations and pressure are the highest.
+func (k *KeyedLoadshedder) Stats() Stats {
+	k.mu.Lock()
+	all := make([]*Loadshedder, 0, len(k.entries)+1)
+	all = append(all, k.shared)
+	for _, entry := range k.entries {
+		all = append(all, entry.ls)
+	}
+	k.mu.Unlock()
+
+	total := Stats{Name: k.config.Name}
+	for _, ls := range all {
+		stats := ls.Stats()
+		total.Running += stats.Running
+		total.Waiting += stats.Waiting
+		total.Limit += stats.Limit
+		total.ConfiguredLimit += stats.ConfiguredLimit
+		total.EffectiveLimit += stats.EffectiveLimit
+		total.AvgDuration = max(total.AvgDuration, stats.AvgDuration)
+		total.ProjectedWait = max(total.ProjectedWait, stats.ProjectedWait)
+		total.Pressure = max(total.Pressure, stats.Pressure)
+	}
+	return total
+}
+
+// Classifier returns a Classifier routing each request to the loadshedder of its key.
+func (k *KeyedLoadshedder) Classifier() Classifier {
+	return func(r *http.Request) *Loadshedder {
+		return k.Get(k.key(r))
+	}
+}
+
+// NewKeyedMiddleware creates a middleware accounting each key in its own loadshedder.
+// See NewMiddleware for the reporter, rejectionHandler and opts parameters.
+func NewKeyedMiddleware(k *KeyedLoadshedder, reporter Reporter, rejectionHandler RejectionHandler, opts ...MiddlewareOption) *Middleware {
+	opts = append([]MiddlewareOption{WithClassifier(k.Classifier())}, opts...)
+	return NewMiddleware(k.shared, reporter, rejectionHandler, opts...)
+}
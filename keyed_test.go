@@ -0,0 +1,102 @@
+package loadshedder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func tenantKey(r *http.Request) string {
+	return r.Header.Get("X-Tenant")
+}
+
+func TestKeyedMiddleware_ShedsNoisyTenantOnly(t *testing.T) {
+	keyed := NewKeyed(KeyedConfig{Config: Config{Name: "api", Limit: 1}, Key: tenantKey})
+	mw := NewKeyedMiddleware(keyed, nil, nil)
+
+	var nestedCodes []int
+	var handler http.Handler
+	handler = mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/outer" {
+			// tenant-a holds its only slot
+			for _, tenant := range []string{"tenant-a", "tenant-b"} {
+				req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+				req.Header.Set("X-Tenant", tenant)
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, req)
+				nestedCodes = append(nestedCodes, rec.Code)
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/outer", http.NoBody)
+	req.Header.Set("X-Tenant", "tenant-a")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(nestedCodes) != 2 || nestedCodes[0] != http.StatusTooManyRequests || nestedCodes[1] != http.StatusOK {
+		t.Errorf("expected tenant-a rejected and tenant-b accepted, got %v", nestedCodes)
+	}
+	if keyed.Len() != 2 {
+		t.Errorf("expected 2 keys, got %d", keyed.Len())
+	}
+	if name := keyed.Get("tenant-b").Stats().Name; name != "tenant-b" {
+		t.Errorf("expected the loadshedder to be named after its key, got %q", name)
+	}
+}
+
+func TestKeyedLoadshedder_SharedWithoutKey(t *testing.T) {
+	keyed := NewKeyed(KeyedConfig{Config: Config{Name: "api", Limit: 1}, Key: tenantKey, MaxKeys: 1})
+
+	if ls := keyed.Get(""); ls != keyed.Get("") || ls.Stats().Name != "api" {
+		t.Error("expected requests without a key to share one loadshedder")
+	}
+
+	tenant := keyed.Get("tenant-a")
+	if keyed.Get("tenant-a") != tenant {
+		t.Error("expected the same loadshedder for the same key")
+	}
+
+	// tenant-a was used recently: the table is full
+	if keyed.Get("tenant-b") != keyed.Get("") {
+		t.Error("expected keys beyond MaxKeys to share the loadshedder without a key")
+	}
+}
+
+func TestKeyedLoadshedder_EvictsIdleKeys(t *testing.T) {
+	keyed := NewKeyed(KeyedConfig{Config: Config{Limit: 1}, Key: tenantKey, IdleTimeout: 20 * time.Millisecond})
+
+	busy := keyed.Get("busy")
+	_, token := busy.Acquire(context.Background())
+	defer busy.Release(token)
+	idle := keyed.Get("idle")
+
+	time.Sleep(30 * time.Millisecond)
+	keyed.Get("other")
+
+	if keyed.Len() != 2 {
+		t.Errorf("expected the idle key to be evicted, got %d keys", keyed.Len())
+	}
+	if keyed.Get("busy") != busy {
+		t.Error("expected a key with running requests to be kept")
+	}
+	if keyed.Get("idle") == idle {
+		t.Error("expected a new loadshedder for an evicted key")
+	}
+}
+
+func TestKeyedLoadshedder_Stats(t *testing.T) {
+	keyed := NewKeyed(KeyedConfig{Config: Config{Name: "api", Limit: 2}, Key: tenantKey})
+
+	_, a := keyed.Get("tenant-a").Acquire(context.Background())
+	_, b := keyed.Get("tenant-b").Acquire(context.Background())
+	defer keyed.Get("tenant-a").Release(a)
+	defer keyed.Get("tenant-b").Release(b)
+
+	stats := keyed.Stats()
+	if stats.Name != "api" || stats.Running != 2 || stats.Limit != 6 {
+		t.Errorf("expected 2 running over 3 limits of 2, got %+v", stats)
+	}
+}
@@ -0,0 +1,73 @@
+package loadshedder
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	defaultMaxLabels = 100
+
+	// OtherLabel accounts the labels beyond Config.MaxLabels.
+	OtherLabel = "other"
+)
+
+// labelCounters are the Counters of a label.
+type labelCounters struct {
+	label    string // the label accounted, OtherLabel beyond the bound
+	accepted atomic.Int64
+	rejected atomic.Int64
+	released atomic.Int64
+}
+
+// labelSet is a bounded set of label counters.
+type labelSet struct {
+	max      int64
+	size     atomic.Int64
+	counters sync.Map // label -> *labelCounters
+}
+
+// countersFor returns the counters of the label, or of OtherLabel once the
+// set is full. Concurrent first uses may exceed the bound by a few labels.
+func (s *labelSet) countersFor(label string) *labelCounters {
+	if c, ok := s.counters.Load(label); ok {
+		return c.(*labelCounters)
+	}
+
+	if s.size.Load() >= s.max {
+		label = OtherLabel
+	}
+
+	c, loaded := s.counters.LoadOrStore(label, &labelCounters{label: label})
+	if !loaded {
+		s.size.Add(1)
+	}
+	return c.(*labelCounters)
+}
+
+// countRejected counts a rejection of the label, if any, and returns the
+// label it is accounted under.
+func (s *labelSet) countRejected(label string) string {
+	if label == "" {
+		return ""
+	}
+	c := s.countersFor(label)
+	c.rejected.Add(1)
+	return c.label
+}
+
+// CountersByLabel returns the totals since creation for each label set with
+// WithLabel. Beyond Config.MaxLabels, new labels are accounted under OtherLabel.
+func (l *Loadshedder) CountersByLabel() map[string]Counters {
+	result := make(map[string]Counters)
+	l.labels.counters.Range(func(label, value any) bool {
+		c := value.(*labelCounters)
+		result[label.(string)] = Counters{
+			Accepted: c.accepted.Load(),
+			Rejected: c.rejected.Load(),
+			Released: c.released.Load(),
+		}
+		return true
+	})
+	return result
+}
@@ -0,0 +1,107 @@
+package loadshedder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoadshedder_CountersByLabel(t *testing.T) {
+	ctx := context.Background()
+	ls := New(Config{Limit: 1})
+
+	_, search := ls.Acquire(ctx, WithLabel("/search"))
+	_, rejected := ls.Acquire(ctx, WithLabel("/checkout"))
+	_, unlabeled := ls.Acquire(ctx)
+	ls.Release(search)
+	ls.Release(rejected)
+	ls.Release(unlabeled)
+
+	if search.Label() != "/search" {
+		t.Errorf("expected token label /search, got %q", search.Label())
+	}
+
+	expected := map[string]Counters{
+		"/search":   {Accepted: 1, Released: 1},
+		"/checkout": {Rejected: 1},
+	}
+	counters := ls.CountersByLabel()
+	if len(counters) != len(expected) {
+		t.Fatalf("expected %d labels, got %v", len(expected), counters)
+	}
+	for label, c := range expected {
+		if counters[label] != c {
+			t.Errorf("%s: expected %+v, got %+v", label, c, counters[label])
+		}
+	}
+}
+
+func TestLoadshedder_CountersByLabelBounded(t *testing.T) {
+	ls := New(Config{Limit: 10, MaxLabels: 2})
+
+	for _, label := range []string{"a", "b", "c", "d", "a"} {
+		_, token := ls.Acquire(context.Background(), WithLabel(label))
+		ls.Release(token)
+	}
+
+	counters := ls.CountersByLabel()
+	if counters["a"].Accepted != 2 || counters["b"].Accepted != 1 {
+		t.Errorf("expected the first labels to be tracked, got %v", counters)
+	}
+	if counters[OtherLabel].Accepted != 2 {
+		t.Errorf("expected the overflowing labels under %q, got %v", OtherLabel, counters)
+	}
+	if len(counters) != 3 {
+		t.Errorf("expected 3 labels, got %v", counters)
+	}
+
+	// The Stats carry the label the acquisition is accounted under
+	stats, token := ls.Acquire(context.Background(), WithLabel("e"))
+	ls.Release(token)
+	if stats.Label != OtherLabel {
+		t.Errorf("expected the Stats label %q, got %q", OtherLabel, stats.Label)
+	}
+}
+
+func TestMiddleware_CountersByRoute(t *testing.T) {
+	ls := New(Config{Limit: 10})
+	mw := NewMiddleware(ls, nil, nil, WithRequestOptions(func(r *http.Request) []AcquireOption {
+		return []AcquireOption{WithLabel(r.Method + " " + r.URL.Path)}
+	}))
+	handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for range 3 {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users", http.NoBody))
+	}
+
+	if c := ls.CountersByLabel()["GET /users"]; c.Accepted != 3 || c.Released != 3 {
+		t.Errorf("expected 3 accepted and released requests, got %+v", c)
+	}
+}
+
+func TestMiddleware_WithRequestLabel(t *testing.T) {
+	ls := New(Config{Limit: 1})
+	reporter := &statsRecordingReporter{}
+	mw := NewMiddleware(ls, reporter, nil, WithRequestLabel(func(r *http.Request) string {
+		return r.URL.Path
+	}))
+
+	var handler http.Handler
+	handler = mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/export" {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/lookup", http.NoBody))
+		}
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/export", http.NoBody))
+
+	if len(reporter.accepted) != 1 || reporter.accepted[0].Label != "/export" {
+		t.Errorf("expected the accepted export to be labeled, got %+v", reporter.accepted)
+	}
+	if len(reporter.rejected) != 1 || reporter.rejected[0].Label != "/lookup" {
+		t.Errorf("expected the rejected lookup to be labeled, got %+v", reporter.rejected)
+	}
+	if c := ls.CountersByLabel()["/lookup"]; c.Rejected != 1 {
+		t.Errorf("expected 1 rejected lookup, got %+v", c)
+	}
+}
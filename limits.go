@@ -0,0 +1,81 @@
+package loadshedder
+
+// The effective limit is the concurrency limit actually enforced. It starts at
+// the configured limit, adjusted by capacity donations between loadshedders
+// (see Registry.Donate), and is lowered by runtime mechanisms such as Clamp,
+// overload Signals, the Adaptive mode, the ErrorRate and the ColdStart; the
+// lowest of them wins.
+// Lowering it does not interrupt running requests: new requests are only
+// admitted once running requests drop below the effective limit.
+
+// Clamp caps the effective limit at the given value (minimum 1) until Unclamp
+// is called. It is intended for emergency load reduction, see HandleSignals.
+func (l *Loadshedder) Clamp(limit int64) {
+	l.limitMu.Lock()
+	defer l.limitMu.Unlock()
+
+	l.clamp = max(1, limit)
+	l.updateEffectiveLimit()
+}
+
+// Unclamp removes the cap set by Clamp.
+func (l *Loadshedder) Unclamp() {
+	l.limitMu.Lock()
+	defer l.limitMu.Unlock()
+
+	l.clamp = 0
+	l.updateEffectiveLimit()
+}
+
+// SetLimit changes the configured limit (Config.Limit), e.g. from an
+// operational knob, see WatchLimit. Raising it admits waiters right away;
+// lowering it does not interrupt running requests. With Config.LimitRamp,
+// the limit ramps to the new value instead, see RampLimit. Panics if limit
+// is not positive.
+func (l *Loadshedder) SetLimit(limit int64) {
+	l.RampLimit(limit, l.config.LimitRamp)
+}
+
+// SetWaitingLimit changes the waiting limit (Config.WaitingLimit), e.g. from
+// an operational knob. Raising it lets more requests wait right away;
+// lowering it rejects the queued requests beyond the new limit, starting with
+// the ones that would be served last. Running requests are not interrupted.
+// Zero switches the loadshedder to the counter-only mode, any other limit
+// switches it back to the waiting queue. Panics if limit is negative.
+func (l *Loadshedder) SetWaitingLimit(limit int64) {
+	if limit < 0 {
+		panic("loadshedder: waiting limit cannot be negative")
+	}
+
+	l.limitMu.Lock()
+	defer l.limitMu.Unlock()
+
+	l.updateSettings(func(s *settings) { s.waitingLimit = limit })
+	l.slots.setWaitingLimit(limit)
+}
+
+// updateEffectiveLimit recomputes the effective limit. Must hold limitMu.
+func (l *Loadshedder) updateEffectiveLimit() {
+	effective := max(1, l.limit.Load()+l.donated)
+	if l.clamp > 0 {
+		effective = min(effective, l.clamp)
+	}
+	if l.signalLimit > 0 {
+		effective = min(effective, l.signalLimit)
+	}
+	if l.adaptiveLimit > 0 {
+		effective = min(effective, l.adaptiveLimit)
+	}
+	if l.errorRateLimit > 0 {
+		effective = min(effective, l.errorRateLimit)
+	}
+	if l.coldStarting() {
+		effective = min(effective, l.coldStart.limit(l.limit.Load()))
+	}
+	if l.chaosFactor > 0 {
+		effective = max(1, int64(float64(effective)*l.chaosFactor))
+	}
+
+	l.effectiveLimit.Store(effective)
+	l.slots.resize(effective)
+}
@@ -0,0 +1,288 @@
+package loadshedder
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLoadshedder_Clamp(t *testing.T) {
+	ctx := context.Background()
+
+	ls := New(Config{Limit: 4})
+
+	var tokens []*Token
+	for range 3 {
+		_, token := ls.Acquire(ctx)
+		if !token.Accepted() {
+			t.Fatal("expected acquisition to succeed")
+		}
+		tokens = append(tokens, token)
+	}
+
+	ls.Clamp(2)
+
+	stats := ls.Stats()
+	if stats.ConfiguredLimit != 4 || stats.EffectiveLimit != 2 || stats.Limit != 2 {
+		t.Errorf("expected ConfiguredLimit=4, EffectiveLimit=2, Limit=2, got %+v", stats)
+	}
+	// Running requests are not interrupted
+	if stats.Running != 3 {
+		t.Errorf("expected Running=3, got %+v", stats)
+	}
+
+	_, token := ls.Acquire(ctx)
+	if token.Accepted() {
+		t.Fatal("expected acquisition to be rejected while over the clamped limit")
+	}
+
+	// Back under the clamped limit
+	ls.Release(tokens[0])
+	ls.Release(tokens[1])
+
+	_, token = ls.Acquire(ctx)
+	if !token.Accepted() {
+		t.Fatal("expected acquisition to succeed under the clamped limit")
+	}
+	tokens = append(tokens, token)
+
+	_, token = ls.Acquire(ctx)
+	if token.Accepted() {
+		t.Fatal("expected acquisition to be rejected at the clamped limit")
+	}
+
+	ls.Unclamp()
+
+	stats = ls.Stats()
+	if stats.EffectiveLimit != 4 {
+		t.Errorf("expected EffectiveLimit=4 after Unclamp, got %+v", stats)
+	}
+
+	_, token = ls.Acquire(ctx)
+	if !token.Accepted() {
+		t.Fatal("expected acquisition to succeed after Unclamp")
+	}
+	tokens = append(tokens, token)
+
+	for _, token := range tokens {
+		ls.Release(token)
+	}
+
+	if stats := ls.Stats(); stats.Running != 0 || stats.Waiting != 0 {
+		t.Errorf("expected no running or waiting requests, got %+v", stats)
+	}
+}
+
+func TestLoadshedder_ClampMinimum(t *testing.T) {
+	ls := New(Config{Limit: 4})
+
+	ls.Clamp(0)
+
+	if stats := ls.Stats(); stats.EffectiveLimit != 1 {
+		t.Errorf("expected EffectiveLimit=1, got %+v", stats)
+	}
+}
+
+func TestLoadshedder_UnclampWakesWaiters(t *testing.T) {
+	ctx := context.Background()
+
+	ls := New(Config{Limit: 2, WaitingLimit: 2})
+	ls.Clamp(1)
+
+	_, token1 := ls.Acquire(ctx)
+	defer ls.Release(token1)
+
+	result := make(chan *Token)
+	go func() {
+		_, token := ls.Acquire(ctx)
+		result <- token
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	if stats := ls.Stats(); stats.Waiting != 1 {
+		t.Errorf("expected Waiting=1, got %+v", stats)
+	}
+
+	ls.Unclamp()
+
+	select {
+	case token := <-result:
+		defer ls.Release(token)
+		if !token.Accepted() {
+			t.Error("expected waiter to be accepted after Unclamp")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected waiter to be woken by Unclamp")
+	}
+}
+
+func TestLoadshedder_SetLimit(t *testing.T) {
+	ls := New(Config{Limit: 4})
+	ls.Clamp(3)
+
+	ls.SetLimit(2)
+	if stats := ls.Stats(); stats.ConfiguredLimit != 2 || stats.EffectiveLimit != 2 {
+		t.Errorf("expected the lowered limit enforced, got %+v", stats)
+	}
+	if cfg := ls.Config(); cfg.Limit != 2 {
+		t.Errorf("expected Config to reflect the new limit, got %d", cfg.Limit)
+	}
+
+	// The clamp still applies to a raised limit
+	ls.SetLimit(10)
+	if stats := ls.Stats(); stats.ConfiguredLimit != 10 || stats.EffectiveLimit != 3 {
+		t.Errorf("expected the clamp to apply, got %+v", stats)
+	}
+}
+
+func TestLoadshedder_SetLimitWakesWaiters(t *testing.T) {
+	ctx := context.Background()
+
+	ls := New(Config{Limit: 1, WaitingLimit: 1})
+	_, token1 := ls.Acquire(ctx)
+	defer ls.Release(token1)
+
+	result := make(chan *Token)
+	go func() {
+		_, token := ls.Acquire(ctx)
+		result <- token
+	}()
+	waitForStats(t, ls, func(s Stats) bool { return s.Waiting == 1 })
+
+	ls.SetLimit(2)
+
+	select {
+	case token := <-result:
+		defer ls.Release(token)
+		if !token.Accepted() {
+			t.Error("expected waiter to be accepted after SetLimit")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected waiter to be woken by SetLimit")
+	}
+}
+
+func TestLoadshedder_SetWaitingLimit(t *testing.T) {
+	ctx := context.Background()
+
+	ls := New(Config{Limit: 1, WaitingLimit: 1})
+	_, running := ls.Acquire(ctx)
+
+	// Raising it lets more requests wait
+	ls.SetWaitingLimit(3)
+	if cfg := ls.Config(); cfg.WaitingLimit != 3 {
+		t.Errorf("expected Config to reflect the new waiting limit, got %d", cfg.WaitingLimit)
+	}
+
+	results := make([]chan *Token, 3)
+	for i := range results {
+		results[i] = make(chan *Token, 1)
+		go func() {
+			_, token := ls.Acquire(ctx)
+			results[i] <- token
+		}()
+		waitForStats(t, ls, func(s Stats) bool { return s.Waiting == int64(i+1) })
+	}
+
+	// Lowering it rejects the newest waiters
+	ls.SetWaitingLimit(1)
+	for _, i := range []int{2, 1} {
+		select {
+		case token := <-results[i]:
+			if token.Accepted() {
+				t.Errorf("expected waiter %d to be rejected", i)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected waiter %d to be shed", i)
+		}
+	}
+	if stats := ls.Stats(); stats.Waiting != 1 {
+		t.Errorf("expected Waiting=1, got %+v", stats)
+	}
+	if _, token := ls.Acquire(ctx, WithNoWait()); token.Accepted() {
+		t.Error("expected rejection with a full queue")
+	}
+
+	// The oldest waiter is still served
+	ls.Release(running)
+	token := <-results[0]
+	if !token.Accepted() {
+		t.Error("expected the oldest waiter to be accepted")
+	}
+	ls.Release(token)
+
+	if c := ls.Counters(); c.Accepted != 2 || c.Rejected != 3 || c.Released != 2 {
+		t.Errorf("unexpected counters: %+v", c)
+	}
+}
+
+func TestLoadshedder_SetWaitingLimit_LIFO(t *testing.T) {
+	ctx := context.Background()
+
+	ls := New(Config{Limit: 1, WaitingLimit: 2, QueueDiscipline: QueueLIFO})
+	_, running := ls.Acquire(ctx)
+
+	results := make([]chan *Token, 2)
+	for i := range results {
+		results[i] = make(chan *Token, 1)
+		go func() {
+			_, token := ls.Acquire(ctx)
+			results[i] <- token
+		}()
+		waitForStats(t, ls, func(s Stats) bool { return s.Waiting == int64(i+1) })
+	}
+
+	// The oldest waiter would be served last
+	ls.SetWaitingLimit(1)
+	if token := <-results[0]; token.Accepted() {
+		t.Error("expected the oldest waiter to be rejected")
+	}
+
+	ls.Release(running)
+	token := <-results[1]
+	if !token.Accepted() {
+		t.Error("expected the newest waiter to be accepted")
+	}
+	ls.Release(token)
+}
+
+func TestLoadshedder_SetWaitingLimit_Panics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	New(Config{Limit: 1, WaitingLimit: 1}).SetWaitingLimit(-1)
+}
+
+func TestLoadshedder_SetWaitingLimit_CounterOnly(t *testing.T) {
+	ctx := context.Background()
+	ls := New(Config{Limit: 1})
+
+	_, running := ls.Acquire(ctx)
+
+	// A waiting queue for a loadshedder created without one
+	ls.SetWaitingLimit(1)
+	result := make(chan *Token)
+	go func() {
+		_, token := ls.Acquire(ctx)
+		result <- token
+	}()
+	waitFor(t, func() bool { return ls.Stats().Waiting == 1 })
+
+	ls.Release(running)
+	token := <-result
+	if !token.Accepted() {
+		t.Fatal("expected the waiting request to be accepted")
+	}
+
+	// Back to the counter-only mode
+	ls.SetWaitingLimit(0)
+	if _, rejected := ls.Acquire(ctx, WithMaxWait(time.Second)); rejected.Accepted() {
+		t.Error("expected a rejection at the limit without a waiting queue")
+	}
+	ls.Release(token)
+	if stats := ls.Stats(); stats.Running != 0 || stats.Waiting != 0 {
+		t.Errorf("expected no running or waiting request, got %+v", stats)
+	}
+}
@@ -0,0 +1,94 @@
+package loadshedder
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const defaultLimitWatchInterval = 10 * time.Second
+
+// LimitWatchConfig configures WatchLimit.
+type LimitWatchConfig struct {
+	// Source returns the desired limit, e.g. from a feature flag.
+	// Values that are not positive are ignored (and logged), keeping the
+	// current limit.
+	// Required.
+	Source func() int64
+
+	// Interval is the polling interval of Source.
+	// Optional, default to 10s.
+	Interval time.Duration
+
+	// OnChange is called after each limit change applied from Source.
+	// Optional.
+	OnChange func(previous, limit int64)
+
+	// Logger logs the limit changes and the ignored values.
+	// Optional, default to slog.Default().
+	Logger *slog.Logger
+}
+
+// WatchLimit starts a goroutine polling cfg.Source and applying its value
+// with SetLimit whenever it differs from the configured limit, so the limit
+// follows an operational knob. The source is polled once before WatchLimit
+// returns. Call the returned function to stop watching.
+func (l *Loadshedder) WatchLimit(cfg LimitWatchConfig) (stop func()) {
+	if cfg.Source == nil {
+		panic("loadshedder: LimitWatchConfig.Source is required")
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultLimitWatchInterval
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+
+	l.applyLimitSource(cfg)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				l.applyLimitSource(cfg)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
+
+func (l *Loadshedder) applyLimitSource(cfg LimitWatchConfig) {
+	limit := cfg.Source()
+	if limit <= 0 {
+		cfg.Logger.Warn("loadshedder: ignoring invalid limit from source", "name", l.name, "limit", limit)
+		return
+	}
+
+	previous := l.targetLimit()
+	if limit == previous {
+		return
+	}
+
+	l.SetLimit(limit)
+	cfg.Logger.Info("loadshedder: limit changed", "name", l.name, "previous", previous, "limit", limit)
+	if cfg.OnChange != nil {
+		cfg.OnChange(previous, limit)
+	}
+}
@@ -0,0 +1,48 @@
+package loadshedder
+
+import (
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoadshedder_WatchLimit(t *testing.T) {
+	ls := New(Config{Limit: 10})
+
+	var source atomic.Int64
+	source.Store(20)
+	changes := make(chan [2]int64, 10)
+	logs := &syncBuffer{}
+
+	stop := ls.WatchLimit(LimitWatchConfig{
+		Source:   source.Load,
+		Interval: time.Millisecond,
+		OnChange: func(previous, limit int64) { changes <- [2]int64{previous, limit} },
+		Logger:   slog.New(slog.NewTextHandler(logs, nil)),
+	})
+	defer stop()
+
+	// Applied before WatchLimit returns
+	if limit := ls.Stats().ConfiguredLimit; limit != 20 {
+		t.Errorf("expected the limit from the source, got %d", limit)
+	}
+	if change := <-changes; change != [2]int64{10, 20} {
+		t.Errorf("expected a change from 10 to 20, got %v", change)
+	}
+
+	// Invalid values are ignored
+	source.Store(0)
+	time.Sleep(10 * time.Millisecond)
+	source.Store(5)
+
+	if change := <-changes; change != [2]int64{20, 5} {
+		t.Errorf("expected a change from 20 to 5, got %v", change)
+	}
+	stop()
+
+	if !strings.Contains(logs.String(), "ignoring invalid limit") {
+		t.Errorf("expected the invalid limit to be logged, got %s", logs.String())
+	}
+}
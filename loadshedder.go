@@ -2,25 +2,82 @@ package loadshedder
 
 import (
 	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
 	"sync/atomic"
 	"time"
-
-	"golang.org/x/sync/semaphore"
 )
 
 // Stats provides current state of the loadshedder.
 type Stats struct {
-	Running  int64         // Current number of running requests
-	Waiting  int64         // Current number of waiting requests
-	Limit    int64         // The configured concurrency limit
-	WaitTime time.Duration // Time spent waiting for acquisition (0 if not waited)
+	Name            string        // The configured name of the loadshedder (empty if not set)
+	Running         int64         // Current number of running requests
+	Waiting         int64         // Current number of waiting requests
+	Limit           int64         // The enforced concurrency limit (same as EffectiveLimit)
+	ConfiguredLimit int64         // The concurrency limit from the configuration
+	EffectiveLimit  int64         // The concurrency limit currently enforced
+	WaitTime        time.Duration // Time spent waiting for acquisition (0 if not waited)
+	AvgDuration     time.Duration // Moving average of request durations (0 until a request completed)
+	ProjectedWait   time.Duration // Estimated wait of a request queued now, see projectedWait
+	RetryAfter      time.Duration // Suggested delay before retrying a rejected request: ProjectedWait, at least AvgDuration (0 until a request completed)
+	Pressure        float64       // Overload score: highest pressure of the Signals at the last sample (0 without signals)
+	ColdStart       bool          // The effective limit is reduced by Config.ColdStart
+	RampTarget      int64         // Configured limit being ramped to, see RampLimit (0 if not ramping)
+	RampProgress    float64       // Elapsed fraction of the ramp, in [0, 1] (0 if not ramping)
+	Draining        bool          // Drain was called: new requests are rejected
+	RejectReason    RejectReason  // Why the Acquire call returning these Stats was rejected (RejectNone otherwise)
+	Label           string        // Label of the Acquire call returning these Stats, see WithLabel (OtherLabel beyond Config.MaxLabels)
+
+	// Admissions since creation by path: a growing share of queued
+	// admissions is an early sign of approaching saturation.
+	FastAdmissions   int64 // Admitted without waiting for a slot
+	QueuedAdmissions int64 // Admitted after waiting for a slot
+
+	// TokenID identifies the token accepted by the Acquire call returning
+	// these Stats, zero otherwise. Reporters can log it to join the events
+	// of a request.
+	TokenID TokenID
+
+	// Priority is the priority of the Acquire call returning these Stats,
+	// PriorityDefault otherwise. Reporters can break their metrics down by it.
+	Priority Priority
+}
+
+// Counters provides the totals since the loadshedder was created.
+type Counters struct {
+	Accepted int64 // Acquisitions accepted
+	Rejected int64 // Acquisitions rejected
+	Released int64 // Accepted tokens released, by Release or automatically
 }
 
 // Token represents an acquisition attempt.
 // Check Accepted() to see if the request was accepted.
 type Token struct {
-	accepted bool
-	released atomic.Bool
+	id         TokenID
+	accepted   bool
+	released   atomic.Uint32 // releaseState
+	arrivedAt  monotime
+	acceptedAt monotime
+	waitTime   time.Duration
+	reason     RejectReason
+	priority   Priority
+	source     string
+	label      string
+	weight     int64
+	queued     bool
+	failed     atomic.Bool // see Fail
+
+	stopReleaseOnDone func() bool
+	cancel            context.CancelCauseFunc
+	labelCounters     *labelCounters
+	owner             *Loadshedder // loadshedder that accepted the token, see checkRelease and OnShed
+}
+
+// ID returns the unique ID of an accepted token, zero if rejected.
+func (t *Token) ID() TokenID {
+	return t.id
 }
 
 // Accepted returns true if the acquisition was successful.
@@ -28,27 +85,300 @@ func (t *Token) Accepted() bool {
 	return t.accepted
 }
 
+// WaitTime returns the time spent waiting for acquisition (0 for hard rejections).
+func (t *Token) WaitTime() time.Duration {
+	return t.waitTime
+}
+
+// RejectReason returns why the acquisition was rejected (RejectNone if accepted).
+func (t *Token) RejectReason() RejectReason {
+	return t.reason
+}
+
+// Age returns the time elapsed since the acquisition attempt started,
+// including any time spent waiting.
+func (t *Token) Age() time.Duration {
+	if t.arrivedAt.IsZero() {
+		return 0
+	}
+	return monoOf(time.Now()).Sub(t.arrivedAt)
+}
+
+// AcceptedAt returns the time the token was accepted (zero if rejected).
+func (t *Token) AcceptedAt() time.Time {
+	return t.acceptedAt.Time()
+}
+
+// Priority returns the priority requested with WithPriority.
+func (t *Token) Priority() Priority {
+	return t.priority
+}
+
+// Source returns the traffic source set with WithSource.
+func (t *Token) Source() string {
+	return t.source
+}
+
+// Label returns the label set with WithLabel.
+func (t *Token) Label() string {
+	return t.label
+}
+
+// Weight returns the number of slots held by the token, see WithWeight.
+func (t *Token) Weight() int64 {
+	return t.weight
+}
+
+// Queued returns true if the acquisition had to wait for a slot.
+func (t *Token) Queued() bool {
+	return t.queued
+}
+
 // Config configures a Loadshedder.
 type Config struct {
+	// Name identifies the loadshedder in Stats, e.g. when a middleware uses several pools.
+	// Optional.
+	Name string
+
 	// Limit is the maximum number of concurrent requests allowed.
 	// Must be positive.
 	Limit int64
 
+	// LimitRamp makes SetLimit (and the limit changes applied with it, e.g.
+	// WatchLimit and the AdminHandler) ramp to the new limit over this
+	// duration rather than step to it, see RampLimit.
+	// Optional, default to 0 (step).
+	LimitRamp time.Duration
+
 	// WaitingLimit is the maximum number of requests allowed to wait.
 	// WaitingLimit is usually a small fraction of Limit, like 20-30%.
 	// If zero, requests are rejected immediately when the concurrency limit is exceeded.
 	// Optional, default to 0, must be positive.
 	WaitingLimit int64
+
+	// QueueDiscipline is the order in which waiting requests are admitted:
+	// QueueFIFO, QueueLIFO or QueueAdaptiveLIFO.
+	// Optional, default to QueueFIFO.
+	QueueDiscipline QueueDiscipline
+
+	// MaxQueueWait bounds the time a request waits for a slot, even if its
+	// context has no deadline, e.g. for clients that set no timeout.
+	// WithMaxWait overrides it for a single acquisition.
+	// Optional, default to 0 (only the context bounds the wait).
+	MaxQueueWait time.Duration
+
+	// DeadlineAware rejects right away the requests whose deadline (from
+	// their context, or WithMaxWait) is earlier than the projected end of
+	// their wait (see Stats.ProjectedWait), instead of letting them take a
+	// waiting slot and time out later. See DeadlineRejections.
+	// Optional, default to false.
+	DeadlineAware bool
+
+	// DeadlineBands sets the maximum queue wait of a request from its
+	// remaining budget, the time left until the deadline of its context, for
+	// traffic mixing latency budgets: e.g. requests with more than 2s left
+	// may wait 500ms, requests with less than 200ms must not wait. Each
+	// request uses the band with the highest MinBudget not above its budget;
+	// budgets below the lowest band do not wait. Requests without deadline
+	// use MaxQueueWait, and WithMaxWait and WithNoWait override the bands.
+	// See WithDeadline for HTTP requests, which have no deadline by default.
+	// Optional, default to nil (MaxQueueWait for all requests).
+	DeadlineBands []DeadlineBand
+
+	// CoDelTarget enables controlled delay (CoDel) on the waiting queue: once
+	// the queueing delay of the admitted requests stayed above CoDelTarget
+	// for CoDelInterval, the queue is standing rather than absorbing a burst,
+	// and waiting requests are rejected instead of admitted while their delay
+	// exceeds the target, until the queue drains. See CoDelDrops.
+	// A target of 5-10% of the request timeout is a reasonable start.
+	// Optional, default to 0 (disabled).
+	CoDelTarget time.Duration
+
+	// CoDelInterval is how long the queueing delay must stay above
+	// CoDelTarget before waiting requests are dropped, typically the time it
+	// takes to serve a burst.
+	// Optional, default to 100ms.
+	CoDelInterval time.Duration
+
+	// SpinWait lets an acquisition spin, yielding the processor, for up to
+	// this duration before parking in the waiting queue, when a slot is
+	// expected to be freed within it: when the average duration spread over
+	// the effective limit is below it. For very fast handlers, it saves the
+	// park and wake-up latency of the queue, at the cost of CPU while
+	// spinning. A few microseconds is a reasonable start. See SpinAdmissions.
+	// Optional, default to 0 (park right away).
+	SpinWait time.Duration
+
+	// PriorityAdmission sheds lower priorities first: an acquisition is
+	// rejected once the running and waiting requests reach the fraction of
+	// the capacity (effective limit plus WaitingLimit) set for its priority,
+	// keeping headroom for more important traffic. Priorities not listed use
+	// the fraction of the next more important priority listed, or the whole
+	// capacity. See DefaultPriorityAdmission and WithPriority.
+	// Optional, default to nil (priorities do not affect admission).
+	PriorityAdmission map[Priority]float64
+
+	// Signals are overload signals sampled every SignalInterval, see Signal.
+	// While any signal reports overload, the effective limit is lowered
+	// multiplicatively; it recovers gradually once all signals are back to normal.
+	// Optional.
+	Signals []Signal
+
+	// SignalInterval is the sampling interval of Signals.
+	// Optional, default to 1s.
+	SignalInterval time.Duration
+
+	// SignalHysteresis is the margin below 1 the pressure must drop under
+	// before the effective limit recovers, so a pressure hovering around the
+	// threshold does not flap between shedding and recovering.
+	// Optional, default to 0.1, must be in [0, 1).
+	SignalHysteresis float64
+
+	// SignalEntryDebounce is how long Signals must report overload before
+	// the effective limit is lowered, to ignore short spikes.
+	// Optional, default to 0 (react on the first overloaded sample).
+	SignalEntryDebounce time.Duration
+
+	// SignalExitDebounce is how long the pressure must stay below the
+	// hysteresis margin before the effective limit recovers.
+	// Optional, default to 0 (recover on the first recovered sample).
+	SignalExitDebounce time.Duration
+
+	// Adaptive continuously adjusts the concurrency limit, between
+	// Gradient.MinLimit and Limit (plus donated capacity), from the observed
+	// request durations: the
+	// limit shrinks when the average duration rises above its long-term
+	// baseline, and grows back while it is stable, see GradientConfig.
+	// Optional, default to false (static limit).
+	Adaptive bool
+
+	// Gradient configures the Adaptive mode.
+	// Optional.
+	Gradient GradientConfig
+
+	// ColdStart enforces a reduced limit after start, while the average
+	// request duration is far above its steady state (ColdStart.SteadyDuration),
+	// e.g. while caches warm up after a deploy. See ColdStartConfig.
+	// Optional, disabled without ColdStart.SteadyDuration.
+	ColdStart ColdStartConfig
+
+	// ErrorRate lowers the effective limit while the fraction of failed
+	// requests exceeds ErrorRate.Threshold, and recovers it gradually, e.g. to
+	// back off a failing dependency. See ErrorRateConfig.
+	// Optional, disabled without ErrorRate.Threshold.
+	ErrorRate ErrorRateConfig
+
+	// Degradation configures the thresholds of the DegradationLevel.
+	// Optional.
+	Degradation DegradationConfig
+
+	// MaxLabels bounds the number of labels tracked by CountersByLabel;
+	// further labels are accounted under OtherLabel.
+	// Optional, default to 100.
+	MaxLabels int
+
+	// DurationIncludesWait makes the average request duration (Stats.AvgDuration)
+	// measure the total time including the queue wait, instead of the time
+	// between acceptance and release. Including the wait inflates the average
+	// under load, which inflates any estimate derived from it.
+	// Optional, default to false (handler time only).
+	DurationIncludesWait bool
+
+	// Startup delays the enforcement of the limits until Ready is called,
+	// e.g. while dependencies warm up and request durations are meaningless:
+	// until then, every acquisition is accepted (StartAcceptAll) or rejected
+	// (StartRejectAll), and durations feed neither the average duration nor
+	// the Adaptive mode. Draining still rejects. See ReadyWhen.
+	// Optional, default to StartEnforcing (enforce from the start).
+	Startup StartupMode
+
+	// StrictMode panics on misuses that are otherwise tolerated silently:
+	// releasing a token twice or with another loadshedder, acquiring after
+	// Drain, and the violations found by CheckInvariants (e.g. leaked tokens).
+	// Intended for development and tests, where tolerating them hides bugs.
+	// Optional, default to false.
+	StrictMode bool
+
+	// CancelOnDrain selects the running requests to cancel as soon as Drain is
+	// called, e.g. SourceIn("batch") or PriorityBelow(PriorityDefault), to
+	// shorten shutdown while interactive requests finish. Only requests
+	// acquired WithCancel can be cancelled; the middleware does it automatically.
+	// Optional.
+	CancelOnDrain func(*Token) bool
+
+	// SampleHook is called with a sampled subset of admissions, to capture
+	// expensive diagnostics (stacks, runtime stats) for later analysis.
+	// It runs in its own goroutine, one call at a time and at most
+	// SampleMaxPerSecond times per second; samples over budget are dropped,
+	// see Samples. It must not Release the token.
+	// Optional.
+	SampleHook func(Stats, *Token)
+
+	// SampleRate is the fraction of admissions sampled for SampleHook.
+	// Optional, default to 0.001, must be in [0, 1].
+	SampleRate float64
+
+	// SampleMaxPerSecond caps the SampleHook calls per second.
+	// Optional, default to 1.
+	SampleMaxPerSecond int
 }
 
 // Loadshedder is a framework-agnostic concurrency limiter.
 // It tracks concurrent operations and determines whether new operations
 // should be accepted or rejected based on the configured limits.
 type Loadshedder struct {
-	semaphore    *semaphore.Weighted
-	current      atomic.Int64 // current number of running + waiting requests
-	limit        int64
-	waitingLimit int64
+	config  Config // configuration with defaults applied
+	name    string
+	slots   *slots
+	current paddedInt64  // current number of running + waiting requests
+	limit   atomic.Int64 // configured limit, written under limitMu, see SetLimit
+
+	settings atomic.Pointer[settings] // written under limitMu, see updateSettings
+
+	priorityShares []priorityShare // see Config.PriorityAdmission
+
+	effectiveLimit paddedInt64 // enforced limit, see limits.go
+	limitMu        sync.Mutex
+	clamp          int64
+	signalLimit    int64
+	donated        int64   // capacity received (positive) or given (negative), see Registry.Donate
+	adaptiveLimit  int64   // see Config.Adaptive
+	errorRateLimit int64   // see Config.ErrorRate
+	chaosFactor    float64 // fraction of the effective limit during a chaos flap, see EnableChaos
+
+	signals   *signalController
+	gradient  *gradientController
+	sampler   *admissionSampler
+	coldStart *coldStartController
+	errorRate *errorRateController
+
+	avgDuration  paddedInt64 // nanoseconds, see recordDuration
+	accepted     paddedInt64
+	rejected     paddedInt64
+	released     paddedInt64
+	fastPath     paddedInt64 // admissions without waiting
+	queuedPath   paddedInt64 // admissions after waiting
+	autoReleased atomic.Int64
+	tokens       atomic.Pointer[tokenTracker] // live tokens, see CheckInvariants
+	labels       labelSet
+
+	shadowRejections   atomic.Int64 // see SetShadow
+	deadlineRejections atomic.Int64 // see Config.DeadlineAware
+	spinAdmissions     atomic.Int64 // see Config.SpinWait
+
+	nextRampUpdate atomic.Int64 // unix nanoseconds, see RampLimit
+
+	// Slots beyond the first one of weighted tokens, see checkCounters
+	extraAcceptedWeight atomic.Int64
+	extraReleasedWeight atomic.Int64
+
+	cancelMu    sync.Mutex
+	cancellable map[*Token]struct{} // running tokens acquired WithCancel
+
+	// Hooks of the running tokens, see OnShed
+	shedMu        sync.Mutex
+	shedHooks     map[*Token][]func()
+	shedHookCount atomic.Int64
 }
 
 // New creates a new concurrency limiter with the specified configuration.
@@ -60,59 +390,515 @@ func New(cfg Config) *Loadshedder {
 		panic("loadshedder: Config.WaitingLimit cannot be negative")
 	}
 
-	return &Loadshedder{
-		limit:        cfg.Limit,
-		waitingLimit: cfg.WaitingLimit,
-		semaphore:    semaphore.NewWeighted(cfg.Limit),
+	if cfg.SignalHysteresis < 0 || cfg.SignalHysteresis >= 1 {
+		panic("loadshedder: Config.SignalHysteresis must be in [0, 1)")
+	}
+	if cfg.SignalEntryDebounce < 0 || cfg.SignalExitDebounce < 0 {
+		panic("loadshedder: Config.SignalEntryDebounce and Config.SignalExitDebounce cannot be negative")
+	}
+
+	for p, fraction := range cfg.PriorityAdmission {
+		if fraction <= 0 || fraction > 1 {
+			panic(fmt.Sprintf("loadshedder: Config.PriorityAdmission fraction for %s must be in (0, 1]", p))
+		}
+	}
+	cfg.PriorityAdmission = maps.Clone(cfg.PriorityAdmission)
+
+	if len(cfg.Signals) > 0 {
+		if cfg.SignalInterval <= 0 {
+			cfg.SignalInterval = defaultSignalInterval
+		}
+		if cfg.SignalHysteresis == 0 {
+			cfg.SignalHysteresis = defaultSignalHysteresis
+		}
+	}
+	cfg.Signals = slices.Clone(cfg.Signals)
+
+	if cfg.Adaptive {
+		cfg.Gradient = applyGradientDefaults(cfg.Gradient, cfg.Limit)
+	}
+
+	if cfg.ColdStart.SteadyDuration != 0 {
+		cfg.ColdStart = applyColdStartDefaults(cfg.ColdStart)
+	}
+
+	if cfg.ErrorRate.Threshold != 0 {
+		cfg.ErrorRate = applyErrorRateDefaults(cfg.ErrorRate, cfg.Limit)
+	}
+
+	cfg.Degradation = applyDegradationDefaults(cfg.Degradation)
+
+	if cfg.LimitRamp < 0 {
+		panic("loadshedder: Config.LimitRamp cannot be negative")
+	}
+
+	if cfg.MaxLabels <= 0 {
+		cfg.MaxLabels = defaultMaxLabels
 	}
+
+	if cfg.Startup < StartEnforcing || cfg.Startup > StartRejectAll {
+		panic("loadshedder: invalid Config.Startup")
+	}
+	if cfg.QueueDiscipline < QueueFIFO || cfg.QueueDiscipline > QueueAdaptiveLIFO {
+		panic("loadshedder: invalid Config.QueueDiscipline")
+	}
+	if cfg.MaxQueueWait < 0 {
+		panic("loadshedder: Config.MaxQueueWait cannot be negative")
+	}
+	cfg.DeadlineBands = validateDeadlineBands(cfg.DeadlineBands)
+	if cfg.CoDelTarget < 0 || cfg.CoDelInterval < 0 {
+		panic("loadshedder: Config.CoDelTarget and Config.CoDelInterval cannot be negative")
+	}
+	if cfg.CoDelTarget > 0 && cfg.CoDelInterval == 0 {
+		cfg.CoDelInterval = defaultCoDelInterval
+	}
+	if cfg.SpinWait < 0 {
+		panic("loadshedder: Config.SpinWait cannot be negative")
+	}
+
+	if cfg.SampleRate < 0 || cfg.SampleRate > 1 {
+		panic("loadshedder: Config.SampleRate must be in [0, 1]")
+	}
+	if cfg.SampleHook != nil {
+		if cfg.SampleRate == 0 {
+			cfg.SampleRate = defaultSampleRate
+		}
+		if cfg.SampleMaxPerSecond <= 0 {
+			cfg.SampleMaxPerSecond = defaultSampleMaxPerSecond
+		}
+	}
+
+	l := &Loadshedder{
+		config: cfg,
+		name:   cfg.Name,
+		slots:  newSlots(cfg.Limit),
+	}
+	l.slots.discipline = cfg.QueueDiscipline
+	l.slots.adaptiveLIFOAbove = int(cfg.WaitingLimit / 2)
+	l.settings.Store(&settings{waitingLimit: cfg.WaitingLimit, startup: cfg.Startup})
+	if cfg.CoDelTarget > 0 {
+		l.slots.codel = &codel{target: cfg.CoDelTarget, interval: cfg.CoDelInterval}
+	}
+	l.limit.Store(cfg.Limit)
+	l.effectiveLimit.Store(cfg.Limit)
+	l.labels.max = int64(cfg.MaxLabels)
+
+	if len(cfg.Signals) > 0 {
+		l.signals = newSignalController(cfg)
+	}
+	if len(cfg.PriorityAdmission) > 0 {
+		l.priorityShares = newPriorityShares(cfg.PriorityAdmission)
+	}
+	if cfg.Adaptive {
+		l.gradient = newGradientController(cfg)
+		l.adaptiveLimit = cfg.Limit
+	}
+	if cfg.ErrorRate.Threshold > 0 {
+		l.errorRate = newErrorRateController(cfg.ErrorRate)
+	}
+	if cfg.SampleHook != nil {
+		l.sampler = newAdmissionSampler(cfg)
+	}
+	if cfg.ColdStart.SteadyDuration > 0 {
+		l.coldStart = newColdStartController(cfg.ColdStart, time.Now())
+		l.limitMu.Lock()
+		l.updateEffectiveLimit()
+		l.limitMu.Unlock()
+	}
+
+	// With the controllers created, derive the settings
+	l.limitMu.Lock()
+	l.updateSettings(func(*settings) {})
+	l.limitMu.Unlock()
+
+	return l
+}
+
+// Config returns the configuration the loadshedder is running with,
+// with defaults applied and runtime changes reflected.
+func (l *Loadshedder) Config() Config {
+	l.limitMu.Lock()
+	defer l.limitMu.Unlock()
+
+	cfg := l.config
+	cfg.Limit = l.targetLimit()
+	cfg.WaitingLimit = l.settings.Load().waitingLimit
+	cfg.Signals = slices.Clone(cfg.Signals)
+	cfg.DeadlineBands = slices.Clone(cfg.DeadlineBands)
+	cfg.PriorityAdmission = maps.Clone(cfg.PriorityAdmission)
+	return cfg
 }
 
 // Acquire attempts to acquire a slot for processing.
 // Always returns a Token. Check token.Accepted() to see if the request was accepted.
 // Always call token.Release() when done, typically in a defer.
-func (l *Loadshedder) Acquire(ctx context.Context) (Stats, *Token) {
-	current := l.current.Add(1)
+// Options override the limiter defaults for this call only.
+func (l *Loadshedder) Acquire(ctx context.Context, opts ...AcquireOption) (Stats, *Token) {
+	var o acquireOptions
+	if len(opts) > 0 {
+		o = applyAcquireOptions(opts)
+	}
+	if !o.prioritySet {
+		o.priority = PriorityFromContext(ctx)
+	}
+	o.weight = max(o.weight, 1)
+	defaultWait := o.maxWait == 0 && !o.noWait
+	if defaultWait {
+		o.maxWait = l.config.MaxQueueWait
+	}
+	s := l.settings.Load()
+	if l.config.StrictMode && s.draining {
+		strictViolation("Acquire after Drain")
+	}
+
+	start := time.Now()
+	if defaultWait && l.config.DeadlineBands != nil {
+		o.applyDeadlineBand(ctx, l.config.DeadlineBands, start)
+	}
+	if s.maintain {
+		l.maintainLimits(s, start)
+	}
+
+	current := l.current.Add(o.weight)
+	effectiveLimit := l.effectiveLimit.Load()
+	capacity := effectiveLimit + s.waitingLimit
 
-	if current > l.limit+l.waitingLimit {
+	// An acquisition heavier than the effective limit would wait forever
+	overCapacity := current > capacity || o.weight > effectiveLimit ||
+		(l.priorityShares != nil && current > l.priorityCapacity(o.priority, capacity))
+	force := s.startup == StartAcceptAll
+	stopped := s.stopped
+	reason := RejectQueueFull
+	if s.startup != StartEnforcing {
+		overCapacity = s.startup == StartRejectAll
+	} else if overCapacity && !stopped && s.shadow {
+		overCapacity, force = false, true
+		l.shadowRejections.Add(1)
+	} else if !overCapacity && l.config.DeadlineAware && !o.noWait && s.waitingLimit > 0 && !force &&
+		l.missesDeadline(ctx, o.maxWait, current, effectiveLimit, start) {
+		overCapacity, reason = true, RejectDeadline
+		l.deadlineRejections.Add(1)
+	}
+	if stopped {
+		reason = RejectStopped
+	}
+
+	var chaosDelay time.Duration
+	if s.chaos != nil && !overCapacity && !stopped {
+		overCapacity, chaosDelay = s.chaos.decide()
+	}
+
+	if overCapacity || stopped {
 		// Release the slot immediately (hard rejection)
-		l.current.Add(-1)
-		return l.statsWithWait(current, 0), &Token{}
+		l.current.Add(-o.weight)
+		l.rejected.Add(1)
+		if l.shedHookCount.Load() > 0 {
+			l.runShedHooks()
+		}
+		label := l.labels.countRejected(o.label)
+		traceDecision(ctx, "rejected")
+		stats := l.statsWithWait(current, 0)
+		stats.Priority = o.priority
+		stats.RejectReason = reason
+		stats.Label = label
+		token := o.newToken(monoOf(start))
+		token.reason = reason
+		return stats, token
 	}
 
-	// Track wait time for semaphore acquisition
-	start := time.Now()
-	err := l.semaphore.Acquire(ctx, 1)
-	waitTime := time.Since(start)
+	// Track wait time for slot acquisition
+	if chaosDelay > 0 {
+		sleepContext(ctx, chaosDelay)
+	}
+	acquired, queued, reason := l.acquireSlotTraced(ctx, o.weight, s.waitingLimit == 0, o.noWait, force, o.maxWait)
+	queued = queued || chaosDelay > 0
+	now := start
+	if queued {
+		now = time.Now()
+	}
+	waitTime := now.Sub(start)
+
+	token := o.newToken(monoOf(start))
+	token.waitTime = waitTime
+	token.queued = queued
+
+	if !acquired {
+		current = l.current.Add(-o.weight)
+		l.rejected.Add(1)
+		label := l.labels.countRejected(o.label)
+		traceDecision(ctx, "rejected")
+		token.reason = reason
+		stats := l.statsWithWait(current, waitTime)
+		stats.Priority = o.priority
+		stats.RejectReason = reason
+		stats.Label = label
+		return stats, token
+	}
+
+	traceDecision(ctx, "accepted")
+	token.accepted = true
+	token.acceptedAt = monoOf(now)
+	l.accepted.Add(1)
+	token.id = nextTokenID()
+	token.owner = l
+	if o.weight > 1 {
+		l.extraAcceptedWeight.Add(o.weight - 1)
+	}
+	if queued {
+		l.queuedPath.Add(1)
+	} else {
+		l.fastPath.Add(1)
+	}
+	if o.label != "" {
+		token.labelCounters = l.labels.countersFor(o.label)
+		token.labelCounters.accepted.Add(1)
+	}
+	if tracker := l.tokens.Load(); tracker != nil {
+		tracker.add(token)
+	}
+	if o.cancel != nil && l.config.CancelOnDrain != nil {
+		l.trackCancellable(token, o.cancel)
+	}
+	if o.releaseOnDone {
+		l.releaseOnDone(ctx, token)
+	}
+
+	stats := l.statsWithWait(current, waitTime)
+	stats.TokenID = token.id
+	stats.Priority = o.priority
+	if token.labelCounters != nil {
+		stats.Label = token.labelCounters.label
+	}
+	if l.sampler != nil {
+		l.sampler.maybeSample(now, stats, token)
+	}
+	return stats, token
+}
+
+// maintainLimits updates the effective limit from the controllers of the
+// limit, and moves the ramp in progress, see settings.maintain.
+func (l *Loadshedder) maintainLimits(s *settings, now time.Time) {
+	if s.startup == StartEnforcing {
+		if l.signals != nil {
+			l.sampleSignals(now)
+		}
+		if l.gradient != nil {
+			l.updateAdaptiveLimit(now)
+		}
+		if l.coldStarting() {
+			l.updateColdStart(now)
+		}
+		if l.errorRate != nil {
+			l.updateErrorRateLimit(now)
+		}
+	}
+	if s.ramp != nil {
+		l.updateRamp(now)
+	}
+}
+
+// AcquireN is Acquire for a request consuming weight slots, see WithWeight.
+func (l *Loadshedder) AcquireN(ctx context.Context, weight int, opts ...AcquireOption) (Stats, *Token) {
+	return l.Acquire(ctx, append(opts, WithWeight(weight))...)
+}
+
+// acquireSlot takes n slots, reporting whether it had to wait for them,
+// for at most maxWait if positive, and why it did not get them.
+// With force, the slots are taken even if they are not free.
+// Without a waiting queue (the waiting limit of the settings is zero), the
+// admission check on the current counter is the whole limit: in this
+// counter-only mode, slots are only counted, bypassing the semaphore lock.
+func (l *Loadshedder) acquireSlot(ctx context.Context, n int64, counterOnly, noWait, force bool, maxWait time.Duration) (acquired, queued bool, reason RejectReason) {
+	if ctx.Err() != nil {
+		return false, false, RejectCanceled
+	}
+	if counterOnly {
+		l.slots.inUse.Add(n)
+		return true, false, RejectNone
+	}
+	if force {
+		l.slots.forceAcquire(n)
+		return true, false, RejectNone
+	}
+	if l.slots.tryAcquire(n) {
+		return true, false, RejectNone
+	}
+	if noWait {
+		return false, false, RejectQueueFull
+	}
 
-	if err != nil {
-		current = l.current.Add(-1)
-		return l.statsWithWait(current, waitTime), &Token{}
+	// Only waiting requests pay for the timer
+	waitCtx := ctx
+	if maxWait > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, maxWait)
+		defer cancel()
+	}
+	if l.config.SpinWait > 0 && l.spinAcquire(waitCtx, n) {
+		return true, true, RejectNone
 	}
 
-	return l.statsWithWait(current, waitTime), &Token{accepted: true}
+	switch err := l.slots.acquire(waitCtx, n); {
+	case err == nil:
+		return true, true, RejectNone
+	case err == errDropped:
+		return false, true, RejectDropped
+	case ctx.Err() != nil:
+		return false, true, RejectCanceled
+	}
+	return false, true, RejectQueueTimeout
 }
 
-// Release releases a token. Safe to call even if not accepted or already released.
+// Release releases a token. Safe to call even if not accepted or already released,
+// except in StrictMode.
 func (l *Loadshedder) Release(t *Token) Stats {
-	if t != nil && t.accepted && t.released.CompareAndSwap(false, true) {
-		l.semaphore.Release(1)
-		current := l.current.Add(-1)
-		return l.statsWithWait(current, 0)
+	if t != nil && t.stopReleaseOnDone != nil {
+		t.stopReleaseOnDone()
+	}
+	if l.config.StrictMode {
+		l.checkRelease(t)
+	}
+
+	if l.release(t, tokenReleased) {
+		return l.statsWithWait(l.current.Add(-t.weight), 0)
 	}
 
 	return l.statsWithWait(l.current.Load(), 0)
 }
 
+// releaseState is the state of a Token, see Token.released.
+type releaseState = uint32
+
+const (
+	tokenHeld         releaseState = iota // accepted and not released yet, or rejected
+	tokenReleased                         // released by Release
+	tokenAutoReleased                     // released by its context ending, see WithReleaseOnDone
+)
+
+// release frees the slot held by the token, except for the current counter,
+// moving it to the state. Returns false if the token was not accepted or
+// already released.
+func (l *Loadshedder) release(t *Token, state releaseState) bool {
+	if t == nil || !t.accepted || !t.released.CompareAndSwap(tokenHeld, state) {
+		return false
+	}
+
+	if t.cancel != nil {
+		l.untrackCancellable(t)
+	}
+	if l.shedHookCount.Load() > 0 {
+		l.removeShedHooks(t)
+	}
+	if tracker := l.tokens.Load(); tracker != nil {
+		tracker.remove(t)
+	}
+
+	if l.settings.Load().startup == StartEnforcing {
+		l.recordDuration(t, time.Now())
+		if l.errorRate != nil {
+			l.errorRate.record(t.failed.Load())
+		}
+	}
+	if t.labelCounters != nil {
+		t.labelCounters.released.Add(1)
+	}
+
+	// Counted before the slot is freed, so Accepted - Released never
+	// exceeds Running, see CheckInvariants
+	l.released.Add(1)
+	if t.weight > 1 {
+		l.extraReleasedWeight.Add(t.weight - 1)
+	}
+	if l.settings.Load().waitingLimit == 0 {
+		l.slots.inUse.Add(-t.weight)
+		// A waiting limit set meanwhile may have let requests wait
+		if l.settings.Load().waitingLimit != 0 {
+			l.slots.release(0)
+		}
+		return true
+	}
+	l.slots.release(t.weight)
+	return true
+}
+
+// releaseOnDone releases the token when the context is done, as a safety net
+// for callers that may never call Release.
+func (l *Loadshedder) releaseOnDone(ctx context.Context, t *Token) {
+	t.stopReleaseOnDone = context.AfterFunc(ctx, func() {
+		if l.release(t, tokenAutoReleased) {
+			l.current.Add(-t.weight)
+			l.autoReleased.Add(1)
+		}
+	})
+}
+
+// AutoReleased returns the number of tokens acquired WithReleaseOnDone that
+// were released by their context ending rather than by Release. A growing
+// count reveals callers that never release their tokens.
+func (l *Loadshedder) AutoReleased() int64 {
+	return l.autoReleased.Load()
+}
+
+// Counters returns the totals since the loadshedder was created.
+func (l *Loadshedder) Counters() Counters {
+	return Counters{
+		Accepted: l.accepted.Load(),
+		Rejected: l.rejected.Load(),
+		Released: l.released.Load(),
+	}
+}
+
 // Stats returns the current statistics.
 func (l *Loadshedder) Stats() Stats {
 	return l.statsWithWait(l.current.Load(), 0)
 }
 
 func (l *Loadshedder) statsWithWait(current int64, waitTime time.Duration) Stats {
+	running := l.slots.inUse.Load()
+	waiting := max(0, current-running)
+	effectiveLimit := l.effectiveLimit.Load()
+	avgDuration := time.Duration(l.avgDuration.Load())
+	wait := projectedWait(waiting, effectiveLimit, avgDuration)
+
+	var rampTarget int64
+	var rampProgress float64
+	s := l.settings.Load()
+	if s.ramp != nil {
+		rampTarget, rampProgress = s.ramp.to, s.ramp.progress(monoOf(time.Now()))
+	}
+
 	return Stats{
-		Running:  min(current, l.limit),
-		Waiting:  max(0, current-l.limit),
-		Limit:    l.limit,
-		WaitTime: waitTime,
+		Name:            l.name,
+		Running:         running,
+		Waiting:         waiting,
+		Limit:           effectiveLimit,
+		ConfiguredLimit: l.limit.Load(),
+		EffectiveLimit:  effectiveLimit,
+		WaitTime:        waitTime,
+		AvgDuration:     avgDuration,
+		ProjectedWait:   wait,
+		RetryAfter:      max(wait, avgDuration),
+		Pressure:        l.pressure(),
+		ColdStart:       l.coldStarting(),
+		RampTarget:      rampTarget,
+		RampProgress:    rampProgress,
+		Draining:        s.draining,
+
+		FastAdmissions:   l.fastPath.Load(),
+		QueuedAdmissions: l.queuedPath.Load(),
+	}
+}
+
+// projectedWait estimates the wait of a request joining the queue behind
+// waiting requests. The limit slots serve the queue in parallel: it drains
+// in waves of limit requests, each taking about the average duration, so
+// the wait is ceil(waiting / limit) * avgDuration.
+// Queueing behind (waiting * avgDuration) would overstate the wait by up to
+// limit times.
+func projectedWait(waiting, limit int64, avgDuration time.Duration) time.Duration {
+	if waiting <= 0 || limit <= 0 {
+		return 0
 	}
+	waves := (waiting + limit - 1) / limit
+	return time.Duration(waves) * avgDuration
 }
@@ -6,6 +6,7 @@ import (
 	"sync/atomic"
 	"testing"
 	"time"
+	"unsafe"
 )
 
 func TestNew_PanicsWithZeroLimit(t *testing.T) {
@@ -219,6 +220,9 @@ func TestLoadshedder_Stats(t *testing.T) {
 	if stats.Running != 0 || stats.Waiting != 0 || stats.Limit != 3 {
 		t.Errorf("unexpected initial stats: %+v", stats)
 	}
+	if stats.ConfiguredLimit != 3 || stats.EffectiveLimit != 3 {
+		t.Errorf("expected ConfiguredLimit=3, EffectiveLimit=3, got %+v", stats)
+	}
 
 	// Acquire one
 	_, token1 := ls.Acquire(ctx)
@@ -511,6 +515,62 @@ func TestLoadshedder_WaitTime(t *testing.T) {
 	ls.Release(waitToken)
 }
 
+func TestToken_TimeAccessors(t *testing.T) {
+	ctx := context.Background()
+
+	ls := New(Config{Limit: 1, WaitingLimit: 1})
+
+	_, token1 := ls.Acquire(ctx)
+	if !token1.Accepted() {
+		t.Fatal("expected first acquisition to succeed")
+	}
+	if token1.AcceptedAt().IsZero() {
+		t.Error("expected AcceptedAt to be set for accepted token")
+	}
+	if token1.WaitTime() > time.Millisecond {
+		t.Errorf("expected WaitTime < 1ms for immediate acceptance, got %v", token1.WaitTime())
+	}
+
+	var wg sync.WaitGroup
+	var waitToken *Token
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_, waitToken = ls.Acquire(ctx)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	// Hard rejection: no wait, no acceptance time
+	_, rejected := ls.Acquire(ctx)
+	if rejected.Accepted() {
+		t.Fatal("expected third acquisition to fail")
+	}
+	if !rejected.AcceptedAt().IsZero() {
+		t.Errorf("expected zero AcceptedAt for rejected token, got %v", rejected.AcceptedAt())
+	}
+	if rejected.WaitTime() != 0 {
+		t.Errorf("expected WaitTime=0 for hard rejection, got %v", rejected.WaitTime())
+	}
+
+	ls.Release(token1)
+	wg.Wait()
+	defer ls.Release(waitToken)
+
+	if !waitToken.Accepted() {
+		t.Fatal("expected waiting acquisition to succeed")
+	}
+	if waitToken.WaitTime() < 40*time.Millisecond {
+		t.Errorf("expected WaitTime >= 40ms for waiting request, got %v", waitToken.WaitTime())
+	}
+	if waitToken.Age() < waitToken.WaitTime() {
+		t.Errorf("expected Age >= WaitTime, got Age=%v WaitTime=%v", waitToken.Age(), waitToken.WaitTime())
+	}
+	if since := time.Since(waitToken.AcceptedAt()); since > waitToken.Age() {
+		t.Errorf("expected AcceptedAt within token age, got %v ago (age %v)", since, waitToken.Age())
+	}
+}
+
 func BenchmarkLimiter(b *testing.B) {
 	ctx := context.Background()
 
@@ -591,6 +651,20 @@ func BenchmarkLimiter_AcceptedPath(b *testing.B) {
 	}
 }
 
+// BenchmarkLimiter_TokenSize reports the size of the Token allocated by each
+// acquisition along with its allocations.
+func BenchmarkLimiter_TokenSize(b *testing.B) {
+	ctx := context.Background()
+	ls := New(Config{Limit: 10000})
+
+	b.ReportAllocs()
+	for b.Loop() {
+		_, token := ls.Acquire(ctx)
+		ls.Release(token)
+	}
+	b.ReportMetric(float64(unsafe.Sizeof(Token{})), "token-bytes")
+}
+
 func BenchmarkLimiter_RejectedPath(b *testing.B) {
 	ctx := context.Background()
 
@@ -614,3 +688,211 @@ func BenchmarkLimiter_Stats(b *testing.B) {
 		_ = ls.Stats()
 	}
 }
+
+func TestLoadshedder_Config(t *testing.T) {
+	signal := &fakeSignal{}
+	ls := New(Config{
+		Name:         "api",
+		Limit:        10,
+		WaitingLimit: 3,
+		Signals:      []Signal{signal},
+	})
+
+	cfg := ls.Config()
+	if cfg.Name != "api" || cfg.Limit != 10 || cfg.WaitingLimit != 3 {
+		t.Errorf("unexpected config: %+v", cfg)
+	}
+	if len(cfg.Signals) != 1 || cfg.Signals[0] != signal {
+		t.Errorf("expected configured signal, got %+v", cfg.Signals)
+	}
+	if cfg.SignalInterval != time.Second {
+		t.Errorf("expected default SignalInterval=1s, got %v", cfg.SignalInterval)
+	}
+	if cfg.SignalHysteresis != 0.1 {
+		t.Errorf("expected default SignalHysteresis=0.1, got %v", cfg.SignalHysteresis)
+	}
+
+	// The returned config is a copy
+	cfg.Signals[0] = nil
+	if ls.Config().Signals[0] != signal {
+		t.Error("expected Config to return a copy of the signals")
+	}
+
+	// Runtime changes to the effective limit don't alter the configuration
+	ls.Clamp(2)
+	if cfg := ls.Config(); cfg.Limit != 10 {
+		t.Errorf("expected Limit=10 while clamped, got %d", cfg.Limit)
+	}
+
+	if cfg := New(Config{Limit: 1}).Config(); cfg.SignalInterval != 0 {
+		t.Errorf("expected no SignalInterval without signals, got %v", cfg.SignalInterval)
+	}
+}
+
+func BenchmarkLimiter_StatsUnderLoad(b *testing.B) {
+	ctx := context.Background()
+
+	ls := New(Config{Limit: 10000})
+
+	// Scrapers (admin endpoint, Prometheus, OTel...) read Stats in a tight loop
+	var scrapes atomic.Int64
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				_ = ls.Stats()
+				scrapes.Add(1)
+			}
+		}()
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, token := ls.Acquire(ctx)
+			ls.Release(token)
+		}
+	})
+	b.StopTimer()
+
+	close(stop)
+	wg.Wait()
+	b.ReportMetric(float64(scrapes.Load())/float64(b.N), "scrapes/op")
+}
+
+func BenchmarkLimiter_AcceptedPathSampled(b *testing.B) {
+	ctx := context.Background()
+
+	ls := New(Config{
+		Limit:      10000,
+		SampleHook: func(Stats, *Token) {},
+	})
+
+	for b.Loop() {
+		_, token := ls.Acquire(ctx)
+		ls.Release(token)
+	}
+}
+
+func TestLoadshedder_Counters(t *testing.T) {
+	ctx := context.Background()
+	ls := New(Config{Limit: 1})
+
+	_, token := ls.Acquire(ctx)
+	_, rejected := ls.Acquire(ctx)
+	ls.Release(rejected)
+	ls.Release(token)
+	ls.Release(token)
+
+	expected := Counters{Accepted: 1, Rejected: 1, Released: 1}
+	if counters := ls.Counters(); counters != expected {
+		t.Errorf("expected %+v, got %+v", expected, counters)
+	}
+}
+
+func TestLoadshedder_CounterOnly(t *testing.T) {
+	ctx := context.Background()
+
+	if ls := New(Config{Limit: 10, WaitingLimit: 1}); ls.settings.Load().waitingLimit == 0 {
+		t.Error("expected the waiting queue with a WaitingLimit")
+	}
+
+	ls := New(Config{Limit: 2})
+	if ls.settings.Load().waitingLimit != 0 {
+		t.Fatal("expected counter-only mode without a WaitingLimit")
+	}
+
+	_, first := ls.Acquire(ctx)
+	_, second := ls.Acquire(ctx)
+	if stats := ls.Stats(); stats.Running != 2 || stats.Waiting != 0 {
+		t.Errorf("expected 2 running, got %+v", stats)
+	}
+
+	// Full: rejected right away, whatever the acquire options
+	start := time.Now()
+	if _, token := ls.Acquire(ctx, WithMaxWait(time.Second)); token.Accepted() {
+		t.Error("expected a rejection at the limit")
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("expected an immediate rejection, took %s", elapsed)
+	}
+
+	// Clamping below the running requests holds new ones until they finish
+	ls.Clamp(1)
+	ls.Release(first)
+	if _, token := ls.Acquire(ctx); token.Accepted() {
+		t.Error("expected a rejection while running requests exceed the clamp")
+	}
+	ls.Release(second)
+
+	_, token := ls.Acquire(ctx)
+	if !token.Accepted() {
+		t.Error("expected an admission once under the clamp")
+	}
+	ls.Release(token)
+	if stats := ls.Stats(); stats.Running != 0 {
+		t.Errorf("expected no running request, got %+v", stats)
+	}
+}
+
+func BenchmarkLimiter_LargeLimit(b *testing.B) {
+	ctx := context.Background()
+
+	for _, bench := range []struct {
+		name         string
+		waitingLimit int64
+	}{
+		{"counter-only", 0},
+		{"queue", 1},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			ls := New(Config{Limit: 1_000_000, WaitingLimit: bench.waitingLimit})
+
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					_, token := ls.Acquire(ctx)
+					ls.Release(token)
+				}
+			})
+		})
+	}
+}
+
+func TestLoadshedder_AcquireN(t *testing.T) {
+	ctx := context.Background()
+	ls := New(Config{Limit: 4})
+
+	_, heavy := ls.AcquireN(ctx, 3)
+	if !heavy.Accepted() || heavy.Weight() != 3 {
+		t.Fatalf("expected a token of weight 3, got accepted=%v weight=%d", heavy.Accepted(), heavy.Weight())
+	}
+
+	_, light := ls.Acquire(ctx)
+	if !light.Accepted() || light.Weight() != 1 {
+		t.Fatalf("expected a token of weight 1, got accepted=%v weight=%d", light.Accepted(), light.Weight())
+	}
+	if stats := ls.Stats(); stats.Running != 4 {
+		t.Errorf("expected 4 slots running, got %+v", stats)
+	}
+	ls.Release(light)
+
+	if _, token := ls.AcquireN(ctx, 2); token.Accepted() {
+		t.Error("expected a rejection with 1 slot left")
+	}
+
+	if stats := ls.Release(heavy); stats.Running != 0 {
+		t.Errorf("expected the heavy token to free its 3 slots, got %+v", stats)
+	}
+
+	if _, token := ls.AcquireN(ctx, 0); token.Weight() != 1 {
+		t.Errorf("expected weights below 1 to count as 1, got %d", token.Weight())
+	}
+}
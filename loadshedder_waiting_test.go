@@ -500,3 +500,91 @@ func TestLoadshedder_WaitingQueue_PartialWaitersCancelled(t *testing.T) {
 		t.Errorf("expected final stats Running=0, Waiting=0, got %+v", finalStats)
 	}
 }
+
+func TestLoadshedder_AdmissionPaths(t *testing.T) {
+	ctx := context.Background()
+	ls := New(Config{Limit: 1, WaitingLimit: 1})
+
+	_, first := ls.Acquire(ctx)
+
+	queued := make(chan Stats)
+	go func() {
+		stats, token := ls.Acquire(ctx)
+		ls.Release(token)
+		queued <- stats
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	ls.Release(first)
+
+	stats := <-queued
+	if stats.FastAdmissions != 1 || stats.QueuedAdmissions != 1 {
+		t.Errorf("expected 1 fast and 1 queued admission, got %+v", stats)
+	}
+
+	// Rejections count in neither path
+	_, blocker := ls.Acquire(ctx)
+	defer ls.Release(blocker)
+	ls.Acquire(ctx, WithNoWait())
+
+	if stats := ls.Stats(); stats.FastAdmissions != 2 || stats.QueuedAdmissions != 1 {
+		t.Errorf("expected 2 fast and 1 queued admissions, got %+v", stats)
+	}
+}
+
+func TestLoadshedder_AcquireN_Waiting(t *testing.T) {
+	ctx := context.Background()
+	ls := New(Config{Limit: 4, WaitingLimit: 4})
+
+	// Heavier than the limit: it would never fit
+	if _, token := ls.AcquireN(ctx, 5); token.Accepted() || token.WaitTime() != 0 {
+		t.Error("expected an immediate rejection above the limit")
+	}
+
+	_, first := ls.AcquireN(ctx, 2)
+
+	admitted := make(chan *Token)
+	go func() {
+		_, token := ls.AcquireN(ctx, 4)
+		admitted <- token
+	}()
+	waitFor(t, func() bool { return ls.Stats().Waiting == 4 })
+
+	// Light requests queue behind the heavy one
+	if _, token := ls.Acquire(ctx, WithNoWait()); token.Accepted() {
+		t.Error("expected a light request not to overtake the queued heavy one")
+	}
+
+	ls.Release(first)
+	token := <-admitted
+	if !token.Accepted() || !token.Queued() {
+		t.Fatal("expected the heavy request to be admitted after waiting")
+	}
+	if stats := ls.Release(token); stats.Running != 0 || stats.Waiting != 0 {
+		t.Errorf("expected all slots to be free, got %+v", stats)
+	}
+}
+
+func TestLoadshedder_MaxQueueWait(t *testing.T) {
+	ctx := context.Background()
+	ls := New(Config{Limit: 1, WaitingLimit: 1, MaxQueueWait: 30 * time.Millisecond})
+
+	_, blocker := ls.Acquire(ctx)
+
+	// The context has no deadline: MaxQueueWait bounds the wait
+	_, token := ls.Acquire(ctx)
+	if token.Accepted() {
+		t.Fatal("expected a rejection after MaxQueueWait")
+	}
+	if wait := token.WaitTime(); wait < 30*time.Millisecond || wait > time.Second {
+		t.Errorf("expected to wait about 30ms, waited %s", wait)
+	}
+
+	// WithMaxWait overrides it
+	time.AfterFunc(60*time.Millisecond, func() { ls.Release(blocker) })
+	_, token = ls.Acquire(ctx, WithMaxWait(5*time.Second))
+	if !token.Accepted() {
+		t.Error("expected WithMaxWait to extend the wait")
+	}
+	ls.Release(token)
+}
@@ -0,0 +1,223 @@
+// Package loadsheddertest provides utilities to validate loadshedder
+// configurations against real HTTP traffic, e.g. in CI.
+package loadsheddertest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pior/loadshedder"
+)
+
+// Scenario describes the traffic driven through a middleware.
+type Scenario struct {
+	// Middleware is the middleware under test.
+	// Required.
+	Middleware *loadshedder.Middleware
+
+	// Handler is the handler wrapped by the middleware.
+	// Optional, default to a handler sleeping HandlerDuration.
+	Handler http.Handler
+
+	// HandlerDuration is the processing time of the default handler.
+	// Optional, default to 10ms.
+	HandlerDuration time.Duration
+
+	// Concurrency is the number of clients sending requests in a loop.
+	// Optional, default to 10.
+	Concurrency int
+
+	// Duration is how long the clients send requests.
+	// Optional, default to 1s.
+	Duration time.Duration
+
+	// NewRequest builds each request for the given server URL.
+	// Optional, default to GET requests on the root path.
+	NewRequest func(ctx context.Context, url string) (*http.Request, error)
+
+	// IsRejected classifies responses as rejected by the loadshedder.
+	// Optional, default to 429 and 503 status codes.
+	IsRejected func(*http.Response) bool
+}
+
+// Result holds the outcome of a scenario.
+type Result struct {
+	Accepted    int         // Requests not rejected by the loadshedder
+	Rejected    int         // Requests rejected by the loadshedder
+	Errors      int         // Requests that failed without a response
+	StatusCodes map[int]int // Number of responses by status code
+
+	// Latencies of the accepted and rejected requests, sorted.
+	AcceptedLatencies []time.Duration
+	RejectedLatencies []time.Duration
+}
+
+// Total returns the number of requests sent.
+func (r Result) Total() int {
+	return r.Accepted + r.Rejected + r.Errors
+}
+
+// RejectionRate returns the fraction of responses rejected by the loadshedder.
+func (r Result) RejectionRate() float64 {
+	if r.Accepted+r.Rejected == 0 {
+		return 0
+	}
+	return float64(r.Rejected) / float64(r.Accepted+r.Rejected)
+}
+
+// AcceptedLatency returns the q-quantile (0 to 1) of the accepted requests latency.
+func (r Result) AcceptedLatency(q float64) time.Duration {
+	return quantile(r.AcceptedLatencies, q)
+}
+
+// RejectedLatency returns the q-quantile (0 to 1) of the rejected requests latency.
+func (r Result) RejectedLatency(q float64) time.Duration {
+	return quantile(r.RejectedLatencies, q)
+}
+
+// RunScenario boots an httptest server running the scenario middleware,
+// drives the configured traffic and returns the observed results.
+// It fails the test if the scenario is invalid.
+func RunScenario(tb testing.TB, s Scenario) Result {
+	tb.Helper()
+
+	if s.Middleware == nil {
+		tb.Fatal("loadsheddertest: Scenario.Middleware is required")
+	}
+	if s.HandlerDuration <= 0 {
+		s.HandlerDuration = 10 * time.Millisecond
+	}
+	if s.Handler == nil {
+		s.Handler = sleepHandler(s.HandlerDuration)
+	}
+	if s.Concurrency <= 0 {
+		s.Concurrency = 10
+	}
+	if s.Duration <= 0 {
+		s.Duration = time.Second
+	}
+	if s.NewRequest == nil {
+		s.NewRequest = func(ctx context.Context, url string) (*http.Request, error) {
+			return http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+		}
+	}
+	if s.IsRejected == nil {
+		s.IsRejected = isRejected
+	}
+
+	server := httptest.NewServer(s.Middleware.Handler(s.Handler))
+	defer server.Close()
+
+	transport := &http.Transport{MaxIdleConnsPerHost: s.Concurrency}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.Duration)
+	defer cancel()
+
+	var (
+		mu     sync.Mutex
+		result = Result{StatusCodes: map[int]int{}}
+		wg     sync.WaitGroup
+	)
+	for range s.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ctx.Err() == nil {
+				outcome := send(ctx, client, server.URL, s)
+				if outcome.cancelled {
+					return
+				}
+
+				mu.Lock()
+				result.record(outcome)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	slices.Sort(result.AcceptedLatencies)
+	slices.Sort(result.RejectedLatencies)
+	return result
+}
+
+type outcome struct {
+	statusCode int
+	rejected   bool
+	latency    time.Duration
+	err        error
+	cancelled  bool // the scenario ended during the request
+}
+
+func send(ctx context.Context, client *http.Client, url string, s Scenario) outcome {
+	req, err := s.NewRequest(ctx, url)
+	if err != nil {
+		return outcome{err: err}
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return outcome{err: err, cancelled: ctx.Err() != nil}
+	}
+	defer resp.Body.Close()
+
+	_, err = io.Copy(io.Discard, resp.Body)
+	if err != nil && ctx.Err() != nil {
+		return outcome{cancelled: true}
+	}
+
+	return outcome{
+		statusCode: resp.StatusCode,
+		rejected:   s.IsRejected(resp),
+		latency:    time.Since(start),
+	}
+}
+
+func (r *Result) record(o outcome) {
+	switch {
+	case o.err != nil:
+		r.Errors++
+	case o.rejected:
+		r.Rejected++
+		r.RejectedLatencies = append(r.RejectedLatencies, o.latency)
+	default:
+		r.Accepted++
+		r.AcceptedLatencies = append(r.AcceptedLatencies, o.latency)
+	}
+
+	if o.err == nil {
+		r.StatusCodes[o.statusCode]++
+	}
+}
+
+func isRejected(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+}
+
+func sleepHandler(d time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(d):
+		case <-r.Context().Done():
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// quantile returns the q-quantile of sorted durations.
+func quantile(sorted []time.Duration, q float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(q * float64(len(sorted)-1))
+	return sorted[min(max(i, 0), len(sorted)-1)]
+}
@@ -0,0 +1,64 @@
+package loadsheddertest
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pior/loadshedder"
+)
+
+func TestRunScenario(t *testing.T) {
+	ls := loadshedder.New(loadshedder.Config{Limit: 2})
+	mw := loadshedder.NewMiddleware(ls, nil, nil)
+
+	result := RunScenario(t, Scenario{
+		Middleware:      mw,
+		HandlerDuration: 20 * time.Millisecond,
+		Concurrency:     8,
+		Duration:        200 * time.Millisecond,
+	})
+
+	if result.Accepted == 0 || result.Rejected == 0 {
+		t.Fatalf("expected both accepted and rejected requests, got %+v", result)
+	}
+	if result.Errors != 0 {
+		t.Errorf("expected no errors, got %d", result.Errors)
+	}
+	if result.StatusCodes[http.StatusOK] != result.Accepted || result.StatusCodes[http.StatusTooManyRequests] != result.Rejected {
+		t.Errorf("unexpected status codes: %v", result.StatusCodes)
+	}
+	if result.Total() != result.Accepted+result.Rejected {
+		t.Errorf("unexpected total: %d", result.Total())
+	}
+	if rate := result.RejectionRate(); rate <= 0 || rate >= 1 {
+		t.Errorf("expected a rejection rate in (0, 1), got %f", rate)
+	}
+
+	// Accepted requests run the handler, rejected ones do not
+	if p50 := result.AcceptedLatency(0.5); p50 < 20*time.Millisecond {
+		t.Errorf("expected accepted latency above the handler duration, got %v", p50)
+	}
+	if p50 := result.RejectedLatency(0.5); p50 >= 20*time.Millisecond {
+		t.Errorf("expected rejected latency below the handler duration, got %v", p50)
+	}
+
+	if stats := ls.Stats(); stats.Running != 0 || stats.Waiting != 0 {
+		t.Errorf("expected the loadshedder to be idle after the scenario, got %+v", stats)
+	}
+}
+
+func TestRunScenario_NoRejections(t *testing.T) {
+	ls := loadshedder.New(loadshedder.Config{Limit: 10})
+	mw := loadshedder.NewMiddleware(ls, nil, nil)
+
+	result := RunScenario(t, Scenario{
+		Middleware:  mw,
+		Concurrency: 4,
+		Duration:    100 * time.Millisecond,
+	})
+
+	if result.Accepted == 0 || result.Rejected != 0 {
+		t.Errorf("expected only accepted requests, got %+v", result)
+	}
+}
@@ -1,9 +1,23 @@
 package loadshedder
 
 import (
+	"context"
 	"log/slog"
+	"math"
 	"net/http"
+	"runtime/pprof"
+	"runtime/trace"
 	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const sourceHTTP = "http"
+
+// Bounds of the Retry-After of the default rejection handler.
+const (
+	defaultMinRetryAfter = time.Second
+	defaultMaxRetryAfter = time.Minute
 )
 
 // RejectionHandler is a function that receives Stats and returns an http.HandlerFunc
@@ -15,8 +29,74 @@ type RejectionHandler func(Stats) http.HandlerFunc
 type Middleware struct {
 	loadshedder      *Loadshedder
 	reporter         Reporter
+	completion       CompletionReporter
+	overheadReporter OverheadReporter
+	overhead         overheadCounters
 	rejectionHandler RejectionHandler
 	logger           *slog.Logger
+	pprofLabels      bool
+	degradationLevel bool
+	reporterTimeout  time.Duration
+	abandonedReports atomic.Int64
+	degradedCache    DegradedCache
+	classifier       Classifier
+	requestOptions   RequestOptions
+	router           router
+	cost             CostFunc
+	label            LabelFunc
+	policy           PolicyFunc
+	streaks          *rejectStreaks
+	healthChecks     *healthChecks
+	softReject       *softRejecter
+	rateLimitHeaders *rateLimitHeaders
+	bypass           func(*http.Request) bool
+	bypassed         atomic.Int64
+	deadline         func(*http.Request) (time.Time, bool)
+}
+
+// MiddlewareOption configures optional Middleware behavior.
+type MiddlewareOption func(*Middleware)
+
+// WithReporterTimeout bounds the time the request path waits for each Reporter callback.
+// A callback exceeding the timeout is abandoned: it keeps running in its own goroutine,
+// the request proceeds, and the abandonment is logged and counted (see AbandonedReports).
+// This complements the panic isolation so a hung reporter cannot wedge the request path.
+func WithReporterTimeout(timeout time.Duration) MiddlewareOption {
+	return func(m *Middleware) {
+		m.reporterTimeout = timeout
+	}
+}
+
+// WithDegradedCache records successful GET responses in the cache and, when a
+// request would otherwise be rejected, serves the cached response with a
+// Warning header instead of calling the rejection handler.
+// Rejections are still reported to the Reporter.
+func WithDegradedCache(cache DegradedCache) MiddlewareOption {
+	return func(m *Middleware) {
+		m.degradedCache = cache
+	}
+}
+
+// WithPprofLabels sets pprof labels on the goroutine running admitted handlers,
+// so CPU profiles can be split by traffic class during overload investigations.
+// Labels: loadshedder_priority, loadshedder_source and loadshedder_shed_state
+// ("immediate" or "queued").
+func WithPprofLabels() MiddlewareOption {
+	return func(m *Middleware) {
+		m.pprofLabels = true
+	}
+}
+
+// WithBypass serves the requests matching bypass directly, e.g.
+// HealthCheckPaths("/healthz", "/readyz") or internal admin routes: they are
+// never counted toward the limit nor shed, reported or measured, and the mux
+// does not need to route them around the middleware. Unlike
+// WithHealthChecks, they always bypass the loadshedder: they must be cheap.
+// See BypassedRequests.
+func WithBypass(bypass func(*http.Request) bool) MiddlewareOption {
+	return func(m *Middleware) {
+		m.bypass = bypass
+	}
 }
 
 // Reporter provides hooks for observability into the middleware's behavior.
@@ -28,24 +108,55 @@ type Reporter interface {
 	Rejected(*http.Request, Stats)
 }
 
-// NewMiddleware creates a new HTTP middleware with the given loadshedder, reporter, and rejection handler.
-// If reporter is nil, a NullReporter is used (no observability).
-// If rejectionHandler is nil, a default handler responding with HTTP 429, and a Retry-After header set to 5s is used.
-func NewMiddleware(loadshedder *Loadshedder, reporter Reporter, rejectionHandler RejectionHandler) *Middleware {
-	if reporter == nil {
-		reporter = NewNullReporter()
+// WithReporter sets the Reporter, like the reporter argument of NewMiddleware.
+func WithReporter(reporter Reporter) MiddlewareOption {
+	return func(m *Middleware) {
+		m.reporter = reporter
 	}
-	if rejectionHandler == nil {
-		retryAfter := 5
-		rejectionHandler = NewRejectionHandler(retryAfter)
+}
+
+// WithRejectionHandler sets the rejection handler, like the rejectionHandler
+// argument of NewMiddleware.
+func WithRejectionHandler(rejectionHandler RejectionHandler) MiddlewareOption {
+	return func(m *Middleware) {
+		m.rejectionHandler = rejectionHandler
 	}
+}
 
-	return &Middleware{
+// NewMiddleware creates a new HTTP middleware with the given loadshedder, reporter, and rejection handler.
+// If reporter is nil, a NullReporter is used (no observability).
+// If rejectionHandler is nil, a default handler responding with HTTP 429, and a Retry-After header computed from the Stats is used,
+// see NewAdaptiveRejectionHandler.
+// Options enable optional behaviors.
+func NewMiddleware(loadshedder *Loadshedder, reporter Reporter, rejectionHandler RejectionHandler, opts ...MiddlewareOption) *Middleware {
+	m := &Middleware{
 		loadshedder:      loadshedder,
 		reporter:         reporter,
 		rejectionHandler: rejectionHandler,
 		logger:           slog.Default(),
 	}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	if m.reporter == nil {
+		m.reporter = NewNullReporter()
+	}
+	m.completion, _ = m.reporter.(CompletionReporter)
+	m.overheadReporter, _ = m.reporter.(OverheadReporter)
+	if m.rejectionHandler == nil {
+		m.rejectionHandler = NewAdaptiveRejectionHandler(defaultMinRetryAfter, defaultMaxRetryAfter)
+	}
+
+	return m
+}
+
+// Wrap returns the middleware as a plain func(http.Handler) http.Handler, the
+// signature routers and middleware chains (chi, alice, negroni...) compose.
+// The reporter and rejection handler are set with WithReporter and
+// WithRejectionHandler, the other options work as with NewMiddleware.
+func Wrap(loadshedder *Loadshedder, opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	return NewMiddleware(loadshedder, nil, nil, opts...).Handler
 }
 
 // Handler wraps the given http.Handler with concurrency limiting.
@@ -53,42 +164,260 @@ func NewMiddleware(loadshedder *Loadshedder, reporter Reporter, rejectionHandler
 // Handler panics propagate after ensuring token cleanup.
 func (m *Middleware) Handler(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		stats, token := m.loadshedder.Acquire(r.Context())
+		if trace.IsEnabled() {
+			ctx, task := trace.NewTask(r.Context(), traceTaskRequest)
+			defer task.End()
+			r = r.WithContext(ctx)
+		}
+
+		if m.bypass != nil && m.bypass(r) {
+			m.bypassed.Add(1)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if m.deadline != nil {
+			if deadline, ok := m.deadline(r); ok {
+				ctx, cancelDeadline := context.WithDeadline(r.Context(), deadline)
+				defer cancelDeadline()
+				r = r.WithContext(ctx)
+			}
+		}
+
+		loadshedder, routeOptions := m.loadshedderFor(r)
+
+		if m.healthChecks != nil && m.healthChecks.serveDiverted(w, r, loadshedder) {
+			return
+		}
+
+		// Deferred first, so it measures the release too
+		overhead := overheadTimer{start: time.Now()}
+		defer m.recordOverhead(r, &overhead)
+
+		// Let Drain cancel the request, see Config.CancelOnDrain
+		var cancel context.CancelCauseFunc
+		if loadshedder.config.CancelOnDrain != nil {
+			var ctx context.Context
+			ctx, cancel = context.WithCancelCause(r.Context())
+			defer cancel(nil)
+			r = r.WithContext(ctx)
+		}
+
+		// New requests never wait with WithSoftReject, retries keep their place
+		var arrival time.Time
+		retry := false
+		if m.softReject != nil {
+			arrival, retry = m.softReject.arrival(r, overhead.start)
+		}
+
+		stats, token := m.acquire(loadshedder, r, routeOptions, cancel, m.softReject != nil && !retry)
+		overhead.excluded = stats.WaitTime
 
 		if !token.Accepted() {
+			// Let the rejection handler and the degraded cache read the rejection
+			r = r.WithContext(ContextWithToken(ContextWithStats(r.Context(), stats), token))
 			m.reportRejected(r, stats)
 
-			m.rejectionHandler(stats).ServeHTTP(w, r)
+			streak := 0
+			if m.streaks != nil {
+				streak = m.streaks.rejected(r)
+			}
+
+			if m.degradedCache != nil && m.serveDegraded(w, r) {
+				return
+			}
+
+			if m.softReject != nil && !loadshedder.Draining() {
+				if !retry {
+					arrival = overhead.start
+				}
+				m.softReject.serve(w, arrival)
+				return
+			}
+
+			var rejection http.Handler = m.rejectionHandler(stats)
+			if m.rateLimitHeaders != nil {
+				rejection = m.rateLimitHeaders.rejected(rejection, stats)
+			}
+
+			if m.streaks != nil {
+				m.streaks.serveRejected(rejection, w, r, streak)
+				return
+			}
+
+			rejection.ServeHTTP(w, r)
 			return
 		}
 
 		// Ensure token is always released, even if handler panics
-		defer m.loadshedder.Release(token)
+		defer loadshedder.Release(token)
+
+		if m.streaks != nil {
+			m.streaks.accepted(r)
+		}
 
 		m.reportAccepted(r, stats)
 
-		next.ServeHTTP(w, r)
+		if m.rateLimitHeaders != nil && !m.rateLimitHeaders.rejectedOnly {
+			m.rateLimitHeaders.set(w.Header(), stats)
+		}
+
+		// Let outgoing requests and nested acquisitions inherit the priority,
+		// handler logs include the token ID and the Stats, and handlers
+		// register OnShed hooks
+		ctx := ContextWithToken(ContextWithStats(r.Context(), stats), token)
+		if p := token.Priority(); p != PriorityFromContext(ctx) {
+			ctx = ContextWithPriority(ctx, p)
+		}
+		if m.degradationLevel {
+			ctx = ContextWithDegradationLevel(ctx, loadshedder.DegradationLevel())
+		}
+		r = r.WithContext(ctx)
+
+		if m.completion != nil || loadshedder.errorRate != nil {
+			m.serveCompleted(func(w http.ResponseWriter) {
+				overhead.serve(func() { m.serveAdmitted(next, w, r, token) })
+			}, w, r, token, stats)
+			return
+		}
+
+		overhead.serve(func() { m.serveAdmitted(next, w, r, token) })
 	})
 }
 
+// serveAdmitted runs the handler of an admitted request.
+func (m *Middleware) serveAdmitted(next http.Handler, w http.ResponseWriter, r *http.Request, token *Token) {
+	if m.degradedCache != nil {
+		m.serveAndRecord(next, w, r, token)
+		return
+	}
+
+	m.serve(next, w, r, token)
+}
+
+// acquire acquires a slot for the request, with the options of its route and
+// its request options if any.
+func (m *Middleware) acquire(loadshedder *Loadshedder, r *http.Request, routeOptions []AcquireOption, cancel context.CancelCauseFunc, noWait bool) (Stats, *Token) {
+	if m.requestOptions == nil && m.cost == nil && m.label == nil && m.policy == nil && routeOptions == nil && cancel == nil && !noWait {
+		return loadshedder.Acquire(r.Context(), WithSource(sourceHTTP))
+	}
+
+	opts := []AcquireOption{WithSource(sourceHTTP)}
+	if noWait {
+		opts = append(opts, WithNoWait())
+	}
+	if cancel != nil {
+		opts = append(opts, WithCancel(cancel))
+	}
+	if m.cost != nil {
+		opts = append(opts, WithWeight(m.cost(r)))
+	}
+	if m.label != nil {
+		opts = append(opts, WithLabel(m.label(r)))
+	}
+	if m.policy != nil {
+		opts = append(opts, WithPriority(m.policy(r)))
+	}
+	opts = append(opts, routeOptions...)
+	if m.requestOptions != nil {
+		opts = append(opts, m.requestOptions(r)...)
+	}
+	return loadshedder.Acquire(r.Context(), opts...)
+}
+
+// serve runs the admitted handler, with the optional profiling and tracing annotations.
+func (m *Middleware) serve(next http.Handler, w http.ResponseWriter, r *http.Request, token *Token) {
+	if m.pprofLabels {
+		pprof.Do(r.Context(), tokenLabels(token), func(ctx context.Context) {
+			serveTraced(next, w, r.WithContext(ctx))
+		})
+		return
+	}
+
+	serveTraced(next, w, r)
+}
+
+func serveTraced(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	if trace.IsEnabled() {
+		trace.WithRegion(r.Context(), traceRegionHandler, func() {
+			next.ServeHTTP(w, r)
+		})
+		return
+	}
+
+	next.ServeHTTP(w, r)
+}
+
+func tokenLabels(token *Token) pprof.LabelSet {
+	shedState := "immediate"
+	if token.Queued() {
+		shedState = "queued"
+	}
+
+	return pprof.Labels(
+		"loadshedder_priority", strconv.Itoa(int(token.Priority())),
+		"loadshedder_source", token.Source(),
+		"loadshedder_shed_state", shedState,
+	)
+}
+
 func (m *Middleware) reportAccepted(r *http.Request, stats Stats) {
-	defer func() {
-		if err := recover(); err != nil {
-			m.logger.Error("loadshedder: reporter panic on accepted", "error", err)
-		}
+	m.report("accepted", func() {
+		m.reporter.Accepted(r, stats)
+	})
+}
+
+func (m *Middleware) reportRejected(r *http.Request, stats Stats) {
+	m.report("rejected", func() {
+		m.reporter.Rejected(r, stats)
+	})
+}
+
+// report invokes a reporter callback, isolating panics and, when a reporter
+// timeout is configured, abandoning callbacks that do not return in time.
+func (m *Middleware) report(event string, callback func()) {
+	if m.reporterTimeout <= 0 {
+		m.callReporter(event, callback)
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.callReporter(event, callback)
 	}()
 
-	m.reporter.Accepted(r, stats)
+	timer := time.NewTimer(m.reporterTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+	case <-timer.C:
+		m.abandonedReports.Add(1)
+		m.logger.Warn("loadshedder: reporter timed out on "+event, "timeout", m.reporterTimeout)
+	}
 }
 
-func (m *Middleware) reportRejected(r *http.Request, stats Stats) {
+func (m *Middleware) callReporter(event string, callback func()) {
 	defer func() {
 		if err := recover(); err != nil {
-			m.logger.Error("loadshedder: reporter panic on rejected", "error", err)
+			m.logger.Error("loadshedder: reporter panic on "+event, "error", err)
 		}
 	}()
 
-	m.reporter.Rejected(r, stats)
+	callback()
+}
+
+// AbandonedReports returns the number of reporter callbacks abandoned
+// after exceeding the reporter timeout.
+func (m *Middleware) AbandonedReports() int64 {
+	return m.abandonedReports.Load()
+}
+
+// BypassedRequests returns the number of requests served without the
+// loadshedder, see WithBypass.
+func (m *Middleware) BypassedRequests() int64 {
+	return m.bypassed.Load()
 }
 
 // NewRejectionHandler creates a rejection handler function that responds with HTTP 429
@@ -104,3 +433,34 @@ func NewRejectionHandler(retryAfterSeconds int) RejectionHandler {
 		}
 	}
 }
+
+// NewAdaptiveRejectionHandler creates a rejection handler like
+// NewRejectionHandler, with the Retry-After computed from Stats.RetryAfter,
+// the projected time to drain the queue, rounded up to the second and
+// bounded by minRetryAfter and maxRetryAfter. Clients then back off longer
+// while the queue is deep, rather than retrying too fast and making the
+// overload worse. It is the default rejection handler, with bounds of 1s and
+// 1m.
+func NewAdaptiveRejectionHandler(minRetryAfter, maxRetryAfter time.Duration) RejectionHandler {
+	if minRetryAfter < 0 || maxRetryAfter < minRetryAfter {
+		panic("loadshedder: Retry-After bounds must be non-negative and ordered")
+	}
+	return func(stats Stats) http.HandlerFunc {
+		retryAfter := strconv.Itoa(retryAfterSeconds(stats, minRetryAfter, maxRetryAfter))
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", retryAfter)
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte("Too Many Requests\n"))
+		}
+	}
+}
+
+// retryAfterSeconds returns Stats.RetryAfter within the bounds, rounded up
+// to the second.
+func retryAfterSeconds(stats Stats, minRetryAfter, maxRetryAfter time.Duration) int {
+	return ceilSeconds(min(max(stats.RetryAfter, minRetryAfter), maxRetryAfter))
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
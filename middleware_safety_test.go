@@ -352,3 +352,74 @@ func TestMiddleware_PanicDoesNotAffectOtherRequests(t *testing.T) {
 		t.Errorf("expected Running=0, Waiting=0, got %+v", stats)
 	}
 }
+
+type blockingReporter struct {
+	unblock chan struct{}
+}
+
+func (r *blockingReporter) Accepted(*http.Request, Stats) {
+	<-r.unblock
+}
+
+func (r *blockingReporter) Rejected(*http.Request, Stats) {
+	<-r.unblock
+}
+
+func TestMiddleware_ReporterTimeout(t *testing.T) {
+	reporter := &blockingReporter{unblock: make(chan struct{})}
+	defer close(reporter.unblock)
+
+	limiter := New(Config{Limit: 1})
+	mw := NewMiddleware(limiter, reporter, nil, WithReporterTimeout(20*time.Millisecond))
+
+	handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rec.Code)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected hung reporter to be abandoned, request took %v", elapsed)
+	}
+	if abandoned := mw.AbandonedReports(); abandoned != 1 {
+		t.Errorf("expected 1 abandoned report, got %d", abandoned)
+	}
+
+	stats := limiter.Stats()
+	if stats.Running != 0 || stats.Waiting != 0 {
+		t.Errorf("expected Running=0, Waiting=0 after request, got %+v", stats)
+	}
+}
+
+func TestMiddleware_ReporterTimeoutPanics(t *testing.T) {
+	var buf bytes.Buffer
+	oldDefault := slog.Default()
+	defer slog.SetDefault(oldDefault)
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+
+	limiter := New(Config{Limit: 5})
+	reporter := &panicReporter{panicOnAccepted: true}
+	mw := NewMiddleware(limiter, reporter, nil, WithReporterTimeout(time.Second))
+
+	handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rec.Code)
+	}
+	if !strings.Contains(buf.String(), "loadshedder: reporter panic on accepted") {
+		t.Errorf("expected panic to be logged, got: %s", buf.String())
+	}
+	if abandoned := mw.AbandonedReports(); abandoned != 0 {
+		t.Errorf("expected no abandoned report, got %d", abandoned)
+	}
+}
@@ -3,6 +3,7 @@ package loadshedder
 import (
 	"net/http"
 	"net/http/httptest"
+	"runtime/pprof"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -227,3 +228,151 @@ func BenchmarkMiddleware_Rejected(b *testing.B) {
 		}
 	})
 }
+
+func TestNewAdaptiveRejectionHandler(t *testing.T) {
+	handler := NewAdaptiveRejectionHandler(time.Second, time.Minute)
+
+	tests := []struct {
+		retryAfter time.Duration
+		expected   string
+	}{
+		{0, "1"},
+		{2500 * time.Millisecond, "3"},
+		{time.Hour, "60"},
+	}
+
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler(Stats{RetryAfter: tt.retryAfter}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+		if rec.Code != http.StatusTooManyRequests {
+			t.Errorf("expected status 429, got %d", rec.Code)
+		}
+		if got := rec.Header().Get("Retry-After"); got != tt.expected {
+			t.Errorf("RetryAfter=%v: expected Retry-After %q, got %q", tt.retryAfter, tt.expected, got)
+		}
+	}
+}
+
+func TestMiddleware_DefaultRetryAfter(t *testing.T) {
+	limiter := New(Config{Limit: 1})
+	limiter.avgDuration.Store(int64(4 * time.Second))
+	mw := NewMiddleware(limiter, nil, nil)
+
+	_, token := limiter.Acquire(t.Context())
+	defer limiter.Release(token)
+
+	rec := httptest.NewRecorder()
+	mw.Handler(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	if got := rec.Header().Get("Retry-After"); got != "4" {
+		t.Errorf("expected the Retry-After computed from the average duration, got %q", got)
+	}
+}
+
+func TestMiddleware_PprofLabels(t *testing.T) {
+	limiter := New(Config{Limit: 1})
+	mw := NewMiddleware(limiter, nil, nil, WithPprofLabels())
+
+	var labels map[string]string
+	handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		labels = map[string]string{}
+		pprof.ForLabels(r.Context(), func(key, value string) bool {
+			labels[key] = value
+			return true
+		})
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	expected := map[string]string{
+		"loadshedder_priority":   "0",
+		"loadshedder_source":     "http",
+		"loadshedder_shed_state": "immediate",
+	}
+	for key, value := range expected {
+		if labels[key] != value {
+			t.Errorf("expected label %s=%q, got %q", key, value, labels[key])
+		}
+	}
+}
+
+func TestMiddleware_WithBypass(t *testing.T) {
+	limiter := New(Config{Limit: 1})
+	reporter := &testReporter{}
+	mw := NewMiddleware(limiter, reporter, nil, WithBypass(HealthCheckPaths("/healthz")))
+
+	var running int64
+	handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		running = limiter.Stats().Running
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// The only slot is taken: bypassed requests are still served
+	_, token := limiter.Acquire(t.Context())
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", http.NoBody))
+	limiter.Release(token)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rec.Code)
+	}
+	if running != 1 {
+		t.Errorf("expected the bypassed request not to be counted, got %d running", running)
+	}
+	if reporter.accepted.Load() != 0 || reporter.rejected.Load() != 0 {
+		t.Errorf("expected no report, got %d accepted and %d rejected", reporter.accepted.Load(), reporter.rejected.Load())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	if running != 1 || reporter.accepted.Load() != 1 {
+		t.Errorf("expected other requests to go through the loadshedder, got %d running and %d accepted", running, reporter.accepted.Load())
+	}
+	if mw.BypassedRequests() != 1 {
+		t.Errorf("expected 1 bypassed request, got %d", mw.BypassedRequests())
+	}
+}
+
+func TestWrap(t *testing.T) {
+	limiter := New(Config{Limit: 1})
+	reporter := &testReporter{}
+	wrap := Wrap(limiter,
+		WithReporter(reporter),
+		WithRejectionHandler(func(Stats) http.HandlerFunc {
+			return func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}),
+	)
+
+	var rejectedCode int
+	handler := wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The only slot is taken: a nested request is rejected
+		rec := httptest.NewRecorder()
+		wrap(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+		rejectedCode = rec.Code
+
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status 200, got %d", rec.Code)
+	}
+	if rejectedCode != http.StatusServiceUnavailable {
+		t.Errorf("expected the custom rejection handler, got %d", rejectedCode)
+	}
+	if reporter.accepted.Load() != 1 || reporter.rejected.Load() != 1 {
+		t.Errorf("expected 1 accepted and 1 rejected report, got %d and %d", reporter.accepted.Load(), reporter.rejected.Load())
+	}
+}
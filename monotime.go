@@ -0,0 +1,40 @@
+package loadshedder
+
+import "time"
+
+// epoch is the origin of the monotonic timestamps, see monotime.
+var epoch = time.Now()
+
+// monotime is a monotonic timestamp in nanoseconds since epoch, zero if unset.
+// Tokens store their timestamps as monotime rather than time.Time: 8 bytes
+// instead of 24, and durations between them are a plain subtraction, immune
+// to wall clock changes.
+type monotime int64
+
+// monoOf converts t, which must carry a monotonic clock reading (as returned
+// by time.Now), to a monotime. Times before epoch are negative.
+func monoOf(t time.Time) monotime {
+	if d := t.Sub(epoch); d != 0 {
+		return monotime(d)
+	}
+	return 1 // zero means unset
+
+}
+
+// IsZero reports whether the timestamp is unset.
+func (m monotime) IsZero() bool {
+	return m == 0
+}
+
+// Sub returns the duration m-u.
+func (m monotime) Sub(u monotime) time.Duration {
+	return time.Duration(m - u)
+}
+
+// Time returns the timestamp as a time.Time (zero if unset).
+func (m monotime) Time() time.Time {
+	if m == 0 {
+		return time.Time{}
+	}
+	return epoch.Add(time.Duration(m))
+}
@@ -0,0 +1,30 @@
+package loadshedder
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMonotime(t *testing.T) {
+	var unset monotime
+	if !unset.IsZero() || !unset.Time().IsZero() {
+		t.Errorf("expected the zero monotime to be unset, got %v", unset.Time())
+	}
+
+	now := time.Now()
+	before := monoOf(now.Add(-time.Hour)) // before epoch
+	at := monoOf(now)
+
+	if before.IsZero() || at.IsZero() {
+		t.Fatal("expected set timestamps")
+	}
+	if d := at.Sub(before); d != time.Hour {
+		t.Errorf("expected 1h between the timestamps, got %v", d)
+	}
+	if !at.Time().Equal(now) {
+		t.Errorf("expected the time to round-trip, got %v instead of %v", at.Time(), now)
+	}
+	if monoOf(epoch).IsZero() {
+		t.Error("expected epoch itself to be a set timestamp")
+	}
+}
@@ -0,0 +1,111 @@
+package loadshedder
+
+import (
+	"context"
+	"time"
+)
+
+// AcquireOption overrides the limiter defaults for a single Acquire call.
+type AcquireOption func(*acquireOptions)
+
+type acquireOptions struct {
+	noWait      bool
+	maxWait     time.Duration
+	priority    Priority
+	prioritySet bool
+	source      string
+	label       string
+	weight      int64
+
+	releaseOnDone bool
+	cancel        context.CancelCauseFunc
+}
+
+func applyAcquireOptions(opts []AcquireOption) acquireOptions {
+	var o acquireOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func (o acquireOptions) newToken(arrivedAt monotime) *Token {
+	return &Token{arrivedAt: arrivedAt, priority: o.priority, source: o.source, label: o.label, weight: o.weight}
+}
+
+// WithNoWait rejects the acquisition immediately if no slot is available,
+// even when the limiter has a WaitingLimit.
+func WithNoWait() AcquireOption {
+	return func(o *acquireOptions) {
+		o.noWait = true
+	}
+}
+
+// WithMaxWait bounds the time spent waiting for a slot.
+// The request context still applies: whichever expires first wins.
+// A non-positive duration behaves like WithNoWait.
+func WithMaxWait(d time.Duration) AcquireOption {
+	return func(o *acquireOptions) {
+		if d <= 0 {
+			o.noWait = true
+			return
+		}
+		o.maxWait = d
+	}
+}
+
+// WithPriority sets the priority of the acquisition.
+// The priority is recorded on the Token and available via Token.Priority().
+// Without WithPriority, the priority is inherited from the context, see
+// ContextWithPriority.
+func WithPriority(p Priority) AcquireOption {
+	return func(o *acquireOptions) {
+		o.priority = p
+		o.prioritySet = true
+	}
+}
+
+// WithSource tags the acquisition with the name of the traffic source
+// (e.g. "http", "grpc", "cron"). The source is available via Token.Source().
+func WithSource(source string) AcquireOption {
+	return func(o *acquireOptions) {
+		o.source = source
+	}
+}
+
+// WithWeight makes the acquisition consume weight slots instead of one, for
+// requests much more expensive than the others (e.g. bulk exports). Weights
+// below 1 count as 1. An acquisition heavier than the effective limit is
+// rejected immediately. See AcquireN.
+func WithWeight(weight int) AcquireOption {
+	return func(o *acquireOptions) {
+		o.weight = int64(weight)
+	}
+}
+
+// WithLabel accounts the acquisition under a label (e.g. the route) in
+// CountersByLabel. Labels should have a low cardinality, see Config.MaxLabels.
+func WithLabel(label string) AcquireOption {
+	return func(o *acquireOptions) {
+		o.label = label
+	}
+}
+
+// WithReleaseOnDone releases the token automatically when ctx is done, as a
+// safety net for integrations where the request lifecycle is less explicit
+// (e.g. framework adapters). Calling Release remains the normal path and
+// is still safe. Tokens released this way are counted by AutoReleased.
+func WithReleaseOnDone() AcquireOption {
+	return func(o *acquireOptions) {
+		o.releaseOnDone = true
+	}
+}
+
+// WithCancel registers the function cancelling the request context, so Drain
+// can cancel the request if it matches Config.CancelOnDrain. The request is
+// cancelled with ErrDraining as the cause.
+func WithCancel(cancel context.CancelCauseFunc) AcquireOption {
+	return func(o *acquireOptions) {
+		o.cancel = cancel
+	}
+}
@@ -0,0 +1,204 @@
+package loadshedder
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAcquire_WithNoWait(t *testing.T) {
+	ctx := context.Background()
+
+	ls := New(Config{Limit: 1, WaitingLimit: 5})
+
+	_, token1 := ls.Acquire(ctx)
+	if !token1.Accepted() {
+		t.Fatal("expected first acquisition to succeed")
+	}
+
+	start := time.Now()
+	stats, token2 := ls.Acquire(ctx, WithNoWait())
+	if token2.Accepted() {
+		t.Fatal("expected no-wait acquisition to be rejected at limit")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Millisecond {
+		t.Errorf("expected immediate rejection, took %v", elapsed)
+	}
+	if stats.WaitTime > time.Millisecond {
+		t.Errorf("expected WaitTime < 1ms, got %v", stats.WaitTime)
+	}
+
+	ls.Release(token1)
+
+	_, token3 := ls.Acquire(ctx, WithNoWait())
+	if !token3.Accepted() {
+		t.Fatal("expected no-wait acquisition to succeed under limit")
+	}
+	ls.Release(token3)
+
+	if stats := ls.Stats(); stats.Running != 0 || stats.Waiting != 0 {
+		t.Errorf("expected no running or waiting requests, got %+v", stats)
+	}
+}
+
+func TestAcquire_WithMaxWait(t *testing.T) {
+	ctx := context.Background()
+
+	ls := New(Config{Limit: 1, WaitingLimit: 5})
+
+	_, token1 := ls.Acquire(ctx)
+	if !token1.Accepted() {
+		t.Fatal("expected first acquisition to succeed")
+	}
+
+	stats, token2 := ls.Acquire(ctx, WithMaxWait(50*time.Millisecond))
+	if token2.Accepted() {
+		t.Fatal("expected acquisition to be rejected after max wait")
+	}
+	if stats.WaitTime < 40*time.Millisecond || stats.WaitTime > 200*time.Millisecond {
+		t.Errorf("expected WaitTime around 50ms, got %v", stats.WaitTime)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		ls.Release(token1)
+	}()
+
+	_, token3 := ls.Acquire(ctx, WithMaxWait(time.Second))
+	if !token3.Accepted() {
+		t.Fatal("expected acquisition to succeed within max wait")
+	}
+	ls.Release(token3)
+
+	if stats := ls.Stats(); stats.Running != 0 || stats.Waiting != 0 {
+		t.Errorf("expected no running or waiting requests, got %+v", stats)
+	}
+}
+
+func TestAcquire_WithMaxWaitZeroDoesNotWait(t *testing.T) {
+	ctx := context.Background()
+
+	ls := New(Config{Limit: 1, WaitingLimit: 5})
+
+	_, token1 := ls.Acquire(ctx)
+	defer ls.Release(token1)
+
+	start := time.Now()
+	_, token2 := ls.Acquire(ctx, WithMaxWait(0))
+	if token2.Accepted() {
+		t.Fatal("expected acquisition to be rejected")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Millisecond {
+		t.Errorf("expected immediate rejection, took %v", elapsed)
+	}
+}
+
+func TestAcquire_WithPriority(t *testing.T) {
+	ctx := context.Background()
+
+	ls := New(Config{Limit: 1})
+
+	_, token := ls.Acquire(ctx, WithPriority(3))
+	defer ls.Release(token)
+	if token.Priority() != 3 {
+		t.Errorf("expected Priority=3, got %d", token.Priority())
+	}
+
+	_, rejected := ls.Acquire(ctx, WithPriority(-1))
+	if rejected.Accepted() {
+		t.Fatal("expected acquisition to be rejected")
+	}
+	if rejected.Priority() != -1 {
+		t.Errorf("expected Priority=-1 on rejected token, got %d", rejected.Priority())
+	}
+}
+
+func TestAcquire_WithSource(t *testing.T) {
+	ctx := context.Background()
+
+	ls := New(Config{Limit: 1, WaitingLimit: 1})
+
+	_, token1 := ls.Acquire(ctx, WithSource("cron"))
+	if token1.Source() != "cron" {
+		t.Errorf("expected Source=cron, got %q", token1.Source())
+	}
+	if token1.Queued() {
+		t.Error("expected immediate acquisition not to be queued")
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		ls.Release(token1)
+	}()
+
+	_, token2 := ls.Acquire(ctx)
+	defer ls.Release(token2)
+	if !token2.Accepted() {
+		t.Fatal("expected waiting acquisition to succeed")
+	}
+	if !token2.Queued() {
+		t.Error("expected waiting acquisition to be queued")
+	}
+}
+
+func TestAcquire_WithReleaseOnDone(t *testing.T) {
+	ls := New(Config{Limit: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	_, token := ls.Acquire(ctx, WithReleaseOnDone())
+	if !token.Accepted() {
+		t.Fatal("expected acquisition to succeed")
+	}
+
+	// The caller never releases; ending the context frees the slot
+	cancel()
+
+	deadline := time.Now().Add(time.Second)
+	for ls.Stats().Running != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected token to be released when the context is done, got %+v", ls.Stats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if stats := ls.Stats(); stats.Waiting != 0 {
+		t.Errorf("expected Waiting=0, got %+v", stats)
+	}
+	if released := ls.AutoReleased(); released != 1 {
+		t.Errorf("expected AutoReleased=1, got %d", released)
+	}
+
+	// A late Release is a no-op
+	ls.Release(token)
+	if stats := ls.Stats(); stats.Running != 0 || stats.Waiting != 0 {
+		t.Errorf("expected no running or waiting requests, got %+v", stats)
+	}
+}
+
+func TestAcquire_WithReleaseOnDoneExplicitRelease(t *testing.T) {
+	ls := New(Config{Limit: 1})
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	_, token := ls.Acquire(ctx, WithReleaseOnDone(), WithMaxWait(time.Millisecond))
+	if !token.Accepted() {
+		t.Fatal("expected acquisition to succeed")
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if stats := ls.Stats(); stats.Running != 1 {
+		t.Errorf("expected the token to outlive the max wait timeout, got %+v", stats)
+	}
+
+	ls.Release(token)
+	cancel()
+	time.Sleep(5 * time.Millisecond)
+
+	if released := ls.AutoReleased(); released != 0 {
+		t.Errorf("expected AutoReleased=0 after an explicit Release, got %d", released)
+	}
+	if stats := ls.Stats(); stats.Running != 0 || stats.Waiting != 0 {
+		t.Errorf("expected no running or waiting requests, got %+v", stats)
+	}
+}
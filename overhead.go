@@ -0,0 +1,99 @@
+package loadshedder
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// OverheadReporter is a Reporter also notified of the overhead of the
+// middleware for each request: the time spent in the middleware itself
+// (acquire bookkeeping, reporter calls, release, rejection response),
+// excluding the handler and the wait for a slot. It shows that the overhead
+// is negligible, and catches regressions. The middleware detects it on the
+// reporter: the Reporter interface stays stable.
+type OverheadReporter interface {
+	Reporter
+
+	// Overhead is called when the middleware is done with a request, after
+	// the release. Requests whose handler panicked are not measured.
+	Overhead(r *http.Request, overhead time.Duration)
+}
+
+// OverheadStats is the overhead of the middleware since its creation, see
+// Middleware.Overhead.
+type OverheadStats struct {
+	Requests int64         // Requests measured
+	Total    time.Duration // Overhead of all the requests measured
+	Max      time.Duration // Highest overhead of a request
+}
+
+// Mean returns the average overhead per request, 0 without requests.
+func (o OverheadStats) Mean() time.Duration {
+	if o.Requests == 0 {
+		return 0
+	}
+	return o.Total / time.Duration(o.Requests)
+}
+
+// overheadCounters accumulates the OverheadStats.
+type overheadCounters struct {
+	requests atomic.Int64
+	total    atomic.Int64 // nanoseconds
+	max      atomic.Int64 // nanoseconds
+}
+
+func (c *overheadCounters) record(overhead time.Duration) {
+	c.requests.Add(1)
+	c.total.Add(int64(overhead))
+	for {
+		current := c.max.Load()
+		if int64(overhead) <= current || c.max.CompareAndSwap(current, int64(overhead)) {
+			return
+		}
+	}
+}
+
+// overheadTimer measures the overhead of a request, see OverheadReporter.
+type overheadTimer struct {
+	start    time.Time
+	excluded time.Duration // handler and wait for a slot
+	serving  bool          // the handler is running, or panicked
+}
+
+// serve runs the handler, excluding its duration from the overhead.
+func (t *overheadTimer) serve(serve func()) {
+	start := time.Now()
+	t.serving = true
+	serve()
+	t.serving = false
+	t.excluded += time.Since(start)
+}
+
+// recordOverhead records the overhead of the request, unless its handler
+// panicked.
+func (m *Middleware) recordOverhead(r *http.Request, t *overheadTimer) {
+	if t.serving {
+		return
+	}
+
+	overhead := max(0, time.Since(t.start)-t.excluded)
+	m.overhead.record(overhead)
+	if m.overheadReporter != nil {
+		m.report("overhead", func() {
+			m.overheadReporter.Overhead(r, overhead)
+		})
+	}
+}
+
+// Overhead returns the overhead of the middleware since its creation: the
+// time spent in the middleware itself, excluding the handlers and the wait
+// for a slot, see OverheadReporter. Health checks served by WithHealthChecks
+// are not measured.
+func (m *Middleware) Overhead() OverheadStats {
+	return OverheadStats{
+		Requests: m.overhead.requests.Load(),
+		Total:    time.Duration(m.overhead.total.Load()),
+		Max:      time.Duration(m.overhead.max.Load()),
+	}
+}
@@ -0,0 +1,82 @@
+package loadshedder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type overheadRecordingReporter struct {
+	NullReporter
+
+	mu        sync.Mutex
+	overheads []time.Duration
+}
+
+func (r *overheadRecordingReporter) Overhead(_ *http.Request, overhead time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.overheads = append(r.overheads, overhead)
+}
+
+func TestMiddleware_Overhead(t *testing.T) {
+	ls := New(Config{Limit: 1})
+	reporter := &overheadRecordingReporter{}
+	mw := NewMiddleware(ls, reporter, nil)
+
+	handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	// Rejected requests are measured too
+	_, token := ls.Acquire(t.Context())
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	ls.Release(token)
+
+	overhead := mw.Overhead()
+	if overhead.Requests != 2 || len(reporter.overheads) != 2 {
+		t.Fatalf("expected 2 requests measured, got %+v and %v", overhead, reporter.overheads)
+	}
+	// The handler duration is excluded
+	if overhead.Max >= 20*time.Millisecond || overhead.Total < overhead.Max || overhead.Mean() > overhead.Max {
+		t.Errorf("expected the handler to be excluded, got %+v", overhead)
+	}
+}
+
+func TestMiddleware_OverheadExcludesWaitAndPanics(t *testing.T) {
+	ls := New(Config{Limit: 1, WaitingLimit: 1})
+	mw := NewMiddleware(ls, nil, nil)
+
+	_, token := ls.Acquire(t.Context())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		ls.Release(token)
+	}()
+	mw.Handler(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	if overhead := mw.Overhead(); overhead.Requests != 1 || overhead.Max >= 20*time.Millisecond {
+		t.Errorf("expected the wait to be excluded, got %+v", overhead)
+	}
+
+	func() {
+		defer func() { _ = recover() }()
+		mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	}()
+	if overhead := mw.Overhead(); overhead.Requests != 1 {
+		t.Errorf("expected the panicking request not to be measured, got %+v", overhead)
+	}
+}
+
+func TestOverheadStats_Mean(t *testing.T) {
+	if mean := (OverheadStats{}).Mean(); mean != 0 {
+		t.Errorf("expected 0 without requests, got %v", mean)
+	}
+	if mean := (OverheadStats{Requests: 4, Total: time.Millisecond}).Mean(); mean != 250*time.Microsecond {
+		t.Errorf("expected 250µs, got %v", mean)
+	}
+}
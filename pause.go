@@ -0,0 +1,48 @@
+package loadshedder
+
+// Pause rejects every new acquisition until Resume is called, e.g. to stop
+// a misbehaving workload during an incident. Unlike Drain, it does not wait
+// and can be undone. Running and waiting requests are not interrupted.
+func (l *Loadshedder) Pause() {
+	l.setPaused(true)
+}
+
+// Resume admits acquisitions again after Pause.
+func (l *Loadshedder) Resume() {
+	l.setPaused(false)
+}
+
+func (l *Loadshedder) setPaused(paused bool) {
+	l.limitMu.Lock()
+	defer l.limitMu.Unlock()
+
+	l.updateSettings(func(s *settings) { s.paused = paused })
+}
+
+// Paused returns true between Pause and Resume.
+func (l *Loadshedder) Paused() bool {
+	return l.settings.Load().paused
+}
+
+// SetShadow enables or disables the shadow mode: the limits are evaluated
+// but not enforced. Acquisitions that would be rejected for exceeding the
+// capacity are admitted right away and counted by ShadowRejections, to
+// validate new limits against production traffic or to stop shedding during
+// an incident. Pause and Drain still reject.
+func (l *Loadshedder) SetShadow(enabled bool) {
+	l.limitMu.Lock()
+	defer l.limitMu.Unlock()
+
+	l.updateSettings(func(s *settings) { s.shadow = enabled })
+}
+
+// Shadow returns true while the shadow mode is enabled, see SetShadow.
+func (l *Loadshedder) Shadow() bool {
+	return l.settings.Load().shadow
+}
+
+// ShadowRejections returns the number of acquisitions admitted by the shadow
+// mode that would have been rejected otherwise.
+func (l *Loadshedder) ShadowRejections() int64 {
+	return l.shadowRejections.Load()
+}
@@ -0,0 +1,73 @@
+package loadshedder
+
+import (
+	"context"
+	"testing"
+)
+
+func TestLoadshedder_Pause(t *testing.T) {
+	ctx := context.Background()
+	ls := New(Config{Limit: 2})
+
+	_, running := ls.Acquire(ctx)
+
+	ls.Pause()
+	if !ls.Paused() {
+		t.Error("expected the loadshedder to be paused")
+	}
+	if _, token := ls.Acquire(ctx); token.Accepted() {
+		t.Error("expected a rejection while paused")
+	}
+	if stats := ls.Release(running); stats.Running != 0 {
+		t.Errorf("expected the running request to finish normally, got %+v", stats)
+	}
+
+	ls.Resume()
+	_, token := ls.Acquire(ctx)
+	if !token.Accepted() {
+		t.Error("expected an admission once resumed")
+	}
+	ls.Release(token)
+}
+
+func TestLoadshedder_Shadow(t *testing.T) {
+	ctx := context.Background()
+	ls := New(Config{Limit: 1})
+	ls.SetShadow(true)
+
+	_, first := ls.Acquire(ctx)
+	_, over := ls.Acquire(ctx)
+	_, overHeavy := ls.AcquireN(ctx, 2)
+
+	if !first.Accepted() || first.Queued() {
+		t.Error("expected the shadow mode not to change admissions within the capacity")
+	}
+	if !over.Accepted() || !overHeavy.Accepted() || over.Queued() {
+		t.Error("expected acquisitions over the capacity to be admitted right away")
+	}
+	if n := ls.ShadowRejections(); n != 2 {
+		t.Errorf("expected 2 shadow rejections, got %d", n)
+	}
+	if stats := ls.Stats(); stats.Running != 4 {
+		t.Errorf("expected 4 slots running beyond the limit, got %+v", stats)
+	}
+
+	ls.Release(first)
+	ls.Release(over)
+	ls.Release(overHeavy)
+
+	// Pause still rejects
+	ls.Pause()
+	if _, token := ls.Acquire(ctx); token.Accepted() {
+		t.Error("expected a rejection while paused, even in shadow mode")
+	}
+	ls.Resume()
+
+	ls.SetShadow(false)
+	_, first = ls.Acquire(ctx)
+	defer ls.Release(first)
+	_, second := ls.Acquire(ctx)
+	if second.Accepted() || ls.Shadow() {
+		t.Error("expected the limit to be enforced once the shadow mode is disabled")
+	}
+}
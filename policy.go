@@ -0,0 +1,68 @@
+package loadshedder
+
+import (
+	"net/http"
+	"strings"
+)
+
+// PolicyFunc returns the priority of a request, so the middleware sheds the
+// least important requests first when over capacity, see
+// Config.PriorityAdmission.
+type PolicyFunc func(*http.Request) Priority
+
+// WithPolicy acquires a slot for each request with the priority returned by
+// policy, see WithPriority. It only affects admission with
+// Config.PriorityAdmission. Priorities set with WithRequestOptions take
+// precedence.
+func WithPolicy(policy PolicyFunc) MiddlewareOption {
+	return func(m *Middleware) {
+		m.policy = policy
+	}
+}
+
+// MethodPolicy sheds reads before writes: safe methods (GET, HEAD, OPTIONS,
+// TRACE) get PriorityDefault, the others (POST, PUT, PATCH, DELETE...) get
+// PriorityHigh. Reads are cheap to retry, while a failed write may have to
+// be replayed by the client, or leave work half done.
+func MethodPolicy() PolicyFunc {
+	return func(r *http.Request) Priority {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			return PriorityDefault
+		default:
+			return PriorityHigh
+		}
+	}
+}
+
+// PathPolicy sets the priority of requests by URL path prefix, the longest
+// matching prefix winning. Requests matching no prefix get the priority
+// returned by fallback, or PriorityDefault if fallback is nil:
+//
+//	loadshedder.PathPolicy(map[string]loadshedder.Priority{
+//		"/checkout": loadshedder.PriorityCritical,
+//		"/search":   loadshedder.PrioritySheddable,
+//	}, loadshedder.MethodPolicy())
+func PathPolicy(routes map[string]Priority, fallback PolicyFunc) PolicyFunc {
+	return func(r *http.Request) Priority {
+		var (
+			match    string
+			priority Priority
+			found    bool
+		)
+		for prefix, p := range routes {
+			if strings.HasPrefix(r.URL.Path, prefix) && (!found || len(prefix) > len(match)) {
+				match, priority, found = prefix, p, true
+			}
+		}
+
+		switch {
+		case found:
+			return priority
+		case fallback != nil:
+			return fallback(r)
+		default:
+			return PriorityDefault
+		}
+	}
+}
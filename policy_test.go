@@ -0,0 +1,89 @@
+package loadshedder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPathPolicy(t *testing.T) {
+	policy := PathPolicy(map[string]Priority{
+		"/api/":         PriorityHigh,
+		"/api/search":   PrioritySheddable,
+		"/api/checkout": PriorityCritical,
+	}, MethodPolicy())
+
+	tests := []struct {
+		method   string
+		path     string
+		expected Priority
+	}{
+		{http.MethodGet, "/api/users", PriorityHigh},
+		{http.MethodGet, "/api/search?q=a", PrioritySheddable},
+		{http.MethodPost, "/api/checkout", PriorityCritical},
+		{http.MethodGet, "/static/app.js", PriorityDefault},
+		{http.MethodHead, "/static/app.js", PriorityDefault},
+		{http.MethodPost, "/upload", PriorityHigh},
+		{http.MethodDelete, "/upload/1", PriorityHigh},
+	}
+
+	for _, tt := range tests {
+		if p := policy(httptest.NewRequest(tt.method, tt.path, http.NoBody)); p != tt.expected {
+			t.Errorf("%s %s: expected %v, got %v", tt.method, tt.path, tt.expected, p)
+		}
+	}
+
+	if p := PathPolicy(nil, nil)(httptest.NewRequest(http.MethodPost, "/", http.NoBody)); p != PriorityDefault {
+		t.Errorf("expected PriorityDefault without fallback, got %v", p)
+	}
+}
+
+func TestMiddleware_WithPolicy(t *testing.T) {
+	ls := New(Config{Limit: 10, PriorityAdmission: DefaultPriorityAdmission()})
+	reporter := &statsRecordingReporter{}
+	mw := NewMiddleware(ls, reporter, nil, WithPolicy(MethodPolicy()))
+
+	handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// Default traffic is shed from 90% of the capacity
+	for range 9 {
+		_, token := ls.Acquire(context.Background())
+		defer ls.Release(token)
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected the read to be shed, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", http.NoBody))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected the write to be admitted, got %d", rec.Code)
+	}
+
+	if len(reporter.accepted) != 1 || reporter.accepted[0].Priority != PriorityHigh {
+		t.Errorf("expected the write accepted with PriorityHigh, got %+v", reporter.accepted)
+	}
+}
+
+func TestMiddleware_WithPolicyRequestOptionsWin(t *testing.T) {
+	ls := New(Config{Limit: 1})
+	reporter := &statsRecordingReporter{}
+	mw := NewMiddleware(ls, reporter, nil,
+		WithPolicy(MethodPolicy()),
+		WithRequestOptions(func(r *http.Request) []AcquireOption {
+			return []AcquireOption{WithPriority(PriorityCritical)}
+		}),
+	)
+
+	mw.Handler(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	if len(reporter.accepted) != 1 || reporter.accepted[0].Priority != PriorityCritical {
+		t.Errorf("expected the request options priority, got %+v", reporter.accepted)
+	}
+}
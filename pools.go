@@ -0,0 +1,61 @@
+package loadshedder
+
+import "net/http"
+
+// Classifier selects the Loadshedder accounting for a request, allowing one
+// middleware to spread traffic over several independent pools (each with its
+// own limit, queue and Stats) while sharing a reporter and rejection handler.
+// Returning nil selects the middleware's default loadshedder.
+type Classifier func(*http.Request) *Loadshedder
+
+// WithClassifier routes each request to the loadshedder selected by the classifier.
+// Set Config.Name on each pool to tell them apart in Stats.
+func WithClassifier(classifier Classifier) MiddlewareOption {
+	return func(m *Middleware) {
+		m.classifier = classifier
+	}
+}
+
+// ReadWriteClassifier routes safe methods (GET, HEAD, OPTIONS, TRACE) to the read
+// pool and all other methods to the write pool.
+func ReadWriteClassifier(read, write *Loadshedder) Classifier {
+	return func(r *http.Request) *Loadshedder {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+			return read
+		default:
+			return write
+		}
+	}
+}
+
+// NewReadWriteMiddleware creates a middleware accounting reads and writes in two
+// independent pools, so a read-heavy workload cannot starve writes (and vice versa).
+// See NewMiddleware for the reporter, rejectionHandler and opts parameters.
+func NewReadWriteMiddleware(read, write *Loadshedder, reporter Reporter, rejectionHandler RejectionHandler, opts ...MiddlewareOption) *Middleware {
+	opts = append([]MiddlewareOption{WithClassifier(ReadWriteClassifier(read, write))}, opts...)
+	return NewMiddleware(write, reporter, rejectionHandler, opts...)
+}
+
+// router resolves the loadshedder and the acquire options of a request in
+// one pass, see TopologyConfig.Build. A nil loadshedder selects the default one.
+type router func(*http.Request) (*Loadshedder, []AcquireOption)
+
+// loadshedderFor returns the loadshedder accounting for the request, and the
+// acquire options of its route. The classifier takes precedence over the route.
+func (m *Middleware) loadshedderFor(r *http.Request) (*Loadshedder, []AcquireOption) {
+	ls := m.loadshedder
+	var options []AcquireOption
+	if m.router != nil {
+		var routed *Loadshedder
+		if routed, options = m.router(r); routed != nil {
+			ls = routed
+		}
+	}
+	if m.classifier != nil {
+		if classified := m.classifier(r); classified != nil {
+			ls = classified
+		}
+	}
+	return ls, options
+}
@@ -0,0 +1,105 @@
+package loadshedder
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+type statsRecordingReporter struct {
+	mu       sync.Mutex
+	accepted []Stats
+	rejected []Stats
+}
+
+func (r *statsRecordingReporter) Accepted(_ *http.Request, stats Stats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.accepted = append(r.accepted, stats)
+}
+
+func (r *statsRecordingReporter) Rejected(_ *http.Request, stats Stats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rejected = append(r.rejected, stats)
+}
+
+func TestMiddleware_ReadWritePools(t *testing.T) {
+	read := New(Config{Name: "read", Limit: 1})
+	write := New(Config{Name: "write", Limit: 1})
+	reporter := &statsRecordingReporter{}
+	mw := NewReadWriteMiddleware(read, write, reporter, nil)
+
+	blocker := make(chan struct{})
+	started := make(chan struct{})
+	handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(started)
+			<-blocker
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// Saturate the read pool
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/slow", http.NoBody))
+	}()
+	<-started
+
+	// Reads are rejected
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected read to be rejected, got %d", rec.Code)
+	}
+
+	// Writes still flow through their own pool
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", http.NoBody))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected write to be accepted, got %d", rec.Code)
+	}
+
+	close(blocker)
+	<-done
+
+	reporter.mu.Lock()
+	defer reporter.mu.Unlock()
+
+	if len(reporter.rejected) != 1 || reporter.rejected[0].Name != "read" {
+		t.Errorf("expected one rejection from the read pool, got %+v", reporter.rejected)
+	}
+	if len(reporter.accepted) != 2 {
+		t.Fatalf("expected 2 accepted requests, got %+v", reporter.accepted)
+	}
+	if reporter.accepted[1].Name != "write" || reporter.accepted[1].Running != 1 {
+		t.Errorf("expected write accepted with its own stats, got %+v", reporter.accepted[1])
+	}
+
+	if stats := read.Stats(); stats.Running != 0 || stats.Waiting != 0 {
+		t.Errorf("expected read pool to be empty, got %+v", stats)
+	}
+	if stats := write.Stats(); stats.Running != 0 || stats.Waiting != 0 {
+		t.Errorf("expected write pool to be empty, got %+v", stats)
+	}
+}
+
+func TestMiddleware_ClassifierFallsBackToDefault(t *testing.T) {
+	limiter := New(Config{Name: "default", Limit: 1})
+	reporter := &statsRecordingReporter{}
+	mw := NewMiddleware(limiter, reporter, nil, WithClassifier(func(*http.Request) *Loadshedder {
+		return nil
+	}))
+
+	handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	if len(reporter.accepted) != 1 || reporter.accepted[0].Name != "default" {
+		t.Errorf("expected request accounted in the default loadshedder, got %+v", reporter.accepted)
+	}
+}
@@ -0,0 +1,103 @@
+package loadshedder
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultSignalInterval   = time.Second
+	defaultSignalHysteresis = 0.1
+
+	// While overloaded, the effective limit is multiplied by signalDecrease at every
+	// sample; once recovered it grows back by signalIncrease of the configured limit.
+	signalDecrease = 0.8
+	signalIncrease = 0.05
+)
+
+// Signal is an overload signal, such as resource usage or runtime health,
+// that can tighten the effective limit independently of request concurrency.
+// Implementations must be safe for concurrent use.
+type Signal interface {
+	// Name identifies the signal, e.g. in logs.
+	Name() string
+
+	// Pressure returns the current pressure: 0 means idle, 1 or more means overloaded.
+	Pressure() float64
+}
+
+// signalController samples the signals at most once per interval, on the Acquire path.
+type signalController struct {
+	signals    []Signal
+	interval   int64        // nanoseconds
+	nextSample atomic.Int64 // unix nanoseconds
+	hysteresis float64
+	last       atomic.Uint64 // math.Float64bits of the last sampled pressure
+
+	overloaded debouncer // guarded by Loadshedder.limitMu
+}
+
+func newSignalController(cfg Config) *signalController {
+	return &signalController{
+		signals:    cfg.Signals,
+		interval:   int64(cfg.SignalInterval),
+		hysteresis: cfg.SignalHysteresis,
+		overloaded: debouncer{
+			onDelay:  cfg.SignalEntryDebounce,
+			offDelay: cfg.SignalExitDebounce,
+		},
+	}
+}
+
+// pressure returns the highest pressure reported by the signals.
+func (c *signalController) pressure() float64 {
+	var pressure float64
+	for _, signal := range c.signals {
+		pressure = max(pressure, signal.Pressure())
+	}
+	return pressure
+}
+
+// sampleSignals samples the signals if the interval elapsed, and adjusts the
+// effective limit: multiplicative decrease while overloaded, additive increase
+// back to the configured limit once recovered.
+// Entering and leaving the overloaded state are debounced, and leaving it
+// requires the pressure to drop below 1 minus the hysteresis margin.
+func (l *Loadshedder) sampleSignals(now time.Time) {
+	c := l.signals
+	next := c.nextSample.Load()
+	if now.UnixNano() < next || !c.nextSample.CompareAndSwap(next, now.UnixNano()+c.interval) {
+		return
+	}
+
+	pressure := c.pressure()
+	c.last.Store(math.Float64bits(pressure))
+
+	l.limitMu.Lock()
+	defer l.limitMu.Unlock()
+
+	overloaded := c.overloaded.update(now, pressure >= 1, pressure < 1-c.hysteresis)
+
+	switch {
+	case overloaded && pressure >= 1:
+		l.signalLimit = max(1, int64(float64(l.effectiveLimit.Load())*signalDecrease))
+	case !overloaded && l.signalLimit > 0:
+		l.signalLimit += max(1, int64(float64(l.limit.Load())*signalIncrease))
+		if l.signalLimit >= l.limit.Load() {
+			l.signalLimit = 0
+		}
+	default:
+		return
+	}
+
+	l.updateEffectiveLimit()
+}
+
+// pressure returns the pressure of the last signal sample, see Stats.Pressure.
+func (l *Loadshedder) pressure() float64 {
+	if l.signals == nil {
+		return 0
+	}
+	return math.Float64frombits(l.signals.last.Load())
+}
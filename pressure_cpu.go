@@ -0,0 +1,88 @@
+package loadshedder
+
+import "sync"
+
+// CPUSignal is a Signal measuring the CPU utilization of the process, relative
+// to the CPUs it can use (GOMAXPROCS), for services whose bottleneck is CPU
+// rather than concurrency. Combine it with Config.SignalEntryDebounce to shed
+// only when the utilization stays above the threshold.
+// Supported on Linux, macOS and Windows; the pressure is always 0 elsewhere.
+// See NewCollectorCPUSignal to measure against the CPU quota of a container.
+//
+// The pressure is the utilization since the previous sample, divided by the threshold.
+type CPUSignal struct {
+	threshold   float64
+	collector   ResourceCollector
+	utilization cpuUtilization
+}
+
+// NewCPUSignal creates a process CPU signal reporting overload when the
+// utilization reaches the threshold fraction (e.g. 0.9) of GOMAXPROCS CPUs.
+func NewCPUSignal(threshold float64) *CPUSignal {
+	if threshold <= 0 || threshold > 1 {
+		panic("loadshedder: CPUSignal threshold must be in (0, 1]")
+	}
+
+	return NewCollectorCPUSignal(threshold, processCollector{})
+}
+
+// Name returns "cpu".
+func (s *CPUSignal) Name() string {
+	return "cpu"
+}
+
+// Pressure returns the recent CPU utilization relative to the threshold.
+func (s *CPUSignal) Pressure() float64 {
+	return s.utilization.sample(s.collector) / s.threshold
+}
+
+// HostCPUSignal is a Signal measuring the CPU utilization of the host, for
+// services sharing their CPUs with other processes.
+// Supported on Linux; the pressure is always 0 elsewhere.
+//
+// The pressure is the utilization since the previous sample, divided by the threshold.
+type HostCPUSignal struct {
+	threshold float64
+
+	mu        sync.Mutex
+	lastBusy  uint64
+	lastTotal uint64
+}
+
+// NewHostCPUSignal creates a host CPU signal reporting overload when the
+// utilization of all CPUs reaches the threshold fraction (e.g. 0.9).
+func NewHostCPUSignal(threshold float64) *HostCPUSignal {
+	if threshold <= 0 || threshold > 1 {
+		panic("loadshedder: HostCPUSignal threshold must be in (0, 1]")
+	}
+
+	s := &HostCPUSignal{threshold: threshold}
+	s.Pressure() // initialize the baseline
+
+	return s
+}
+
+// Name returns "host_cpu".
+func (s *HostCPUSignal) Name() string {
+	return "host_cpu"
+}
+
+// Pressure returns the recent host CPU utilization relative to the threshold.
+func (s *HostCPUSignal) Pressure() float64 {
+	busy, total, ok := hostCPUTimes()
+	if !ok {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lastBusy, lastTotal := s.lastBusy, s.lastTotal
+	s.lastBusy, s.lastTotal = busy, total
+
+	if lastTotal == 0 || total <= lastTotal || busy < lastBusy {
+		return 0
+	}
+
+	return float64(busy-lastBusy) / float64(total-lastTotal) / s.threshold
+}
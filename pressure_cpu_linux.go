@@ -0,0 +1,51 @@
+package loadshedder
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// hostCPUTimes returns the busy and total CPU time of the host, in clock ticks,
+// from the aggregated "cpu" line of /proc/stat.
+func hostCPUTimes() (busy, total uint64, ok bool) {
+	f, err := os.Open("/proc/stat")
+	if err != nil {
+		return 0, 0, false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		return 0, 0, false
+	}
+	return parseProcStatCPU(scanner.Text())
+}
+
+// parseProcStatCPU parses "cpu user nice system idle iowait irq softirq steal ...".
+// Idle and iowait count as idle time.
+func parseProcStatCPU(line string) (busy, total uint64, ok bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return 0, 0, false
+	}
+
+	var idle uint64
+	for i, field := range fields[1:] {
+		// guest and guest_nice are already included in user and nice
+		if i >= 8 {
+			break
+		}
+		value, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return 0, 0, false
+		}
+		total += value
+		if i == 3 || i == 4 {
+			idle += value
+		}
+	}
+
+	return total - idle, total, true
+}
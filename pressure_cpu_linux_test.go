@@ -0,0 +1,14 @@
+package loadshedder
+
+import "testing"
+
+func TestParseProcStatCPU(t *testing.T) {
+	busy, total, ok := parseProcStatCPU("cpu  100 10 50 800 20 5 5 10 3 0")
+	if !ok || busy != 180 || total != 1000 {
+		t.Errorf("expected busy=180 total=1000, got busy=%d total=%d ok=%v", busy, total, ok)
+	}
+
+	if _, _, ok := parseProcStatCPU("cpu0 1 2 3 4"); ok {
+		t.Error("expected a per-CPU line to be rejected")
+	}
+}
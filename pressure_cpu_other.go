@@ -0,0 +1,7 @@
+//go:build !linux
+
+package loadshedder
+
+func hostCPUTimes() (busy, total uint64, ok bool) {
+	return 0, 0, false
+}
@@ -0,0 +1,76 @@
+package loadshedder
+
+import (
+	"runtime/pprof"
+)
+
+// defaultMaxThreads is the Go runtime default for runtime/debug.SetMaxThreads.
+const defaultMaxThreads = 10000
+
+// FileDescriptorSignal is a Signal measuring open file descriptors against the
+// process limit (RLIMIT_NOFILE), so the shedder backs off before accept() and
+// dials start failing with "too many open files".
+// Supported on Linux and macOS; the pressure is always 0 elsewhere.
+type FileDescriptorSignal struct {
+	threshold float64
+}
+
+// NewFileDescriptorSignal creates a file descriptor signal reporting overload
+// when open file descriptors reach the threshold fraction (e.g. 0.8) of the limit.
+func NewFileDescriptorSignal(threshold float64) *FileDescriptorSignal {
+	if threshold <= 0 || threshold > 1 {
+		panic("loadshedder: FileDescriptorSignal threshold must be in (0, 1]")
+	}
+	return &FileDescriptorSignal{threshold: threshold}
+}
+
+// Name returns "file_descriptors".
+func (s *FileDescriptorSignal) Name() string {
+	return "file_descriptors"
+}
+
+// Pressure returns the open file descriptor usage relative to the threshold.
+func (s *FileDescriptorSignal) Pressure() float64 {
+	open, limit, ok := fileDescriptorUsage()
+	if !ok || limit == 0 {
+		return 0
+	}
+	return float64(open) / (float64(limit) * s.threshold)
+}
+
+// ThreadSignal is a Signal measuring the OS threads created by the Go runtime
+// against the runtime limit (10000 by default, see runtime/debug.SetMaxThreads),
+// which crashes the program when exceeded. Threads pile up when goroutines
+// block in syscalls or cgo calls.
+type ThreadSignal struct {
+	threshold  float64
+	maxThreads int
+	profile    *pprof.Profile
+}
+
+// NewThreadSignal creates a thread signal reporting overload when the thread
+// count reaches the threshold fraction (e.g. 0.8) of maxThreads.
+// If maxThreads is zero, the Go runtime default of 10000 is assumed.
+func NewThreadSignal(threshold float64, maxThreads int) *ThreadSignal {
+	if threshold <= 0 || threshold > 1 {
+		panic("loadshedder: ThreadSignal threshold must be in (0, 1]")
+	}
+	if maxThreads <= 0 {
+		maxThreads = defaultMaxThreads
+	}
+	return &ThreadSignal{
+		threshold:  threshold,
+		maxThreads: maxThreads,
+		profile:    pprof.Lookup("threadcreate"),
+	}
+}
+
+// Name returns "threads".
+func (s *ThreadSignal) Name() string {
+	return "threads"
+}
+
+// Pressure returns the thread count relative to the threshold.
+func (s *ThreadSignal) Pressure() float64 {
+	return float64(s.profile.Count()) / (float64(s.maxThreads) * s.threshold)
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !windows
+
+package loadshedder
+
+import "time"
+
+func fileDescriptorUsage() (open, limit uint64, ok bool) {
+	return 0, 0, false
+}
+
+func processCPUTime() (time.Duration, bool) {
+	return 0, false
+}
@@ -0,0 +1,99 @@
+package loadshedder
+
+import (
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestFileDescriptorSignal(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
+		t.Skip("file descriptor usage is only supported on Linux and macOS")
+	}
+
+	signal := NewFileDescriptorSignal(1)
+	if name := signal.Name(); name != "file_descriptors" {
+		t.Errorf("expected name file_descriptors, got %q", name)
+	}
+
+	before := signal.Pressure()
+	if before <= 0 || before >= 1 {
+		t.Fatalf("expected pressure in (0, 1), got %f", before)
+	}
+
+	for range 20 {
+		f, err := os.Open(os.DevNull)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+	}
+
+	if after := signal.Pressure(); after <= before {
+		t.Errorf("expected pressure to increase with open files, got %f then %f", before, after)
+	}
+
+	// A lower threshold reports proportionally more pressure
+	if half := NewFileDescriptorSignal(0.5).Pressure(); half <= signal.Pressure() {
+		t.Errorf("expected higher pressure with a lower threshold, got %f", half)
+	}
+}
+
+func TestThreadSignal(t *testing.T) {
+	signal := NewThreadSignal(0.8, 0)
+	if name := signal.Name(); name != "threads" {
+		t.Errorf("expected name threads, got %q", name)
+	}
+
+	pressure := signal.Pressure()
+	if pressure <= 0 || pressure >= 1 {
+		t.Errorf("expected pressure in (0, 1), got %f", pressure)
+	}
+
+	// With a limit at the current thread count, the signal reports overload
+	if pressure := NewThreadSignal(1, runtime.GOMAXPROCS(0)).Pressure(); pressure < 1 {
+		t.Errorf("expected overload with a tiny thread limit, got %f", pressure)
+	}
+}
+
+func TestCPUSignal(t *testing.T) {
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" && runtime.GOOS != "windows" {
+		t.Skip("process CPU time is only supported on Linux, macOS and Windows")
+	}
+
+	signal := NewCPUSignal(0.5)
+	if name := signal.Name(); name != "cpu" {
+		t.Errorf("expected name cpu, got %q", name)
+	}
+
+	// Burn one CPU for a while
+	deadline := time.Now().Add(20 * time.Millisecond)
+	for time.Now().Before(deadline) {
+	}
+
+	// One busy CPU out of GOMAXPROCS, against a 0.5 threshold
+	expected := 2 / float64(runtime.GOMAXPROCS(0))
+	if pressure := signal.Pressure(); pressure < expected/2 {
+		t.Errorf("expected pressure around %f while burning CPU, got %f", expected, pressure)
+	}
+}
+
+func TestHostCPUSignal(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("host CPU usage is only supported on Linux")
+	}
+
+	signal := NewHostCPUSignal(0.9)
+	if name := signal.Name(); name != "host_cpu" {
+		t.Errorf("expected name host_cpu, got %q", name)
+	}
+
+	deadline := time.Now().Add(20 * time.Millisecond)
+	for time.Now().Before(deadline) {
+	}
+
+	if pressure := signal.Pressure(); pressure <= 0 {
+		t.Errorf("expected some host CPU pressure while burning CPU, got %f", pressure)
+	}
+}
@@ -0,0 +1,46 @@
+//go:build linux || darwin
+
+package loadshedder
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// fileDescriptorUsage returns the number of open file descriptors and the soft limit.
+func fileDescriptorUsage() (open, limit uint64, ok bool) {
+	var rlimit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rlimit); err != nil {
+		return 0, 0, false
+	}
+
+	dir := "/proc/self/fd"
+	if runtime.GOOS == "darwin" {
+		dir = "/dev/fd"
+	}
+
+	f, err := os.Open(dir)
+	if err != nil {
+		return 0, 0, false
+	}
+	defer f.Close()
+
+	names, err := f.Readdirnames(-1)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	// Exclude the descriptor used to list the directory
+	return uint64(len(names) - 1), uint64(rlimit.Cur), true
+}
+
+// processCPUTime returns the user and system CPU time consumed by the process.
+func processCPUTime() (time.Duration, bool) {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0, false
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano()), true
+}
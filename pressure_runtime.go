@@ -0,0 +1,165 @@
+package loadshedder
+
+import (
+	"math"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"time"
+)
+
+const (
+	heapObjectsMetric  = "/memory/classes/heap/objects:bytes"
+	gcPausesMetric     = "/sched/pauses/total/gc:seconds"
+	memoryLimitMetric  = "/gc/gomemlimit:bytes"
+	memoryTotalMetric  = "/memory/classes/total:bytes"
+	heapReleasedMetric = "/memory/classes/heap/released:bytes"
+)
+
+// GoroutineSignal is a Signal measuring the number of goroutines, which grows
+// with work piling up (blocked handlers, slow downstreams) before latency or
+// concurrency reveal it.
+type GoroutineSignal struct {
+	max int
+}
+
+// NewGoroutineSignal creates a goroutine signal reporting overload when the
+// number of goroutines reaches max.
+func NewGoroutineSignal(max int) *GoroutineSignal {
+	if max <= 0 {
+		panic("loadshedder: GoroutineSignal max must be positive")
+	}
+	return &GoroutineSignal{max: max}
+}
+
+// Name returns "goroutines".
+func (s *GoroutineSignal) Name() string {
+	return "goroutines"
+}
+
+// Pressure returns the number of goroutines relative to max.
+func (s *GoroutineSignal) Pressure() float64 {
+	return float64(runtime.NumGoroutine()) / float64(s.max)
+}
+
+// HeapSignal is a Signal measuring the heap size (memory occupied by live and
+// not yet swept objects) against a memory limit, so the shedder backs off
+// before the garbage collector thrashes or the process is OOM-killed.
+type HeapSignal struct {
+	threshold float64
+	limit     uint64
+}
+
+// NewHeapSignal creates a heap signal reporting overload when the heap
+// reaches the threshold fraction (e.g. 0.8) of limit, in bytes.
+// If limit is zero, the Go memory limit (GOMEMLIMIT) is used; without a
+// memory limit, the pressure is always 0.
+func NewHeapSignal(threshold float64, limit uint64) *HeapSignal {
+	if threshold <= 0 || threshold > 1 {
+		panic("loadshedder: HeapSignal threshold must be in (0, 1]")
+	}
+	if limit == 0 {
+		if memoryLimit := debug.SetMemoryLimit(-1); memoryLimit != math.MaxInt64 {
+			limit = uint64(memoryLimit)
+		}
+	}
+	return &HeapSignal{threshold: threshold, limit: limit}
+}
+
+// Name returns "heap".
+func (s *HeapSignal) Name() string {
+	return "heap"
+}
+
+// Pressure returns the heap size relative to the threshold.
+func (s *HeapSignal) Pressure() float64 {
+	if s.limit == 0 {
+		return 0
+	}
+
+	sample := []metrics.Sample{{Name: heapObjectsMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return float64(sample[0].Value.Uint64()) / (float64(s.limit) * s.threshold)
+}
+
+// MemoryLimitSignal is a Signal measuring the memory used by the Go runtime
+// against the Go memory limit (GOMEMLIMIT or debug.SetMemoryLimit), as the
+// runtime accounts it. Near the limit, the garbage collector runs ever more
+// often to stay under it: shedding before then keeps the CPU for requests.
+//
+// The limit is read at every sample, so changes at runtime are followed.
+// Without a memory limit, the pressure is always 0.
+type MemoryLimitSignal struct {
+	threshold float64
+}
+
+// NewMemoryLimitSignal creates a memory limit signal reporting overload when
+// the memory used reaches the threshold fraction (e.g. 0.9) of the Go memory
+// limit, leaving the remaining margin to absorb the requests in flight.
+func NewMemoryLimitSignal(threshold float64) *MemoryLimitSignal {
+	if threshold <= 0 || threshold > 1 {
+		panic("loadshedder: MemoryLimitSignal threshold must be in (0, 1]")
+	}
+	return &MemoryLimitSignal{threshold: threshold}
+}
+
+// Name returns "gomemlimit".
+func (s *MemoryLimitSignal) Name() string {
+	return "gomemlimit"
+}
+
+// Pressure returns the memory used relative to the threshold of the memory limit.
+func (s *MemoryLimitSignal) Pressure() float64 {
+	samples := []metrics.Sample{{Name: memoryLimitMetric}, {Name: memoryTotalMetric}, {Name: heapReleasedMetric}}
+	metrics.Read(samples)
+	for _, sample := range samples {
+		if sample.Value.Kind() != metrics.KindUint64 {
+			return 0
+		}
+	}
+
+	limit := samples[0].Value.Uint64()
+	if limit == 0 || limit >= math.MaxInt64 {
+		return 0
+	}
+
+	// The memory limit applies to the memory mapped by the runtime, minus
+	// the heap memory returned to the OS
+	used := samples[1].Value.Uint64() - samples[2].Value.Uint64()
+	return float64(used) / (float64(limit) * s.threshold)
+}
+
+// GCPauseSignal is a Signal measuring the stop-the-world pauses of the
+// garbage collector, which stall every request at once.
+//
+// The pressure is the 99th percentile pause observed since the previous
+// sample, divided by the threshold.
+type GCPauseSignal struct {
+	threshold time.Duration
+	pauses    *histogramWindow
+}
+
+// NewGCPauseSignal creates a GC pause signal reporting overload when the 99th
+// percentile pause exceeds the threshold.
+func NewGCPauseSignal(threshold time.Duration) *GCPauseSignal {
+	if threshold <= 0 {
+		panic("loadshedder: GCPauseSignal threshold must be positive")
+	}
+	return &GCPauseSignal{
+		threshold: threshold,
+		pauses:    newHistogramWindow(gcPausesMetric),
+	}
+}
+
+// Name returns "gc_pause".
+func (s *GCPauseSignal) Name() string {
+	return "gc_pause"
+}
+
+// Pressure returns the recent 99th percentile GC pause relative to the threshold.
+func (s *GCPauseSignal) Pressure() float64 {
+	return s.pauses.quantile(0.99) / s.threshold.Seconds()
+}
@@ -0,0 +1,107 @@
+package loadshedder
+
+import (
+	"math"
+	"runtime/metrics"
+	"sync"
+	"time"
+)
+
+const schedLatenciesMetric = "/sched/latencies:seconds"
+
+// SchedulerLatencySignal is a Signal measuring the Go scheduler latency: the
+// time goroutines spend runnable before getting a CPU. It catches CPU
+// saturation that request concurrency and CPU utilization both miss on bursty
+// workloads.
+//
+// The pressure is the 99th percentile latency observed since the previous
+// sample, divided by the threshold.
+type SchedulerLatencySignal struct {
+	threshold time.Duration
+	latencies *histogramWindow
+}
+
+// NewSchedulerLatencySignal creates a scheduler latency signal reporting
+// overload when the 99th percentile latency exceeds the threshold.
+func NewSchedulerLatencySignal(threshold time.Duration) *SchedulerLatencySignal {
+	if threshold <= 0 {
+		panic("loadshedder: SchedulerLatencySignal threshold must be positive")
+	}
+
+	return &SchedulerLatencySignal{
+		threshold: threshold,
+		latencies: newHistogramWindow(schedLatenciesMetric),
+	}
+}
+
+// Name returns "scheduler_latency".
+func (s *SchedulerLatencySignal) Name() string {
+	return "scheduler_latency"
+}
+
+// Pressure returns the recent 99th percentile scheduler latency relative to the threshold.
+func (s *SchedulerLatencySignal) Pressure() float64 {
+	return s.latencies.quantile(0.99) / s.threshold.Seconds()
+}
+
+// histogramWindow reads a runtime/metrics histogram, keeping the counts of
+// the previous read to compute quantiles over the last window only.
+type histogramWindow struct {
+	mu       sync.Mutex
+	sample   []metrics.Sample
+	previous []uint64
+}
+
+func newHistogramWindow(metric string) *histogramWindow {
+	w := &histogramWindow{sample: []metrics.Sample{{Name: metric}}}
+	w.quantile(0) // initialize the baseline
+	return w
+}
+
+// quantile returns the quantile of the values recorded since the previous call.
+func (w *histogramWindow) quantile(quantile float64) float64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	metrics.Read(w.sample)
+	if w.sample[0].Value.Kind() != metrics.KindFloat64Histogram {
+		return 0
+	}
+	histogram := w.sample[0].Value.Float64Histogram()
+
+	delta := make([]uint64, len(histogram.Counts))
+	for i, count := range histogram.Counts {
+		delta[i] = count
+		if i < len(w.previous) {
+			delta[i] -= w.previous[i]
+		}
+	}
+	w.previous = append(w.previous[:0], histogram.Counts...)
+
+	return histogramQuantile(delta, histogram.Buckets, quantile)
+}
+
+// histogramQuantile returns an upper-bound estimate of the quantile from
+// runtime/metrics histogram counts and bucket boundaries (len(buckets) == len(counts)+1).
+func histogramQuantile(counts []uint64, buckets []float64, quantile float64) float64 {
+	var total uint64
+	for _, count := range counts {
+		total += count
+	}
+	if total == 0 {
+		return 0
+	}
+
+	rank := uint64(math.Ceil(float64(total) * quantile))
+	var cumulative uint64
+	for i, count := range counts {
+		cumulative += count
+		if cumulative >= rank {
+			if upper := buckets[i+1]; !math.IsInf(upper, 1) {
+				return upper
+			}
+			return buckets[i]
+		}
+	}
+	return 0
+}
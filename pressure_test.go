@@ -0,0 +1,318 @@
+package loadshedder
+
+import (
+	"context"
+	"math"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeSignal struct {
+	pressure atomic.Uint64 // math.Float64bits
+}
+
+func (s *fakeSignal) Name() string {
+	return "fake"
+}
+
+func (s *fakeSignal) Pressure() float64 {
+	return math.Float64frombits(s.pressure.Load())
+}
+
+func (s *fakeSignal) set(pressure float64) {
+	s.pressure.Store(math.Float64bits(pressure))
+}
+
+// acquireAfterInterval lets the signal interval elapse, then runs one
+// acquire/release cycle to trigger a sample.
+func acquireAfterInterval(ls *Loadshedder) Stats {
+	time.Sleep(2 * time.Millisecond)
+	_, token := ls.Acquire(context.Background())
+	return ls.Release(token)
+}
+
+func TestLoadshedder_SignalsTightenAndRecover(t *testing.T) {
+	signal := &fakeSignal{}
+	ls := New(Config{
+		Limit:          100,
+		Signals:        []Signal{signal},
+		SignalInterval: time.Millisecond,
+	})
+
+	if stats := acquireAfterInterval(ls); stats.EffectiveLimit != 100 {
+		t.Fatalf("expected EffectiveLimit=100 without pressure, got %+v", stats)
+	}
+
+	signal.set(1.5)
+
+	stats := acquireAfterInterval(ls)
+	if stats.EffectiveLimit != 80 {
+		t.Errorf("expected EffectiveLimit=80 after one overloaded sample, got %+v", stats)
+	}
+	stats = acquireAfterInterval(ls)
+	if stats.EffectiveLimit != 64 {
+		t.Errorf("expected EffectiveLimit=64 after two overloaded samples, got %+v", stats)
+	}
+	if stats.ConfiguredLimit != 100 {
+		t.Errorf("expected ConfiguredLimit=100, got %+v", stats)
+	}
+
+	signal.set(0.5)
+
+	stats = acquireAfterInterval(ls)
+	if stats.EffectiveLimit != 69 {
+		t.Errorf("expected EffectiveLimit=69 after one recovered sample, got %+v", stats)
+	}
+
+	for range 10 {
+		stats = acquireAfterInterval(ls)
+	}
+	if stats.EffectiveLimit != 100 {
+		t.Errorf("expected EffectiveLimit=100 after recovery, got %+v", stats)
+	}
+}
+
+func TestLoadshedder_SignalsSampledOncePerInterval(t *testing.T) {
+	signal := &fakeSignal{}
+	signal.set(2)
+	ls := New(Config{
+		Limit:          100,
+		Signals:        []Signal{signal},
+		SignalInterval: time.Hour,
+	})
+
+	var stats Stats
+	for range 10 {
+		_, token := ls.Acquire(context.Background())
+		stats = ls.Release(token)
+	}
+
+	if stats.EffectiveLimit != 80 {
+		t.Errorf("expected a single sample within the interval (EffectiveLimit=80), got %+v", stats)
+	}
+}
+
+func TestSchedulerLatencySignal(t *testing.T) {
+	signal := NewSchedulerLatencySignal(time.Hour)
+
+	if name := signal.Name(); name != "scheduler_latency" {
+		t.Errorf("expected name scheduler_latency, got %q", name)
+	}
+
+	// Generate some scheduling activity
+	done := make(chan struct{})
+	for range 100 {
+		go func() { done <- struct{}{} }()
+	}
+	for range 100 {
+		<-done
+	}
+
+	pressure := signal.Pressure()
+	if pressure < 0 || pressure >= 1 {
+		t.Errorf("expected pressure in [0, 1) with a 1h threshold, got %f", pressure)
+	}
+}
+
+func TestGoroutineSignal(t *testing.T) {
+	signal := NewGoroutineSignal(1_000_000)
+	if name := signal.Name(); name != "goroutines" {
+		t.Errorf("expected name goroutines, got %q", name)
+	}
+	if pressure := signal.Pressure(); pressure <= 0 || pressure >= 1 {
+		t.Errorf("expected pressure in (0, 1), got %f", pressure)
+	}
+
+	if pressure := NewGoroutineSignal(1).Pressure(); pressure < 1 {
+		t.Errorf("expected overload with a single goroutine allowed, got %f", pressure)
+	}
+}
+
+func TestMemoryLimitSignal(t *testing.T) {
+	signal := NewMemoryLimitSignal(0.9)
+	if name := signal.Name(); name != "gomemlimit" {
+		t.Errorf("expected name gomemlimit, got %q", name)
+	}
+
+	previous := debug.SetMemoryLimit(math.MaxInt64)
+	defer debug.SetMemoryLimit(previous)
+
+	if pressure := signal.Pressure(); pressure != 0 {
+		t.Errorf("expected no pressure without a memory limit, got %f", pressure)
+	}
+
+	// The limit is followed at runtime
+	debug.SetMemoryLimit(1 << 40)
+	if pressure := signal.Pressure(); pressure <= 0 || pressure >= 1 {
+		t.Errorf("expected some pressure far from a 1TiB limit, got %f", pressure)
+	}
+
+	debug.SetMemoryLimit(1 << 20)
+	if pressure := signal.Pressure(); pressure < 1 {
+		t.Errorf("expected overload with a 1MiB limit, got %f", pressure)
+	}
+}
+
+func TestHeapSignal(t *testing.T) {
+	signal := NewHeapSignal(1, 1<<40)
+	if name := signal.Name(); name != "heap" {
+		t.Errorf("expected name heap, got %q", name)
+	}
+	if pressure := signal.Pressure(); pressure <= 0 || pressure >= 1 {
+		t.Errorf("expected pressure in (0, 1) with a 1TiB limit, got %f", pressure)
+	}
+
+	if pressure := NewHeapSignal(0.5, 1).Pressure(); pressure < 1 {
+		t.Errorf("expected overload with a 1 byte limit, got %f", pressure)
+	}
+
+	// Without a limit nor GOMEMLIMIT, the signal is disabled
+	if pressure := NewHeapSignal(1, 0).Pressure(); pressure != 0 && os.Getenv("GOMEMLIMIT") == "" {
+		t.Errorf("expected no pressure without a memory limit, got %f", pressure)
+	}
+}
+
+func TestGCPauseSignal(t *testing.T) {
+	signal := NewGCPauseSignal(time.Hour)
+	if name := signal.Name(); name != "gc_pause" {
+		t.Errorf("expected name gc_pause, got %q", name)
+	}
+
+	runtime.GC()
+
+	if pressure := signal.Pressure(); pressure <= 0 || pressure >= 1 {
+		t.Errorf("expected pressure in (0, 1) after a GC with a 1h threshold, got %f", pressure)
+	}
+}
+
+func TestStats_Pressure(t *testing.T) {
+	signal := &fakeSignal{}
+	ls := New(Config{Limit: 10, Signals: []Signal{signal, &fakeSignal{}}, SignalInterval: time.Millisecond})
+
+	signal.set(0.7)
+	if stats := acquireAfterInterval(ls); stats.Pressure != 0.7 {
+		t.Errorf("expected the highest signal pressure, got %+v", stats)
+	}
+
+	if stats := New(Config{Limit: 10}).Stats(); stats.Pressure != 0 {
+		t.Errorf("expected no pressure without signals, got %+v", stats)
+	}
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	buckets := []float64{0, 1, 2, 3, math.Inf(1)}
+
+	tests := []struct {
+		counts   []uint64
+		expected float64
+	}{
+		{counts: []uint64{0, 0, 0, 0}, expected: 0},
+		{counts: []uint64{100, 0, 0, 0}, expected: 1},
+		{counts: []uint64{98, 1, 1, 0}, expected: 2},
+		{counts: []uint64{90, 0, 0, 10}, expected: 3},
+	}
+
+	for _, tt := range tests {
+		if got := histogramQuantile(tt.counts, buckets, 0.99); got != tt.expected {
+			t.Errorf("histogramQuantile(%v) = %v, expected %v", tt.counts, got, tt.expected)
+		}
+	}
+}
+
+func TestLoadshedder_SignalsHysteresis(t *testing.T) {
+	signal := &fakeSignal{}
+	ls := New(Config{
+		Limit:          100,
+		Signals:        []Signal{signal},
+		SignalInterval: time.Millisecond,
+	})
+
+	signal.set(1)
+	if stats := acquireAfterInterval(ls); stats.EffectiveLimit != 80 {
+		t.Fatalf("expected EffectiveLimit=80 when overloaded, got %+v", stats)
+	}
+
+	// Within the hysteresis margin: neither shedding more nor recovering
+	signal.set(0.95)
+	for range 3 {
+		if stats := acquireAfterInterval(ls); stats.EffectiveLimit != 80 {
+			t.Fatalf("expected EffectiveLimit to hold at 80 within the margin, got %+v", stats)
+		}
+	}
+
+	signal.set(0.85)
+	if stats := acquireAfterInterval(ls); stats.EffectiveLimit != 85 {
+		t.Errorf("expected EffectiveLimit=85 below the margin, got %+v", stats)
+	}
+}
+
+func TestLoadshedder_SignalsDebounce(t *testing.T) {
+	signal := &fakeSignal{}
+	ls := New(Config{
+		Limit:               100,
+		Signals:             []Signal{signal},
+		SignalInterval:      time.Millisecond,
+		SignalEntryDebounce: 20 * time.Millisecond,
+		SignalExitDebounce:  20 * time.Millisecond,
+	})
+
+	// A short spike is ignored
+	signal.set(2)
+	if stats := acquireAfterInterval(ls); stats.EffectiveLimit != 100 {
+		t.Fatalf("expected a spike shorter than the entry debounce to be ignored, got %+v", stats)
+	}
+	signal.set(0)
+	acquireAfterInterval(ls)
+
+	// A sustained overload is not
+	signal.set(2)
+	acquireAfterInterval(ls)
+	time.Sleep(25 * time.Millisecond)
+	stats := acquireAfterInterval(ls)
+	if stats.EffectiveLimit >= 100 {
+		t.Fatalf("expected a sustained overload to lower the limit, got %+v", stats)
+	}
+
+	// Recovery waits for the exit debounce
+	lowered := stats.EffectiveLimit
+	signal.set(0)
+	if stats := acquireAfterInterval(ls); stats.EffectiveLimit != lowered {
+		t.Errorf("expected the limit to hold during the exit debounce, got %+v", stats)
+	}
+	time.Sleep(25 * time.Millisecond)
+	if stats := acquireAfterInterval(ls); stats.EffectiveLimit <= lowered {
+		t.Errorf("expected the limit to recover after the exit debounce, got %+v", stats)
+	}
+}
+
+func TestDebouncer(t *testing.T) {
+	d := debouncer{onDelay: 10 * time.Second, offDelay: 20 * time.Second}
+	start := time.Now()
+	at := func(seconds int) time.Time { return start.Add(time.Duration(seconds) * time.Second) }
+
+	steps := []struct {
+		at       int
+		on, off  bool
+		expected bool
+	}{
+		{0, true, false, false},  // on condition starts
+		{5, false, false, false}, // interrupted: pending transition cancelled
+		{6, true, false, false},
+		{15, true, false, false},
+		{16, true, false, true},  // held for 10s
+		{20, false, false, true}, // between thresholds: state kept
+		{21, false, true, true},  // off condition starts
+		{40, false, true, true},
+		{41, false, true, false}, // held for 20s
+	}
+
+	for _, step := range steps {
+		if got := d.update(at(step.at), step.on, step.off); got != step.expected {
+			t.Errorf("t=%ds: expected %v, got %v", step.at, step.expected, got)
+		}
+	}
+}
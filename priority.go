@@ -0,0 +1,190 @@
+package loadshedder
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Priority indicates the relative importance of an acquisition.
+// Higher values are more important; the zero value is the default priority.
+// Values outside the named constants are allowed and keep their ordering.
+type Priority int
+
+// Standard priorities, from the first to be shed to the last.
+const (
+	PrioritySheddable Priority = -1 // Work that can be dropped first (prefetch, batch, retries)
+	PriorityDefault   Priority = 0  // Regular traffic
+	PriorityHigh      Priority = 1  // User-facing traffic that should be preserved
+	PriorityCritical  Priority = 2  // Traffic that must not be shed (health checks, auth)
+)
+
+// DefaultPriorityAdmission returns a Config.PriorityAdmission shedding
+// sheddable traffic from half of the capacity and default traffic from 90%,
+// leaving the last 10% to high and critical traffic.
+func DefaultPriorityAdmission() map[Priority]float64 {
+	return map[Priority]float64{
+		PrioritySheddable: 0.5,
+		PriorityDefault:   0.9,
+	}
+}
+
+// priorityShare is the fraction of the capacity admitting a priority.
+type priorityShare struct {
+	priority Priority
+	fraction float64
+}
+
+// newPriorityShares sorts Config.PriorityAdmission by priority.
+func newPriorityShares(admission map[Priority]float64) []priorityShare {
+	shares := make([]priorityShare, 0, len(admission))
+	for p, fraction := range admission {
+		shares = append(shares, priorityShare{priority: p, fraction: fraction})
+	}
+	slices.SortFunc(shares, func(a, b priorityShare) int {
+		return a.priority.Compare(b.priority)
+	})
+	return shares
+}
+
+// priorityCapacity returns the share of the capacity admitting the priority:
+// the fraction of the priority, or of the next more important priority listed
+// in Config.PriorityAdmission, or the whole capacity.
+func (l *Loadshedder) priorityCapacity(p Priority, capacity int64) int64 {
+	for _, share := range l.priorityShares {
+		if share.priority >= p {
+			return max(1, int64(share.fraction*float64(capacity)))
+		}
+	}
+	return capacity
+}
+
+// Header names carrying a request priority.
+const (
+	// PriorityHeader carries a priority name or integer, see ParsePriority.
+	PriorityHeader = "X-Request-Priority"
+
+	// PriorityMetadataKey is PriorityHeader as a gRPC metadata key.
+	PriorityMetadataKey = "x-request-priority"
+
+	// RFC9218PriorityHeader is the HTTP Priority header from RFC 9218,
+	// see ParseRFC9218Priority.
+	RFC9218PriorityHeader = "Priority"
+)
+
+var priorityNames = map[Priority]string{
+	PrioritySheddable: "sheddable",
+	PriorityDefault:   "default",
+	PriorityHigh:      "high",
+	PriorityCritical:  "critical",
+}
+
+// String returns the name of a standard priority, or its integer value.
+func (p Priority) String() string {
+	if name, ok := priorityNames[p]; ok {
+		return name
+	}
+	return strconv.Itoa(int(p))
+}
+
+// Compare returns -1 if p is less important than other, +1 if it is more
+// important, and 0 if they are equal.
+func (p Priority) Compare(other Priority) int {
+	return cmp.Compare(p, other)
+}
+
+// AtLeast reports whether p is as important as min or more.
+func (p Priority) AtLeast(min Priority) bool {
+	return p >= min
+}
+
+// ParsePriority parses a priority name ("sheddable", "default", "high",
+// "critical", case-insensitive) or an integer.
+func ParsePriority(s string) (Priority, error) {
+	s = strings.TrimSpace(s)
+	for p, name := range priorityNames {
+		if strings.EqualFold(s, name) {
+			return p, nil
+		}
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return PriorityDefault, fmt.Errorf("loadshedder: invalid priority %q", s)
+	}
+	return Priority(n), nil
+}
+
+// ParseRFC9218Priority maps the urgency of an RFC 9218 Priority header value
+// (e.g. "u=1, i") to a Priority:
+//
+//	u=0       PriorityCritical
+//	u=1, u=2  PriorityHigh
+//	u=3       PriorityDefault (also when urgency is absent)
+//	u=4..u=7  PrioritySheddable
+//
+// Returns false if the urgency is present but invalid.
+func ParseRFC9218Priority(s string) (Priority, bool) {
+	for param := range strings.SplitSeq(s, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if key != "u" {
+			continue
+		}
+
+		urgency, err := strconv.Atoi(value)
+		if err != nil || urgency < 0 || urgency > 7 {
+			return PriorityDefault, false
+		}
+		switch {
+		case urgency == 0:
+			return PriorityCritical, true
+		case urgency <= 2:
+			return PriorityHigh, true
+		case urgency == 3:
+			return PriorityDefault, true
+		default:
+			return PrioritySheddable, true
+		}
+	}
+
+	return PriorityDefault, true
+}
+
+// PriorityFromHeader reads the request priority from PriorityHeader, falling
+// back to the RFC 9218 Priority header. Returns PriorityDefault if neither is
+// set or valid.
+// Headers are set by clients: only trust them from internal callers.
+func PriorityFromHeader(h http.Header) Priority {
+	if value := h.Get(PriorityHeader); value != "" {
+		if p, err := ParsePriority(value); err == nil {
+			return p
+		}
+	}
+
+	if value := h.Get(RFC9218PriorityHeader); value != "" {
+		if p, ok := ParseRFC9218Priority(value); ok {
+			return p
+		}
+	}
+
+	return PriorityDefault
+}
+
+// PriorityFromMetadata reads the request priority from PriorityMetadataKey in
+// gRPC metadata (metadata.MD can be passed directly). Returns PriorityDefault
+// if it is not set or invalid.
+func PriorityFromMetadata(md map[string][]string) Priority {
+	values := md[PriorityMetadataKey]
+	if len(values) == 0 {
+		return PriorityDefault
+	}
+
+	p, err := ParsePriority(values[0])
+	if err != nil {
+		return PriorityDefault
+	}
+	return p
+}
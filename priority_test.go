@@ -0,0 +1,189 @@
+package loadshedder
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestPriority_Ordering(t *testing.T) {
+	ordered := []Priority{PrioritySheddable, PriorityDefault, PriorityHigh, PriorityCritical}
+	for i := 1; i < len(ordered); i++ {
+		if ordered[i].Compare(ordered[i-1]) != 1 || ordered[i-1].Compare(ordered[i]) != -1 {
+			t.Errorf("expected %s > %s", ordered[i], ordered[i-1])
+		}
+		if !ordered[i].AtLeast(ordered[i-1]) || ordered[i-1].AtLeast(ordered[i]) {
+			t.Errorf("unexpected AtLeast between %s and %s", ordered[i], ordered[i-1])
+		}
+	}
+
+	if PriorityDefault != Priority(0) {
+		t.Error("expected the zero value to be the default priority")
+	}
+	if PriorityHigh.Compare(PriorityHigh) != 0 {
+		t.Error("expected equal priorities to compare as 0")
+	}
+}
+
+func TestParsePriority(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Priority
+		wantErr  bool
+	}{
+		{"sheddable", PrioritySheddable, false},
+		{"Default", PriorityDefault, false},
+		{" HIGH ", PriorityHigh, false},
+		{"critical", PriorityCritical, false},
+		{"5", Priority(5), false},
+		{"-3", Priority(-3), false},
+		{"urgent", PriorityDefault, true},
+		{"", PriorityDefault, true},
+	}
+
+	for _, tt := range tests {
+		p, err := ParsePriority(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePriority(%q): unexpected error %v", tt.input, err)
+		}
+		if p != tt.expected {
+			t.Errorf("ParsePriority(%q) = %s, expected %s", tt.input, p, tt.expected)
+		}
+	}
+
+	// Names round-trip through String
+	for _, p := range []Priority{PrioritySheddable, PriorityDefault, PriorityHigh, PriorityCritical, 7} {
+		if parsed, err := ParsePriority(p.String()); err != nil || parsed != p {
+			t.Errorf("expected %s to round-trip, got %s (%v)", p, parsed, err)
+		}
+	}
+}
+
+func TestParseRFC9218Priority(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected Priority
+		ok       bool
+	}{
+		{"u=0", PriorityCritical, true},
+		{"u=1, i", PriorityHigh, true},
+		{"i, u=2", PriorityHigh, true},
+		{"u=3", PriorityDefault, true},
+		{"i", PriorityDefault, true},
+		{"u=5", PrioritySheddable, true},
+		{"u=7", PrioritySheddable, true},
+		{"u=8", PriorityDefault, false},
+		{"u=x", PriorityDefault, false},
+	}
+
+	for _, tt := range tests {
+		p, ok := ParseRFC9218Priority(tt.input)
+		if ok != tt.ok || p != tt.expected {
+			t.Errorf("ParseRFC9218Priority(%q) = %s, %v; expected %s, %v", tt.input, p, ok, tt.expected, tt.ok)
+		}
+	}
+}
+
+func TestPriorityFromHeader(t *testing.T) {
+	h := http.Header{}
+	if p := PriorityFromHeader(h); p != PriorityDefault {
+		t.Errorf("expected default priority without headers, got %s", p)
+	}
+
+	h.Set(RFC9218PriorityHeader, "u=6")
+	if p := PriorityFromHeader(h); p != PrioritySheddable {
+		t.Errorf("expected RFC 9218 fallback, got %s", p)
+	}
+
+	h.Set(PriorityHeader, "critical")
+	if p := PriorityFromHeader(h); p != PriorityCritical {
+		t.Errorf("expected %s to take precedence, got %s", PriorityHeader, p)
+	}
+
+	h.Set(PriorityHeader, "bogus")
+	if p := PriorityFromHeader(h); p != PrioritySheddable {
+		t.Errorf("expected an invalid %s to fall back to RFC 9218, got %s", PriorityHeader, p)
+	}
+}
+
+func TestPriorityFromMetadata(t *testing.T) {
+	if p := PriorityFromMetadata(nil); p != PriorityDefault {
+		t.Errorf("expected default priority without metadata, got %s", p)
+	}
+
+	md := map[string][]string{PriorityMetadataKey: {"high"}}
+	if p := PriorityFromMetadata(md); p != PriorityHigh {
+		t.Errorf("expected high priority, got %s", p)
+	}
+
+	md[PriorityMetadataKey] = []string{"bogus"}
+	if p := PriorityFromMetadata(md); p != PriorityDefault {
+		t.Errorf("expected default priority for invalid metadata, got %s", p)
+	}
+}
+
+func TestLoadshedder_PriorityAdmission(t *testing.T) {
+	ctx := context.Background()
+	ls := New(Config{Limit: 10, PriorityAdmission: DefaultPriorityAdmission()})
+
+	acquire := func(p Priority) bool {
+		_, token := ls.Acquire(ctx, WithPriority(p))
+		return token.Accepted()
+	}
+
+	for i := range 5 {
+		if !acquire(PrioritySheddable) {
+			t.Fatalf("expected sheddable request %d to be accepted under half of the capacity", i+1)
+		}
+	}
+	if acquire(PrioritySheddable) {
+		t.Error("expected sheddable traffic to be shed from half of the capacity")
+	}
+	if acquire(Priority(-5)) {
+		t.Error("expected an unlisted lower priority to use the sheddable share")
+	}
+
+	for i := range 4 {
+		if !acquire(PriorityDefault) {
+			t.Fatalf("expected default request %d to be accepted under 90%% of the capacity", i+1)
+		}
+	}
+	if acquire(PriorityDefault) {
+		t.Error("expected default traffic to be shed from 90% of the capacity")
+	}
+
+	if !acquire(PriorityHigh) {
+		t.Error("expected high priority traffic to use the reserved headroom")
+	}
+	if acquire(PriorityCritical) {
+		t.Error("expected the capacity to still bound critical traffic")
+	}
+}
+
+func TestLoadshedder_PriorityAdmissionValidation(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for a fraction above 1")
+		}
+	}()
+	New(Config{Limit: 10, PriorityAdmission: map[Priority]float64{PriorityDefault: 1.5}})
+}
+
+func TestLoadshedder_StatsPriority(t *testing.T) {
+	ls := New(Config{Limit: 1})
+
+	stats, token := ls.Acquire(context.Background(), WithPriority(PriorityHigh))
+	defer ls.Release(token)
+	if stats.Priority != PriorityHigh {
+		t.Errorf("expected the priority of the accepted call, got %v", stats.Priority)
+	}
+
+	ctx := ContextWithPriority(context.Background(), PrioritySheddable)
+	if stats, _ := ls.Acquire(ctx); stats.Priority != PrioritySheddable {
+		t.Errorf("expected the priority of the rejected call, got %v", stats.Priority)
+	}
+
+	if stats := ls.Stats(); stats.Priority != PriorityDefault {
+		t.Errorf("expected the default priority in Stats, got %v", stats.Priority)
+	}
+}
@@ -0,0 +1,51 @@
+package loadshedder
+
+import (
+	"context"
+	"net/http"
+)
+
+type priorityContextKey struct{}
+
+// ContextWithPriority returns a copy of ctx carrying the priority, inherited by
+// Acquire calls without WithPriority and propagated to outgoing requests by
+// PriorityTransport. The middleware sets it for admitted requests with a
+// non-default priority.
+func ContextWithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityContextKey{}, p)
+}
+
+// PriorityFromContext returns the priority set with ContextWithPriority, or
+// PriorityDefault.
+func PriorityFromContext(ctx context.Context) Priority {
+	p, _ := ctx.Value(priorityContextKey{}).(Priority)
+	return p
+}
+
+// PriorityTransport sets PriorityHeader on outgoing requests from the priority
+// of their context (see ContextWithPriority), so the criticality of a request
+// is preserved across hops. Requests with an explicit PriorityHeader or the
+// default priority are sent unchanged.
+// If next is nil, http.DefaultTransport is used.
+func PriorityTransport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &priorityTransport{next: next}
+}
+
+type priorityTransport struct {
+	next http.RoundTripper
+}
+
+func (t *priorityTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	p := PriorityFromContext(r.Context())
+	if p == PriorityDefault || r.Header.Get(PriorityHeader) != "" {
+		return t.next.RoundTrip(r)
+	}
+
+	// A RoundTripper must not modify the request
+	r = r.Clone(r.Context())
+	r.Header.Set(PriorityHeader, p.String())
+	return t.next.RoundTrip(r)
+}
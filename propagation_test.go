@@ -0,0 +1,86 @@
+package loadshedder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPriorityFromContext(t *testing.T) {
+	if got := PriorityFromContext(context.Background()); got != PriorityDefault {
+		t.Errorf("expected the default priority, got %s", got)
+	}
+
+	ctx := ContextWithPriority(context.Background(), PriorityHigh)
+	if got := PriorityFromContext(ctx); got != PriorityHigh {
+		t.Errorf("expected high priority, got %s", got)
+	}
+
+	// Acquire inherits the priority, unless WithPriority is set
+	ls := New(Config{Limit: 10})
+	_, token := ls.Acquire(ctx)
+	defer ls.Release(token)
+	if token.Priority() != PriorityHigh {
+		t.Errorf("expected the token to inherit high priority, got %s", token.Priority())
+	}
+
+	_, token = ls.Acquire(ctx, WithPriority(PriorityDefault))
+	defer ls.Release(token)
+	if token.Priority() != PriorityDefault {
+		t.Errorf("expected WithPriority to override the context, got %s", token.Priority())
+	}
+}
+
+func TestPriorityTransport(t *testing.T) {
+	var received []string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get(PriorityHeader))
+	}))
+	defer upstream.Close()
+
+	client := &http.Client{Transport: PriorityTransport(nil)}
+	send := func(ctx context.Context, header string) {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, upstream.URL, http.NoBody)
+		if header != "" {
+			req.Header.Set(PriorityHeader, header)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	critical := ContextWithPriority(context.Background(), PriorityCritical)
+	send(context.Background(), "")
+	send(critical, "")
+	send(critical, "sheddable")
+
+	expected := []string{"", "critical", "sheddable"}
+	for i := range expected {
+		if received[i] != expected[i] {
+			t.Errorf("request %d: expected priority header %q, got %q", i, expected[i], received[i])
+		}
+	}
+}
+
+func TestMiddleware_InheritPriority(t *testing.T) {
+	ls := New(Config{Limit: 10})
+	mw := NewMiddleware(ls, nil, nil, WithRequestOptions(func(r *http.Request) []AcquireOption {
+		return []AcquireOption{WithPriority(PriorityFromHeader(r.Header))}
+	}))
+
+	var inherited Priority
+	handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inherited = PriorityFromContext(r.Context())
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	req.Header.Set(PriorityHeader, "high")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if inherited != PriorityHigh {
+		t.Errorf("expected the handler context to carry the high priority, got %s", inherited)
+	}
+}
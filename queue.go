@@ -0,0 +1,34 @@
+package loadshedder
+
+// QueueDiscipline selects the order in which waiting requests are admitted,
+// see Config.QueueDiscipline.
+type QueueDiscipline int
+
+const (
+	// QueueFIFO admits the oldest waiting request first.
+	QueueFIFO QueueDiscipline = iota
+
+	// QueueLIFO admits the newest waiting request first. Under overload, the
+	// oldest requests are the most likely to have been given up on by their
+	// clients: serving the freshest ones avoids wasting work on them, at the
+	// cost of starving the oldest ones until they time out.
+	QueueLIFO
+
+	// QueueAdaptiveLIFO is FIFO while the queue is short, and LIFO while more
+	// than half of WaitingLimit is waiting: fair under normal load, and
+	// serving the freshest requests once the queue builds up.
+	QueueAdaptiveLIFO
+)
+
+func (d QueueDiscipline) String() string {
+	switch d {
+	case QueueFIFO:
+		return "fifo"
+	case QueueLIFO:
+		return "lifo"
+	case QueueAdaptiveLIFO:
+		return "adaptive-lifo"
+	default:
+		return "unknown"
+	}
+}
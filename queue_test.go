@@ -0,0 +1,84 @@
+package loadshedder
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// admissionOrder queues the requests one after the other behind a held
+// slot, then frees the slots one at a time and returns the order in which
+// the requests were admitted.
+func admissionOrder(t *testing.T, ls *Loadshedder, requests int) []int {
+	t.Helper()
+	ctx := context.Background()
+
+	type admission struct {
+		request int
+		token   *Token
+	}
+
+	_, blocker := ls.Acquire(ctx)
+
+	admitted := make(chan admission)
+	for i := range requests {
+		go func() {
+			_, token := ls.Acquire(ctx)
+			admitted <- admission{i, token}
+		}()
+		waitFor(t, func() bool { return ls.Stats().Waiting == int64(i+1) })
+	}
+
+	var order []int
+	ls.Release(blocker)
+	for range requests {
+		select {
+		case a := <-admitted:
+			if !a.token.Accepted() {
+				t.Fatalf("request %d: expected an admission", a.request)
+			}
+			order = append(order, a.request)
+			ls.Release(a.token)
+		case <-time.After(time.Second):
+			t.Fatalf("expected %d admissions, got %v", requests, order)
+		}
+	}
+	return order
+}
+
+func TestLoadshedder_QueueDiscipline(t *testing.T) {
+	tests := []struct {
+		discipline QueueDiscipline
+		expected   []int
+	}{
+		{QueueFIFO, []int{0, 1, 2, 3}},
+		{QueueLIFO, []int{3, 2, 1, 0}},
+		// LIFO while more than 2 requests wait (half the WaitingLimit), then FIFO
+		{QueueAdaptiveLIFO, []int{3, 2, 0, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.discipline.String(), func(t *testing.T) {
+			ls := New(Config{Limit: 1, WaitingLimit: 4, QueueDiscipline: tt.discipline})
+
+			order := admissionOrder(t, ls, 4)
+			if len(order) != len(tt.expected) {
+				t.Fatalf("expected %v, got %v", tt.expected, order)
+			}
+			for i := range order {
+				if order[i] != tt.expected[i] {
+					t.Fatalf("expected %v, got %v", tt.expected, order)
+				}
+			}
+		})
+	}
+}
+
+func TestNew_PanicsWithInvalidQueueDiscipline(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	New(Config{Limit: 1, QueueDiscipline: 42})
+}
@@ -0,0 +1,98 @@
+package loadshedder
+
+import (
+	"math"
+	"time"
+)
+
+// rampUpdateInterval is the period between updates of a ramping limit, on
+// the Acquire path.
+const rampUpdateInterval = 10 * time.Millisecond
+
+// limitRamp is a transition of the configured limit, see RampLimit.
+// Immutable: a new ramp replaces it.
+type limitRamp struct {
+	from, to int64
+	start    monotime
+	duration time.Duration
+}
+
+// progress returns the fraction of the ramp elapsed at now, in [0, 1].
+func (r *limitRamp) progress(now monotime) float64 {
+	return min(1, max(0, float64(now.Sub(r.start))/float64(r.duration)))
+}
+
+// at returns the limit at now.
+func (r *limitRamp) at(now monotime) int64 {
+	return r.from + int64(math.Round(float64(r.to-r.from)*r.progress(now)))
+}
+
+// RampLimit changes the configured limit (Config.Limit) to limit linearly
+// over duration, instead of stepping to it: a sudden drop rejects a burst of
+// requests whose retries make things worse, and a sudden raise lets the
+// queue rush a backend that was shielded. The ramp starts from the current
+// limit, and replaces any ramp in progress. Stats.RampTarget and
+// Stats.RampProgress report it. The limit is updated on the Acquire path.
+// A duration of zero steps to the limit, like SetLimit without
+// Config.LimitRamp. Panics if limit is not positive or duration is negative.
+func (l *Loadshedder) RampLimit(limit int64, duration time.Duration) {
+	if limit <= 0 {
+		panic("loadshedder: limit must be positive")
+	}
+	if duration < 0 {
+		panic("loadshedder: ramp duration cannot be negative")
+	}
+
+	l.limitMu.Lock()
+	defer l.limitMu.Unlock()
+
+	l.rampLimit(limit, duration)
+}
+
+// rampLimit is RampLimit, without the validation. Must hold limitMu.
+func (l *Loadshedder) rampLimit(limit int64, duration time.Duration) {
+	from := l.limit.Load()
+	if duration == 0 || from == limit {
+		l.updateSettings(func(s *settings) { s.ramp = nil })
+		l.limit.Store(limit)
+		l.updateEffectiveLimit()
+		return
+	}
+
+	r := &limitRamp{from: from, to: limit, start: monoOf(time.Now()), duration: duration}
+	l.updateSettings(func(s *settings) { s.ramp = r })
+	l.nextRampUpdate.Store(0)
+}
+
+// updateRamp moves the configured limit along the ramp, at most once per
+// rampUpdateInterval.
+func (l *Loadshedder) updateRamp(now time.Time) {
+	next := l.nextRampUpdate.Load()
+	if now.UnixNano() < next || !l.nextRampUpdate.CompareAndSwap(next, now.UnixNano()+int64(rampUpdateInterval)) {
+		return
+	}
+
+	l.limitMu.Lock()
+	defer l.limitMu.Unlock()
+
+	r := l.settings.Load().ramp
+	if r == nil {
+		return
+	}
+
+	mono := monoOf(now)
+	l.limit.Store(r.at(mono))
+	if r.progress(mono) >= 1 {
+		l.updateSettings(func(s *settings) { s.ramp = nil })
+	}
+	l.updateEffectiveLimit()
+}
+
+// targetLimit returns the configured limit, or the target of the ramp in
+// progress.
+func (l *Loadshedder) targetLimit() int64 {
+	if r := l.settings.Load().ramp; r != nil {
+		return r.to
+	}
+	return l.limit.Load()
+}
@@ -0,0 +1,82 @@
+package loadshedder
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLoadshedder_RampLimit(t *testing.T) {
+	ls := New(Config{Limit: 10})
+
+	start := time.Now()
+	ls.RampLimit(30, time.Second)
+
+	stats := ls.Stats()
+	if stats.ConfiguredLimit != 10 || stats.RampTarget != 30 || stats.RampProgress >= 0.5 {
+		t.Fatalf("expected the ramp to start from the current limit, got %+v", stats)
+	}
+	if limit := ls.Config().Limit; limit != 30 {
+		t.Errorf("expected the configuration to report the target, got %d", limit)
+	}
+
+	update := func(at time.Duration) Stats {
+		ls.nextRampUpdate.Store(0)
+		ls.updateRamp(start.Add(at))
+		return ls.Stats()
+	}
+
+	if stats := update(500 * time.Millisecond); stats.EffectiveLimit < 19 || stats.EffectiveLimit > 21 || stats.RampTarget != 30 {
+		t.Errorf("expected about 20 halfway through the ramp, got %+v", stats)
+	}
+	if stats := update(time.Second + time.Millisecond); stats.EffectiveLimit != 30 || stats.RampTarget != 0 || stats.RampProgress != 0 {
+		t.Errorf("expected the target at the end of the ramp, got %+v", stats)
+	}
+
+	// Ramping down, replaced by a step
+	ls.RampLimit(10, time.Minute)
+	if stats := update(30 * time.Second); stats.EffectiveLimit > 21 || stats.EffectiveLimit < 19 {
+		t.Errorf("expected about 20 halfway down, got %+v", stats)
+	}
+	ls.RampLimit(5, 0)
+	if stats := ls.Stats(); stats.EffectiveLimit != 5 || stats.RampTarget != 0 {
+		t.Errorf("expected a step to replace the ramp, got %+v", stats)
+	}
+}
+
+func TestLoadshedder_LimitRamp(t *testing.T) {
+	ls := New(Config{Limit: 10, LimitRamp: time.Minute})
+
+	ls.SetLimit(2)
+	if stats := ls.Stats(); stats.EffectiveLimit != 10 || stats.RampTarget != 2 {
+		t.Errorf("expected SetLimit to ramp, got %+v", stats)
+	}
+
+	// Acquisitions move the limit along the ramp
+	ls.limitMu.Lock()
+	ls.updateSettings(func(s *settings) {
+		s.ramp = &limitRamp{from: 10, to: 2, start: monoOf(time.Now().Add(-time.Minute)), duration: time.Minute}
+	})
+	ls.limitMu.Unlock()
+	_, token := ls.Acquire(t.Context())
+	ls.Release(token)
+	if stats := ls.Stats(); stats.EffectiveLimit != 2 || stats.RampTarget != 0 {
+		t.Errorf("expected the ramp to complete on the Acquire path, got %+v", stats)
+	}
+}
+
+func TestLoadshedder_RampLimitValidation(t *testing.T) {
+	for name, f := range map[string]func(){
+		"limit":    func() { New(Config{Limit: 1}).RampLimit(0, time.Second) },
+		"duration": func() { New(Config{Limit: 1}).RampLimit(1, -time.Second) },
+		"config":   func() { New(Config{Limit: 1, LimitRamp: -time.Second}) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected a panic")
+				}
+			}()
+			f()
+		})
+	}
+}
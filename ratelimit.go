@@ -0,0 +1,125 @@
+package loadshedder
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RateLimitHeadersConfig configures the RateLimit headers, see
+// WithRateLimitHeaders.
+type RateLimitHeadersConfig struct {
+	// RejectedOnly sets the headers on the rejections only.
+	// Optional, default to false (admitted requests get them too).
+	RejectedOnly bool
+
+	// MinRetryAfter and MaxRetryAfter bound the Retry-After computed for the
+	// rejections.
+	// Optional, default to 1s and 1m.
+	MinRetryAfter time.Duration
+	MaxRetryAfter time.Duration
+}
+
+// WithRateLimitHeaders sets the RateLimit headers of the IETF draft
+// (draft-ietf-httpapi-ratelimit-headers), concurrency flavored, so clients
+// and proxies see the capacity left:
+//   - RateLimit-Limit: the effective limit
+//   - RateLimit-Remaining: the free slots (0 while requests are waiting)
+//   - RateLimit-Reset: the seconds until a slot is expected to be free, see
+//     Stats.RetryAfter (0 with free slots)
+//
+// Rejections also get a Retry-After computed the same way, within
+// MinRetryAfter and MaxRetryAfter, replacing the one of the rejection
+// handler (WithRejectStreaks still escalates it). The headers of admitted
+// requests are set before the handler runs, which can change them.
+func WithRateLimitHeaders(cfg RateLimitHeadersConfig) MiddlewareOption {
+	h := newRateLimitHeaders(cfg)
+	return func(m *Middleware) {
+		m.rateLimitHeaders = h
+	}
+}
+
+type rateLimitHeaders struct {
+	rejectedOnly  bool
+	minRetryAfter time.Duration
+	maxRetryAfter time.Duration
+}
+
+func newRateLimitHeaders(cfg RateLimitHeadersConfig) *rateLimitHeaders {
+	if cfg.MinRetryAfter < 0 || cfg.MaxRetryAfter < 0 {
+		panic("loadshedder: RateLimitHeadersConfig.MinRetryAfter and RateLimitHeadersConfig.MaxRetryAfter cannot be negative")
+	}
+	if cfg.MinRetryAfter == 0 {
+		cfg.MinRetryAfter = defaultMinRetryAfter
+	}
+	if cfg.MaxRetryAfter == 0 {
+		cfg.MaxRetryAfter = defaultMaxRetryAfter
+	}
+	if cfg.MaxRetryAfter < cfg.MinRetryAfter {
+		panic("loadshedder: RateLimitHeadersConfig.MaxRetryAfter must not be lower than MinRetryAfter")
+	}
+
+	return &rateLimitHeaders{
+		rejectedOnly:  cfg.RejectedOnly,
+		minRetryAfter: cfg.MinRetryAfter,
+		maxRetryAfter: cfg.MaxRetryAfter,
+	}
+}
+
+// set sets the RateLimit headers from the Stats.
+func (h *rateLimitHeaders) set(header http.Header, stats Stats) {
+	remaining := max(0, stats.EffectiveLimit-stats.Running-stats.Waiting)
+	reset := 0
+	if remaining == 0 {
+		reset = ceilSeconds(stats.RetryAfter)
+	}
+
+	header.Set("RateLimit-Limit", strconv.FormatInt(stats.EffectiveLimit, 10))
+	header.Set("RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+	header.Set("RateLimit-Reset", strconv.Itoa(reset))
+}
+
+// rejected wraps the rejection handler, to set the headers and the computed
+// Retry-After once it is done with the response header.
+func (h *rateLimitHeaders) rejected(handler http.Handler, stats Stats) http.Handler {
+	retryAfter := retryAfterSeconds(stats, h.minRetryAfter, h.maxRetryAfter)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler.ServeHTTP(&rateLimitWriter{ResponseWriter: w, headers: h, stats: stats, retryAfter: retryAfter}, r)
+	})
+}
+
+// rateLimitWriter sets the RateLimit headers and the Retry-After set by the
+// rejection handler before the response header is written.
+type rateLimitWriter struct {
+	http.ResponseWriter
+	headers     *rateLimitHeaders
+	stats       Stats
+	retryAfter  int
+	wroteHeader bool
+}
+
+func (rw *rateLimitWriter) WriteHeader(statusCode int) {
+	rw.setHeaders()
+	rw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rw *rateLimitWriter) Write(b []byte) (int, error) {
+	rw.setHeaders()
+	return rw.ResponseWriter.Write(b)
+}
+
+func (rw *rateLimitWriter) setHeaders() {
+	if rw.wroteHeader {
+		return
+	}
+	rw.wroteHeader = true
+
+	rw.headers.set(rw.Header(), rw.stats)
+	rw.Header().Set("Retry-After", strconv.Itoa(rw.retryAfter))
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController.
+func (rw *rateLimitWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
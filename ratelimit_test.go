@@ -0,0 +1,110 @@
+package loadshedder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMiddleware_WithRateLimitHeaders(t *testing.T) {
+	ls := New(Config{Limit: 4})
+	mw := NewMiddleware(ls, nil, nil, WithRateLimitHeaders(RateLimitHeadersConfig{}))
+
+	rec := httptest.NewRecorder()
+	mw.Handler(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	expected := map[string]string{
+		"RateLimit-Limit":     "4",
+		"RateLimit-Remaining": "3",
+		"RateLimit-Reset":     "0",
+		"Retry-After":         "",
+	}
+	for name, value := range expected {
+		if got := rec.Header().Get(name); got != value {
+			t.Errorf("expected %s: %q, got %q", name, value, got)
+		}
+	}
+}
+
+func TestMiddleware_WithRateLimitHeadersRejected(t *testing.T) {
+	tests := []struct {
+		name        string
+		avgDuration time.Duration
+		retryAfter  string
+		reset       string
+	}{
+		{"computed", 3500 * time.Millisecond, "4", "4"},
+		{"min", 0, "1", "0"},
+		{"max", 10 * time.Minute, "60", "600"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ls := New(Config{Limit: 1})
+			ls.avgDuration.Store(int64(tt.avgDuration))
+			mw := NewMiddleware(ls, nil, nil, WithRateLimitHeaders(RateLimitHeadersConfig{}))
+
+			_, held := ls.Acquire(context.Background())
+			defer ls.Release(held)
+
+			rec := httptest.NewRecorder()
+			mw.Handler(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+			if rec.Code != http.StatusTooManyRequests {
+				t.Fatalf("expected status 429, got %d", rec.Code)
+			}
+			expected := map[string]string{
+				"RateLimit-Limit":     "1",
+				"RateLimit-Remaining": "0",
+				"RateLimit-Reset":     tt.reset,
+				"Retry-After":         tt.retryAfter,
+			}
+			for name, value := range expected {
+				if got := rec.Header().Get(name); got != value {
+					t.Errorf("expected %s: %q, got %q", name, value, got)
+				}
+			}
+		})
+	}
+}
+
+func TestMiddleware_WithRateLimitHeadersRejectedOnly(t *testing.T) {
+	ls := New(Config{Limit: 1})
+	mw := NewMiddleware(ls, nil, nil, WithRateLimitHeaders(RateLimitHeadersConfig{RejectedOnly: true}))
+
+	var rejected *httptest.ResponseRecorder
+	handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rejected = httptest.NewRecorder()
+		mw.Handler(http.NotFoundHandler()).ServeHTTP(rejected, r)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	if rec.Header().Get("RateLimit-Limit") != "" {
+		t.Errorf("expected no headers on the admitted request, got %v", rec.Header())
+	}
+	if rejected.Header().Get("RateLimit-Limit") != "1" {
+		t.Errorf("expected the headers on the rejection, got %v", rejected.Header())
+	}
+}
+
+func TestRateLimitHeadersConfigValidation(t *testing.T) {
+	tests := map[string]RateLimitHeadersConfig{
+		"negative min":  {MinRetryAfter: -time.Second},
+		"max below min": {MinRetryAfter: time.Minute, MaxRetryAfter: time.Second},
+	}
+
+	for name, cfg := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected a panic")
+				}
+			}()
+			WithRateLimitHeaders(cfg)
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package loadshedder
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Registry holds named loadshedders, e.g. one per workload sharing a process.
+type Registry struct {
+	mu           sync.RWMutex
+	loadshedders map[string]*Loadshedder
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{loadshedders: make(map[string]*Loadshedder)}
+}
+
+// Register adds a loadshedder under its Config.Name.
+// Returns an error if the name is empty or already registered.
+func (r *Registry) Register(ls *Loadshedder) error {
+	if ls.name == "" {
+		return fmt.Errorf("loadshedder: cannot register a loadshedder without Config.Name")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.loadshedders[ls.name]; ok {
+		return fmt.Errorf("loadshedder: %q is already registered", ls.name)
+	}
+	r.loadshedders[ls.name] = ls
+	return nil
+}
+
+// Get returns the loadshedder registered under the name, or nil.
+func (r *Registry) Get(name string) *Loadshedder {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.loadshedders[name]
+}
+
+// All returns the registered loadshedders, sorted by name.
+func (r *Registry) All() []*Loadshedder {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.sorted()
+}
+
+// sorted returns the registered loadshedders, sorted by name. Must hold mu.
+func (r *Registry) sorted() []*Loadshedder {
+	all := make([]*Loadshedder, 0, len(r.loadshedders))
+	for _, ls := range r.loadshedders {
+		all = append(all, ls)
+	}
+
+	slices.SortFunc(all, func(a, b *Loadshedder) int { return cmp.Compare(a.name, b.name) })
+	return all
+}
+
+// Donate atomically moves capacity slots of the limit of the loadshedder
+// named from to the one named to, e.g. from a batch workload to an API
+// during its peak. The capacity is returned after ttl, or earlier by calling
+// the returned function.
+// The donor keeps a limit of at least 1; running requests are not interrupted,
+// the donor admits new requests once below its reduced limit.
+func (r *Registry) Donate(from, to string, capacity int64, ttl time.Duration) (giveBack func(), err error) {
+	if capacity <= 0 {
+		return nil, fmt.Errorf("loadshedder: donated capacity must be positive")
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("loadshedder: donation ttl must be positive")
+	}
+	if from == to {
+		return nil, fmt.Errorf("loadshedder: cannot donate capacity to itself")
+	}
+
+	donor, recipient := r.Get(from), r.Get(to)
+	if donor == nil {
+		return nil, fmt.Errorf("loadshedder: unknown loadshedder %q", from)
+	}
+	if recipient == nil {
+		return nil, fmt.Errorf("loadshedder: unknown loadshedder %q", to)
+	}
+
+	if err := transferCapacity(donor, recipient, capacity, true); err != nil {
+		return nil, err
+	}
+
+	var once sync.Once
+	returnCapacity := func() {
+		once.Do(func() {
+			// Always give back, even if the recipient donated it onward
+			_ = transferCapacity(recipient, donor, capacity, false)
+		})
+	}
+	timer := time.AfterFunc(ttl, returnCapacity)
+
+	return func() {
+		timer.Stop()
+		returnCapacity()
+	}, nil
+}
+
+// transferCapacity moves capacity from one loadshedder's limit to another,
+// holding both limit locks so no observer sees the capacity twice.
+// With keepMinimum, it fails rather than leaving the donor a limit below 1.
+func transferCapacity(from, to *Loadshedder, capacity int64, keepMinimum bool) error {
+	// Lock in a consistent order to avoid deadlocks between opposite transfers
+	first, second := from, to
+	if first.name > second.name {
+		first, second = second, first
+	}
+	first.limitMu.Lock()
+	defer first.limitMu.Unlock()
+	second.limitMu.Lock()
+	defer second.limitMu.Unlock()
+
+	if keepMinimum && from.limit.Load()+from.donated-capacity < 1 {
+		return fmt.Errorf("loadshedder: %q cannot donate %d of its %d capacity", from.name, capacity, from.limit.Load()+from.donated)
+	}
+
+	from.donated -= capacity
+	to.donated += capacity
+	from.updateEffectiveLimit()
+	to.updateEffectiveLimit()
+	return nil
+}
@@ -0,0 +1,147 @@
+package loadshedder
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	api := New(Config{Name: "api", Limit: 10})
+	batch := New(Config{Name: "batch", Limit: 10})
+
+	for _, ls := range []*Loadshedder{batch, api} {
+		if err := registry.Register(ls); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if err := registry.Register(New(Config{Name: "api", Limit: 1})); err == nil {
+		t.Error("expected an error for a duplicate name")
+	}
+	if err := registry.Register(New(Config{Limit: 1})); err == nil {
+		t.Error("expected an error for an unnamed loadshedder")
+	}
+
+	if registry.Get("api") != api || registry.Get("unknown") != nil {
+		t.Error("unexpected Get result")
+	}
+	if all := registry.All(); len(all) != 2 || all[0] != api || all[1] != batch {
+		t.Errorf("expected loadshedders sorted by name, got %v", all)
+	}
+}
+
+func TestRegistry_Donate(t *testing.T) {
+	registry := NewRegistry()
+	api := New(Config{Name: "api", Limit: 10})
+	batch := New(Config{Name: "batch", Limit: 10})
+	_ = registry.Register(api)
+	_ = registry.Register(batch)
+
+	giveBack, err := registry.Donate("batch", "api", 2, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if stats := api.Stats(); stats.EffectiveLimit != 12 || stats.ConfiguredLimit != 10 {
+		t.Errorf("expected the recipient limit to be 12, got %+v", stats)
+	}
+	if stats := batch.Stats(); stats.EffectiveLimit != 8 {
+		t.Errorf("expected the donor limit to be 8, got %+v", stats)
+	}
+
+	// The donated capacity is usable
+	var tokens []*Token
+	for range 12 {
+		_, token := api.Acquire(context.Background())
+		if !token.Accepted() {
+			t.Fatal("expected the recipient to admit up to its raised limit")
+		}
+		tokens = append(tokens, token)
+	}
+	for _, token := range tokens {
+		api.Release(token)
+	}
+
+	giveBack()
+	giveBack()
+
+	if api.Stats().EffectiveLimit != 10 || batch.Stats().EffectiveLimit != 10 {
+		t.Errorf("expected the capacity to be returned once, got api=%+v batch=%+v", api.Stats(), batch.Stats())
+	}
+}
+
+func TestRegistry_DonateTTL(t *testing.T) {
+	registry := NewRegistry()
+	api := New(Config{Name: "api", Limit: 10})
+	batch := New(Config{Name: "batch", Limit: 10})
+	_ = registry.Register(api)
+	_ = registry.Register(batch)
+
+	if _, err := registry.Donate("batch", "api", 5, 20*time.Millisecond); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if api.Stats().EffectiveLimit != 15 {
+		t.Fatalf("expected the donation to apply, got %+v", api.Stats())
+	}
+
+	waitForStats(t, api, func(s Stats) bool { return s.EffectiveLimit == 10 })
+	if batch.Stats().EffectiveLimit != 10 {
+		t.Errorf("expected the donor to get its capacity back, got %+v", batch.Stats())
+	}
+}
+
+func TestRegistry_DonateErrors(t *testing.T) {
+	registry := NewRegistry()
+	_ = registry.Register(New(Config{Name: "api", Limit: 10}))
+	_ = registry.Register(New(Config{Name: "batch", Limit: 3}))
+
+	tests := []struct {
+		name     string
+		from, to string
+		capacity int64
+		ttl      time.Duration
+	}{
+		{"unknown donor", "unknown", "api", 1, time.Hour},
+		{"unknown recipient", "batch", "unknown", 1, time.Hour},
+		{"self donation", "api", "api", 1, time.Hour},
+		{"non-positive capacity", "batch", "api", 0, time.Hour},
+		{"non-positive ttl", "batch", "api", 1, 0},
+		{"donor exhausted", "batch", "api", 3, time.Hour},
+	}
+
+	for _, tt := range tests {
+		if _, err := registry.Donate(tt.from, tt.to, tt.capacity, tt.ttl); err == nil {
+			t.Errorf("%s: expected an error", tt.name)
+		}
+	}
+
+	if limit := registry.Get("batch").Stats().EffectiveLimit; limit != 3 {
+		t.Errorf("expected failed donations to leave the limits unchanged, got %d", limit)
+	}
+}
+
+func TestRegistry_DonateWithClamp(t *testing.T) {
+	registry := NewRegistry()
+	api := New(Config{Name: "api", Limit: 10})
+	_ = registry.Register(api)
+	_ = registry.Register(New(Config{Name: "batch", Limit: 10}))
+
+	api.Clamp(5)
+	giveBack, err := registry.Donate("batch", "api", 5, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer giveBack()
+
+	// The clamp still caps the raised limit
+	if limit := api.Stats().EffectiveLimit; limit != 5 {
+		t.Errorf("expected the clamp to win, got %d", limit)
+	}
+
+	api.Unclamp()
+	if limit := api.Stats().EffectiveLimit; limit != 15 {
+		t.Errorf("expected the donation to apply once unclamped, got %d", limit)
+	}
+}
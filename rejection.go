@@ -0,0 +1,152 @@
+package loadshedder
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NewJSONRejectionHandler creates a rejection handler like
+// NewAdaptiveRejectionHandler, with a body API clients can parse, selected
+// by the Accept header of the request:
+//   - application/problem+json: a problem details object (RFC 7807)
+//   - application/json: a JSON object
+//   - otherwise: the plain text of NewAdaptiveRejectionHandler
+//
+// Both JSON bodies carry the retry_after seconds, the utilization (running
+// and waiting requests over the effective limit) and the machine-readable
+// reason, Stats.RejectReason (e.g. "queue_full", "queue_timeout").
+func NewJSONRejectionHandler(minRetryAfter, maxRetryAfter time.Duration) RejectionHandler {
+	if minRetryAfter < 0 || maxRetryAfter < minRetryAfter {
+		panic("loadshedder: Retry-After bounds must be non-negative and ordered")
+	}
+	return func(stats Stats) http.HandlerFunc {
+		seconds := retryAfterSeconds(stats, minRetryAfter, maxRetryAfter)
+		retryAfter := strconv.Itoa(seconds)
+		utilization := 0.0
+		if stats.EffectiveLimit > 0 {
+			utilization = math.Round(float64(stats.Running+stats.Waiting)/float64(stats.EffectiveLimit)*100) / 100
+		}
+
+		return func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Retry-After", retryAfter)
+			w.Header().Add("Vary", "Accept")
+
+			body := rejectionBody{Reason: stats.RejectReason, RetryAfter: seconds, Utilization: utilization}
+			switch negotiateRejection(r.Header.Values("Accept")) {
+			case mediaProblemJSON:
+				body.Type = "about:blank"
+				body.Title = http.StatusText(http.StatusTooManyRequests)
+				body.Status = http.StatusTooManyRequests
+				body.Detail = rejectionDetails[stats.RejectReason]
+				writeRejectionJSON(w, mediaProblemJSON, body)
+			case mediaJSON:
+				body.Error = http.StatusText(http.StatusTooManyRequests)
+				writeRejectionJSON(w, mediaJSON, body)
+			default:
+				w.WriteHeader(http.StatusTooManyRequests)
+				_, _ = w.Write([]byte("Too Many Requests\n"))
+			}
+		}
+	}
+}
+
+// rejectionBody is the body of both JSON rejections: Error for
+// application/json, the problem details members for application/problem+json.
+type rejectionBody struct {
+	Error  string `json:"error,omitempty"`
+	Type   string `json:"type,omitempty"`
+	Title  string `json:"title,omitempty"`
+	Status int    `json:"status,omitempty"`
+	Detail string `json:"detail,omitempty"`
+
+	Reason      RejectReason `json:"reason"`
+	RetryAfter  int          `json:"retry_after"`
+	Utilization float64      `json:"utilization"`
+}
+
+var rejectionDetails = map[RejectReason]string{
+	RejectQueueFull:    "The server is at capacity.",
+	RejectQueueTimeout: "The request timed out waiting for capacity.",
+	RejectCanceled:     "The request was canceled while waiting for capacity.",
+	RejectDeadline:     "The request deadline is earlier than the expected wait for capacity.",
+	RejectDropped:      "The request was dropped from a standing queue.",
+	RejectStopped:      "The server is not admitting requests.",
+}
+
+func writeRejectionJSON(w http.ResponseWriter, contentType string, body rejectionBody) {
+	data, _ := json.Marshal(body) // cannot fail
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusTooManyRequests)
+	_, _ = w.Write(append(data, '\n'))
+}
+
+const (
+	mediaText        = "text/plain"
+	mediaJSON        = "application/json"
+	mediaProblemJSON = "application/problem+json"
+)
+
+// negotiateRejection returns the media type of the rejection preferred by
+// the Accept header: the highest quality wins, then the most specific media
+// range, then plain text, so "*/*" keeps the plain text.
+func negotiateRejection(accept []string) string {
+	offers := []string{mediaText, mediaJSON, mediaProblemJSON}
+
+	best, bestQuality, bestSpecificity := mediaText, 0.0, -1
+	for _, offer := range offers {
+		quality, specificity := acceptQuality(accept, offer)
+		if quality > bestQuality || (quality == bestQuality && quality > 0 && specificity > bestSpecificity) {
+			best, bestQuality, bestSpecificity = offer, quality, specificity
+		}
+	}
+	return best
+}
+
+// acceptQuality returns the quality of the media type in the Accept header,
+// from its most specific media range, with the specificity of the range: 2
+// for type/subtype, 1 for type/*, 0 for */*, -1 without match.
+func acceptQuality(accept []string, media string) (quality float64, specificity int) {
+	mediaType, _, _ := strings.Cut(media, "/")
+	specificity = -1
+
+	for _, header := range accept {
+		for _, part := range strings.Split(header, ",") {
+			mediaRange, params, _ := strings.Cut(part, ";")
+			mediaRange = strings.ToLower(strings.TrimSpace(mediaRange))
+
+			s := -1
+			switch mediaRange {
+			case media:
+				s = 2
+			case mediaType + "/*":
+				s = 1
+			case "*/*":
+				s = 0
+			}
+			if s <= specificity {
+				continue
+			}
+			quality, specificity = acceptParamQuality(params), s
+		}
+	}
+	return quality, specificity
+}
+
+// acceptParamQuality returns the q parameter of a media range, 1 by default.
+func acceptParamQuality(params string) float64 {
+	for _, param := range strings.Split(params, ";") {
+		name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if strings.EqualFold(name, "q") {
+			q, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return 0
+			}
+			return min(max(q, 0), 1)
+		}
+	}
+	return 1
+}
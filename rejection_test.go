@@ -0,0 +1,87 @@
+package loadshedder
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewJSONRejectionHandler(t *testing.T) {
+	handler := NewJSONRejectionHandler(time.Second, time.Minute)
+	stats := Stats{Running: 4, Waiting: 2, EffectiveLimit: 4, RetryAfter: 2500 * time.Millisecond, RejectReason: RejectQueueFull}
+
+	tests := []struct {
+		accept      string
+		contentType string
+		expected    string
+	}{
+		{"", "", "Too Many Requests\n"},
+		{"*/*", "", "Too Many Requests\n"},
+		{"text/html", "", "Too Many Requests\n"},
+		{"application/json", "application/json",
+			`{"error":"Too Many Requests","reason":"queue_full","retry_after":3,"utilization":1.5}` + "\n"},
+		{"application/problem+json", "application/problem+json",
+			`{"type":"about:blank","title":"Too Many Requests","status":429,"detail":"The server is at capacity.","reason":"queue_full","retry_after":3,"utilization":1.5}` + "\n"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+		r.Header.Set("Accept", tt.accept)
+		rec := httptest.NewRecorder()
+		handler(stats).ServeHTTP(rec, r)
+
+		if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "3" {
+			t.Errorf("Accept %q: expected a 429 with Retry-After: 3, got %d and %q", tt.accept, rec.Code, rec.Header().Get("Retry-After"))
+		}
+		if got := rec.Header().Get("Content-Type"); tt.contentType != "" && got != tt.contentType {
+			t.Errorf("Accept %q: expected Content-Type %q, got %q", tt.accept, tt.contentType, got)
+		}
+		if rec.Body.String() != tt.expected {
+			t.Errorf("Accept %q: expected body %q, got %q", tt.accept, tt.expected, rec.Body.String())
+		}
+	}
+}
+
+func TestNegotiateRejection(t *testing.T) {
+	tests := map[string]string{
+		"":                                  mediaText,
+		"*/*":                               mediaText,
+		"application/*":                     mediaJSON,
+		"Application/JSON":                  mediaJSON,
+		"application/json, */*;q=0.1":       mediaJSON,
+		"application/json;q=0.5, text/*":    mediaText,
+		"application/json;q=0.5, */*":       mediaText,
+		"application/problem+json, */*":     mediaProblemJSON,
+		"application/json;q=0, */*":         mediaText,
+		"text/plain;q=0, application/*;q=1": mediaJSON,
+		"application/problem+json;q=x":      mediaText,
+	}
+
+	for accept, expected := range tests {
+		if got := negotiateRejection([]string{accept}); got != expected {
+			t.Errorf("Accept %q: expected %q, got %q", accept, expected, got)
+		}
+	}
+}
+
+func TestMiddleware_JSONRejectionStopped(t *testing.T) {
+	ls := New(Config{Limit: 1})
+	mw := NewMiddleware(ls, nil, NewJSONRejectionHandler(time.Second, time.Minute))
+	ls.Drain(context.Background())
+
+	r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	r.Header.Set("Accept", "application/json")
+	rec := httptest.NewRecorder()
+	mw.Handler(http.NotFoundHandler()).ServeHTTP(rec, r)
+
+	var body struct{ Reason string }
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Reason != "stopped" {
+		t.Errorf("expected the stopped reason, got %q", body.Reason)
+	}
+}
@@ -0,0 +1,63 @@
+package loadshedder
+
+// RejectReason tells why an acquisition was rejected, see Stats.RejectReason
+// and Token.RejectReason.
+type RejectReason int
+
+const (
+	// RejectNone is the reason of the accepted acquisitions.
+	RejectNone RejectReason = iota
+
+	// RejectQueueFull is a hard rejection: no free slot and no room in the
+	// waiting queue, or no free slot for an acquisition not allowed to wait
+	// (WithNoWait, Config.DeadlineBands). The rejections of the priority
+	// admission and of EnableChaos are hard rejections too.
+	RejectQueueFull
+
+	// RejectQueueTimeout is an acquisition that waited for a slot for its
+	// maximum wait (WithMaxWait, Config.MaxQueueWait, Config.DeadlineBands).
+	RejectQueueTimeout
+
+	// RejectCanceled is an acquisition whose context was done, before or
+	// while waiting for a slot: the client hung up or its deadline expired.
+	RejectCanceled
+
+	// RejectDeadline is an acquisition whose deadline was earlier than its
+	// projected wait, see Config.DeadlineAware.
+	RejectDeadline
+
+	// RejectDropped is an acquisition dropped from a standing queue, see
+	// Config.CoDelTarget.
+	RejectDropped
+
+	// RejectStopped is an acquisition rejected because the loadshedder is
+	// not admitting: draining (Drain), paused (Pause) or starting with
+	// StartRejectAll.
+	RejectStopped
+)
+
+func (r RejectReason) String() string {
+	switch r {
+	case RejectNone:
+		return "none"
+	case RejectQueueFull:
+		return "queue_full"
+	case RejectQueueTimeout:
+		return "queue_timeout"
+	case RejectCanceled:
+		return "canceled"
+	case RejectDeadline:
+		return "deadline"
+	case RejectDropped:
+		return "dropped"
+	case RejectStopped:
+		return "stopped"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (r RejectReason) MarshalText() ([]byte, error) {
+	return []byte(r.String()), nil
+}
@@ -0,0 +1,89 @@
+package loadshedder
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLoadshedder_RejectReason(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name     string
+		cfg      Config
+		setup    func(*Loadshedder)
+		ctx      func() (context.Context, context.CancelFunc)
+		opts     []AcquireOption
+		expected RejectReason
+	}{
+		{name: "queue full", cfg: Config{Limit: 1}, expected: RejectQueueFull},
+		{name: "no wait", cfg: Config{Limit: 1, WaitingLimit: 1}, opts: []AcquireOption{WithNoWait()}, expected: RejectQueueFull},
+		{name: "queue timeout", cfg: Config{Limit: 1, WaitingLimit: 1}, opts: []AcquireOption{WithMaxWait(5 * time.Millisecond)}, expected: RejectQueueTimeout},
+		{name: "canceled", cfg: Config{Limit: 1, WaitingLimit: 1}, ctx: func() (context.Context, context.CancelFunc) {
+			return canceled, func() {}
+		}, expected: RejectCanceled},
+		{name: "request deadline", cfg: Config{Limit: 1, WaitingLimit: 1}, ctx: func() (context.Context, context.CancelFunc) {
+			return context.WithTimeout(context.Background(), 5*time.Millisecond)
+		}, opts: []AcquireOption{WithMaxWait(time.Second)}, expected: RejectCanceled},
+		{name: "deadline aware", cfg: Config{Limit: 1, WaitingLimit: 1, DeadlineAware: true}, setup: func(l *Loadshedder) {
+			l.avgDuration.Store(int64(time.Second))
+		}, opts: []AcquireOption{WithMaxWait(5 * time.Millisecond)}, expected: RejectDeadline},
+		{name: "paused", cfg: Config{Limit: 2}, setup: (*Loadshedder).Pause, expected: RejectStopped},
+		{name: "reject all", cfg: Config{Limit: 2, Startup: StartRejectAll}, expected: RejectStopped},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ls := New(tt.cfg)
+			_, held := ls.Acquire(context.Background())
+			defer ls.Release(held)
+			if tt.setup != nil {
+				tt.setup(ls)
+			}
+
+			ctx, cancel := context.Background(), context.CancelFunc(func() {})
+			if tt.ctx != nil {
+				ctx, cancel = tt.ctx()
+			}
+			defer cancel()
+
+			stats, token := ls.Acquire(ctx, tt.opts...)
+			if token.Accepted() {
+				t.Fatal("expected a rejection")
+			}
+			if stats.RejectReason != tt.expected || token.RejectReason() != tt.expected {
+				t.Errorf("expected %v, got %v in the Stats and %v on the token", tt.expected, stats.RejectReason, token.RejectReason())
+			}
+		})
+	}
+}
+
+func TestLoadshedder_RejectReasonAccepted(t *testing.T) {
+	ls := New(Config{Limit: 1})
+	stats, token := ls.Acquire(context.Background())
+	defer ls.Release(token)
+
+	if stats.RejectReason != RejectNone || token.RejectReason() != RejectNone {
+		t.Errorf("expected no reason, got %v and %v", stats.RejectReason, token.RejectReason())
+	}
+}
+
+func TestRejectReason_String(t *testing.T) {
+	expected := map[RejectReason]string{
+		RejectNone:         "none",
+		RejectQueueFull:    "queue_full",
+		RejectQueueTimeout: "queue_timeout",
+		RejectCanceled:     "canceled",
+		RejectDeadline:     "deadline",
+		RejectDropped:      "dropped",
+		RejectStopped:      "stopped",
+		RejectReason(42):   "unknown",
+	}
+	for reason, name := range expected {
+		if reason.String() != name {
+			t.Errorf("expected %q, got %q", name, reason.String())
+		}
+	}
+}
@@ -3,8 +3,114 @@ package loadshedder
 import (
 	"log/slog"
 	"net/http"
+	"net/url"
 )
 
+// The Reporter interface is stable: new data is added as Stats fields rather
+// than as new methods, so existing implementations keep compiling.
+var (
+	_ Reporter = (*NullReporter)(nil)
+	_ Reporter = (*LogReporter)(nil)
+	_ Reporter = (*legacyReporter)(nil)
+)
+
+var _ LegacyReporter = LegacyReporterFuncs{}
+
+// LegacyReporter is the reporter interface of earlier versions, receiving the
+// number of running and waiting requests and the enforced limit.
+// Use AdaptLegacyReporter to pass one to NewMiddleware.
+type LegacyReporter interface {
+	OnAccepted(current, limit int64)
+	OnRejected(current, limit int64)
+}
+
+// AdaptLegacyReporter returns a Reporter calling the LegacyReporter, so
+// integrations written against the earlier interface can be passed to
+// NewMiddleware. A nil reporter returns a NullReporter.
+func AdaptLegacyReporter(reporter LegacyReporter) Reporter {
+	if reporter == nil {
+		return NewNullReporter()
+	}
+	return &legacyReporter{reporter: reporter}
+}
+
+// legacyReporter adapts a LegacyReporter to the Reporter interface.
+type legacyReporter struct {
+	reporter LegacyReporter
+}
+
+func (r *legacyReporter) Accepted(_ *http.Request, stats Stats) {
+	r.reporter.OnAccepted(stats.Running+stats.Waiting, stats.Limit)
+}
+
+func (r *legacyReporter) Rejected(_ *http.Request, stats Stats) {
+	r.reporter.OnRejected(stats.Running+stats.Waiting, stats.Limit)
+}
+
+// LegacyReporterFuncs is a LegacyReporter calling plain callbacks, so
+// observability glue written as functions for the earlier middleware packages
+// keeps working during a migration, see AdaptLegacyReporter. Nil callbacks are
+// skipped.
+type LegacyReporterFuncs struct {
+	AcceptedFunc func(current, limit int64)
+	RejectedFunc func(current, limit int64)
+}
+
+// OnAccepted calls AcceptedFunc.
+func (f LegacyReporterFuncs) OnAccepted(current, limit int64) {
+	if f.AcceptedFunc != nil {
+		f.AcceptedFunc(current, limit)
+	}
+}
+
+// OnRejected calls RejectedFunc.
+func (f LegacyReporterFuncs) OnRejected(current, limit int64) {
+	if f.RejectedFunc != nil {
+		f.RejectedFunc(current, limit)
+	}
+}
+
+// ToLegacyReporter returns a LegacyReporter calling the reporter, the other
+// way around from AdaptLegacyReporter, for code still emitting the legacy calls.
+// The legacy calls carry neither the request nor the details of the Stats:
+// the reporter receives a placeholder GET / request, and Stats with the
+// running and waiting requests split at the limit.
+func ToLegacyReporter(reporter Reporter) LegacyReporter {
+	return &reporterToLegacy{reporter: reporter}
+}
+
+// reporterToLegacy adapts a Reporter to the LegacyReporter interface.
+type reporterToLegacy struct {
+	reporter Reporter
+}
+
+func (r *reporterToLegacy) OnAccepted(current, limit int64) {
+	r.reporter.Accepted(legacyRequest(), legacyStats(current, limit))
+}
+
+func (r *reporterToLegacy) OnRejected(current, limit int64) {
+	r.reporter.Rejected(legacyRequest(), legacyStats(current, limit))
+}
+
+// legacyStats returns the Stats of a legacy reporter call: the requests
+// beyond the limit are waiting.
+func legacyStats(current, limit int64) Stats {
+	running := min(current, limit)
+	return Stats{
+		Running:         running,
+		Waiting:         current - running,
+		Limit:           limit,
+		ConfiguredLimit: limit,
+		EffectiveLimit:  limit,
+	}
+}
+
+// legacyRequest returns the placeholder request of a legacy reporter call,
+// for reporters reading the request (e.g. its route).
+func legacyRequest() *http.Request {
+	return &http.Request{Method: http.MethodGet, URL: &url.URL{Path: "/"}, Header: http.Header{}}
+}
+
 // NullReporter is a no-op Reporter implementation that discards all events.
 // Useful when you don't need observability or want to use external metrics only.
 type NullReporter struct{}
@@ -50,6 +156,7 @@ func (r *LogReporter) Accepted(req *http.Request, stats Stats) {
 		slog.Int64("limit", stats.Limit),
 		slog.Float64("utilization", float64(stats.Running)/float64(stats.Limit)),
 		slog.Duration("wait_time", stats.WaitTime),
+		slog.String("token_id", stats.TokenID.String()),
 	)
 }
 
@@ -65,5 +172,6 @@ func (r *LogReporter) Rejected(req *http.Request, stats Stats) {
 		slog.Int64("limit", stats.Limit),
 		slog.Float64("utilization", float64(stats.Running)/float64(stats.Limit)),
 		slog.Duration("wait_time", stats.WaitTime),
+		slog.String("reason", stats.RejectReason.String()),
 	)
 }
@@ -56,11 +56,14 @@ func TestLogReporter_Rejected(t *testing.T) {
 	reporter := NewLogReporter(logger)
 
 	req := httptest.NewRequest(http.MethodPost, "/api/data", http.NoBody)
-	stats := Stats{Running: 10, Waiting: 5, Limit: 10}
+	stats := Stats{Running: 10, Waiting: 5, Limit: 10, RejectReason: RejectQueueTimeout}
 
 	reporter.Rejected(req, stats)
 
 	output := buf.String()
+	if !strings.Contains(output, `"reason":"queue_timeout"`) {
+		t.Errorf("expected the reason in output, got: %s", output)
+	}
 	if !strings.Contains(output, "Request rejected") {
 		t.Errorf("expected 'Request rejected' in output, got: %s", output)
 	}
@@ -142,3 +145,100 @@ func TestMiddleware_DefaultReporter(t *testing.T) {
 		t.Errorf("expected status 200, got %d", rec.Code)
 	}
 }
+
+type recordingLegacyReporter struct {
+	accepted, rejected [][2]int64
+}
+
+func (r *recordingLegacyReporter) OnAccepted(current, limit int64) {
+	r.accepted = append(r.accepted, [2]int64{current, limit})
+}
+
+func (r *recordingLegacyReporter) OnRejected(current, limit int64) {
+	r.rejected = append(r.rejected, [2]int64{current, limit})
+}
+
+func TestAdaptLegacyReporter(t *testing.T) {
+	if _, ok := AdaptLegacyReporter(nil).(*NullReporter); !ok {
+		t.Error("expected a NullReporter for nil")
+	}
+}
+
+func TestLegacyReporterFuncs(t *testing.T) {
+	var accepted, rejected [][2]int64
+	funcs := LegacyReporterFuncs{
+		AcceptedFunc: func(current, limit int64) { accepted = append(accepted, [2]int64{current, limit}) },
+		RejectedFunc: func(current, limit int64) { rejected = append(rejected, [2]int64{current, limit}) },
+	}
+	mw := NewMiddleware(New(Config{Limit: 1}), AdaptLegacyReporter(funcs), nil)
+
+	handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Rejected while this request holds the only slot
+		mw.Handler(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), r)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	if len(accepted) != 1 || accepted[0] != [2]int64{1, 1} {
+		t.Errorf("expected OnAccepted(1, 1), got %v", accepted)
+	}
+	if len(rejected) != 1 || rejected[0] != [2]int64{2, 1} {
+		t.Errorf("expected OnRejected(2, 1), got %v", rejected)
+	}
+
+	// Nil callbacks are skipped
+	LegacyReporterFuncs{}.OnAccepted(1, 1)
+}
+
+func TestToLegacyReporter(t *testing.T) {
+	reporter := &statsRecordingReporter{}
+	legacy := ToLegacyReporter(reporter)
+
+	legacy.OnAccepted(3, 10)
+	legacy.OnRejected(12, 10)
+
+	if len(reporter.accepted) != 1 || reporter.accepted[0].Running != 3 || reporter.accepted[0].Waiting != 0 || reporter.accepted[0].Limit != 10 {
+		t.Errorf("unexpected accepted stats: %+v", reporter.accepted)
+	}
+	if len(reporter.rejected) != 1 || reporter.rejected[0].Running != 10 || reporter.rejected[0].Waiting != 2 || reporter.rejected[0].EffectiveLimit != 10 {
+		t.Errorf("unexpected rejected stats: %+v", reporter.rejected)
+	}
+
+	// A round trip gives the legacy calls back
+	recorder := &recordingLegacyReporter{}
+	ToLegacyReporter(AdaptLegacyReporter(recorder)).OnRejected(8, 5)
+	if len(recorder.rejected) != 1 || recorder.rejected[0] != [2]int64{8, 5} {
+		t.Errorf("expected OnRejected(8, 5), got %v", recorder.rejected)
+	}
+}
+
+func TestAdaptLegacyReporter_Middleware(t *testing.T) {
+	legacy := &recordingLegacyReporter{}
+	ls := New(Config{Limit: 1})
+	mw := NewMiddleware(ls, AdaptLegacyReporter(legacy), nil)
+
+	blocker := make(chan struct{})
+	started := make(chan struct{})
+	handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-blocker
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	}()
+	<-started
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	close(blocker)
+	<-done
+
+	if len(legacy.accepted) != 1 || legacy.accepted[0] != [2]int64{1, 1} {
+		t.Errorf("expected OnAccepted(1, 1), got %v", legacy.accepted)
+	}
+	// Rejection stats include the rejected request
+	if len(legacy.rejected) != 1 || legacy.rejected[0] != [2]int64{2, 1} {
+		t.Errorf("expected OnRejected(2, 1), got %v", legacy.rejected)
+	}
+}
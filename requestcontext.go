@@ -0,0 +1,28 @@
+package loadshedder
+
+import "context"
+
+type statsContextKey struct{}
+
+// ContextWithStats returns a copy of ctx carrying the Stats of an
+// acquisition. The middleware sets the Stats returned by Acquire in the
+// context of its requests, admitted or rejected.
+func ContextWithStats(ctx context.Context, stats Stats) context.Context {
+	return context.WithValue(ctx, statsContextKey{}, stats)
+}
+
+// StatsFromContext returns the Stats set with ContextWithStats, so handlers
+// and logging middleware can read the utilization and the wait time of the
+// request without a Reporter. Returns false if the context carries none.
+func StatsFromContext(ctx context.Context) (Stats, bool) {
+	stats, ok := ctx.Value(statsContextKey{}).(Stats)
+	return stats, ok
+}
+
+// TokenFromContext returns the token set with ContextWithToken, or nil. The
+// middleware sets it for its requests: Accepted reports whether the request
+// was admitted.
+func TokenFromContext(ctx context.Context) *Token {
+	t, _ := ctx.Value(tokenContextKey{}).(*Token)
+	return t
+}
@@ -0,0 +1,74 @@
+package loadshedder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware_StatsInContext(t *testing.T) {
+	ls := New(Config{Limit: 1})
+
+	var handled Stats
+	var handledToken *Token
+	reporter := &statsRecordingReporter{}
+	handler := NewMiddleware(ls, reporter, nil).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ok bool
+		if handled, ok = StatsFromContext(r.Context()); !ok {
+			t.Error("expected Stats in the handler context")
+		}
+		handledToken = TokenFromContext(r.Context())
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	if len(reporter.accepted) != 1 || handled != reporter.accepted[0] {
+		t.Errorf("expected the reported Stats in the handler context, got %+v and %+v", handled, reporter.accepted)
+	}
+	if handledToken == nil || !handledToken.Accepted() || handledToken.ID() != handled.TokenID {
+		t.Errorf("expected the accepted token in the handler context, got %+v", handledToken)
+	}
+}
+
+func TestMiddleware_StatsInContext_Rejected(t *testing.T) {
+	ls := New(Config{Limit: 1})
+	_, held := ls.Acquire(context.Background())
+	defer ls.Release(held)
+
+	var rejected Stats
+	var rejectedToken *Token
+	rejectionHandler := func(Stats) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			rejected, _ = StatsFromContext(r.Context())
+			rejectedToken = TokenFromContext(r.Context())
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}
+	handler := NewMiddleware(ls, nil, rejectionHandler).Handler(http.NotFoundHandler())
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	if rejected.Running != 1 || rejected.Limit != 1 || rejected.TokenID != 0 {
+		t.Errorf("expected the rejection Stats in the context, got %+v", rejected)
+	}
+	if rejectedToken == nil || rejectedToken.Accepted() {
+		t.Errorf("expected the rejected token in the context, got %+v", rejectedToken)
+	}
+	ctx := ContextWithToken(context.Background(), rejectedToken)
+	if OnShed(ctx, func() {}) || Severity(ctx) != 0 {
+		t.Error("expected a rejected token to be ignored by OnShed and Severity")
+	}
+}
+
+func TestStatsFromContext_Empty(t *testing.T) {
+	if _, ok := StatsFromContext(context.Background()); ok {
+		t.Error("expected no Stats in an empty context")
+	}
+	if TokenFromContext(context.Background()) != nil {
+		t.Error("expected no token in an empty context")
+	}
+	if TokenFromContext(ContextWithTokenID(context.Background(), 42)) != nil {
+		t.Error("expected no token in a context carrying only its ID")
+	}
+}
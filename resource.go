@@ -0,0 +1,146 @@
+package loadshedder
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// ResourceCollector measures the CPU and memory available to the process, for
+// the resource-based Signals. The platform collector, see NewResourceCollector,
+// reads the limits of the container when there is one; implement it to
+// measure other resources, e.g. a VM or a sidecar.
+// Implementations must be safe for concurrent use.
+type ResourceCollector interface {
+	// CPU returns the cumulative CPU time used and the CPUs available, or
+	// false if not supported.
+	CPU() (CPUUsage, bool)
+
+	// Memory returns the memory used and its limit, or false if not supported.
+	Memory() (MemoryUsage, bool)
+}
+
+// CPUUsage is a measure of a ResourceCollector.
+type CPUUsage struct {
+	Used time.Duration // Cumulative CPU time used
+	CPUs float64       // CPUs available, e.g. the container quota (fractional)
+}
+
+// MemoryUsage is a measure of a ResourceCollector.
+type MemoryUsage struct {
+	Used  uint64 // Bytes used
+	Limit uint64 // Bytes available, e.g. the container limit (0 if unlimited)
+}
+
+// NewResourceCollector returns the collector of the platform:
+//   - Linux: the cgroup (v2, or v1) of the process when it has a CPU quota or
+//     a memory limit, as in a container; the process and host otherwise
+//   - macOS: the process CPU time, and the memory mapped by the Go runtime
+//     against the physical memory
+//   - Windows: the process CPU time and working set, against the physical memory
+//   - elsewhere: nothing is supported, and the signals report no pressure.
+func NewResourceCollector() ResourceCollector {
+	if c := detectCgroup(cgroupRoot, procSelfCgroup); c != nil {
+		return c
+	}
+	return processCollector{}
+}
+
+// processCollector measures the process itself: the CPUs it can use are
+// GOMAXPROCS.
+type processCollector struct{}
+
+func (processCollector) CPU() (CPUUsage, bool) {
+	used, ok := processCPUTime()
+	return CPUUsage{Used: used, CPUs: float64(runtime.GOMAXPROCS(0))}, ok
+}
+
+func (processCollector) Memory() (MemoryUsage, bool) {
+	return processMemory()
+}
+
+// NewCollectorCPUSignal creates a CPU signal measuring the utilization
+// reported by the collector, e.g. NewResourceCollector() to measure against
+// the CPU quota of the container rather than GOMAXPROCS.
+// If collector is nil, NewResourceCollector is used.
+func NewCollectorCPUSignal(threshold float64, collector ResourceCollector) *CPUSignal {
+	if threshold <= 0 || threshold > 1 {
+		panic("loadshedder: CPUSignal threshold must be in (0, 1]")
+	}
+	if collector == nil {
+		collector = NewResourceCollector()
+	}
+
+	s := &CPUSignal{threshold: threshold, collector: collector}
+	s.Pressure() // initialize the baseline
+
+	return s
+}
+
+// MemorySignal is a Signal measuring the memory used against the limit
+// reported by a ResourceCollector, e.g. the memory limit of the container, so
+// the shedder backs off before the OOM killer steps in. Unlike
+// MemoryLimitSignal, it covers the memory not managed by the Go runtime (cgo,
+// page cache charged to the container).
+// Without a memory limit, the pressure is always 0.
+type MemorySignal struct {
+	threshold float64
+	collector ResourceCollector
+}
+
+// NewMemorySignal creates a memory signal reporting overload when the memory
+// used reaches the threshold fraction (e.g. 0.9) of the limit.
+// If collector is nil, NewResourceCollector is used.
+func NewMemorySignal(threshold float64, collector ResourceCollector) *MemorySignal {
+	if threshold <= 0 || threshold > 1 {
+		panic("loadshedder: MemorySignal threshold must be in (0, 1]")
+	}
+	if collector == nil {
+		collector = NewResourceCollector()
+	}
+	return &MemorySignal{threshold: threshold, collector: collector}
+}
+
+// Name returns "memory".
+func (s *MemorySignal) Name() string {
+	return "memory"
+}
+
+// Pressure returns the memory used relative to the threshold of the limit.
+func (s *MemorySignal) Pressure() float64 {
+	usage, ok := s.collector.Memory()
+	if !ok || usage.Limit == 0 {
+		return 0
+	}
+	return float64(usage.Used) / (float64(usage.Limit) * s.threshold)
+}
+
+// cpuUtilization tracks the CPU utilization between samples of a collector.
+type cpuUtilization struct {
+	mu       sync.Mutex
+	lastWall time.Time
+	lastCPU  time.Duration
+}
+
+// sample returns the utilization of the available CPUs since the previous
+// sample, 0 for the first one.
+func (u *cpuUtilization) sample(collector ResourceCollector) float64 {
+	usage, ok := collector.CPU()
+	if !ok || usage.CPUs <= 0 {
+		return 0
+	}
+	now := time.Now()
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	wall := now.Sub(u.lastWall)
+	used := usage.Used - u.lastCPU
+	first := u.lastWall.IsZero()
+	u.lastWall, u.lastCPU = now, usage.Used
+
+	if first || wall <= 0 || used < 0 {
+		return 0
+	}
+	return float64(used) / (float64(wall) * usage.CPUs)
+}
@@ -0,0 +1,214 @@
+package loadshedder
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	cgroupRoot     = "/sys/fs/cgroup"
+	procSelfCgroup = "/proc/self/cgroup"
+
+	// cgroupV1Unlimited is the smallest memory.limit_in_bytes treated as
+	// unlimited: cgroup v1 reports "no limit" as the largest page-aligned
+	// int64.
+	cgroupV1Unlimited = 1 << 62
+)
+
+// detectCgroup returns the collector of the cgroup of the process, or nil if
+// the process runs without a cgroup CPU quota nor memory limit.
+func detectCgroup(root, procCgroup string) ResourceCollector {
+	memberships := readCgroupMemberships(procCgroup)
+
+	var c ResourceCollector
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err == nil {
+		c = cgroupV2Collector{dir: cgroupDir(root, memberships[""], "cpu.max")}
+	} else if _, err := os.Stat(filepath.Join(root, "memory")); err == nil {
+		c = cgroupV1Collector{
+			cpuDir:    cgroupDir(cgroupV1Mount(root, "cpu,cpuacct", "cpuacct,cpu", "cpuacct"), memberships["cpuacct"], "cpuacct.usage"),
+			memoryDir: cgroupDir(filepath.Join(root, "memory"), memberships["memory"], "memory.usage_in_bytes"),
+		}
+	} else {
+		return nil
+	}
+
+	cpu, cpuOK := c.CPU()
+	memory, memoryOK := c.Memory()
+	if (cpuOK && cpu.CPUs < float64(runtime.NumCPU())) || (memoryOK && memory.Limit > 0) {
+		return c
+	}
+	return nil
+}
+
+// readCgroupMemberships parses /proc/self/cgroup: the path of the process in
+// each hierarchy, by controller ("" for the unified cgroup v2 hierarchy).
+func readCgroupMemberships(path string) map[string]string {
+	memberships := map[string]string{}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return memberships
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// hierarchy-ID:controller-list:cgroup-path
+		fields := strings.SplitN(scanner.Text(), ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		if fields[1] == "" {
+			memberships[""] = fields[2]
+			continue
+		}
+		for _, controller := range strings.Split(fields[1], ",") {
+			memberships[controller] = fields[2]
+		}
+	}
+	return memberships
+}
+
+// cgroupV1Mount returns the first existing mount of a cgroup v1 controller,
+// whose name depends on the distribution.
+func cgroupV1Mount(root string, names ...string) string {
+	for _, name := range names {
+		if _, err := os.Stat(filepath.Join(root, name)); err == nil {
+			return filepath.Join(root, name)
+		}
+	}
+	return filepath.Join(root, names[0])
+}
+
+// cgroupDir returns the directory of the cgroup of the process in the
+// hierarchy mounted at mount. In a container with its own cgroup namespace,
+// the mount is the cgroup itself and the path is not visible: the mount is
+// used when the path does not have the file.
+func cgroupDir(mount, path, file string) string {
+	if path != "" {
+		dir := filepath.Join(mount, path)
+		if _, err := os.Stat(filepath.Join(dir, file)); err == nil {
+			return dir
+		}
+	}
+	return mount
+}
+
+// cgroupV2Collector reads the unified cgroup v2 hierarchy.
+type cgroupV2Collector struct {
+	dir string
+}
+
+func (c cgroupV2Collector) CPU() (CPUUsage, bool) {
+	// cpu.stat has the cumulative usage_usec, among other counters
+	stat, ok := readCgroupFile(filepath.Join(c.dir, "cpu.stat"))
+	if !ok {
+		return CPUUsage{}, false
+	}
+	var used time.Duration
+	found := false
+	for line := range strings.Lines(stat) {
+		if value, ok := strings.CutPrefix(line, "usage_usec "); ok {
+			usec, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+			if err != nil {
+				return CPUUsage{}, false
+			}
+			used, found = time.Duration(usec)*time.Microsecond, true
+			break
+		}
+	}
+	if !found {
+		return CPUUsage{}, false
+	}
+
+	cpus := float64(runtime.NumCPU())
+	// cpu.max is "$MAX $PERIOD", with "max" for no quota
+	if limit, ok := readCgroupFile(filepath.Join(c.dir, "cpu.max")); ok {
+		if fields := strings.Fields(limit); len(fields) == 2 && fields[0] != "max" {
+			quota, err1 := strconv.ParseFloat(fields[0], 64)
+			period, err2 := strconv.ParseFloat(fields[1], 64)
+			if err1 == nil && err2 == nil && quota > 0 && period > 0 {
+				cpus = min(cpus, quota/period)
+			}
+		}
+	}
+
+	return CPUUsage{Used: used, CPUs: cpus}, true
+}
+
+func (c cgroupV2Collector) Memory() (MemoryUsage, bool) {
+	used, ok := readCgroupUint(filepath.Join(c.dir, "memory.current"))
+	if !ok {
+		return MemoryUsage{}, false
+	}
+	usage := MemoryUsage{Used: used}
+	if limit, ok := readCgroupUint(filepath.Join(c.dir, "memory.max")); ok {
+		usage.Limit = limit // "max" does not parse: unlimited
+	}
+	return usage, true
+}
+
+// cgroupV1Collector reads the cpuacct, cpu and memory cgroup v1 controllers.
+type cgroupV1Collector struct {
+	cpuDir    string
+	memoryDir string
+}
+
+func (c cgroupV1Collector) CPU() (CPUUsage, bool) {
+	used, ok := readCgroupUint(filepath.Join(c.cpuDir, "cpuacct.usage"))
+	if !ok {
+		return CPUUsage{}, false
+	}
+
+	cpus := float64(runtime.NumCPU())
+	quota, quotaOK := readCgroupInt(filepath.Join(c.cpuDir, "cpu.cfs_quota_us"))
+	period, periodOK := readCgroupInt(filepath.Join(c.cpuDir, "cpu.cfs_period_us"))
+	if quotaOK && periodOK && quota > 0 && period > 0 { // -1 for no quota
+		cpus = min(cpus, float64(quota)/float64(period))
+	}
+
+	return CPUUsage{Used: time.Duration(used), CPUs: cpus}, true
+}
+
+func (c cgroupV1Collector) Memory() (MemoryUsage, bool) {
+	used, ok := readCgroupUint(filepath.Join(c.memoryDir, "memory.usage_in_bytes"))
+	if !ok {
+		return MemoryUsage{}, false
+	}
+	usage := MemoryUsage{Used: used}
+	if limit, ok := readCgroupUint(filepath.Join(c.memoryDir, "memory.limit_in_bytes")); ok && limit < cgroupV1Unlimited {
+		usage.Limit = limit
+	}
+	return usage, true
+}
+
+func readCgroupFile(path string) (string, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+func readCgroupUint(path string) (uint64, bool) {
+	data, ok := readCgroupFile(path)
+	if !ok {
+		return 0, false
+	}
+	value, err := strconv.ParseUint(strings.TrimSpace(data), 10, 64)
+	return value, err == nil
+}
+
+func readCgroupInt(path string) (int64, bool) {
+	data, ok := readCgroupFile(path)
+	if !ok {
+		return 0, false
+	}
+	value, err := strconv.ParseInt(strings.TrimSpace(data), 10, 64)
+	return value, err == nil
+}
@@ -0,0 +1,29 @@
+package loadshedder
+
+import (
+	"encoding/binary"
+	"runtime/metrics"
+	"syscall"
+)
+
+// processMemory returns the memory mapped by the Go runtime, minus the heap
+// returned to the OS, against the physical memory (hw.memsize). The memory
+// allocated outside of the Go runtime (cgo) is not accounted.
+func processMemory() (MemoryUsage, bool) {
+	samples := []metrics.Sample{{Name: memoryTotalMetric}, {Name: heapReleasedMetric}}
+	metrics.Read(samples)
+	for _, sample := range samples {
+		if sample.Value.Kind() != metrics.KindUint64 {
+			return MemoryUsage{}, false
+		}
+	}
+
+	usage := MemoryUsage{Used: samples[0].Value.Uint64() - samples[1].Value.Uint64()}
+	// syscall.Sysctl returns the raw value, trimmed of its trailing zero bytes
+	if memsize, err := syscall.Sysctl("hw.memsize"); err == nil && len(memsize) <= 8 {
+		var raw [8]byte
+		copy(raw[:], memsize)
+		usage.Limit = binary.LittleEndian.Uint64(raw[:])
+	}
+	return usage, true
+}
@@ -0,0 +1,49 @@
+package loadshedder
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// processMemory returns the resident memory of the process, from
+// /proc/self/statm, against the memory of the host, from /proc/meminfo.
+func processMemory() (MemoryUsage, bool) {
+	statm, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		return MemoryUsage{}, false
+	}
+	// size resident shared text lib data dt, in pages
+	fields := strings.Fields(string(statm))
+	if len(fields) < 2 {
+		return MemoryUsage{}, false
+	}
+	resident, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return MemoryUsage{}, false
+	}
+
+	usage := MemoryUsage{Used: resident * uint64(os.Getpagesize())}
+	usage.Limit, _ = hostMemory()
+	return usage, true
+}
+
+// hostMemory returns the MemTotal of /proc/meminfo.
+func hostMemory() (uint64, bool) {
+	f, err := os.Open("/proc/meminfo")
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// MemTotal:       16318172 kB
+		if value, ok := strings.CutPrefix(scanner.Text(), "MemTotal:"); ok {
+			kb, err := strconv.ParseUint(strings.TrimSuffix(strings.TrimSpace(value), " kB"), 10, 64)
+			return kb * 1024, err == nil
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,7 @@
+//go:build !linux && !darwin && !windows
+
+package loadshedder
+
+func processMemory() (MemoryUsage, bool) {
+	return MemoryUsage{}, false
+}
@@ -0,0 +1,154 @@
+package loadshedder
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+type fakeCollector struct {
+	cpu    CPUUsage
+	memory MemoryUsage
+	ok     bool
+}
+
+func (c *fakeCollector) CPU() (CPUUsage, bool)       { return c.cpu, c.ok }
+func (c *fakeCollector) Memory() (MemoryUsage, bool) { return c.memory, c.ok }
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCgroupV2Collector(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"cgroup.controllers":   "cpu memory\n",
+		"app/cpu.stat":         "usage_usec 1500000\nuser_usec 1000000\nsystem_usec 500000\n",
+		"app/cpu.max":          "50000 100000\n",
+		"app/memory.current":   "268435456\n",
+		"app/memory.max":       "536870912\n",
+		"proc/self/cgroup":     "0::/app\n",
+		"other/memory.current": "1\n",
+	})
+
+	c := detectCgroup(root, filepath.Join(root, "proc/self/cgroup"))
+	if c == nil {
+		t.Fatal("expected a cgroup v2 collector")
+	}
+
+	cpu, ok := c.CPU()
+	if !ok || cpu.Used != 1500*time.Millisecond || cpu.CPUs != min(0.5, float64(runtime.NumCPU())) {
+		t.Errorf("unexpected CPU usage: %+v (%v)", cpu, ok)
+	}
+	memory, ok := c.Memory()
+	if !ok || memory.Used != 256<<20 || memory.Limit != 512<<20 {
+		t.Errorf("unexpected memory usage: %+v (%v)", memory, ok)
+	}
+
+	// Without limits, the process is measured
+	writeFiles(t, root, map[string]string{"app/cpu.max": "max 100000\n", "app/memory.max": "max\n"})
+	if c := detectCgroup(root, filepath.Join(root, "proc/self/cgroup")); c != nil {
+		t.Errorf("expected no collector without limits, got %+v", c)
+	}
+}
+
+func TestCgroupV1Collector(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"cpu,cpuacct/cpuacct.usage":     "2000000000\n",
+		"cpu,cpuacct/cpu.cfs_quota_us":  "-1\n",
+		"cpu,cpuacct/cpu.cfs_period_us": "100000\n",
+		"memory/memory.usage_in_bytes":  "1048576\n",
+		"memory/memory.limit_in_bytes":  "4194304\n",
+	})
+
+	// The cgroup path is not visible in the namespace of the container
+	c := detectCgroup(root, filepath.Join(root, "missing"))
+	if c == nil {
+		t.Fatal("expected a cgroup v1 collector")
+	}
+
+	cpu, ok := c.CPU()
+	if !ok || cpu.Used != 2*time.Second || cpu.CPUs != float64(runtime.NumCPU()) {
+		t.Errorf("unexpected CPU usage: %+v (%v)", cpu, ok)
+	}
+	memory, ok := c.Memory()
+	if !ok || memory.Used != 1<<20 || memory.Limit != 4<<20 {
+		t.Errorf("unexpected memory usage: %+v (%v)", memory, ok)
+	}
+
+	writeFiles(t, root, map[string]string{"memory/memory.limit_in_bytes": "9223372036854771712\n"})
+	if c := detectCgroup(root, filepath.Join(root, "missing")); c != nil {
+		t.Errorf("expected no collector without limits, got %+v", c)
+	}
+}
+
+func TestReadCgroupMemberships(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"cgroup": "12:memory:/docker/abc\n4:cpu,cpuacct:/docker/abc\n0::/system.slice\n"})
+
+	memberships := readCgroupMemberships(filepath.Join(dir, "cgroup"))
+	if memberships["memory"] != "/docker/abc" || memberships["cpuacct"] != "/docker/abc" || memberships[""] != "/system.slice" {
+		t.Errorf("unexpected memberships: %v", memberships)
+	}
+}
+
+func TestCollectorCPUSignal(t *testing.T) {
+	collector := &fakeCollector{cpu: CPUUsage{CPUs: 2}, ok: true}
+	signal := NewCollectorCPUSignal(0.5, collector)
+
+	time.Sleep(10 * time.Millisecond)
+	collector.cpu.Used = 20 * time.Millisecond // both CPUs busy at most
+
+	// At most full utilization, against a 0.5 threshold
+	if pressure := signal.Pressure(); pressure <= 0 || pressure > 2 {
+		t.Errorf("expected a pressure in (0, 2], got %f", pressure)
+	}
+
+	// Unsupported collectors report no pressure
+	collector.ok = false
+	if pressure := signal.Pressure(); pressure != 0 {
+		t.Errorf("expected no pressure, got %f", pressure)
+	}
+}
+
+func TestMemorySignal(t *testing.T) {
+	collector := &fakeCollector{memory: MemoryUsage{Used: 450, Limit: 1000}, ok: true}
+	signal := NewMemorySignal(0.9, collector)
+	if name := signal.Name(); name != "memory" {
+		t.Errorf("expected name memory, got %q", name)
+	}
+	if pressure := signal.Pressure(); pressure != 0.5 {
+		t.Errorf("expected pressure 0.5, got %f", pressure)
+	}
+
+	collector.memory.Limit = 0
+	if pressure := signal.Pressure(); pressure != 0 {
+		t.Errorf("expected no pressure without a limit, got %f", pressure)
+	}
+}
+
+func TestNewResourceCollector(t *testing.T) {
+	c := NewResourceCollector()
+	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" && runtime.GOOS != "windows" {
+		t.Skip("resources are only supported on Linux, macOS and Windows")
+	}
+
+	if cpu, ok := c.CPU(); !ok || cpu.CPUs <= 0 {
+		t.Errorf("expected the CPU usage, got %+v (%v)", cpu, ok)
+	}
+	if memory, ok := c.Memory(); !ok || memory.Used == 0 {
+		t.Errorf("expected the memory usage, got %+v (%v)", memory, ok)
+	}
+}
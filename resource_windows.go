@@ -0,0 +1,83 @@
+package loadshedder
+
+import (
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+var (
+	kernel32                 = syscall.NewLazyDLL("kernel32.dll")
+	psapi                    = syscall.NewLazyDLL("psapi.dll")
+	procGlobalMemoryStatusEx = kernel32.NewProc("GlobalMemoryStatusEx")
+	procGetProcessMemoryInfo = psapi.NewProc("GetProcessMemoryInfo")
+)
+
+// processMemoryCounters is PROCESS_MEMORY_COUNTERS.
+type processMemoryCounters struct {
+	cb                         uint32
+	PageFaultCount             uint32
+	PeakWorkingSetSize         uintptr
+	WorkingSetSize             uintptr
+	QuotaPeakPagedPoolUsage    uintptr
+	QuotaPagedPoolUsage        uintptr
+	QuotaPeakNonPagedPoolUsage uintptr
+	QuotaNonPagedPoolUsage     uintptr
+	PagefileUsage              uintptr
+	PeakPagefileUsage          uintptr
+}
+
+// memoryStatusEx is MEMORYSTATUSEX.
+type memoryStatusEx struct {
+	dwLength                uint32
+	dwMemoryLoad            uint32
+	ullTotalPhys            uint64
+	ullAvailPhys            uint64
+	ullTotalPageFile        uint64
+	ullAvailPageFile        uint64
+	ullTotalVirtual         uint64
+	ullAvailVirtual         uint64
+	ullAvailExtendedVirtual uint64
+}
+
+func fileDescriptorUsage() (open, limit uint64, ok bool) {
+	return 0, 0, false
+}
+
+// processCPUTime returns the user and kernel CPU time consumed by the process.
+func processCPUTime() (time.Duration, bool) {
+	process, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return 0, false
+	}
+	var creation, exit, kernel, user syscall.Filetime
+	if err := syscall.GetProcessTimes(process, &creation, &exit, &kernel, &user); err != nil {
+		return 0, false
+	}
+	return filetimeDuration(kernel) + filetimeDuration(user), true
+}
+
+// filetimeDuration converts a FILETIME holding a duration, in 100ns intervals.
+func filetimeDuration(ft syscall.Filetime) time.Duration {
+	return time.Duration(uint64(ft.HighDateTime)<<32|uint64(ft.LowDateTime)) * 100
+}
+
+// processMemory returns the working set of the process against the physical
+// memory.
+func processMemory() (MemoryUsage, bool) {
+	process, err := syscall.GetCurrentProcess()
+	if err != nil {
+		return MemoryUsage{}, false
+	}
+	counters := processMemoryCounters{cb: uint32(unsafe.Sizeof(processMemoryCounters{}))}
+	if ok, _, _ := procGetProcessMemoryInfo.Call(uintptr(process), uintptr(unsafe.Pointer(&counters)), uintptr(counters.cb)); ok == 0 {
+		return MemoryUsage{}, false
+	}
+
+	usage := MemoryUsage{Used: uint64(counters.WorkingSetSize)}
+	status := memoryStatusEx{dwLength: uint32(unsafe.Sizeof(memoryStatusEx{}))}
+	if ok, _, _ := procGlobalMemoryStatusEx.Call(uintptr(unsafe.Pointer(&status))); ok != 0 {
+		usage.Limit = status.ullTotalPhys
+	}
+	return usage, true
+}
@@ -0,0 +1,221 @@
+package loadshedder
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+)
+
+// statusCapturingWriter captures the status code and the size of the body
+// written by the handler, for the reporters and the algorithms consuming
+// them. The handler receives it through wrapWriter, which only offers the
+// optional interfaces of the underlying ResponseWriter: Flush, Hijack, Push
+// and ReadFrom behave as if the handler used it directly.
+type statusCapturingWriter struct {
+	http.ResponseWriter
+	statusCode   int
+	bytesWritten int64
+	hijacked     bool
+}
+
+// wrap returns the writer to pass to the handler, see wrapWriter.
+func (w *statusCapturingWriter) wrap() http.ResponseWriter {
+	return wrapWriter(w)
+}
+
+// capturingWriter is a ResponseWriter capturing the response of the handler,
+// wrapped by wrapWriter. The optional interfaces are implemented by
+// unexported methods, only exposed when the underlying ResponseWriter
+// (Unwrap) implements them.
+type capturingWriter interface {
+	http.ResponseWriter
+	Unwrap() http.ResponseWriter
+	FlushError() error
+	hijack() (net.Conn, *bufio.ReadWriter, error)
+	readFrom(io.Reader) (int64, error)
+}
+
+// wrapWriter returns the writer to pass to the handler, implementing
+// http.Flusher, http.Hijacker, http.Pusher and io.ReaderFrom when the
+// underlying ResponseWriter does.
+func wrapWriter(w capturingWriter) http.ResponseWriter {
+	underlying := w.Unwrap()
+	_, flush := underlying.(http.Flusher)
+	_, hijack := underlying.(http.Hijacker)
+	_, push := underlying.(http.Pusher)
+	_, readFrom := underlying.(io.ReaderFrom)
+
+	f, h, p, r := flusher{w}, hijacker{w}, pusher{w}, readerFrom{w}
+	switch {
+	case flush && hijack && push && readFrom:
+		return struct {
+			capturingWriter
+			flusher
+			hijacker
+			pusher
+			readerFrom
+		}{w, f, h, p, r}
+	case flush && hijack && push:
+		return struct {
+			capturingWriter
+			flusher
+			hijacker
+			pusher
+		}{w, f, h, p}
+	case flush && hijack && readFrom:
+		return struct {
+			capturingWriter
+			flusher
+			hijacker
+			readerFrom
+		}{w, f, h, r}
+	case flush && push && readFrom:
+		return struct {
+			capturingWriter
+			flusher
+			pusher
+			readerFrom
+		}{w, f, p, r}
+	case hijack && push && readFrom:
+		return struct {
+			capturingWriter
+			hijacker
+			pusher
+			readerFrom
+		}{w, h, p, r}
+	case flush && hijack:
+		return struct {
+			capturingWriter
+			flusher
+			hijacker
+		}{w, f, h}
+	case flush && push:
+		return struct {
+			capturingWriter
+			flusher
+			pusher
+		}{w, f, p}
+	case flush && readFrom:
+		return struct {
+			capturingWriter
+			flusher
+			readerFrom
+		}{w, f, r}
+	case hijack && push:
+		return struct {
+			capturingWriter
+			hijacker
+			pusher
+		}{w, h, p}
+	case hijack && readFrom:
+		return struct {
+			capturingWriter
+			hijacker
+			readerFrom
+		}{w, h, r}
+	case push && readFrom:
+		return struct {
+			capturingWriter
+			pusher
+			readerFrom
+		}{w, p, r}
+	case flush:
+		return struct {
+			capturingWriter
+			flusher
+		}{w, f}
+	case hijack:
+		return struct {
+			capturingWriter
+			hijacker
+		}{w, h}
+	case push:
+		return struct {
+			capturingWriter
+			pusher
+		}{w, p}
+	case readFrom:
+		return struct {
+			capturingWriter
+			readerFrom
+		}{w, r}
+	default:
+		return w
+	}
+}
+
+func (w *statusCapturingWriter) WriteHeader(statusCode int) {
+	// Informational responses (e.g. 103 Early Hints) precede the final status
+	if w.statusCode == 0 && (statusCode >= 200 || statusCode == http.StatusSwitchingProtocols) {
+		w.statusCode = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *statusCapturingWriter) Write(b []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytesWritten += int64(n)
+	return n, err
+}
+
+// FlushError flushes the underlying ResponseWriter, for
+// http.ResponseController. Flushing writes the header: the status is then
+// 200 unless set.
+func (w *statusCapturingWriter) FlushError() error {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// hijack hijacks the connection of the underlying ResponseWriter.
+func (w *statusCapturingWriter) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// readFrom copies r to the underlying ResponseWriter, preserving the
+// sendfile optimization of the net/http ResponseWriter for handlers serving
+// files.
+func (w *statusCapturingWriter) readFrom(r io.Reader) (int64, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	n, err := w.ResponseWriter.(io.ReaderFrom).ReadFrom(r)
+	w.bytesWritten += n
+	return n, err
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController.
+func (w *statusCapturingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// flusher implements http.Flusher for a capturingWriter.
+type flusher struct{ w capturingWriter }
+
+func (f flusher) Flush() { _ = f.w.FlushError() }
+
+// hijacker implements http.Hijacker for a capturingWriter.
+type hijacker struct{ w capturingWriter }
+
+func (h hijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) { return h.w.hijack() }
+
+// pusher implements http.Pusher for a capturingWriter.
+type pusher struct{ w capturingWriter }
+
+func (p pusher) Push(target string, opts *http.PushOptions) error {
+	return p.w.Unwrap().(http.Pusher).Push(target, opts)
+}
+
+// readerFrom implements io.ReaderFrom for a capturingWriter.
+type readerFrom struct{ w capturingWriter }
+
+func (r readerFrom) ReadFrom(src io.Reader) (int64, error) { return r.w.readFrom(src) }
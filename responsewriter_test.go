@@ -0,0 +1,135 @@
+package loadshedder
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestStatusCapturingWriter(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := &statusCapturingWriter{ResponseWriter: rec}
+
+	w.WriteHeader(http.StatusServiceUnavailable)
+	w.Write([]byte("unavailable"))
+
+	if w.statusCode != http.StatusServiceUnavailable || w.bytesWritten != 11 {
+		t.Errorf("expected 503 and 11 bytes, got %d and %d", w.statusCode, w.bytesWritten)
+	}
+}
+
+func TestStatusCapturingWriter_Flush(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := &statusCapturingWriter{ResponseWriter: rec}
+
+	w.wrap().(http.Flusher).Flush()
+	if !rec.Flushed || w.statusCode != http.StatusOK {
+		t.Errorf("expected the flush to pass through with status 200, got %v and %d", rec.Flushed, w.statusCode)
+	}
+}
+
+type failingFlushWriter struct {
+	http.ResponseWriter
+}
+
+func (w failingFlushWriter) FlushError() error {
+	return errors.New("connection reset")
+}
+
+func TestStatusCapturingWriter_FlushError(t *testing.T) {
+	w := &statusCapturingWriter{ResponseWriter: failingFlushWriter{httptest.NewRecorder()}}
+
+	if err := http.NewResponseController(w.wrap()).Flush(); err == nil || err.Error() != "connection reset" {
+		t.Errorf("expected the flush error to be returned, got %v", err)
+	}
+}
+
+type readerFromWriter struct {
+	*httptest.ResponseRecorder
+	readFrom bool
+}
+
+func (w *readerFromWriter) ReadFrom(r io.Reader) (int64, error) {
+	w.readFrom = true
+	return io.Copy(w.ResponseRecorder, r)
+}
+
+func TestStatusCapturingWriter_ReadFrom(t *testing.T) {
+	rec := &readerFromWriter{ResponseRecorder: httptest.NewRecorder()}
+	w := &statusCapturingWriter{ResponseWriter: rec}
+
+	readerFrom, ok := w.wrap().(io.ReaderFrom)
+	if !ok {
+		t.Fatal("expected the writer to implement io.ReaderFrom like the underlying one")
+	}
+	n, err := readerFrom.ReadFrom(strings.NewReader("streamed body"))
+	if err != nil || n != 13 {
+		t.Fatalf("expected 13 bytes, got %d (%v)", n, err)
+	}
+	if !rec.readFrom || w.bytesWritten != 13 || rec.Body.String() != "streamed body" {
+		t.Errorf("expected the body to be counted and read from, got %v, %d and %q", rec.readFrom, w.bytesWritten, rec.Body.String())
+	}
+}
+
+func TestStatusCapturingWriter_Interfaces(t *testing.T) {
+	w := &statusCapturingWriter{ResponseWriter: httptest.NewRecorder()}
+	wrapped := w.wrap()
+
+	if _, ok := wrapped.(http.Flusher); !ok {
+		t.Error("expected the writer to implement http.Flusher like the recorder")
+	}
+	if _, ok := wrapped.(http.Hijacker); ok {
+		t.Error("expected the writer not to implement http.Hijacker")
+	}
+	if _, ok := wrapped.(http.Pusher); ok {
+		t.Error("expected the writer not to implement http.Pusher")
+	}
+	if _, ok := wrapped.(io.ReaderFrom); ok {
+		t.Error("expected the writer not to implement io.ReaderFrom")
+	}
+
+	if _, _, err := http.NewResponseController(wrapped).Hijack(); !errors.Is(err, http.ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported from Hijack, got %v", err)
+	}
+	if w.hijacked {
+		t.Error("expected the writer not to be hijacked")
+	}
+}
+
+func TestMiddleware_Hijack(t *testing.T) {
+	reporter := &completionRecordingReporter{}
+	mw := NewMiddleware(New(Config{Limit: 1}), reporter, nil)
+
+	server := httptest.NewServer(mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		rw.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+		rw.Flush()
+	})))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "hijacked" {
+		t.Errorf("expected the hijacked response, got %q", body)
+	}
+
+	// The handler may still be returning after the client read the response
+	server.Close()
+	reporter.mu.Lock()
+	defer reporter.mu.Unlock()
+	if len(reporter.completions) != 1 || !reporter.completions[0].Hijacked || reporter.completions[0].StatusCode != 0 {
+		t.Errorf("expected a hijacked completion, got %+v", reporter.completions)
+	}
+}
@@ -0,0 +1,70 @@
+package loadshedder
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// RequestOptions returns the acquire options for a request, allowing routes or
+// request classes sharing one loadshedder to wait differently for a slot
+// (e.g. login can wait 2s, autocomplete must not wait more than 50ms).
+type RequestOptions func(*http.Request) []AcquireOption
+
+// WithRequestOptions applies the acquire options returned for each request,
+// in addition to the ones set by the middleware.
+func WithRequestOptions(options RequestOptions) MiddlewareOption {
+	return func(m *Middleware) {
+		m.requestOptions = options
+	}
+}
+
+// CostFunc returns the weight of a request, the number of slots it consumes
+// (e.g. 10 for a bulk export, 1 for a lookup). See WithWeight.
+type CostFunc func(*http.Request) int
+
+// WithCost makes each request consume the number of slots returned by cost,
+// so expensive requests count for more against the limit than cheap ones.
+func WithCost(cost CostFunc) MiddlewareOption {
+	return func(m *Middleware) {
+		m.cost = cost
+	}
+}
+
+// LabelFunc returns the label of a request (e.g. its route pattern), see
+// WithLabel.
+type LabelFunc func(*http.Request) string
+
+// WithRequestLabel accounts each request under the label returned by label,
+// in CountersByLabel and in the Stats.Label passed to the reporter, for
+// per-route metrics without a custom reporter. Labels should have a low
+// cardinality, see Config.MaxLabels.
+func WithRequestLabel(label LabelFunc) MiddlewareOption {
+	return func(m *Middleware) {
+		m.label = label
+	}
+}
+
+// MaxWaitByPath bounds the waiting time of requests by URL path prefix, the
+// longest matching prefix winning. Requests matching no prefix use the
+// limiter defaults. A non-positive duration rejects without waiting,
+// see WithMaxWait.
+func MaxWaitByPath(routes map[string]time.Duration) RequestOptions {
+	return func(r *http.Request) []AcquireOption {
+		var (
+			match   string
+			maxWait time.Duration
+			found   bool
+		)
+		for prefix, d := range routes {
+			if strings.HasPrefix(r.URL.Path, prefix) && (!found || len(prefix) > len(match)) {
+				match, maxWait, found = prefix, d, true
+			}
+		}
+
+		if !found {
+			return nil
+		}
+		return []AcquireOption{WithMaxWait(maxWait)}
+	}
+}
@@ -0,0 +1,106 @@
+package loadshedder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMaxWaitByPath(t *testing.T) {
+	options := MaxWaitByPath(map[string]time.Duration{
+		"/api/":             time.Second,
+		"/api/autocomplete": 50 * time.Millisecond,
+	})
+
+	tests := []struct {
+		path     string
+		expected time.Duration
+		matched  bool
+	}{
+		{"/api/login", time.Second, true},
+		{"/api/autocomplete?q=a", 50 * time.Millisecond, true},
+		{"/health", 0, false},
+	}
+
+	for _, tt := range tests {
+		opts := options(httptest.NewRequest(http.MethodGet, tt.path, http.NoBody))
+		if !tt.matched {
+			if len(opts) != 0 {
+				t.Errorf("%s: expected no options, got %d", tt.path, len(opts))
+			}
+			continue
+		}
+
+		o := applyAcquireOptions(opts)
+		if o.maxWait != tt.expected {
+			t.Errorf("%s: expected max wait %v, got %v", tt.path, tt.expected, o.maxWait)
+		}
+	}
+}
+
+func TestMiddleware_WithRequestOptions(t *testing.T) {
+	ls := New(Config{Limit: 1, WaitingLimit: 5})
+	mw := NewMiddleware(ls, nil, nil, WithRequestOptions(MaxWaitByPath(map[string]time.Duration{
+		"/login":        2 * time.Second,
+		"/autocomplete": 10 * time.Millisecond,
+	})))
+
+	handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// Hold the only slot
+	_, token := ls.Acquire(context.Background())
+
+	// Autocomplete gives up after its short wait
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/autocomplete", http.NoBody))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected autocomplete to be rejected, got %d", rec.Code)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected autocomplete to give up quickly, waited %v", elapsed)
+	}
+
+	// Login waits long enough for the slot to be released
+	time.AfterFunc(50*time.Millisecond, func() { ls.Release(token) })
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/login", http.NoBody))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected login to wait for a slot, got %d", rec.Code)
+	}
+}
+
+func TestMiddleware_WithCost(t *testing.T) {
+	ls := New(Config{Limit: 4})
+	mw := NewMiddleware(ls, nil, nil, WithCost(func(r *http.Request) int {
+		if r.URL.Path == "/export" {
+			return 3
+		}
+		return 1
+	}))
+
+	var nested []int
+	var handler http.Handler
+	handler = mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/export" {
+			// The export holds 3 of the 4 slots
+			for _, path := range []string{"/export", "/lookup"} {
+				rec := httptest.NewRecorder()
+				handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, http.NoBody))
+				nested = append(nested, rec.Code)
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/export", http.NoBody))
+
+	if len(nested) != 2 || nested[0] != http.StatusTooManyRequests || nested[1] != http.StatusOK {
+		t.Errorf("expected a second export rejected and a lookup accepted, got %v", nested)
+	}
+}
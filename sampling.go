@@ -0,0 +1,77 @@
+package loadshedder
+
+import (
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultSampleRate         = 0.001
+	defaultSampleMaxPerSecond = 1
+)
+
+// admissionSampler invokes the sample hook on a random subset of admissions,
+// within a strict budget: the hook runs in its own goroutine, one call at a
+// time, at most maxPerSecond times per second. Sampled admissions exceeding
+// the budget are dropped, never delayed.
+type admissionSampler struct {
+	hook        func(Stats, *Token)
+	threshold   uint64       // sample when a random uint64 is below
+	minInterval int64        // nanoseconds between samples
+	next        atomic.Int64 // unix nanoseconds of the next allowed sample
+	busy        atomic.Bool
+
+	taken   atomic.Int64
+	dropped atomic.Int64
+}
+
+func newAdmissionSampler(cfg Config) *admissionSampler {
+	threshold := uint64(math.MaxUint64)
+	if cfg.SampleRate < 1 {
+		threshold = uint64(cfg.SampleRate * math.MaxUint64)
+	}
+
+	return &admissionSampler{
+		hook:        cfg.SampleHook,
+		threshold:   threshold,
+		minInterval: int64(time.Second) / int64(cfg.SampleMaxPerSecond),
+	}
+}
+
+// maybeSample runs the hook if the admission is sampled and the budget allows.
+func (s *admissionSampler) maybeSample(now time.Time, stats Stats, token *Token) {
+	if rand.Uint64() >= s.threshold {
+		return
+	}
+
+	next := s.next.Load()
+	if now.UnixNano() < next || !s.busy.CompareAndSwap(false, true) {
+		s.dropped.Add(1)
+		return
+	}
+	s.next.Store(now.UnixNano() + s.minInterval)
+	s.taken.Add(1)
+
+	go func() {
+		defer s.busy.Store(false)
+		defer func() {
+			if err := recover(); err != nil {
+				slog.Error("loadshedder: sample hook panic", "error", err)
+			}
+		}()
+
+		s.hook(stats, token)
+	}()
+}
+
+// Samples returns the number of sampled admissions passed to Config.SampleHook,
+// and the number dropped because the hook budget was exhausted.
+func (l *Loadshedder) Samples() (taken, dropped int64) {
+	if l.sampler == nil {
+		return 0, 0
+	}
+	return l.sampler.taken.Load(), l.sampler.dropped.Load()
+}
@@ -0,0 +1,113 @@
+package loadshedder
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLoadshedder_SampleHook(t *testing.T) {
+	sampled := make(chan Stats, 1)
+	ls := New(Config{
+		Name:       "api",
+		Limit:      10,
+		SampleRate: 1,
+		SampleHook: func(stats Stats, token *Token) {
+			if !token.Accepted() {
+				t.Error("expected only admissions to be sampled")
+			}
+			sampled <- stats
+		},
+	})
+
+	_, token := ls.Acquire(context.Background())
+	defer ls.Release(token)
+
+	select {
+	case stats := <-sampled:
+		if stats.Name != "api" || stats.Running != 1 {
+			t.Errorf("unexpected sampled stats: %+v", stats)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the admission to be sampled")
+	}
+
+	if taken, dropped := ls.Samples(); taken != 1 || dropped != 0 {
+		t.Errorf("expected 1 sample taken and 0 dropped, got %d and %d", taken, dropped)
+	}
+}
+
+func TestLoadshedder_SampleHookBudget(t *testing.T) {
+	release := make(chan struct{})
+	calls := make(chan struct{}, 10)
+	ls := New(Config{
+		Limit:              10,
+		SampleRate:         1,
+		SampleMaxPerSecond: 1000,
+		SampleHook: func(Stats, *Token) {
+			calls <- struct{}{}
+			<-release
+		},
+	})
+
+	ctx := context.Background()
+
+	_, token := ls.Acquire(ctx)
+	ls.Release(token)
+	<-calls
+
+	// The hook is still running: sampled admissions are dropped, not delayed
+	for range 5 {
+		_, token := ls.Acquire(ctx)
+		ls.Release(token)
+	}
+
+	if taken, dropped := ls.Samples(); taken != 1 || dropped != 5 {
+		t.Errorf("expected 1 sample taken and 5 dropped, got %d and %d", taken, dropped)
+	}
+
+	close(release)
+}
+
+func TestLoadshedder_SampleHookRateCap(t *testing.T) {
+	ls := New(Config{
+		Limit:      10,
+		SampleRate: 1,
+		SampleHook: func(Stats, *Token) {},
+	})
+
+	for range 100 {
+		_, token := ls.Acquire(context.Background())
+		ls.Release(token)
+	}
+
+	// Default budget: one sample per second
+	if taken, _ := ls.Samples(); taken != 1 {
+		t.Errorf("expected 1 sample taken within a second, got %d", taken)
+	}
+}
+
+func TestLoadshedder_SampleHookPanic(t *testing.T) {
+	ls := New(Config{
+		Limit:              10,
+		SampleRate:         1,
+		SampleMaxPerSecond: 1000,
+		SampleHook: func(Stats, *Token) {
+			panic("boom")
+		},
+	})
+
+	_, token := ls.Acquire(context.Background())
+	ls.Release(token)
+
+	// The panic is recovered and the sampler keeps working
+	waitFor(t, func() bool { return !ls.sampler.busy.Load() })
+	time.Sleep(2 * time.Millisecond)
+
+	_, token = ls.Acquire(context.Background())
+	ls.Release(token)
+
+	if taken, _ := ls.Samples(); taken != 2 {
+		t.Errorf("expected 2 samples taken, got %d", taken)
+	}
+}
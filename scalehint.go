@@ -0,0 +1,176 @@
+package loadshedder
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultScaleHintDuration  = 10 * time.Second
+	defaultScaleHintInterval  = time.Second
+	defaultScaleHintSmoothing = 0.2
+)
+
+// ScaleHint reports a sustained queue pressure to ScaleHintConfig.Callback,
+// for custom autoscalers and job schedulers: the loadshedder sees the
+// pressure before latency or CPU based signals do.
+// The averages are exponentially smoothed over the samples.
+type ScaleHint struct {
+	Name         string    // The configured name of the loadshedder (empty if not set)
+	Since        time.Time // When the smoothed queue depth went above the threshold
+	Waiting      float64   // Smoothed number of waiting requests
+	Running      float64   // Smoothed number of running requests
+	RejectRate   float64   // Smoothed rejections per second
+	Limit        int64     // The concurrency limit currently enforced
+	WaitingLimit int64     // The configured waiting limit
+}
+
+// ScaleHintConfig configures WatchScaleHints.
+type ScaleHintConfig struct {
+	// Threshold is the smoothed number of waiting requests above which the
+	// queue is under pressure.
+	// Required, must be positive.
+	Threshold int64
+
+	// Duration is how long the queue must stay above Threshold before the
+	// callback is called.
+	// Optional, default to 10s.
+	Duration time.Duration
+
+	// Interval is the sampling interval of the stats.
+	// Optional, default to 1s.
+	Interval time.Duration
+
+	// Smoothing is the weight of each new sample in the averages, in (0, 1].
+	// Optional, default to 0.2.
+	Smoothing float64
+
+	// Repeat is the interval at which the callback is called again while the
+	// pressure lasts, for autoscalers expecting a steady signal.
+	// Optional, default to 0: called once per pressure episode.
+	Repeat time.Duration
+
+	// Callback receives the hints. It is called from the sampling goroutine,
+	// so it must not block: hand slow calls (e.g. webhooks) over to another
+	// goroutine.
+	// Required.
+	Callback func(ScaleHint)
+}
+
+// WatchScaleHints starts a goroutine sampling the stats every Interval and
+// calling cfg.Callback once the smoothed queue depth stayed above Threshold
+// for Duration. A new episode starts once the smoothed depth went back under
+// the threshold. Call the returned function to stop watching.
+func (l *Loadshedder) WatchScaleHints(cfg ScaleHintConfig) (stop func()) {
+	if cfg.Callback == nil {
+		panic("loadshedder: ScaleHintConfig.Callback is required")
+	}
+	if cfg.Threshold <= 0 {
+		panic("loadshedder: ScaleHintConfig.Threshold must be positive")
+	}
+	if cfg.Duration <= 0 {
+		cfg.Duration = defaultScaleHintDuration
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultScaleHintInterval
+	}
+	if cfg.Smoothing == 0 {
+		cfg.Smoothing = defaultScaleHintSmoothing
+	}
+	if cfg.Smoothing < 0 || cfg.Smoothing > 1 {
+		panic("loadshedder: ScaleHintConfig.Smoothing must be in (0, 1]")
+	}
+
+	w := &scaleHintWatcher{cfg: cfg}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case now := <-ticker.C:
+				if hint, ok := w.observe(now, l.Stats(), l.rejected.Load(), l.settings.Load().waitingLimit); ok {
+					cfg.Callback(hint)
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+		})
+	}
+}
+
+// scaleHintWatcher smooths the samples and detects the pressure episodes.
+// Not safe for concurrent use.
+type scaleHintWatcher struct {
+	cfg ScaleHintConfig
+
+	sampled    bool
+	lastSample time.Time
+	rejected   int64 // counter at the last sample
+
+	waiting    float64
+	running    float64
+	rejectRate float64
+
+	aboveSince time.Time // start of the pressure episode, zero if none
+	lastHint   time.Time // last callback of the episode, zero if none
+}
+
+// observe records a sample and returns the hint to report, if any.
+func (w *scaleHintWatcher) observe(now time.Time, stats Stats, rejected, waitingLimit int64) (ScaleHint, bool) {
+	if !w.sampled {
+		w.sampled = true
+		w.waiting = float64(stats.Waiting)
+		w.running = float64(stats.Running)
+	} else {
+		alpha := w.cfg.Smoothing
+		w.waiting += alpha * (float64(stats.Waiting) - w.waiting)
+		w.running += alpha * (float64(stats.Running) - w.running)
+		if elapsed := now.Sub(w.lastSample).Seconds(); elapsed > 0 {
+			rate := float64(rejected-w.rejected) / elapsed
+			w.rejectRate += alpha * (rate - w.rejectRate)
+		}
+	}
+	w.lastSample = now
+	w.rejected = rejected
+
+	if w.waiting <= float64(w.cfg.Threshold) {
+		w.aboveSince = time.Time{}
+		w.lastHint = time.Time{}
+		return ScaleHint{}, false
+	}
+	if w.aboveSince.IsZero() {
+		w.aboveSince = now
+	}
+	if now.Sub(w.aboveSince) < w.cfg.Duration {
+		return ScaleHint{}, false
+	}
+	if !w.lastHint.IsZero() && (w.cfg.Repeat <= 0 || now.Sub(w.lastHint) < w.cfg.Repeat) {
+		return ScaleHint{}, false
+	}
+	w.lastHint = now
+
+	return ScaleHint{
+		Name:         stats.Name,
+		Since:        w.aboveSince,
+		Waiting:      w.waiting,
+		Running:      w.running,
+		RejectRate:   w.rejectRate,
+		Limit:        stats.EffectiveLimit,
+		WaitingLimit: waitingLimit,
+	}, true
+}
@@ -0,0 +1,130 @@
+package loadshedder
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestScaleHintWatcher(t *testing.T) {
+	w := &scaleHintWatcher{cfg: ScaleHintConfig{
+		Threshold: 5,
+		Duration:  3 * time.Second,
+		Smoothing: 1,
+		Repeat:    2 * time.Second,
+	}}
+	start := time.Now()
+	at := func(s int) time.Time { return start.Add(time.Duration(s) * time.Second) }
+
+	type step struct {
+		second   int
+		waiting  int64
+		rejected int64
+		hint     bool
+	}
+	steps := []step{
+		{0, 10, 0, false},  // above, episode starts
+		{1, 3, 0, false},   // under, episode reset
+		{2, 10, 0, false},  // above, new episode
+		{4, 10, 10, false}, // not above for long enough
+		{5, 10, 20, true},  // above for 3s
+		{6, 10, 30, false}, // not repeated yet
+		{7, 10, 40, true},  // repeated
+		{8, 0, 40, false},  // episode ends
+		{9, 10, 40, false}, // new episode
+	}
+
+	for _, s := range steps {
+		hint, ok := w.observe(at(s.second), Stats{Name: "api", Waiting: s.waiting, Running: 8, EffectiveLimit: 8}, s.rejected, 20)
+		if ok != s.hint {
+			t.Fatalf("at %ds: expected hint %v, got %v", s.second, s.hint, ok)
+		}
+		if !ok {
+			continue
+		}
+
+		expected := ScaleHint{Name: "api", Since: at(2), Waiting: 10, Running: 8, RejectRate: 10, Limit: 8, WaitingLimit: 20}
+		if hint != expected {
+			t.Errorf("at %ds: expected %+v, got %+v", s.second, expected, hint)
+		}
+	}
+}
+
+func TestScaleHintWatcher_Smoothing(t *testing.T) {
+	w := &scaleHintWatcher{cfg: ScaleHintConfig{Threshold: 5, Smoothing: 0.5}}
+	now := time.Now()
+
+	// A single spike does not bring the smoothed depth above the threshold
+	w.observe(now, Stats{Waiting: 0}, 0, 0)
+	w.observe(now.Add(time.Second), Stats{Waiting: 8}, 0, 0)
+	if w.waiting != 4 || !w.aboveSince.IsZero() {
+		t.Errorf("expected a smoothed depth of 4 under the threshold, got %v", w.waiting)
+	}
+
+	w.observe(now.Add(2*time.Second), Stats{Waiting: 8}, 0, 0)
+	if w.waiting != 6 || w.aboveSince.IsZero() {
+		t.Errorf("expected a smoothed depth of 6 above the threshold, got %v", w.waiting)
+	}
+}
+
+func TestLoadshedder_WatchScaleHints(t *testing.T) {
+	ls := New(Config{Limit: 1, WaitingLimit: 10})
+
+	_, running := ls.Acquire(context.Background())
+	defer ls.Release(running)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	for range 3 {
+		go ls.Acquire(ctx)
+	}
+	for ls.Stats().Waiting < 3 {
+		time.Sleep(time.Millisecond)
+	}
+
+	hints := make(chan ScaleHint, 10)
+	stop := ls.WatchScaleHints(ScaleHintConfig{
+		Threshold: 1,
+		Duration:  5 * time.Millisecond,
+		Interval:  time.Millisecond,
+		Smoothing: 1,
+		Callback:  func(hint ScaleHint) { hints <- hint },
+	})
+	defer stop()
+
+	select {
+	case hint := <-hints:
+		if hint.Waiting != 3 || hint.Running != 1 || hint.Limit != 1 || hint.WaitingLimit != 10 {
+			t.Errorf("unexpected hint: %+v", hint)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a scale hint")
+	}
+	stop()
+
+	// Called once per episode without Repeat
+	select {
+	case hint := <-hints:
+		t.Errorf("unexpected hint: %+v", hint)
+	default:
+	}
+}
+
+func TestLoadshedder_WatchScaleHints_Validation(t *testing.T) {
+	ls := New(Config{Limit: 1})
+
+	for name, cfg := range map[string]ScaleHintConfig{
+		"callback":  {Threshold: 1},
+		"threshold": {Callback: func(ScaleHint) {}},
+		"smoothing": {Threshold: 1, Smoothing: 2, Callback: func(ScaleHint) {}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected a panic")
+				}
+			}()
+			ls.WatchScaleHints(cfg)
+		})
+	}
+}
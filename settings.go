@@ -0,0 +1,33 @@
+package loadshedder
+
+// settings is an immutable snapshot of the runtime settings read on the
+// Acquire and Release paths. The setters (SetWaitingLimit, Pause, SetShadow,
+// Drain, Ready, EnableChaos, RampLimit...) publish a modified copy under
+// limitMu: reconfiguring never adds a lock to the hot path, which reads all
+// the settings with a single atomic load.
+type settings struct {
+	waitingLimit int64
+	startup      StartupMode
+	paused       bool
+	shadow       bool
+	draining     bool
+	chaos        *chaosController // see EnableChaos
+	ramp         *limitRamp       // see RampLimit
+
+	// Derived by updateSettings, so that a loadshedder without runtime
+	// controls pays for a single branch on each:
+	stopped  bool // draining, paused or rejecting all until Ready
+	maintain bool // the limits are maintained on the Acquire path, see maintainLimits
+}
+
+// updateSettings publishes a copy of the settings modified by fn, with the
+// derived fields recomputed. Must hold limitMu.
+func (l *Loadshedder) updateSettings(fn func(*settings)) {
+	s := *l.settings.Load()
+	fn(&s)
+
+	s.stopped = s.draining || s.paused || s.startup == StartRejectAll
+	s.maintain = s.ramp != nil || (s.startup == StartEnforcing &&
+		(l.signals != nil || l.gradient != nil || l.coldStarting() || l.errorRate != nil))
+	l.settings.Store(&s)
+}
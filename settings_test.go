@@ -0,0 +1,107 @@
+package loadshedder
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSettings_Derived(t *testing.T) {
+	ls := New(Config{Limit: 10, WaitingLimit: 5, Startup: StartRejectAll})
+	if s := ls.settings.Load(); !s.stopped || s.maintain {
+		t.Errorf("expected stopped before Ready, got %+v", s)
+	}
+
+	ls.Ready()
+	if s := ls.settings.Load(); s.stopped || s.maintain {
+		t.Errorf("expected a static loadshedder, got %+v", s)
+	}
+
+	ls.RampLimit(20, time.Minute)
+	ls.Pause()
+	if s := ls.settings.Load(); !s.stopped || !s.maintain || s.waitingLimit != 5 {
+		t.Errorf("expected the ramp and the pause to be published, got %+v", s)
+	}
+
+	ls.Resume()
+	ls.RampLimit(20, 0)
+	if s := ls.settings.Load(); s.stopped || s.maintain {
+		t.Errorf("expected a static loadshedder again, got %+v", s)
+	}
+
+	adaptive := New(Config{Limit: 10, Adaptive: true})
+	if !adaptive.settings.Load().maintain {
+		t.Error("expected the adaptive limit to be maintained on the Acquire path")
+	}
+}
+
+func TestSettings_ConcurrentReconfiguration(t *testing.T) {
+	ls := New(Config{Limit: 4, WaitingLimit: 4, MaxQueueWait: time.Millisecond})
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := int64(0); ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			ls.SetShadow(i%2 == 0)
+			ls.SetWaitingLimit(i % 8)
+		}
+	}()
+
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 1000 {
+				_, token := ls.Acquire(context.Background())
+				ls.Release(token)
+			}
+		}()
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	close(done)
+	wg.Wait()
+
+	if stats := ls.Stats(); stats.Running != 0 || stats.Waiting != 0 {
+		t.Errorf("expected no running or waiting request, got %+v", stats)
+	}
+}
+
+// BenchmarkSettings_Reconfigured measures the hot path while the settings are
+// changed every millisecond, like a control loop or an admin endpoint would.
+// BenchmarkLimiter_WithWaiting is the baseline, with the same configuration.
+func BenchmarkSettings_Reconfigured(b *testing.B) {
+	ctx := context.Background()
+	ls := New(Config{Limit: 100, WaitingLimit: 50})
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(time.Millisecond)
+		defer ticker.Stop()
+		for i := int64(0); ; i++ {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				ls.SetShadow(i%2 == 0)
+				ls.SetWaitingLimit(50 + i%2)
+			}
+		}
+	}()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			_, token := ls.Acquire(ctx)
+			ls.Release(token)
+		}
+	})
+}
@@ -0,0 +1,54 @@
+package loadshedder
+
+import "context"
+
+// Severity returns how hard the loadshedder is pressed, for handlers trading
+// quality for capacity (partial results, smaller pages) before requests get
+// rejected: 0 while requests are admitted without waiting, rising with the
+// fill of the waiting queue, up to 1 once new requests are rejected (queue
+// full, or paused or draining). Without a waiting queue, it is 1 at the
+// limit and 0 below.
+func (l *Loadshedder) Severity() float64 {
+	s := l.settings.Load()
+	if s.paused || s.draining {
+		return 1
+	}
+
+	queued := l.current.Load() - l.effectiveLimit.Load()
+	if queued < 0 {
+		return 0
+	}
+	if s.waitingLimit == 0 {
+		return 1
+	}
+	return min(1, float64(queued)/float64(s.waitingLimit))
+}
+
+// Severity returns the Severity of the loadshedder that admitted the request
+// of ctx, or 0 if the context carries no token, see ContextWithToken.
+// The middleware sets the token in the context of the requests it admits.
+func Severity(ctx context.Context) float64 {
+	t, _ := ctx.Value(tokenContextKey{}).(*Token)
+	if t == nil || t.owner == nil {
+		return 0
+	}
+	return t.owner.Severity()
+}
+
+// Degraded reports whether the loadshedder that admitted the request of ctx
+// is queueing requests, see Severity: handlers should return partial results
+// or skip optional work.
+func Degraded(ctx context.Context) bool {
+	return Severity(ctx) > 0
+}
+
+// PageSize scales a page size down with the Severity of the loadshedder that
+// admitted the request of ctx: size without pressure, down to minSize when
+// shedding, so each request holds its slot for less time under pressure.
+func PageSize(ctx context.Context, size, minSize int) int {
+	severity := Severity(ctx)
+	if severity == 0 || size <= minSize {
+		return size
+	}
+	return size - int(severity*float64(size-minSize))
+}
@@ -0,0 +1,92 @@
+package loadshedder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLoadshedder_Severity(t *testing.T) {
+	ctx := context.Background()
+	ls := New(Config{Limit: 2, WaitingLimit: 4})
+
+	_, token1 := ls.Acquire(ctx)
+	_, token2 := ls.Acquire(ctx)
+	defer ls.Release(token1)
+	defer ls.Release(token2)
+	if severity := ls.Severity(); severity != 0 {
+		t.Errorf("expected no severity at the limit without waiters, got %v", severity)
+	}
+
+	waitCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for range 2 {
+		go ls.Acquire(waitCtx)
+	}
+	waitForStats(t, ls, func(s Stats) bool { return s.Waiting == 2 })
+	if severity := ls.Severity(); severity != 0.5 {
+		t.Errorf("expected the queue fill as severity, got %v", severity)
+	}
+
+	ls.Pause()
+	if severity := ls.Severity(); severity != 1 {
+		t.Errorf("expected full severity while paused, got %v", severity)
+	}
+}
+
+func TestLoadshedder_Severity_CounterOnly(t *testing.T) {
+	ls := New(Config{Limit: 1})
+	if severity := ls.Severity(); severity != 0 {
+		t.Errorf("expected no severity under the limit, got %v", severity)
+	}
+
+	_, token := ls.Acquire(context.Background())
+	defer ls.Release(token)
+	if severity := ls.Severity(); severity != 1 {
+		t.Errorf("expected full severity at the limit, got %v", severity)
+	}
+}
+
+func TestSeverity_Context(t *testing.T) {
+	ls := New(Config{Limit: 1})
+
+	if Severity(context.Background()) != 0 || Degraded(context.Background()) {
+		t.Error("expected no severity without a token")
+	}
+	if size := PageSize(context.Background(), 100, 10); size != 100 {
+		t.Errorf("expected the full page size without a token, got %d", size)
+	}
+
+	var severity float64
+	var degraded bool
+	var size int
+	handler := NewMiddleware(ls, nil, nil).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		severity, degraded, size = Severity(r.Context()), Degraded(r.Context()), PageSize(r.Context(), 100, 10)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	// The request itself fills the counter-only loadshedder
+	if severity != 1 || !degraded || size != 10 {
+		t.Errorf("expected full severity, got %v, %v and page size %d", severity, degraded, size)
+	}
+}
+
+func TestPageSize(t *testing.T) {
+	ls := New(Config{Limit: 1, WaitingLimit: 4})
+	_, running := ls.Acquire(context.Background())
+	defer ls.Release(running)
+
+	waitCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go ls.Acquire(waitCtx)
+	waitForStats(t, ls, func(s Stats) bool { return s.Waiting == 1 })
+
+	ctx := ContextWithToken(context.Background(), running)
+	if size := PageSize(ctx, 100, 20); size != 80 {
+		t.Errorf("expected a page size scaled by the severity of 0.25, got %d", size)
+	}
+	if size := PageSize(ctx, 10, 20); size != 10 {
+		t.Errorf("expected a size under the minimum unchanged, got %d", size)
+	}
+}
@@ -0,0 +1,67 @@
+package loadshedder
+
+import "context"
+
+// OnShed registers f to be called if the loadshedder that admitted the
+// request of ctx starts shedding while the request is running: the next time
+// it rejects an acquisition without queueing it, for exceeding the capacity
+// or while paused or draining. Admitted requests can then skip optional work
+// (prefetching, enrichment) to finish faster and free their slot.
+// f is called at most once, from the rejected Acquire call: it must be cheap
+// and must not block, e.g. cancel a context or set a flag. It is dropped when
+// the token is released.
+// The context must carry the token, see ContextWithToken: the middleware
+// sets it. Returns false, without registering f, if the context carries no
+// running token.
+func OnShed(ctx context.Context, f func()) bool {
+	t, _ := ctx.Value(tokenContextKey{}).(*Token)
+	if t == nil || t.owner == nil {
+		return false
+	}
+	l := t.owner
+
+	l.shedMu.Lock()
+	defer l.shedMu.Unlock()
+
+	// Counted before checking the token, so a concurrent release either sees
+	// the hook to remove or is seen here.
+	l.shedHookCount.Add(1)
+	if t.released.Load() != tokenHeld {
+		l.shedHookCount.Add(-1)
+		return false
+	}
+
+	if l.shedHooks == nil {
+		l.shedHooks = make(map[*Token][]func())
+	}
+	l.shedHooks[t] = append(l.shedHooks[t], f)
+	return true
+}
+
+// runShedHooks calls and removes all the hooks registered with OnShed.
+func (l *Loadshedder) runShedHooks() {
+	l.shedMu.Lock()
+	hooks := l.shedHooks
+	l.shedHooks = nil
+	for _, fs := range hooks {
+		l.shedHookCount.Add(-int64(len(fs)))
+	}
+	l.shedMu.Unlock()
+
+	for _, fs := range hooks {
+		for _, f := range fs {
+			f()
+		}
+	}
+}
+
+// removeShedHooks removes the hooks of a released token.
+func (l *Loadshedder) removeShedHooks(t *Token) {
+	l.shedMu.Lock()
+	defer l.shedMu.Unlock()
+
+	if fs, ok := l.shedHooks[t]; ok {
+		delete(l.shedHooks, t)
+		l.shedHookCount.Add(-int64(len(fs)))
+	}
+}
@@ -0,0 +1,113 @@
+package loadshedder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestOnShed(t *testing.T) {
+	ls := New(Config{Limit: 2})
+
+	_, token1 := ls.Acquire(context.Background())
+	_, token2 := ls.Acquire(context.Background())
+	defer ls.Release(token2)
+
+	var calls1, calls2 atomic.Int32
+	if !OnShed(ContextWithToken(context.Background(), token1), func() { calls1.Add(1) }) {
+		t.Fatal("expected the hook registered")
+	}
+	if !OnShed(ContextWithToken(context.Background(), token2), func() { calls2.Add(1) }) {
+		t.Fatal("expected the hook registered")
+	}
+
+	// Released tokens drop their hooks
+	ls.Release(token1)
+	_, token3 := ls.Acquire(context.Background())
+	defer ls.Release(token3)
+
+	if _, token := ls.Acquire(context.Background()); token.Accepted() {
+		t.Fatal("expected a rejection")
+	}
+	if calls1.Load() != 0 || calls2.Load() != 1 {
+		t.Errorf("expected only the hook of the running request called, got %d and %d", calls1.Load(), calls2.Load())
+	}
+
+	// Called at most once
+	ls.Acquire(context.Background())
+	if calls2.Load() != 1 {
+		t.Errorf("expected the hook called once, got %d", calls2.Load())
+	}
+	if count := ls.shedHookCount.Load(); count != 0 {
+		t.Errorf("expected no hooks left, got %d", count)
+	}
+}
+
+func TestOnShed_Paused(t *testing.T) {
+	ls := New(Config{Limit: 10})
+	_, token := ls.Acquire(context.Background())
+	defer ls.Release(token)
+
+	var called atomic.Bool
+	OnShed(ContextWithToken(context.Background(), token), func() { called.Store(true) })
+
+	ls.Pause()
+	ls.Acquire(context.Background())
+	if !called.Load() {
+		t.Error("expected the hook called when rejecting while paused")
+	}
+}
+
+func TestOnShed_NoToken(t *testing.T) {
+	ls := New(Config{Limit: 1})
+
+	if OnShed(context.Background(), func() {}) {
+		t.Error("expected no registration without a token")
+	}
+
+	ls.Pause()
+	_, rejected := ls.Acquire(context.Background())
+	if OnShed(ContextWithToken(context.Background(), rejected), func() {}) {
+		t.Error("expected no registration for a rejected token")
+	}
+
+	ls.Resume()
+	_, token := ls.Acquire(context.Background())
+	ls.Release(token)
+	if OnShed(ContextWithToken(context.Background(), token), func() {}) {
+		t.Error("expected no registration for a released token")
+	}
+	if count := ls.shedHookCount.Load(); count != 0 {
+		t.Errorf("expected no hooks, got %d", count)
+	}
+}
+
+func TestMiddleware_OnShed(t *testing.T) {
+	ls := New(Config{Limit: 1})
+	mw := NewMiddleware(ls, nil, nil)
+
+	registered := make(chan bool)
+	shed := make(chan struct{})
+	handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		registered <- OnShed(r.Context(), func() { close(shed) })
+		<-shed
+	}))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	}()
+	if !<-registered {
+		t.Fatal("expected the hook registered from the handler")
+	}
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected a rejection, got %d", rec.Code)
+	}
+	<-done
+}
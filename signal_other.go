@@ -0,0 +1,8 @@
+//go:build !unix
+
+package loadshedder
+
+// HandleSignals is a no-op on platforms without SIGUSR1/SIGUSR2.
+func HandleSignals(ls *Loadshedder) (stop func()) {
+	return func() {}
+}
@@ -0,0 +1,56 @@
+//go:build unix
+
+package loadshedder
+
+import (
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// HandleSignals installs an emergency clamp operated with signals, so on-call
+// can shed load on a box without any admin API or redeploy:
+//   - SIGUSR1 halves the effective limit (repeatable, down to 1)
+//   - SIGUSR2 restores the configured limit
+//
+// Changes are logged with slog.Default(). Call the returned function to
+// uninstall the handlers.
+func HandleSignals(ls *Loadshedder) (stop func()) {
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(signals, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				handleSignal(ls, sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+func handleSignal(ls *Loadshedder, sig os.Signal) {
+	switch sig {
+	case syscall.SIGUSR1:
+		ls.Clamp(ls.effectiveLimit.Load() / 2)
+	case syscall.SIGUSR2:
+		ls.Unclamp()
+	}
+
+	stats := ls.Stats()
+	slog.Warn("loadshedder: limit changed by signal",
+		"signal", sig.String(),
+		"name", stats.Name,
+		"configured_limit", stats.ConfiguredLimit,
+		"effective_limit", stats.EffectiveLimit,
+	)
+}
@@ -0,0 +1,42 @@
+//go:build unix
+
+package loadshedder
+
+import (
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestHandleSignals(t *testing.T) {
+	ls := New(Config{Limit: 8})
+
+	stop := HandleSignals(ls)
+	defer stop()
+
+	waitForLimit := func(expected int64) {
+		t.Helper()
+		deadline := time.Now().Add(time.Second)
+		for ls.Stats().EffectiveLimit != expected {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected EffectiveLimit=%d, got %+v", expected, ls.Stats())
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+	waitForLimit(4)
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+	waitForLimit(2)
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR2); err != nil {
+		t.Fatal(err)
+	}
+	waitForLimit(8)
+}
@@ -0,0 +1,244 @@
+package loadshedder
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// slots is a resizable weighted semaphore with a waiting queue, FIFO by default,
+// modeled after golang.org/x/sync/semaphore.Weighted.
+// Like semaphore.Weighted, a waiter needing more slots than are free blocks
+// the waiters behind it, so heavy acquisitions are not starved by light ones.
+// The queue can also be served in LIFO order, see QueueDiscipline.
+// Unlike semaphore.Weighted, its size can change while slots are held:
+// growing wakes waiters, shrinking lets in-flight holders finish and only
+// admits new holders once usage is back under the new size.
+// With CoDel, stale waiters can be dropped from the queue, see codel.
+type slots struct {
+	mu      sync.Mutex
+	size    int64
+	inUse   paddedInt64 // written under mu (lock-free in counter-only mode), read lock-free by Stats
+	waiters list.List
+
+	discipline        QueueDiscipline
+	adaptiveLIFOAbove int // queue length above which QueueAdaptiveLIFO serves the newest waiter
+
+	codel   *codel       // nil without Config.CoDelTarget
+	dropped atomic.Int64 // waiters dropped by codel
+}
+
+type slotWaiter struct {
+	n          int64
+	ready      chan struct{} // closed when the slots are granted or the waiter is dropped
+	dropped    bool          // written under mu before closing ready
+	enqueuedAt time.Time
+}
+
+func newSlots(size int64) *slots {
+	s := &slots{size: size}
+	s.waiters.Init()
+	return s
+}
+
+// tryAcquire takes n slots if they are free and nobody is waiting.
+func (s *slots) tryAcquire(n int64) bool {
+	acquired, _ := s.poll(n)
+	return acquired
+}
+
+// poll takes n slots if they are free and nobody is waiting, like
+// tryAcquire. Also returns whether waiters are parked: a spinning acquisition
+// would not get the slots before them.
+func (s *slots) poll(n int64) (acquired, parked bool) {
+	s.mu.Lock()
+	parked = s.waiters.Len() > 0
+	acquired = !parked && s.inUse.Load()+n <= s.size
+	if acquired {
+		s.inUse.Add(n)
+	}
+	s.mu.Unlock()
+	return acquired, parked
+}
+
+// forceAcquire takes n slots even if they are not free, exceeding the size
+// until enough slots are released.
+func (s *slots) forceAcquire(n int64) {
+	s.mu.Lock()
+	s.inUse.Add(n)
+	s.mu.Unlock()
+}
+
+// acquire takes n slots, waiting in queue order until they are free or ctx is done.
+func (s *slots) acquire(ctx context.Context, n int64) error {
+	done := ctx.Done()
+
+	s.mu.Lock()
+	select {
+	case <-done:
+		s.mu.Unlock()
+		return ctx.Err()
+	default:
+	}
+
+	if s.inUse.Load()+n <= s.size && s.waiters.Len() == 0 {
+		s.inUse.Add(n)
+		s.mu.Unlock()
+		return nil
+	}
+
+	w := &slotWaiter{n: n, ready: make(chan struct{}), enqueuedAt: time.Now()}
+	elem := s.waiters.PushBack(w)
+	s.mu.Unlock()
+
+	select {
+	case <-done:
+		s.abandon(elem, w)
+		return ctx.Err()
+
+	case <-w.ready:
+		if w.dropped {
+			return errDropped
+		}
+		// Acquired the slots; prefer reporting cancellation if it raced.
+		select {
+		case <-done:
+			s.release(n)
+			return ctx.Err()
+		default:
+		}
+		return nil
+	}
+}
+
+// abandon removes a cancelled waiter. A slot is never lost nor granted twice
+// when a waiter cancels as a slot is freed: under mu, the waiter was either
+// granted the slot (ready is closed) or is still queued, and in both cases
+// the compensating wakeup hands the capacity to the next waiters.
+func (s *slots) abandon(elem *list.Element, w *slotWaiter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		if w.dropped {
+			return // nothing was granted
+		}
+		// Granted right as the context was done: hand the slots over.
+		s.inUse.Add(-w.n)
+		s.notifyWaiters()
+	default:
+		isNext := s.next() == elem
+		s.waiters.Remove(elem)
+		// The next waiter may fit now that the one served next left. With
+		// QueueAdaptiveLIFO, the shorter queue may also switch back to FIFO.
+		if isNext || s.discipline == QueueAdaptiveLIFO {
+			s.notifyWaiters()
+		}
+	}
+}
+
+// release returns n slots and wakes waiters that fit.
+func (s *slots) release(n int64) {
+	s.mu.Lock()
+	s.inUse.Add(-n)
+	s.notifyWaiters()
+	s.mu.Unlock()
+}
+
+// resize changes the number of slots and wakes waiters that fit.
+func (s *slots) resize(size int64) {
+	s.mu.Lock()
+	s.size = size
+	s.notifyWaiters()
+	s.mu.Unlock()
+}
+
+// setWaitingLimit drops the waiters beyond limit slots of queued weight,
+// starting with the ones served last, and updates the QueueAdaptiveLIFO
+// threshold.
+func (s *slots) setWaitingLimit(limit int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.adaptiveLIFOAbove = int(limit / 2)
+
+	var queued int64
+	for e := s.waiters.Front(); e != nil; e = e.Next() {
+		queued += e.Value.(*slotWaiter).n
+	}
+	for queued > limit {
+		last := s.waiters.Back()
+		if s.next() == last {
+			last = s.waiters.Front()
+		}
+		w := last.Value.(*slotWaiter)
+		s.waiters.Remove(last)
+		queued -= w.n
+		w.dropped = true
+		close(w.ready)
+	}
+
+	// With QueueAdaptiveLIFO, the order may have changed
+	s.notifyWaiters()
+}
+
+// notifyWaiters grants free slots to waiters in queue order, stopping at the
+// first waiter that does not fit. With codel, the waiters leaving the queue
+// may be dropped instead. Must hold mu.
+func (s *slots) notifyWaiters() {
+	var now time.Time
+	for {
+		next := s.next()
+		if next == nil {
+			return
+		}
+
+		w := next.Value.(*slotWaiter)
+		if s.inUse.Load()+w.n > s.size {
+			return
+		}
+		s.waiters.Remove(next)
+
+		if s.codel != nil {
+			if now.IsZero() {
+				now = time.Now()
+			}
+			if s.codel.drop(now, now.Sub(w.enqueuedAt)) {
+				w.dropped = true
+				s.dropped.Add(1)
+				close(w.ready)
+				continue
+			}
+		}
+
+		s.inUse.Add(w.n)
+		close(w.ready)
+	}
+}
+
+// oldestWait returns how long the oldest waiter has been waiting, 0 if none.
+func (s *slots) oldestWait(now time.Time) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Waiters are queued at the back, whatever the discipline
+	oldest := s.waiters.Front()
+	if oldest == nil {
+		return 0
+	}
+	return now.Sub(oldest.Value.(*slotWaiter).enqueuedAt)
+}
+
+// next returns the waiter to serve next, or nil. Must hold mu.
+func (s *slots) next() *list.Element {
+	switch {
+	case s.discipline == QueueLIFO,
+		s.discipline == QueueAdaptiveLIFO && s.waiters.Len() > s.adaptiveLIFOAbove:
+		return s.waiters.Back()
+	default:
+		return s.waiters.Front()
+	}
+}
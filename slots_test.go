@@ -0,0 +1,146 @@
+package loadshedder
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestSlots_CancellationRace stresses waiters cancelling exactly as slots are
+// freed: slots must never be lost (a stall after mass timeouts) nor granted
+// beyond the size (over-admission).
+func TestSlots_CancellationRace(t *testing.T) {
+	const size = 4
+	s := newSlots(size)
+
+	var holders, maxHolders atomic.Int64
+	var wg sync.WaitGroup
+	for range 200 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				// Timeouts on the order of the hold time maximize the races
+				ctx, cancel := context.WithTimeout(context.Background(), time.Duration(rand.IntN(100))*time.Microsecond)
+				err := s.acquire(ctx, 1)
+				cancel()
+				if err != nil {
+					continue
+				}
+
+				n := holders.Add(1)
+				for {
+					m := maxHolders.Load()
+					if n <= m || maxHolders.CompareAndSwap(m, n) {
+						break
+					}
+				}
+				time.Sleep(time.Duration(rand.IntN(50)) * time.Microsecond)
+				holders.Add(-1)
+				s.release(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if m := maxHolders.Load(); m > size {
+		t.Errorf("over-admission: %d concurrent holders with %d slots", m, size)
+	}
+	if inUse := s.inUse.Load(); inUse != 0 {
+		t.Errorf("expected all slots to be free, got %d in use", inUse)
+	}
+	if waiters := s.waiters.Len(); waiters != 0 {
+		t.Errorf("expected no waiters, got %d", waiters)
+	}
+
+	// No capacity was lost: all the slots can be taken right away
+	for range size {
+		if !s.tryAcquire(1) {
+			t.Fatal("expected a free slot after the mass timeouts")
+		}
+	}
+}
+
+func TestSlots_CancelledFrontWaiterWakesNext(t *testing.T) {
+	s := newSlots(1)
+	if !s.tryAcquire(1) {
+		t.Fatal("expected to acquire the slot")
+	}
+
+	frontCtx, cancelFront := context.WithCancel(context.Background())
+	frontDone := make(chan error)
+	go func() { frontDone <- s.acquire(frontCtx, 1) }()
+	waitFor(t, func() bool { return waiterCount(s) == 1 })
+
+	nextDone := make(chan error)
+	go func() { nextDone <- s.acquire(context.Background(), 1) }()
+	waitFor(t, func() bool { return waiterCount(s) == 2 })
+
+	// The slot is freed as the front waiter cancels: either way, the next
+	// waiter ends up with the slot
+	go s.release(1)
+	cancelFront()
+
+	if err := <-frontDone; err == nil {
+		s.release(1)
+	}
+	select {
+	case err := <-nextDone:
+		if err != nil {
+			t.Errorf("expected the next waiter to acquire the slot, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("lost wakeup: the next waiter never acquired the freed slot")
+	}
+
+	if inUse := s.inUse.Load(); inUse != 1 {
+		t.Errorf("expected 1 slot in use, got %d", inUse)
+	}
+}
+
+func TestSlots_WeightedWaiterBlocksLighterWaiters(t *testing.T) {
+	s := newSlots(4)
+	if !s.tryAcquire(3) {
+		t.Fatal("expected to acquire 3 slots")
+	}
+	if s.tryAcquire(2) {
+		t.Fatal("expected 2 slots not to fit with 3 of 4 in use")
+	}
+
+	heavyCtx, cancelHeavy := context.WithCancel(context.Background())
+	heavyDone := make(chan error)
+	go func() { heavyDone <- s.acquire(heavyCtx, 2) }()
+	waitFor(t, func() bool { return waiterCount(s) == 1 })
+
+	// A free slot is left, but the heavy waiter is first in line
+	lightDone := make(chan error)
+	go func() { lightDone <- s.acquire(context.Background(), 1) }()
+	waitFor(t, func() bool { return waiterCount(s) == 2 })
+
+	// Once the heavy waiter gives up, the light one fits
+	cancelHeavy()
+	if err := <-heavyDone; err == nil {
+		t.Fatal("expected the heavy waiter to be cancelled")
+	}
+	select {
+	case err := <-lightDone:
+		if err != nil {
+			t.Errorf("expected the light waiter to acquire a slot, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the light waiter stayed blocked behind a cancelled heavy waiter")
+	}
+
+	if inUse := s.inUse.Load(); inUse != 4 {
+		t.Errorf("expected 4 slots in use, got %d", inUse)
+	}
+}
+
+func waiterCount(s *slots) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.waiters.Len()
+}
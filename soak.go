@@ -0,0 +1,180 @@
+package loadshedder
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSoakInterval = 10 * time.Second
+
+	// Running is updated a moment before the counters when a slot is granted:
+	// a drift must persist over consecutive checks to be reported.
+	soakConsecutiveDrifts = 3
+)
+
+// SoakConfig configures the invariant checker, see CheckInvariants.
+type SoakConfig struct {
+	// Interval is the time between checks.
+	// Optional, default to 10s.
+	Interval time.Duration
+
+	// MaxTokenAge reports tokens held longer than this as leaked.
+	// Optional, default to 0 (no leak detection).
+	MaxTokenAge time.Duration
+
+	// Panic panics on violations instead of logging them, like Config.StrictMode.
+	// Optional.
+	Panic bool
+
+	// Logger receives the violations.
+	// Optional, default to slog.Default().
+	Logger *slog.Logger
+}
+
+// CheckInvariants starts a goroutine periodically verifying that the
+// loadshedder bookkeeping reconciles (Accepted - Released == Running) and,
+// with MaxTokenAge, that no token is held for too long. Violations are logged
+// with diagnostics, or panic with SoakConfig.Panic.
+// It is intended for test and staging environments, where token leaks would
+// otherwise only surface after days of uptime: leak detection tracks every
+// live token, which adds contention on the hot path.
+// Call the returned function to stop checking.
+func (l *Loadshedder) CheckInvariants(cfg SoakConfig) (stop func()) {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultSoakInterval
+	}
+	if cfg.Logger == nil {
+		cfg.Logger = slog.Default()
+	}
+
+	var tracker *tokenTracker
+	if cfg.MaxTokenAge > 0 {
+		tracker = &tokenTracker{tokens: make(map[*Token]struct{})}
+		l.tokens.Store(tracker)
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		ticker := time.NewTicker(cfg.Interval)
+		defer ticker.Stop()
+
+		drifts := 0
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			drifts = l.checkCounters(cfg, drifts)
+			if tracker != nil {
+				l.checkLeaks(cfg, tracker)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			wg.Wait()
+			if tracker != nil {
+				l.tokens.CompareAndSwap(tracker, nil)
+			}
+		})
+	}
+}
+
+// checkCounters verifies Accepted - Released == Running, and returns the
+// number of consecutive checks with a drift. Weighted tokens count for their
+// weight, like in Running.
+func (l *Loadshedder) checkCounters(cfg SoakConfig, drifts int) int {
+	// Read in this order, a correct bookkeeping never has Accepted - Released
+	// above Running, even under load (see release)
+	accepted := l.accepted.Load() + l.extraAcceptedWeight.Load()
+	stats := l.Stats()
+	released := l.released.Load() + l.extraReleasedWeight.Load()
+
+	outstanding := accepted - released
+	if outstanding > stats.Running || outstanding < 0 {
+		l.violation(cfg, "released more tokens than accepted", stats, "accepted", accepted, "released", released)
+		return 0
+	}
+
+	if outstanding == stats.Running {
+		return 0
+	}
+
+	drifts++
+	if drifts >= soakConsecutiveDrifts {
+		l.violation(cfg, "running requests without an accepted token", stats, "accepted", accepted, "released", released, "drift", stats.Running-outstanding)
+		return 0
+	}
+	return drifts
+}
+
+// checkLeaks reports the tokens held longer than MaxTokenAge.
+func (l *Loadshedder) checkLeaks(cfg SoakConfig, tracker *tokenTracker) {
+	leaked, oldest := tracker.olderThan(time.Now().Add(-cfg.MaxTokenAge))
+	if leaked == 0 {
+		return
+	}
+
+	l.violation(cfg, "tokens held longer than MaxTokenAge", l.Stats(),
+		"leaked", leaked,
+		"oldest_age", time.Since(oldest.AcceptedAt()),
+		"oldest_source", oldest.Source(),
+		"oldest_priority", oldest.Priority().String(),
+	)
+}
+
+func (l *Loadshedder) violation(cfg SoakConfig, msg string, stats Stats, args ...any) {
+	args = append(args, "name", stats.Name, "running", stats.Running, "waiting", stats.Waiting, "limit", stats.Limit)
+
+	if cfg.Panic || l.config.StrictMode {
+		panic(fmt.Sprintf("loadshedder: invariant violated: %s %v", msg, args))
+	}
+	cfg.Logger.Error("loadshedder: invariant violated: "+msg, args...)
+}
+
+// tokenTracker records the live tokens, for leak detection.
+type tokenTracker struct {
+	mu     sync.Mutex
+	tokens map[*Token]struct{}
+}
+
+func (t *tokenTracker) add(token *Token) {
+	t.mu.Lock()
+	t.tokens[token] = struct{}{}
+	t.mu.Unlock()
+}
+
+func (t *tokenTracker) remove(token *Token) {
+	t.mu.Lock()
+	delete(t.tokens, token)
+	t.mu.Unlock()
+}
+
+// olderThan returns the number of tokens accepted before cutoff, and the oldest one.
+func (t *tokenTracker) olderThan(cutoff time.Time) (count int, oldest *Token) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for token := range t.tokens {
+		if !token.AcceptedAt().Before(cutoff) {
+			continue
+		}
+		count++
+		if oldest == nil || token.AcceptedAt().Before(oldest.AcceptedAt()) {
+			oldest = token
+		}
+	}
+	return count, oldest
+}
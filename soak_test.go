@@ -0,0 +1,143 @@
+package loadshedder
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use, for log assertions.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestCheckInvariants_HealthyUnderLoad(t *testing.T) {
+	ls := New(Config{Limit: 5, WaitingLimit: 5})
+
+	// Any violation panics the test binary
+	stop := ls.CheckInvariants(SoakConfig{Interval: time.Millisecond, MaxTokenAge: time.Second, Panic: true})
+	defer stop()
+
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 200 {
+				_, token := ls.Acquire(context.Background())
+				ls.Release(token)
+			}
+		}()
+	}
+	wg.Wait()
+
+	counters := ls.Counters()
+	if counters.Accepted+counters.Rejected != 20*200 {
+		t.Errorf("expected %d acquisitions, got %+v", 20*200, counters)
+	}
+	if counters.Released != counters.Accepted {
+		t.Errorf("expected every accepted token to be released, got %+v", counters)
+	}
+}
+
+func TestCheckInvariants_WeightedTokens(t *testing.T) {
+	ls := New(Config{Limit: 6, WaitingLimit: 6})
+
+	stop := ls.CheckInvariants(SoakConfig{Interval: time.Millisecond, Panic: true})
+	defer stop()
+
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 200 {
+				_, token := ls.AcquireN(context.Background(), 1+i%3)
+				ls.Release(token)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if stats := ls.Stats(); stats.Running != 0 || stats.Waiting != 0 {
+		t.Errorf("expected no running nor waiting requests, got %+v", stats)
+	}
+}
+
+func TestCheckInvariants_TokenLeak(t *testing.T) {
+	ls := New(Config{Name: "api", Limit: 5})
+	logs := &syncBuffer{}
+
+	stop := ls.CheckInvariants(SoakConfig{
+		Interval:    time.Millisecond,
+		MaxTokenAge: 10 * time.Millisecond,
+		Logger:      slog.New(slog.NewTextHandler(logs, nil)),
+	})
+	defer stop()
+
+	_, leaked := ls.Acquire(context.Background(), WithSource("cron"))
+	defer ls.Release(leaked)
+
+	// Released tokens are not reported
+	_, token := ls.Acquire(context.Background())
+	ls.Release(token)
+
+	waitFor(t, func() bool { return strings.Contains(logs.String(), "tokens held longer than MaxTokenAge") })
+
+	output := logs.String()
+	for _, expected := range []string{"leaked=1", "oldest_source=cron", "name=api"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("expected %q in diagnostics, got %s", expected, output)
+		}
+	}
+}
+
+func TestCheckInvariants_CounterDrift(t *testing.T) {
+	ls := New(Config{Limit: 5})
+	logs := &syncBuffer{}
+
+	stop := ls.CheckInvariants(SoakConfig{Interval: time.Millisecond, Logger: slog.New(slog.NewTextHandler(logs, nil))})
+	defer stop()
+
+	// Simulate a slot held without a token
+	ls.slots.inUse.Add(1)
+	waitFor(t, func() bool { return strings.Contains(logs.String(), "running requests without an accepted token") })
+	ls.slots.inUse.Add(-1)
+
+	// Simulate a double release
+	ls.released.Add(1)
+	waitFor(t, func() bool { return strings.Contains(logs.String(), "released more tokens than accepted") })
+}
+
+func TestCheckInvariants_Stop(t *testing.T) {
+	ls := New(Config{Limit: 5})
+
+	stop := ls.CheckInvariants(SoakConfig{Interval: time.Millisecond, MaxTokenAge: time.Second})
+	if ls.tokens.Load() == nil {
+		t.Fatal("expected live tokens to be tracked")
+	}
+
+	stop()
+	stop()
+
+	if ls.tokens.Load() != nil {
+		t.Error("expected token tracking to stop")
+	}
+}
@@ -0,0 +1,172 @@
+package loadshedder
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"math"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultSoftRejectHeader     = "Loadshedder-Queue-Token"
+	defaultSoftRejectMaxAge     = 30 * time.Second
+	defaultSoftRejectRetryAfter = time.Second
+
+	// softRejectMACSize is the size of the truncated HMAC-SHA256 of a token.
+	softRejectMACSize = 16
+)
+
+// SoftRejectConfig configures the client-directed queueing, see
+// WithSoftReject.
+type SoftRejectConfig struct {
+	// Key signs the queue tokens, so clients cannot forge an earlier arrival.
+	// Instances behind a load balancer must share it to honor the tokens
+	// issued by each other.
+	// Optional, default to a random key: tokens are only honored by the
+	// middleware that issued them.
+	Key []byte
+
+	// Header carries the queue token, in the advisory response and in the
+	// retry.
+	// Optional, default to "Loadshedder-Queue-Token".
+	Header string
+
+	// MaxAge is the time since the original arrival during which a token is
+	// honored. Retries with an older token are handled as new requests.
+	// Optional, default to 30s.
+	MaxAge time.Duration
+
+	// RetryAfter is the delay advised to the client, in the Retry-After
+	// header (rounded up to the second).
+	// Optional, default to 1s.
+	RetryAfter time.Duration
+
+	// Status is the status of the advisory response.
+	// Optional, default to 503 (Service Unavailable).
+	Status int
+}
+
+// WithSoftReject enables client-directed queueing, an experimental mode for
+// very high-fanout public APIs, whose API may change. Instead of waiting in
+// the server-side queue, a new request that finds no free slot is answered
+// right away with an advisory response: the Status, a Retry-After header
+// and a signed queue token in the Header, carrying its arrival time. The
+// memory and the connection of the waiting request are then held by the
+// client rather than the server.
+// A retry carrying a valid token keeps its place: it waits for a slot in the
+// regular queue (up to its context or Config.MaxQueueWait), ahead of the new
+// requests, which never wait. If it is rejected again, the advisory response
+// carries its original arrival time, until the token expires after MaxAge.
+// The advisory responses replace the rejection handler, except while
+// draining, and are reported as rejections. See SoftRejections.
+func WithSoftReject(cfg SoftRejectConfig) MiddlewareOption {
+	s := newSoftRejecter(cfg)
+	return func(m *Middleware) {
+		m.softReject = s
+	}
+}
+
+// SoftRejections returns the number of advisory responses served, see
+// WithSoftReject.
+func (m *Middleware) SoftRejections() int64 {
+	if m.softReject == nil {
+		return 0
+	}
+	return m.softReject.served.Load()
+}
+
+type softRejecter struct {
+	key        []byte
+	header     string
+	maxAge     time.Duration
+	retryAfter string
+	status     int
+
+	served atomic.Int64
+}
+
+func newSoftRejecter(cfg SoftRejectConfig) *softRejecter {
+	if cfg.MaxAge < 0 || cfg.RetryAfter < 0 {
+		panic("loadshedder: SoftRejectConfig.MaxAge and SoftRejectConfig.RetryAfter cannot be negative")
+	}
+	if cfg.Status != 0 && (cfg.Status < 200 || cfg.Status > 599) {
+		panic("loadshedder: SoftRejectConfig.Status must be a final status")
+	}
+
+	key := cfg.Key
+	if len(key) == 0 {
+		key = make([]byte, sha256.Size)
+		_, _ = rand.Read(key)
+	}
+	if cfg.Header == "" {
+		cfg.Header = defaultSoftRejectHeader
+	}
+	if cfg.MaxAge == 0 {
+		cfg.MaxAge = defaultSoftRejectMaxAge
+	}
+	if cfg.RetryAfter == 0 {
+		cfg.RetryAfter = defaultSoftRejectRetryAfter
+	}
+	if cfg.Status == 0 {
+		cfg.Status = http.StatusServiceUnavailable
+	}
+
+	return &softRejecter{
+		key:        key,
+		header:     http.CanonicalHeaderKey(cfg.Header),
+		maxAge:     cfg.MaxAge,
+		retryAfter: strconv.Itoa(int(math.Ceil(cfg.RetryAfter.Seconds()))),
+		status:     cfg.Status,
+	}
+}
+
+// arrival returns the original arrival time of the request from its token,
+// or false if it carries no valid token.
+func (s *softRejecter) arrival(r *http.Request, now time.Time) (time.Time, bool) {
+	token := r.Header.Get(s.header)
+	if token == "" {
+		return time.Time{}, false
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(data) != 8+softRejectMACSize {
+		return time.Time{}, false
+	}
+	if !hmac.Equal(data[8:], s.mac(data[:8])) {
+		return time.Time{}, false
+	}
+
+	arrival := time.Unix(0, int64(binary.BigEndian.Uint64(data[:8])))
+	if age := now.Sub(arrival); age < 0 || age > s.maxAge {
+		return time.Time{}, false
+	}
+	return arrival, true
+}
+
+// token returns the queue token of a request arrived at arrival.
+func (s *softRejecter) token(arrival time.Time) string {
+	data := binary.BigEndian.AppendUint64(make([]byte, 0, 8+softRejectMACSize), uint64(arrival.UnixNano()))
+	return base64.RawURLEncoding.EncodeToString(append(data, s.mac(data)...))
+}
+
+func (s *softRejecter) mac(data []byte) []byte {
+	h := hmac.New(sha256.New, s.key)
+	h.Write(data)
+	return h.Sum(nil)[:softRejectMACSize]
+}
+
+// serve responds with the advisory response of a request arrived at arrival.
+func (s *softRejecter) serve(w http.ResponseWriter, arrival time.Time) {
+	s.served.Add(1)
+
+	w.Header().Set(s.header, s.token(arrival))
+	w.Header().Set("Retry-After", s.retryAfter)
+	w.WriteHeader(s.status)
+	_, _ = w.Write([]byte("Retry with the queue token\n"))
+}
@@ -0,0 +1,134 @@
+package loadshedder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMiddleware_WithSoftReject(t *testing.T) {
+	ls := New(Config{Limit: 1, WaitingLimit: 5, MaxQueueWait: 20 * time.Millisecond})
+	reporter := &statsRecordingReporter{}
+	mw := NewMiddleware(ls, reporter, nil, WithSoftReject(SoftRejectConfig{Key: []byte("secret")}))
+	handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// Hold the only slot
+	_, held := ls.Acquire(context.Background())
+
+	// A new request does not wait for a slot
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "1" {
+		t.Fatalf("expected an advisory 503 with Retry-After: 1, got %d and %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if elapsed := time.Since(start); elapsed >= 20*time.Millisecond {
+		t.Errorf("expected an immediate advisory response, took %v", elapsed)
+	}
+	token := rec.Header().Get("Loadshedder-Queue-Token")
+	if token == "" {
+		t.Fatal("expected a queue token")
+	}
+
+	// A retry rejected again keeps its arrival time
+	retry := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+	retry.Header.Set("Loadshedder-Queue-Token", token)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, retry)
+
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Loadshedder-Queue-Token") != token {
+		t.Errorf("expected the same queue token, got %d and %q", rec.Code, rec.Header().Get("Loadshedder-Queue-Token"))
+	}
+	if stats := reporter.rejected[1]; stats.WaitTime < 20*time.Millisecond {
+		t.Errorf("expected the retry to wait in the queue, waited %v", stats.WaitTime)
+	}
+
+	// A retry waits for the slot
+	time.AfterFunc(5*time.Millisecond, func() { ls.Release(held) })
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, retry)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected the retry to be admitted, got %d", rec.Code)
+	}
+	if mw.SoftRejections() != 2 || len(reporter.rejected) != 2 || len(reporter.accepted) != 1 {
+		t.Errorf("expected 2 soft rejections reported, got %d, %d rejected and %d accepted", mw.SoftRejections(), len(reporter.rejected), len(reporter.accepted))
+	}
+}
+
+func TestMiddleware_WithSoftRejectDraining(t *testing.T) {
+	ls := New(Config{Limit: 1})
+	mw := NewMiddleware(ls, nil, nil, WithSoftReject(SoftRejectConfig{}))
+	ls.Drain(context.Background())
+
+	rec := httptest.NewRecorder()
+	mw.Handler(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Loadshedder-Queue-Token") != "" {
+		t.Errorf("expected the rejection handler while draining, got %d", rec.Code)
+	}
+	if mw.SoftRejections() != 0 {
+		t.Errorf("expected no soft rejection, got %d", mw.SoftRejections())
+	}
+}
+
+func TestSoftRejecter_Arrival(t *testing.T) {
+	s := newSoftRejecter(SoftRejectConfig{Header: "X-Queue", MaxAge: time.Minute})
+	now := time.Now()
+	arrival := now.Add(-time.Second)
+	token := s.token(arrival)
+
+	forged := []byte(token)
+	forged[0] ^= 1
+
+	tests := map[string]struct {
+		token string
+		now   time.Time
+		valid bool
+	}{
+		"valid":   {token, now, true},
+		"expired": {token, now.Add(time.Minute), false},
+		"future":  {token, arrival.Add(-time.Second), false},
+		"forged":  {string(forged), now, false},
+		"foreign": {newSoftRejecter(SoftRejectConfig{}).token(arrival), now, false},
+		"garbage": {"not a token", now, false},
+		"missing": {"", now, false},
+	}
+
+	for name, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+		r.Header.Set("X-Queue", tt.token)
+
+		got, ok := s.arrival(r, tt.now)
+		if ok != tt.valid {
+			t.Errorf("%s: expected valid=%v, got %v", name, tt.valid, ok)
+		}
+		if ok && !got.Equal(arrival.Round(0)) {
+			t.Errorf("%s: expected arrival %v, got %v", name, arrival, got)
+		}
+	}
+}
+
+func TestSoftRejectConfigValidation(t *testing.T) {
+	tests := map[string]SoftRejectConfig{
+		"negative max age":     {MaxAge: -time.Second},
+		"negative retry after": {RetryAfter: -time.Second},
+		"informational status": {Status: http.StatusEarlyHints},
+	}
+
+	for name, cfg := range tests {
+		t.Run(name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected a panic")
+				}
+			}()
+			WithSoftReject(cfg)
+		})
+	}
+}
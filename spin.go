@@ -0,0 +1,54 @@
+package loadshedder
+
+import (
+	"context"
+	"runtime"
+	"time"
+)
+
+// SpinAdmissions returns the number of acquisitions admitted while spinning,
+// before parking in the waiting queue, see Config.SpinWait. They are also
+// counted as queued admissions.
+func (l *Loadshedder) SpinAdmissions() int64 {
+	return l.spinAdmissions.Load()
+}
+
+// spinAcquire takes n slots by yielding the processor until they are free,
+// for up to Config.SpinWait, when a slot is expected to be freed within it.
+// Returns false to park in the waiting queue, right away if waiters are
+// already parked: it never overtakes them.
+func (l *Loadshedder) spinAcquire(ctx context.Context, n int64) bool {
+	if interval := l.freeSlotInterval(); interval <= 0 || interval > l.config.SpinWait {
+		return false
+	}
+
+	done := ctx.Done()
+	deadline := time.Now().Add(l.config.SpinWait)
+	for {
+		runtime.Gosched()
+		acquired, parked := l.slots.poll(n)
+		if acquired {
+			l.spinAdmissions.Add(1)
+			return true
+		}
+		if parked {
+			return false
+		}
+
+		select {
+		case <-done:
+			return false
+		default:
+		}
+		if !time.Now().Before(deadline) {
+			return false
+		}
+	}
+}
+
+// freeSlotInterval returns the expected interval between two slots being
+// freed: the average duration spread over the effective limit, 0 before the
+// first release.
+func (l *Loadshedder) freeSlotInterval() time.Duration {
+	return time.Duration(l.avgDuration.Load() / max(1, l.effectiveLimit.Load()))
+}
@@ -0,0 +1,122 @@
+package loadshedder
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestLoadshedder_SpinWait(t *testing.T) {
+	ls := New(Config{Limit: 1, WaitingLimit: 1, SpinWait: time.Second})
+	ls.avgDuration.Store(int64(time.Millisecond))
+
+	_, held := ls.Acquire(context.Background())
+	go func() {
+		time.Sleep(time.Millisecond)
+		ls.Release(held)
+	}()
+
+	_, token := ls.Acquire(context.Background())
+	defer ls.Release(token)
+
+	if !token.Accepted() || !token.Queued() {
+		t.Fatalf("expected a queued admission, got accepted=%v queued=%v", token.Accepted(), token.Queued())
+	}
+	if ls.SpinAdmissions() != 1 {
+		t.Errorf("expected 1 spin admission, got %d", ls.SpinAdmissions())
+	}
+	if ls.slots.waiters.Len() != 0 {
+		t.Errorf("expected no parked waiter, got %d", ls.slots.waiters.Len())
+	}
+}
+
+func TestLoadshedder_SpinWaitParks(t *testing.T) {
+	tests := map[string]time.Duration{
+		"slow handlers": time.Second,
+		"no duration":   0,
+	}
+	for name, avgDuration := range tests {
+		t.Run(name, func(t *testing.T) {
+			ls := New(Config{Limit: 1, WaitingLimit: 1, SpinWait: time.Millisecond})
+			ls.avgDuration.Store(int64(avgDuration))
+
+			_, held := ls.Acquire(context.Background())
+			go func() {
+				// Released once the acquisition is parked
+				for ls.slots.oldestWait(time.Now()) == 0 {
+					time.Sleep(time.Millisecond)
+				}
+				ls.Release(held)
+			}()
+
+			_, token := ls.Acquire(context.Background())
+			defer ls.Release(token)
+
+			if !token.Accepted() || !token.Queued() {
+				t.Fatalf("expected a queued admission, got accepted=%v queued=%v", token.Accepted(), token.Queued())
+			}
+			if ls.SpinAdmissions() != 0 {
+				t.Errorf("expected the acquisition to park right away, got %d spin admissions", ls.SpinAdmissions())
+			}
+		})
+	}
+}
+
+func TestLoadshedder_SpinWaitTimesOut(t *testing.T) {
+	ls := New(Config{Limit: 1, WaitingLimit: 1, SpinWait: time.Millisecond, MaxQueueWait: 20 * time.Millisecond})
+	ls.avgDuration.Store(int64(time.Microsecond))
+
+	_, held := ls.Acquire(context.Background())
+	defer ls.Release(held)
+
+	_, token := ls.Acquire(context.Background())
+	if token.Accepted() {
+		t.Fatal("expected a rejection once the wait exceeds MaxQueueWait")
+	}
+	if token.WaitTime() < 20*time.Millisecond {
+		t.Errorf("expected the acquisition to park after spinning, waited %v", token.WaitTime())
+	}
+	if ls.SpinAdmissions() != 0 {
+		t.Errorf("expected no spin admission, got %d", ls.SpinAdmissions())
+	}
+}
+
+func TestLoadshedder_SpinWaitValidation(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for a negative SpinWait")
+		}
+	}()
+	New(Config{Limit: 1, SpinWait: -time.Microsecond})
+}
+
+// BenchmarkLimiter_SpinWait compares spinning with parking right away, with
+// more goroutines than slots and handlers of about a microsecond: the
+// acquisitions mostly wait.
+func BenchmarkLimiter_SpinWait(b *testing.B) {
+	for _, spinWait := range []time.Duration{0, 20 * time.Microsecond} {
+		name := "park"
+		if spinWait > 0 {
+			name = "spin"
+		}
+		b.Run(name, func(b *testing.B) {
+			ctx := context.Background()
+			limit := max(1, runtime.GOMAXPROCS(0)/2)
+			ls := New(Config{Limit: int64(limit), WaitingLimit: 1 << 20, SpinWait: spinWait})
+
+			b.SetParallelism(2)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					_, token := ls.Acquire(ctx)
+					start := time.Now()
+					for time.Since(start) < time.Microsecond {
+					}
+					ls.Release(token)
+				}
+			})
+			b.ReportMetric(float64(ls.SpinAdmissions())/float64(b.N), "spins/op")
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package loadshedder
+
+import (
+	"context"
+	"time"
+)
+
+// StartupMode selects how acquisitions are handled until Ready is called,
+// see Config.Startup.
+type StartupMode int32
+
+const (
+	// StartEnforcing enforces the limits from the start (Ready has no effect).
+	StartEnforcing StartupMode = iota
+
+	// StartAcceptAll accepts every acquisition until Ready is called.
+	StartAcceptAll
+
+	// StartRejectAll rejects every acquisition until Ready is called.
+	StartRejectAll
+)
+
+func (m StartupMode) String() string {
+	switch m {
+	case StartEnforcing:
+		return "enforcing"
+	case StartAcceptAll:
+		return "accept-all"
+	case StartRejectAll:
+		return "reject-all"
+	default:
+		return "unknown"
+	}
+}
+
+// Ready starts enforcing the limits of a loadshedder created with a
+// Config.Startup mode, e.g. once its dependencies are warmed up. Requests
+// accepted before Ready keep running: new requests are admitted once the
+// running requests are back under the limit. Safe to call several times.
+func (l *Loadshedder) Ready() {
+	l.limitMu.Lock()
+	defer l.limitMu.Unlock()
+
+	l.updateSettings(func(s *settings) { s.startup = StartEnforcing })
+}
+
+// Starting returns true until Ready is called on a loadshedder created with a
+// Config.Startup mode.
+func (l *Loadshedder) Starting() bool {
+	return l.settings.Load().startup != StartEnforcing
+}
+
+// ReadyWhen polls check every interval and calls Ready as soon as it returns
+// nil, e.g. with a check pinging the database and the caches.
+// Returns the context error if ctx is done before check succeeds.
+func (l *Loadshedder) ReadyWhen(ctx context.Context, interval time.Duration, check func(context.Context) error) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if check(ctx) == nil {
+			l.Ready()
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package loadshedder
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLoadshedder_StartAcceptAll(t *testing.T) {
+	for _, waitingLimit := range []int64{0, 1} {
+		ls := New(Config{Limit: 1, WaitingLimit: waitingLimit, Startup: StartAcceptAll})
+
+		_, first := ls.Acquire(context.Background())
+		_, second := ls.Acquire(context.Background())
+		if !first.Accepted() || !second.Accepted() {
+			t.Fatalf("waitingLimit=%d: expected every acquisition accepted while starting", waitingLimit)
+		}
+		if stats := ls.Stats(); stats.Running != 2 || stats.AvgDuration != 0 {
+			t.Errorf("waitingLimit=%d: expected 2 running and no duration recorded, got %+v", waitingLimit, stats)
+		}
+		ls.Release(first)
+
+		ls.Ready()
+		if ls.Starting() {
+			t.Error("expected Ready to end the startup")
+		}
+
+		// second still holds the only slot
+		_, third := ls.Acquire(context.Background(), WithNoWait())
+		if third.Accepted() {
+			t.Errorf("waitingLimit=%d: expected the limit to be enforced once ready", waitingLimit)
+		}
+
+		ls.Release(second)
+		_, fourth := ls.Acquire(context.Background())
+		if !fourth.Accepted() {
+			t.Errorf("waitingLimit=%d: expected a slot once the startup requests are done", waitingLimit)
+		}
+		ls.Release(fourth)
+	}
+}
+
+func TestLoadshedder_StartRejectAll(t *testing.T) {
+	ls := New(Config{Limit: 1, Startup: StartRejectAll})
+
+	if !ls.Starting() {
+		t.Fatal("expected the loadshedder to be starting")
+	}
+	if _, token := ls.Acquire(context.Background()); token.Accepted() {
+		t.Error("expected acquisitions rejected while starting")
+	}
+
+	ls.Ready()
+	_, token := ls.Acquire(context.Background())
+	if !token.Accepted() {
+		t.Error("expected acquisitions accepted once ready")
+	}
+	ls.Release(token)
+}
+
+func TestLoadshedder_ReadyWhen(t *testing.T) {
+	ls := New(Config{Limit: 1, Startup: StartRejectAll})
+
+	checks := 0
+	err := ls.ReadyWhen(context.Background(), time.Millisecond, func(context.Context) error {
+		checks++
+		if checks < 3 {
+			return errors.New("warming up")
+		}
+		return nil
+	})
+	if err != nil || ls.Starting() || checks != 3 {
+		t.Errorf("expected ready after 3 checks, got err=%v starting=%t checks=%d", err, ls.Starting(), checks)
+	}
+
+	ls = New(Config{Limit: 1, Startup: StartRejectAll})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err = ls.ReadyWhen(ctx, time.Millisecond, func(context.Context) error { return errors.New("down") })
+	if !errors.Is(err, context.DeadlineExceeded) || !ls.Starting() {
+		t.Errorf("expected to stay starting when the check never succeeds, got %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+package loadshedder
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// StatsResponse is the JSON document served by NewStatsHandler.
+type StatsResponse struct {
+	Name            string `json:"name,omitempty"`
+	Running         int64  `json:"running"`
+	Waiting         int64  `json:"waiting"`
+	Limit           int64  `json:"limit"`
+	ConfiguredLimit int64  `json:"configured_limit"`
+	AvgDurationMs   int64  `json:"avg_duration_ms"`
+
+	FastAdmissions   int64 `json:"fast_admissions"`
+	QueuedAdmissions int64 `json:"queued_admissions"`
+
+	// Counters are the totals since the loadshedder was created.
+	Counters CountersResponse `json:"counters"`
+
+	// Since are the counters accumulated since the checkpoint passed with
+	// ?since=, absent without it. If the checkpoint is from another
+	// loadshedder instance (e.g. before a restart), Reset is true and Since
+	// equals Counters.
+	Since *CountersResponse `json:"since,omitempty"`
+	Reset bool              `json:"reset,omitempty"`
+
+	// Checkpoint is an opaque token to pass as ?since= on the next poll.
+	Checkpoint string `json:"checkpoint"`
+}
+
+// CountersResponse is the JSON representation of Counters.
+type CountersResponse struct {
+	Accepted int64 `json:"accepted"`
+	Rejected int64 `json:"rejected"`
+	Released int64 `json:"released"`
+}
+
+// NewStatsHandler serves the loadshedder Stats and Counters as JSON.
+// Each response carries a checkpoint: polling with ?since=<checkpoint> also
+// returns the counters accumulated since, for integrations that cannot
+// compute rates from totals. Checkpoints are stateless, so any number of
+// pollers can use the handler concurrently.
+func NewStatsHandler(ls *Loadshedder) http.Handler {
+	epoch := newStatsEpoch()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := newStatsResponse(ls, epoch, r.URL.Query().Get("since"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		_ = json.NewEncoder(w).Encode(resp)
+	})
+}
+
+// newStatsResponse returns the stats document, with the counters accumulated
+// since the checkpoint if since is not empty.
+func newStatsResponse(ls *Loadshedder, epoch, since string) (StatsResponse, error) {
+	counters := ls.Counters()
+	stats := ls.Stats()
+
+	resp := StatsResponse{
+		Name:             stats.Name,
+		Running:          stats.Running,
+		Waiting:          stats.Waiting,
+		Limit:            stats.EffectiveLimit,
+		ConfiguredLimit:  stats.ConfiguredLimit,
+		AvgDurationMs:    stats.AvgDuration.Milliseconds(),
+		FastAdmissions:   stats.FastAdmissions,
+		QueuedAdmissions: stats.QueuedAdmissions,
+		Counters:         CountersResponse(counters),
+		Checkpoint:       formatCheckpoint(epoch, counters),
+	}
+
+	if since != "" {
+		checkpointEpoch, previous, err := parseCheckpoint(since)
+		if err != nil {
+			return StatsResponse{}, err
+		}
+
+		delta := CountersResponse(counters)
+		if checkpointEpoch == epoch {
+			delta.Accepted -= previous.Accepted
+			delta.Rejected -= previous.Rejected
+			delta.Released -= previous.Released
+		} else {
+			resp.Reset = true
+		}
+		resp.Since = &delta
+	}
+
+	return resp, nil
+}
+
+// newStatsEpoch returns a random identifier of the handler instance for the
+// checkpoints, so a checkpoint from before a restart is detected instead of
+// producing negative deltas.
+func newStatsEpoch() string {
+	return strconv.FormatUint(rand.Uint64()>>16, 36)
+}
+
+func formatCheckpoint(epoch string, c Counters) string {
+	return fmt.Sprintf("%s.%s.%s.%s", epoch,
+		strconv.FormatInt(c.Accepted, 36),
+		strconv.FormatInt(c.Rejected, 36),
+		strconv.FormatInt(c.Released, 36))
+}
+
+func parseCheckpoint(s string) (string, Counters, error) {
+	fields := strings.Split(s, ".")
+	if len(fields) != 4 {
+		return "", Counters{}, fmt.Errorf("loadshedder: invalid checkpoint %q", s)
+	}
+
+	var values [3]int64
+	for i, field := range fields[1:] {
+		value, err := strconv.ParseInt(field, 36, 64)
+		if err != nil || value < 0 {
+			return "", Counters{}, fmt.Errorf("loadshedder: invalid checkpoint %q", s)
+		}
+		values[i] = value
+	}
+
+	return fields[0], Counters{Accepted: values[0], Rejected: values[1], Released: values[2]}, nil
+}
@@ -0,0 +1,89 @@
+package loadshedder
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestStatsHandler(t *testing.T) {
+	ls := New(Config{Name: "api", Limit: 1})
+	handler := NewStatsHandler(ls)
+
+	get := func(since string) (StatsResponse, int) {
+		target := "/stats"
+		if since != "" {
+			target += "?since=" + url.QueryEscape(since)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, http.NoBody))
+
+		var resp StatsResponse
+		if rec.Code == http.StatusOK {
+			if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return resp, rec.Code
+	}
+
+	_, token := ls.Acquire(context.Background())
+	ls.Acquire(context.Background()) // rejected
+
+	first, _ := get("")
+	if first.Name != "api" || first.Running != 1 || first.Limit != 1 {
+		t.Errorf("unexpected stats: %+v", first)
+	}
+	if first.Counters != (CountersResponse{Accepted: 1, Rejected: 1}) {
+		t.Errorf("unexpected counters: %+v", first.Counters)
+	}
+	if first.FastAdmissions != 1 || first.QueuedAdmissions != 0 {
+		t.Errorf("expected 1 fast admission, got %+v", first)
+	}
+	if first.Since != nil {
+		t.Errorf("expected no deltas without a checkpoint, got %+v", first.Since)
+	}
+
+	ls.Release(token)
+	ls.Acquire(context.Background())
+	ls.Acquire(context.Background())
+
+	second, _ := get(first.Checkpoint)
+	if second.Since == nil || *second.Since != (CountersResponse{Accepted: 1, Rejected: 1, Released: 1}) {
+		t.Errorf("unexpected deltas: %+v", second.Since)
+	}
+	if second.Reset {
+		t.Error("expected no reset")
+	}
+
+	// Polling again from the new checkpoint: nothing happened
+	third, _ := get(second.Checkpoint)
+	if third.Since == nil || *third.Since != (CountersResponse{}) {
+		t.Errorf("expected empty deltas, got %+v", third.Since)
+	}
+
+	// A checkpoint from another instance returns the totals
+	other, _ := get(otherCheckpoint(t))
+	if !other.Reset || *other.Since != other.Counters {
+		t.Errorf("expected a reset with the totals, got %+v", other)
+	}
+
+	if _, code := get("garbage"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid checkpoint, got %d", code)
+	}
+}
+
+// otherCheckpoint returns a checkpoint from another handler instance.
+func otherCheckpoint(t *testing.T) string {
+	rec := httptest.NewRecorder()
+	NewStatsHandler(New(Config{Limit: 1})).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	var resp StatsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Checkpoint
+}
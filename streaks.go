@@ -0,0 +1,233 @@
+package loadshedder
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RejectStreakConfig configures the tracking of clients rejected repeatedly,
+// see WithRejectStreaks.
+type RejectStreakConfig struct {
+	// Key identifies the client of a request, e.g. RemoteIPKey or an API key.
+	// Requests with an empty key are not tracked.
+	// Required.
+	Key func(*http.Request) string
+
+	// Window is the time after which a client's streak is forgotten if it
+	// was not rejected again.
+	// Optional, default to 1m.
+	Window time.Duration
+
+	// EscalateAfter is the number of consecutive rejections after which the
+	// client gets a 503 with Connection: close instead of the rejection handler.
+	// Optional, default to 10.
+	EscalateAfter int
+
+	// MaxRetryAfter caps the escalated Retry-After.
+	// Optional, default to 60s.
+	MaxRetryAfter time.Duration
+
+	// MaxKeys bounds the number of tracked clients; new clients are not
+	// tracked while the table is full of active streaks.
+	// Optional, default to 10000.
+	MaxKeys int
+}
+
+// WithRejectStreaks tracks how many times each client was rejected in a row
+// and escalates for clients that ignore backoff: the Retry-After header set
+// by the rejection handler is doubled for every consecutive rejection (up to
+// MaxRetryAfter), and after EscalateAfter rejections the client gets a 503
+// with Connection: close. An accepted request resets the client's streak.
+func WithRejectStreaks(cfg RejectStreakConfig) MiddlewareOption {
+	streaks := newRejectStreaks(cfg)
+	return func(m *Middleware) {
+		m.streaks = streaks
+	}
+}
+
+// RemoteIPKey returns the IP address of the client connection.
+// Behind a proxy, use a key from a trusted forwarding header instead.
+func RemoteIPKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+type rejectStreak struct {
+	count        int
+	lastRejected time.Time
+}
+
+type rejectStreaks struct {
+	key           func(*http.Request) string
+	window        time.Duration
+	escalateAfter int
+	maxRetryAfter time.Duration
+	maxKeys       int
+
+	// The streaks are written under mu, and looked up without it by accepted:
+	// admitted requests only take the lock when their client has a streak.
+	mu      sync.Mutex
+	streaks sync.Map // client key -> *rejectStreak
+	tracked atomic.Int64
+}
+
+func newRejectStreaks(cfg RejectStreakConfig) *rejectStreaks {
+	if cfg.Key == nil {
+		panic("loadshedder: RejectStreakConfig.Key is required")
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = time.Minute
+	}
+	if cfg.EscalateAfter <= 0 {
+		cfg.EscalateAfter = 10
+	}
+	if cfg.MaxRetryAfter <= 0 {
+		cfg.MaxRetryAfter = time.Minute
+	}
+	if cfg.MaxKeys <= 0 {
+		cfg.MaxKeys = 10000
+	}
+
+	return &rejectStreaks{
+		key:           cfg.Key,
+		window:        cfg.Window,
+		escalateAfter: cfg.EscalateAfter,
+		maxRetryAfter: cfg.MaxRetryAfter,
+		maxKeys:       cfg.MaxKeys,
+	}
+}
+
+// rejected records a rejection and returns the client's streak length
+// (0 if the client is not tracked).
+func (s *rejectStreaks) rejected(r *http.Request) int {
+	key := s.key(r)
+	if key == "" {
+		return 0
+	}
+
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var streak *rejectStreak
+	if value, ok := s.streaks.Load(key); ok {
+		streak = value.(*rejectStreak)
+	} else {
+		if s.tracked.Load() >= int64(s.maxKeys) {
+			s.evictExpired(now)
+			if s.tracked.Load() >= int64(s.maxKeys) {
+				return 0
+			}
+		}
+		streak = &rejectStreak{}
+		s.streaks.Store(key, streak)
+		s.tracked.Add(1)
+	}
+
+	if now.Sub(streak.lastRejected) > s.window {
+		streak.count = 0
+	}
+	streak.count++
+	streak.lastRejected = now
+
+	return streak.count
+}
+
+// accepted resets the client's streak. Without any streak, the client key is
+// not even computed.
+func (s *rejectStreaks) accepted(r *http.Request) {
+	if s.tracked.Load() == 0 {
+		return
+	}
+
+	key := s.key(r)
+	if key == "" {
+		return
+	}
+	if _, ok := s.streaks.Load(key); !ok {
+		return
+	}
+
+	s.mu.Lock()
+	if _, ok := s.streaks.LoadAndDelete(key); ok {
+		s.tracked.Add(-1)
+	}
+	s.mu.Unlock()
+}
+
+// evictExpired removes the streaks outside the window. Must hold mu.
+func (s *rejectStreaks) evictExpired(now time.Time) {
+	s.streaks.Range(func(key, value any) bool {
+		if now.Sub(value.(*rejectStreak).lastRejected) > s.window {
+			s.streaks.Delete(key)
+			s.tracked.Add(-1)
+		}
+		return true
+	})
+}
+
+// serveRejected responds to a rejected request according to the client's streak.
+func (s *rejectStreaks) serveRejected(handler http.Handler, w http.ResponseWriter, r *http.Request, streak int) {
+	if streak >= s.escalateAfter {
+		w.Header().Set("Connection", "close")
+		w.Header().Set("Retry-After", strconv.Itoa(int(s.maxRetryAfter/time.Second)))
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("Service Unavailable\n"))
+		return
+	}
+
+	if streak <= 1 {
+		handler.ServeHTTP(w, r)
+		return
+	}
+
+	handler.ServeHTTP(&retryAfterWriter{ResponseWriter: w, factor: 1 << min(streak-1, 16), max: s.maxRetryAfter}, r)
+}
+
+// retryAfterWriter multiplies the Retry-After header (in seconds) set by the
+// rejection handler before the response header is written.
+type retryAfterWriter struct {
+	http.ResponseWriter
+	factor      int
+	max         time.Duration
+	wroteHeader bool
+}
+
+func (rw *retryAfterWriter) WriteHeader(statusCode int) {
+	rw.scaleRetryAfter()
+	rw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rw *retryAfterWriter) Write(b []byte) (int, error) {
+	rw.scaleRetryAfter()
+	return rw.ResponseWriter.Write(b)
+}
+
+func (rw *retryAfterWriter) scaleRetryAfter() {
+	if rw.wroteHeader {
+		return
+	}
+	rw.wroteHeader = true
+
+	seconds, err := strconv.Atoi(rw.Header().Get("Retry-After"))
+	if err != nil || seconds <= 0 {
+		return
+	}
+
+	base := time.Duration(seconds) * time.Second
+	scaled := max(base, min(base*time.Duration(rw.factor), rw.max))
+	rw.Header().Set("Retry-After", strconv.Itoa(int(scaled/time.Second)))
+}
+
+// Unwrap returns the underlying ResponseWriter, for http.ResponseController.
+func (rw *retryAfterWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,135 @@
+package loadshedder
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMiddleware_WithRejectStreaks(t *testing.T) {
+	ls := New(Config{Limit: 1})
+	mw := NewMiddleware(ls, nil, NewRejectionHandler(2), WithRejectStreaks(RejectStreakConfig{
+		Key:           RemoteIPKey,
+		EscalateAfter: 4,
+		MaxRetryAfter: 6 * time.Second,
+	}))
+
+	handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Saturate the limiter
+	_, token := ls.Acquire(context.Background())
+
+	// Retry-After doubles with every consecutive rejection, up to MaxRetryAfter
+	for i, expected := range []string{"2", "4", "6"} {
+		rec := request("10.0.0.1:1234")
+		if rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("rejection %d: expected 429, got %d", i+1, rec.Code)
+		}
+		if got := rec.Header().Get("Retry-After"); got != expected {
+			t.Errorf("rejection %d: expected Retry-After=%s, got %s", i+1, expected, got)
+		}
+	}
+
+	// The client ignoring backoff gets a firmer signal
+	rec := request("10.0.0.1:5678")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 after the streak escalates, got %d", rec.Code)
+	}
+	if rec.Header().Get("Connection") != "close" {
+		t.Errorf("expected Connection: close, got %q", rec.Header().Get("Connection"))
+	}
+
+	// Other clients are not affected
+	rec = request("10.0.0.2:1234")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "2" {
+		t.Errorf("expected a regular rejection for another client, got %d (Retry-After=%s)", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	// An accepted request resets the streak
+	ls.Release(token)
+	if rec := request("10.0.0.1:1234"); rec.Code != http.StatusOK {
+		t.Fatalf("expected request to be accepted, got %d", rec.Code)
+	}
+
+	_, token = ls.Acquire(context.Background())
+	defer ls.Release(token)
+
+	rec = request("10.0.0.1:1234")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "2" {
+		t.Errorf("expected the streak to be reset, got %d (Retry-After=%s)", rec.Code, rec.Header().Get("Retry-After"))
+	}
+}
+
+func TestRejectStreaks_Window(t *testing.T) {
+	streaks := newRejectStreaks(RejectStreakConfig{Key: RemoteIPKey, Window: 20 * time.Millisecond})
+	req := httptest.NewRequest(http.MethodGet, "/", http.NoBody)
+
+	if streak := streaks.rejected(req); streak != 1 {
+		t.Errorf("expected streak=1, got %d", streak)
+	}
+	if streak := streaks.rejected(req); streak != 2 {
+		t.Errorf("expected streak=2, got %d", streak)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	if streak := streaks.rejected(req); streak != 1 {
+		t.Errorf("expected the streak to restart after the window, got %d", streak)
+	}
+}
+
+func TestRejectStreaks_MaxKeys(t *testing.T) {
+	streaks := newRejectStreaks(RejectStreakConfig{
+		Key:     func(r *http.Request) string { return r.URL.Path },
+		MaxKeys: 2,
+	})
+
+	for _, path := range []string{"/a", "/b"} {
+		if streak := streaks.rejected(httptest.NewRequest(http.MethodGet, path, http.NoBody)); streak != 1 {
+			t.Errorf("%s: expected streak=1, got %d", path, streak)
+		}
+	}
+
+	// The table is full of active streaks: new clients are not tracked
+	if streak := streaks.rejected(httptest.NewRequest(http.MethodGet, "/c", http.NoBody)); streak != 0 {
+		t.Errorf("expected untracked client, got streak=%d", streak)
+	}
+}
+
+func TestRejectStreaks_AcceptedWithoutStreak(t *testing.T) {
+	var keys atomic.Int64
+	streaks := newRejectStreaks(RejectStreakConfig{Key: func(r *http.Request) string {
+		keys.Add(1)
+		return r.URL.Path
+	}})
+
+	// No streak: the key is not computed for admitted requests
+	streaks.accepted(httptest.NewRequest(http.MethodGet, "/a", http.NoBody))
+	if keys.Load() != 0 {
+		t.Errorf("expected no key computed without streaks, got %d", keys.Load())
+	}
+
+	streaks.rejected(httptest.NewRequest(http.MethodGet, "/a", http.NoBody))
+	streaks.accepted(httptest.NewRequest(http.MethodGet, "/b", http.NoBody))
+	if streaks.tracked.Load() != 1 {
+		t.Errorf("expected the streak of another client to be kept, got %d", streaks.tracked.Load())
+	}
+
+	streaks.accepted(httptest.NewRequest(http.MethodGet, "/a", http.NoBody))
+	if streak := streaks.rejected(httptest.NewRequest(http.MethodGet, "/a", http.NoBody)); streak != 1 {
+		t.Errorf("expected the streak reset by the accepted request, got %d", streak)
+	}
+}
@@ -0,0 +1,21 @@
+package loadshedder
+
+// strictViolation reports a misuse detected in StrictMode.
+func strictViolation(msg string) {
+	panic("loadshedder: strict mode: " + msg)
+}
+
+// checkRelease panics if the token is released with another loadshedder than
+// the one it was acquired from, or released twice. A token released by its
+// context ending (WithReleaseOnDone) can still be released once.
+func (l *Loadshedder) checkRelease(t *Token) {
+	if t == nil || !t.accepted {
+		return
+	}
+	if t.owner != nil && t.owner != l {
+		strictViolation("token released with another loadshedder than the one it was acquired from")
+	}
+	if t.released.Load() == tokenReleased {
+		strictViolation("token released twice")
+	}
+}
@@ -0,0 +1,88 @@
+package loadshedder
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func expectStrictPanic(t *testing.T, contains string, fn func()) {
+	t.Helper()
+	defer func() {
+		t.Helper()
+		r := recover()
+		if msg, _ := r.(string); !strings.Contains(msg, contains) {
+			t.Errorf("expected a panic containing %q, got %v", contains, r)
+		}
+	}()
+	fn()
+}
+
+func TestStrictMode_DoubleRelease(t *testing.T) {
+	ls := New(Config{Limit: 1, StrictMode: true})
+	_, token := ls.Acquire(context.Background())
+	ls.Release(token)
+
+	expectStrictPanic(t, "released twice", func() { ls.Release(token) })
+
+	// Rejected tokens can be released any number of times
+	_, blocker := ls.Acquire(context.Background())
+	_, rejected := ls.Acquire(context.Background())
+	ls.Release(rejected)
+	ls.Release(rejected)
+	ls.Release(blocker)
+}
+
+func TestStrictMode_ReleaseAfterReleaseOnDone(t *testing.T) {
+	ls := New(Config{Limit: 1, StrictMode: true})
+	ctx, cancel := context.WithCancel(context.Background())
+
+	_, token := ls.Acquire(ctx, WithReleaseOnDone())
+	cancel()
+	waitFor(t, func() bool { return ls.AutoReleased() == 1 })
+
+	ls.Release(token) // the normal path, not a misuse
+}
+
+func TestStrictMode_CrossLimiterRelease(t *testing.T) {
+	ls := New(Config{Limit: 1, StrictMode: true})
+	other := New(Config{Limit: 1, StrictMode: true})
+	_, token := ls.Acquire(context.Background())
+	defer ls.Release(token)
+
+	expectStrictPanic(t, "another loadshedder", func() { other.Release(token) })
+}
+
+func TestStrictMode_AcquireAfterDrain(t *testing.T) {
+	ls := New(Config{Limit: 1, StrictMode: true})
+	_ = ls.Drain(context.Background())
+
+	expectStrictPanic(t, "Acquire after Drain", func() { ls.Acquire(context.Background()) })
+}
+
+func TestStrictMode_InvariantViolation(t *testing.T) {
+	ls := New(Config{Limit: 1, StrictMode: true})
+	_, token := ls.Acquire(context.Background())
+	defer ls.Release(token)
+
+	tracker := &tokenTracker{tokens: map[*Token]struct{}{token: {}}}
+	time.Sleep(5 * time.Millisecond)
+
+	expectStrictPanic(t, "tokens held longer than MaxTokenAge", func() {
+		ls.checkLeaks(SoakConfig{MaxTokenAge: time.Millisecond}, tracker)
+	})
+}
+
+func TestStrictMode_Disabled(t *testing.T) {
+	ls := New(Config{Limit: 1})
+	_, token := ls.Acquire(context.Background())
+	ls.Release(token)
+	ls.Release(token)
+	New(Config{Limit: 1}).Release(token)
+
+	_ = ls.Drain(context.Background())
+	if _, token := ls.Acquire(context.Background()); token.Accepted() {
+		t.Error("expected a rejection after Drain")
+	}
+}
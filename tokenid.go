@@ -0,0 +1,84 @@
+package loadshedder
+
+import (
+	"context"
+	"math/rand/v2"
+	"strconv"
+	"sync/atomic"
+)
+
+// tokenIDSeqBits is the number of bits of the acceptance sequence in a
+// TokenID, the other bits identifying the process.
+const tokenIDSeqBits = 40
+
+var (
+	tokenIDPrefix = newTokenIDPrefix()
+	tokenIDSeq    atomic.Int64 // shared by the loadshedders of the process
+)
+
+// TokenID identifies an accepted token, to join the logs of a request
+// (queueing, handling, completion) without an external request ID.
+// IDs are unique within a process: they combine a random identifier of the
+// process and an acceptance sequence shared by all the loadshedders, which
+// wraps after about 10^12 admissions. The zero TokenID means no token.
+// It formats as a compact base36 string, including in slog and JSON.
+type TokenID uint64
+
+// newTokenIDPrefix returns a random non-zero process identifier, shifted in
+// place.
+func newTokenIDPrefix() uint64 {
+	return (1 + rand.Uint64N(1<<(64-tokenIDSeqBits)-1)) << tokenIDSeqBits
+}
+
+func newTokenID(prefix uint64, seq int64) TokenID {
+	return TokenID(prefix | uint64(seq)&(1<<tokenIDSeqBits-1))
+}
+
+// nextTokenID returns the ID of a newly accepted token.
+func nextTokenID() TokenID {
+	return newTokenID(tokenIDPrefix, tokenIDSeq.Add(1))
+}
+
+// String returns the base36 representation of the ID, or "" for the zero ID.
+func (id TokenID) String() string {
+	if id == 0 {
+		return ""
+	}
+	return strconv.FormatUint(uint64(id), 36)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (id TokenID) MarshalText() ([]byte, error) {
+	return []byte(id.String()), nil
+}
+
+type (
+	tokenIDContextKey struct{}
+	tokenContextKey   struct{}
+)
+
+// ContextWithTokenID returns a copy of ctx carrying the token ID. The
+// middleware sets it for admitted requests, so handler logs can include it.
+func ContextWithTokenID(ctx context.Context, id TokenID) context.Context {
+	return context.WithValue(ctx, tokenIDContextKey{}, id)
+}
+
+// ContextWithToken returns a copy of ctx carrying a token, so
+// TokenIDFromContext returns its ID and OnShed registers hooks on it (both
+// ignore rejected tokens). The middleware sets it for its requests, see
+// TokenFromContext.
+func ContextWithToken(ctx context.Context, t *Token) context.Context {
+	return context.WithValue(ctx, tokenContextKey{}, t)
+}
+
+// TokenIDFromContext returns the ID set with ContextWithTokenID, or else the
+// ID of the token set with ContextWithToken, or zero.
+func TokenIDFromContext(ctx context.Context) TokenID {
+	if id, ok := ctx.Value(tokenIDContextKey{}).(TokenID); ok {
+		return id
+	}
+	if t, ok := ctx.Value(tokenContextKey{}).(*Token); ok {
+		return t.id
+	}
+	return 0
+}
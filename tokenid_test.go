@@ -0,0 +1,98 @@
+package loadshedder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestToken_ID(t *testing.T) {
+	ctx := context.Background()
+	ls := New(Config{Limit: 1})
+	other := New(Config{Limit: 1})
+
+	seen := make(map[TokenID]bool)
+	for _, l := range []*Loadshedder{ls, ls, other} {
+		stats, token := l.Acquire(ctx)
+		if token.ID() == 0 || stats.TokenID != token.ID() {
+			t.Fatalf("expected an ID in the token and the stats, got %v and %v", token.ID(), stats.TokenID)
+		}
+		if seen[token.ID()] {
+			t.Errorf("duplicate ID %v", token.ID())
+		}
+		seen[token.ID()] = true
+
+		_, rejected := l.Acquire(ctx)
+		if rejected.ID() != 0 {
+			t.Error("expected no ID for a rejected token")
+		}
+		l.Release(token)
+	}
+
+	if stats := ls.Stats(); stats.TokenID != 0 {
+		t.Errorf("expected no ID in Stats, got %v", stats.TokenID)
+	}
+}
+
+func TestTokenID_Format(t *testing.T) {
+	id := newTokenID(newTokenIDPrefix(), 42)
+
+	if s := id.String(); s == "" || len(s) > 13 || strings.Trim(s, "0123456789abcdefghijklmnopqrstuvwxyz") != "" {
+		t.Errorf("expected a compact base36 string, got %q", s)
+	}
+	if TokenID(0).String() != "" {
+		t.Error("expected an empty string for the zero ID")
+	}
+
+	encoded, _ := json.Marshal(map[string]TokenID{"id": id})
+	if string(encoded) != `{"id":"`+id.String()+`"}` {
+		t.Errorf("expected the ID as a JSON string, got %s", encoded)
+	}
+
+	var buf bytes.Buffer
+	slog.New(slog.NewTextHandler(&buf, nil)).Info("done", "token", id)
+	if !strings.Contains(buf.String(), "token="+id.String()) {
+		t.Errorf("expected the ID formatted in logs, got %q", buf.String())
+	}
+}
+
+func TestMiddleware_TokenIDInContext(t *testing.T) {
+	ls := New(Config{Limit: 1})
+
+	var handled TokenID
+	reporter := &statsRecordingReporter{}
+	handler := NewMiddleware(ls, reporter, nil).Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handled = TokenIDFromContext(r.Context())
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	if len(reporter.accepted) != 1 || handled == 0 || handled != reporter.accepted[0].TokenID {
+		t.Errorf("expected the reported token ID in the handler context, got %v and %+v", handled, reporter.accepted)
+	}
+}
+
+func TestTokenIDFromContext(t *testing.T) {
+	ls := New(Config{Limit: 1})
+	_, token := ls.Acquire(context.Background())
+	defer ls.Release(token)
+
+	ctx := ContextWithToken(context.Background(), token)
+	if id := TokenIDFromContext(ctx); id != token.ID() {
+		t.Errorf("expected the ID of the token, got %v", id)
+	}
+
+	// Each has its own key: the token stays in the context
+	ctx = ContextWithTokenID(ctx, 42)
+	if id := TokenIDFromContext(ctx); id != 42 {
+		t.Errorf("expected the ID set with ContextWithTokenID, got %v", id)
+	}
+	if TokenFromContext(ctx) != token {
+		t.Error("expected the token to stay in the context")
+	}
+}
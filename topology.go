@@ -0,0 +1,407 @@
+package loadshedder
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// TopologyConfig describes a whole shedding topology declaratively: the
+// pools (independent loadshedders), the request classes, the routes mapping
+// requests to a pool and a class, and the reporter. Large deployments can
+// keep it in reviewed configuration files: the fields have json and yaml
+// tags, and durations are strings such as "250ms".
+//
+// Build wires the loadshedders and the middleware.
+type TopologyConfig struct {
+	// Pools are the loadshedders, each with its own limit and queue.
+	// Required, at least one.
+	Pools []PoolSpec `json:"pools" yaml:"pools"`
+
+	// Classes are the request classes the routes refer to.
+	// Optional.
+	Classes []ClassSpec `json:"classes,omitempty" yaml:"classes,omitempty"`
+
+	// Routes map the requests to a pool and a class. The longest matching
+	// path prefix wins.
+	// Optional.
+	Routes []RouteSpec `json:"routes,omitempty" yaml:"routes,omitempty"`
+
+	// DefaultPool is the pool of the requests matching no route.
+	// Optional, default to the first pool.
+	DefaultPool string `json:"default_pool,omitempty" yaml:"default_pool,omitempty"`
+
+	// Reporter is the reporter of the middleware: "none" or "log" (slog.Default).
+	// Optional, default to "none".
+	Reporter string `json:"reporter,omitempty" yaml:"reporter,omitempty"`
+
+	// RetryAfter is the Retry-After of the rejections, rounded up to the second.
+	// Optional, default to a Retry-After computed from the Stats, see
+	// NewAdaptiveRejectionHandler.
+	RetryAfter Duration `json:"retry_after,omitempty" yaml:"retry_after,omitempty"`
+}
+
+// PoolSpec describes a loadshedder of a TopologyConfig, see Config for the
+// meaning of the fields.
+type PoolSpec struct {
+	Name            string   `json:"name" yaml:"name"` // Required, unique
+	Limit           int64    `json:"limit" yaml:"limit"`
+	WaitingLimit    int64    `json:"waiting_limit,omitempty" yaml:"waiting_limit,omitempty"`
+	QueueDiscipline string   `json:"queue_discipline,omitempty" yaml:"queue_discipline,omitempty"` // "fifo", "lifo" or "adaptive-lifo"
+	MaxQueueWait    Duration `json:"max_queue_wait,omitempty" yaml:"max_queue_wait,omitempty"`
+	DeadlineAware   bool     `json:"deadline_aware,omitempty" yaml:"deadline_aware,omitempty"`
+	CoDelTarget     Duration `json:"codel_target,omitempty" yaml:"codel_target,omitempty"`
+	Adaptive        bool     `json:"adaptive,omitempty" yaml:"adaptive,omitempty"`
+
+	// PriorityAdmission is keyed by priority name or integer, see ParsePriority.
+	PriorityAdmission map[string]float64 `json:"priority_admission,omitempty" yaml:"priority_admission,omitempty"`
+
+	Signals []SignalSpec `json:"signals,omitempty" yaml:"signals,omitempty"`
+}
+
+// SignalSpec describes an overload signal of a pool. Type selects the
+// signal and the fields it uses:
+//
+//	cpu, host_cpu, file_descriptors, memory_limit  Threshold
+//	container_cpu, memory                          Threshold (see NewResourceCollector)
+//	threads                                        Threshold, Max (optional, see NewThreadSignal)
+//	heap                                           Threshold, Max (bytes, optional, see NewHeapSignal)
+//	goroutines                                     Max
+//	gc_pause, scheduler_latency                    Latency
+type SignalSpec struct {
+	Type      string   `json:"type" yaml:"type"`
+	Threshold float64  `json:"threshold,omitempty" yaml:"threshold,omitempty"`
+	Max       int64    `json:"max,omitempty" yaml:"max,omitempty"`
+	Latency   Duration `json:"latency,omitempty" yaml:"latency,omitempty"`
+}
+
+// ClassSpec describes a request class: how its requests acquire a slot.
+type ClassSpec struct {
+	Name     string   `json:"name" yaml:"name"`                             // Required, unique
+	Priority string   `json:"priority,omitempty" yaml:"priority,omitempty"` // See ParsePriority
+	MaxWait  Duration `json:"max_wait,omitempty" yaml:"max_wait,omitempty"` // See WithMaxWait
+	NoWait   bool     `json:"no_wait,omitempty" yaml:"no_wait,omitempty"`   // See WithNoWait
+	Weight   int      `json:"weight,omitempty" yaml:"weight,omitempty"`     // See WithWeight
+}
+
+// RouteSpec maps the requests to a pool and a class.
+type RouteSpec struct {
+	Path    string   `json:"path" yaml:"path"`                           // URL path prefix, matching whole segments ("/api" matches "/api/users", not "/apiary"), required
+	Methods []string `json:"methods,omitempty" yaml:"methods,omitempty"` // Optional, default to all methods
+	Pool    string   `json:"pool,omitempty" yaml:"pool,omitempty"`       // Optional, default to DefaultPool
+	Class   string   `json:"class,omitempty" yaml:"class,omitempty"`     // Optional
+	Label   string   `json:"label,omitempty" yaml:"label,omitempty"`     // See WithLabel, optional
+}
+
+// Duration is a time.Duration read from and written to configuration files
+// as a string, e.g. "1.5s".
+type Duration time.Duration
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(time.Duration(d).String()), nil
+}
+
+// Topology is the result of TopologyConfig.Build.
+type Topology struct {
+	// Registry holds the pools by name, e.g. to Donate capacity between them.
+	Registry *Registry
+
+	// Middleware routes each request to its pool, with the acquire options
+	// of its class.
+	Middleware *Middleware
+}
+
+// Pool returns the loadshedder of the pool, or nil.
+func (t *Topology) Pool(name string) *Loadshedder {
+	return t.Registry.Get(name)
+}
+
+// Handler wraps the handler with the middleware.
+func (t *Topology) Handler(next http.Handler) http.Handler {
+	return t.Middleware.Handler(next)
+}
+
+// topologyRoute is a validated RouteSpec.
+type topologyRoute struct {
+	path    string
+	methods []string
+	pool    *Loadshedder
+	options []AcquireOption
+}
+
+// Build validates the configuration and creates the loadshedders and the
+// middleware. The options are applied after the ones derived from the
+// configuration, e.g. WithReporter to use a reporter from a contrib package:
+// a WithClassifier takes precedence over the pools of the routes, and
+// WithRequestOptions applies after the options of their classes.
+func (c TopologyConfig) Build(opts ...MiddlewareOption) (*Topology, error) {
+	if len(c.Pools) == 0 {
+		return nil, errors.New("loadshedder: topology has no pools")
+	}
+
+	registry := NewRegistry()
+	for _, spec := range c.Pools {
+		cfg, err := spec.config()
+		if err != nil {
+			return nil, err
+		}
+		if err := registry.Register(New(cfg)); err != nil {
+			return nil, err
+		}
+	}
+
+	defaultPool := registry.Get(c.Pools[0].Name)
+	if c.DefaultPool != "" {
+		defaultPool = registry.Get(c.DefaultPool)
+		if defaultPool == nil {
+			return nil, fmt.Errorf("loadshedder: topology default pool %q is not defined", c.DefaultPool)
+		}
+	}
+
+	classes := make(map[string][]AcquireOption, len(c.Classes))
+	for _, spec := range c.Classes {
+		if spec.Name == "" {
+			return nil, errors.New("loadshedder: topology class without name")
+		}
+		if _, ok := classes[spec.Name]; ok {
+			return nil, fmt.Errorf("loadshedder: topology class %q is defined twice", spec.Name)
+		}
+		options, err := spec.options()
+		if err != nil {
+			return nil, err
+		}
+		classes[spec.Name] = options
+	}
+
+	routes := make([]topologyRoute, 0, len(c.Routes))
+	for _, spec := range c.Routes {
+		if spec.Path == "" {
+			return nil, errors.New("loadshedder: topology route without path")
+		}
+		route := topologyRoute{path: spec.Path, pool: defaultPool}
+		for _, method := range spec.Methods {
+			route.methods = append(route.methods, strings.ToUpper(method))
+		}
+		if spec.Pool != "" {
+			route.pool = registry.Get(spec.Pool)
+			if route.pool == nil {
+				return nil, fmt.Errorf("loadshedder: topology route %q: pool %q is not defined", spec.Path, spec.Pool)
+			}
+		}
+		if spec.Class != "" {
+			options, ok := classes[spec.Class]
+			if !ok {
+				return nil, fmt.Errorf("loadshedder: topology route %q: class %q is not defined", spec.Path, spec.Class)
+			}
+			route.options = options
+		}
+		if spec.Label != "" {
+			route.options = append(route.options[:len(route.options):len(route.options)], WithLabel(spec.Label))
+		}
+		routes = append(routes, route)
+	}
+
+	var reporter Reporter
+	switch c.Reporter {
+	case "", "none":
+	case "log":
+		reporter = NewLogReporter(nil)
+	default:
+		return nil, fmt.Errorf("loadshedder: topology reporter %q is unknown, expected none or log", c.Reporter)
+	}
+
+	var rejectionHandler RejectionHandler
+	if c.RetryAfter < 0 {
+		return nil, errors.New("loadshedder: topology retry_after cannot be negative")
+	}
+	if c.RetryAfter > 0 {
+		rejectionHandler = NewRejectionHandler(int(math.Ceil(time.Duration(c.RetryAfter).Seconds())))
+	}
+
+	match := func(r *http.Request) *topologyRoute {
+		var found *topologyRoute
+		for i := range routes {
+			route := &routes[i]
+			if !hasPathPrefix(r.URL.Path, route.path) {
+				continue
+			}
+			if len(route.methods) > 0 && !slices.Contains(route.methods, r.Method) {
+				continue
+			}
+			if found == nil || len(route.path) > len(found.path) {
+				found = route
+			}
+		}
+		return found
+	}
+
+	// Resolved once per request, for the pool and the options
+	opts = append([]MiddlewareOption{func(m *Middleware) {
+		m.router = func(r *http.Request) (*Loadshedder, []AcquireOption) {
+			if route := match(r); route != nil {
+				return route.pool, route.options
+			}
+			return nil, nil
+		}
+	}}, opts...)
+
+	return &Topology{
+		Registry:   registry,
+		Middleware: NewMiddleware(defaultPool, reporter, rejectionHandler, opts...),
+	}, nil
+}
+
+// hasPathPrefix reports whether the path is in the prefix, on a segment boundary.
+func hasPathPrefix(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/")
+}
+
+// config validates the spec, returning the Config of the pool. The checks
+// of New are repeated to return errors instead of panicking.
+func (s PoolSpec) config() (Config, error) {
+	if s.Name == "" {
+		return Config{}, errors.New("loadshedder: topology pool without name")
+	}
+	if s.Limit <= 0 {
+		return Config{}, fmt.Errorf("loadshedder: topology pool %q: limit must be positive", s.Name)
+	}
+	if s.WaitingLimit < 0 || s.MaxQueueWait < 0 || s.CoDelTarget < 0 {
+		return Config{}, fmt.Errorf("loadshedder: topology pool %q: waiting_limit, max_queue_wait and codel_target cannot be negative", s.Name)
+	}
+
+	cfg := Config{
+		Name:          s.Name,
+		Limit:         s.Limit,
+		WaitingLimit:  s.WaitingLimit,
+		MaxQueueWait:  time.Duration(s.MaxQueueWait),
+		DeadlineAware: s.DeadlineAware,
+		CoDelTarget:   time.Duration(s.CoDelTarget),
+		Adaptive:      s.Adaptive,
+	}
+
+	switch s.QueueDiscipline {
+	case "", "fifo":
+		cfg.QueueDiscipline = QueueFIFO
+	case "lifo":
+		cfg.QueueDiscipline = QueueLIFO
+	case "adaptive-lifo":
+		cfg.QueueDiscipline = QueueAdaptiveLIFO
+	default:
+		return Config{}, fmt.Errorf("loadshedder: topology pool %q: queue discipline %q is unknown", s.Name, s.QueueDiscipline)
+	}
+
+	if len(s.PriorityAdmission) > 0 {
+		cfg.PriorityAdmission = make(map[Priority]float64, len(s.PriorityAdmission))
+		for name, fraction := range s.PriorityAdmission {
+			p, err := ParsePriority(name)
+			if err != nil {
+				return Config{}, fmt.Errorf("loadshedder: topology pool %q: %w", s.Name, err)
+			}
+			if fraction <= 0 || fraction > 1 {
+				return Config{}, fmt.Errorf("loadshedder: topology pool %q: priority admission of %s must be in (0, 1]", s.Name, p)
+			}
+			cfg.PriorityAdmission[p] = fraction
+		}
+	}
+
+	for _, spec := range s.Signals {
+		signal, err := spec.signal()
+		if err != nil {
+			return Config{}, fmt.Errorf("loadshedder: topology pool %q: %w", s.Name, err)
+		}
+		cfg.Signals = append(cfg.Signals, signal)
+	}
+
+	return cfg, nil
+}
+
+// signal creates the signal. The constructors panic on invalid values, so
+// they are checked first.
+func (s SignalSpec) signal() (Signal, error) {
+	switch s.Type {
+	case "cpu", "host_cpu", "container_cpu", "file_descriptors", "memory_limit", "memory", "threads", "heap":
+		if s.Threshold <= 0 || s.Threshold > 1 {
+			return nil, fmt.Errorf("signal %q: threshold must be in (0, 1]", s.Type)
+		}
+	case "goroutines":
+		if s.Max <= 0 {
+			return nil, fmt.Errorf("signal %q: max must be positive", s.Type)
+		}
+	case "gc_pause", "scheduler_latency":
+		if s.Latency <= 0 {
+			return nil, fmt.Errorf("signal %q: latency must be positive", s.Type)
+		}
+	default:
+		return nil, fmt.Errorf("signal %q is unknown", s.Type)
+	}
+	if s.Max < 0 {
+		return nil, fmt.Errorf("signal %q: max cannot be negative", s.Type)
+	}
+
+	switch s.Type {
+	case "cpu":
+		return NewCPUSignal(s.Threshold), nil
+	case "host_cpu":
+		return NewHostCPUSignal(s.Threshold), nil
+	case "container_cpu":
+		return NewCollectorCPUSignal(s.Threshold, nil), nil
+	case "file_descriptors":
+		return NewFileDescriptorSignal(s.Threshold), nil
+	case "memory_limit":
+		return NewMemoryLimitSignal(s.Threshold), nil
+	case "memory":
+		return NewMemorySignal(s.Threshold, nil), nil
+	case "threads":
+		return NewThreadSignal(s.Threshold, int(s.Max)), nil
+	case "heap":
+		return NewHeapSignal(s.Threshold, uint64(s.Max)), nil
+	case "goroutines":
+		return NewGoroutineSignal(int(s.Max)), nil
+	case "gc_pause":
+		return NewGCPauseSignal(time.Duration(s.Latency)), nil
+	default:
+		return NewSchedulerLatencySignal(time.Duration(s.Latency)), nil
+	}
+}
+
+// options returns the acquire options of the class.
+func (s ClassSpec) options() ([]AcquireOption, error) {
+	var options []AcquireOption
+	if s.Priority != "" {
+		p, err := ParsePriority(s.Priority)
+		if err != nil {
+			return nil, fmt.Errorf("loadshedder: topology class %q: %w", s.Name, err)
+		}
+		options = append(options, WithPriority(p))
+	}
+	if s.MaxWait < 0 {
+		return nil, fmt.Errorf("loadshedder: topology class %q: max_wait cannot be negative", s.Name)
+	}
+	if s.NoWait {
+		options = append(options, WithNoWait())
+	} else if s.MaxWait > 0 {
+		options = append(options, WithMaxWait(time.Duration(s.MaxWait)))
+	}
+	if s.Weight < 0 {
+		return nil, fmt.Errorf("loadshedder: topology class %q: weight cannot be negative", s.Name)
+	}
+	if s.Weight > 0 {
+		options = append(options, WithWeight(s.Weight))
+	}
+	return options, nil
+}
@@ -0,0 +1,196 @@
+package loadshedder
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testTopology = `{
+	"pools": [
+		{"name": "api", "limit": 10, "waiting_limit": 5, "queue_discipline": "adaptive-lifo", "max_queue_wait": "2s",
+		 "priority_admission": {"sheddable": 0.5},
+		 "signals": [{"type": "goroutines", "max": 100000}, {"type": "gc_pause", "latency": "50ms"}]},
+		{"name": "exports", "limit": 2}
+	],
+	"classes": [
+		{"name": "interactive", "priority": "high", "max_wait": "250ms"},
+		{"name": "bulk", "priority": "sheddable", "no_wait": true, "weight": 2}
+	],
+	"routes": [
+		{"path": "/api/", "class": "interactive", "label": "api"},
+		{"path": "/api/export", "methods": ["post"], "pool": "exports", "class": "bulk"}
+	],
+	"retry_after": "10s"
+}`
+
+func TestTopologyConfig_Build(t *testing.T) {
+	var cfg TopologyConfig
+	if err := json.Unmarshal([]byte(testTopology), &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	reporter := &statsRecordingReporter{}
+	topology, err := cfg.Build(WithReporter(reporter))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api := topology.Pool("api")
+	if api == nil || topology.Pool("exports") == nil {
+		t.Fatal("expected the pools to be registered")
+	}
+	apiConfig := api.Config()
+	if apiConfig.WaitingLimit != 5 || apiConfig.QueueDiscipline != QueueAdaptiveLIFO || apiConfig.MaxQueueWait != 2*time.Second {
+		t.Errorf("unexpected pool config: %+v", apiConfig)
+	}
+	if apiConfig.PriorityAdmission[PrioritySheddable] != 0.5 || len(apiConfig.Signals) != 2 {
+		t.Errorf("unexpected pool policies: %+v", apiConfig)
+	}
+
+	var priorities []Priority
+	var weights []int64
+	handler := topology.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, _ := r.Context().Value(tokenContextKey{}).(*Token)
+		priorities = append(priorities, token.Priority())
+		weights = append(weights, token.Weight())
+	}))
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/api/users", http.NoBody),   // api, interactive
+		httptest.NewRequest(http.MethodPost, "/api/export", http.NoBody), // exports, bulk
+		httptest.NewRequest(http.MethodGet, "/api/export", http.NoBody),  // api, interactive
+		httptest.NewRequest(http.MethodGet, "/healthz", http.NoBody),     // default pool
+	} {
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	var pools []string
+	for _, stats := range reporter.accepted {
+		pools = append(pools, stats.Name)
+	}
+	if strings.Join(pools, ",") != "api,exports,api,api" {
+		t.Errorf("unexpected pools: %v", pools)
+	}
+	expectedPriorities := []Priority{PriorityHigh, PrioritySheddable, PriorityHigh, PriorityDefault}
+	for i, p := range expectedPriorities {
+		if priorities[i] != p {
+			t.Errorf("request %d: expected priority %s, got %s", i, p, priorities[i])
+		}
+	}
+	if weights[1] != 2 {
+		t.Errorf("expected the bulk class to weigh 2, got %d", weights[1])
+	}
+	if counters := api.CountersByLabel()["api"]; counters.Accepted != 2 {
+		t.Errorf("expected 2 requests labeled api, got %+v", counters)
+	}
+}
+
+func TestTopologyConfig_BuildRejection(t *testing.T) {
+	topology, err := TopologyConfig{
+		Pools:      []PoolSpec{{Name: "api", Limit: 1}},
+		RetryAfter: Duration(10 * time.Second),
+	}.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, token := topology.Pool("api").Acquire(t.Context())
+	defer topology.Pool("api").Release(token)
+
+	rec := httptest.NewRecorder()
+	topology.Handler(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "10" {
+		t.Errorf("expected a rejection with Retry-After 10, got %d %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+}
+
+func TestTopologyConfig_RetryAfterRoundedUp(t *testing.T) {
+	topology, err := TopologyConfig{
+		Pools:      []PoolSpec{{Name: "api", Limit: 1}},
+		RetryAfter: Duration(500 * time.Millisecond),
+	}.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, token := topology.Pool("api").Acquire(t.Context())
+	defer topology.Pool("api").Release(token)
+
+	rec := httptest.NewRecorder()
+	topology.Handler(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+	if rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("expected Retry-After rounded up to 1, got %q", rec.Header().Get("Retry-After"))
+	}
+}
+
+func TestTopologyConfig_RouteSegments(t *testing.T) {
+	reporter := &statsRecordingReporter{}
+	topology, err := TopologyConfig{
+		Pools:  []PoolSpec{{Name: "default", Limit: 10}, {Name: "api", Limit: 10}},
+		Routes: []RouteSpec{{Path: "/api", Pool: "api"}},
+	}.Build(WithReporter(reporter))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := topology.Handler(http.NotFoundHandler())
+	for _, path := range []string{"/api", "/api/users", "/apiary", "/api-v2"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, http.NoBody))
+	}
+
+	var pools []string
+	for _, stats := range reporter.accepted {
+		pools = append(pools, stats.Name)
+	}
+	if strings.Join(pools, ",") != "api,api,default,default" {
+		t.Errorf("expected the route to match whole segments, got %v", pools)
+	}
+}
+
+func TestTopologyConfig_BuildErrors(t *testing.T) {
+	pools := []PoolSpec{{Name: "api", Limit: 1}}
+
+	for name, cfg := range map[string]TopologyConfig{
+		"no pools":         {},
+		"pool name":        {Pools: []PoolSpec{{Limit: 1}}},
+		"pool limit":       {Pools: []PoolSpec{{Name: "api"}}},
+		"duplicate pool":   {Pools: []PoolSpec{{Name: "api", Limit: 1}, {Name: "api", Limit: 1}}},
+		"discipline":       {Pools: []PoolSpec{{Name: "api", Limit: 1, QueueDiscipline: "random"}}},
+		"priority":         {Pools: []PoolSpec{{Name: "api", Limit: 1, PriorityAdmission: map[string]float64{"urgent": 0.5}}}},
+		"admission":        {Pools: []PoolSpec{{Name: "api", Limit: 1, PriorityAdmission: map[string]float64{"default": 2}}}},
+		"signal type":      {Pools: []PoolSpec{{Name: "api", Limit: 1, Signals: []SignalSpec{{Type: "disk"}}}}},
+		"signal threshold": {Pools: []PoolSpec{{Name: "api", Limit: 1, Signals: []SignalSpec{{Type: "cpu"}}}}},
+		"default pool":     {Pools: pools, DefaultPool: "other"},
+		"class priority":   {Pools: pools, Classes: []ClassSpec{{Name: "bulk", Priority: "urgent"}}},
+		"duplicate class":  {Pools: pools, Classes: []ClassSpec{{Name: "bulk"}, {Name: "bulk"}}},
+		"route path":       {Pools: pools, Routes: []RouteSpec{{Pool: "api"}}},
+		"route pool":       {Pools: pools, Routes: []RouteSpec{{Path: "/", Pool: "other"}}},
+		"route class":      {Pools: pools, Routes: []RouteSpec{{Path: "/", Class: "other"}}},
+		"reporter":         {Pools: pools, Reporter: "prometheus"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := cfg.Build(); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestDuration(t *testing.T) {
+	var d Duration
+	if err := json.Unmarshal([]byte(`"1.5s"`), &d); err != nil || d != Duration(1500*time.Millisecond) {
+		t.Errorf("expected 1.5s, got %v (%v)", time.Duration(d), err)
+	}
+	if err := json.Unmarshal([]byte(`"soon"`), &d); err == nil {
+		t.Error("expected an error")
+	}
+
+	data, err := json.Marshal(Duration(250 * time.Millisecond))
+	if err != nil || string(data) != `"250ms"` {
+		t.Errorf("expected \"250ms\", got %s (%v)", data, err)
+	}
+}
@@ -0,0 +1,36 @@
+package loadshedder
+
+import (
+	"context"
+	"runtime/trace"
+	"time"
+)
+
+// Execution tracing annotations. They are only emitted while a runtime/trace
+// session is active (e.g. via go test -trace or net/http/pprof), so that
+// `go tool trace` shows queue waits, shed decisions and handler execution
+// alongside scheduler activity. When tracing is off they cost a single check.
+
+const (
+	traceCategory      = "loadshedder"
+	traceTaskRequest   = "loadshedder.request"
+	traceRegionWait    = "loadshedder.wait"
+	traceRegionHandler = "loadshedder.handler"
+)
+
+func (l *Loadshedder) acquireSlotTraced(ctx context.Context, n int64, counterOnly, noWait, force bool, maxWait time.Duration) (acquired, queued bool, reason RejectReason) {
+	if !trace.IsEnabled() {
+		return l.acquireSlot(ctx, n, counterOnly, noWait, force, maxWait)
+	}
+
+	trace.WithRegion(ctx, traceRegionWait, func() {
+		acquired, queued, reason = l.acquireSlot(ctx, n, counterOnly, noWait, force, maxWait)
+	})
+	return acquired, queued, reason
+}
+
+func traceDecision(ctx context.Context, decision string) {
+	if trace.IsEnabled() {
+		trace.Log(ctx, traceCategory, decision)
+	}
+}
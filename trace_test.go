@@ -0,0 +1,41 @@
+package loadshedder
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"runtime/trace"
+	"testing"
+)
+
+func TestMiddleware_TraceAnnotations(t *testing.T) {
+	var buf bytes.Buffer
+	if err := trace.Start(&buf); err != nil {
+		t.Skipf("execution tracing unavailable: %v", err)
+	}
+
+	limiter := New(Config{Limit: 1})
+	mw := NewMiddleware(limiter, nil, nil)
+
+	handler := mw.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Nested request is rejected while the outer one holds the only slot
+		rec := httptest.NewRecorder()
+		mw.Handler(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+		w.WriteHeader(rec.Code)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", http.NoBody))
+
+	trace.Stop()
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("expected nested request to be rejected, got %d", rec.Code)
+	}
+
+	for _, name := range []string{traceTaskRequest, traceRegionWait, traceRegionHandler, "accepted", "rejected"} {
+		if !bytes.Contains(buf.Bytes(), []byte(name)) {
+			t.Errorf("expected %q in execution trace", name)
+		}
+	}
+}
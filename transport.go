@@ -0,0 +1,94 @@
+package loadshedder
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+const sourceHTTPClient = "http-client"
+
+// Transport is an http.RoundTripper limiting the concurrency of outgoing
+// requests, a client-side bulkhead protecting a dependency. Each request
+// acquires a slot first, waiting in the queue if the loadshedder has a
+// WaitingLimit, and holds it until its response body is closed or read to
+// the end. The body of a 101 Switching Protocols response stays an
+// io.ReadWriteCloser, holding the slot until the connection is closed.
+// Rejected requests fail with a *ShedError without being sent.
+type Transport struct {
+	loadshedder *Loadshedder
+	next        http.RoundTripper
+	options     []AcquireOption
+}
+
+// NewTransport creates a Transport sending the requests admitted by the
+// loadshedder with next. Options apply to every acquisition, e.g. WithMaxWait.
+// Acquisitions are tagged with the "http-client" source, and inherit the
+// priority of the request context.
+// If next is nil, http.DefaultTransport is used.
+func NewTransport(loadshedder *Loadshedder, next http.RoundTripper, opts ...AcquireOption) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Transport{
+		loadshedder: loadshedder,
+		next:        next,
+		options:     append([]AcquireOption{WithSource(sourceHTTPClient)}, opts...),
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	stats, token := t.loadshedder.Acquire(r.Context(), t.options...)
+	if !token.Accepted() {
+		if r.Body != nil {
+			_ = r.Body.Close() // a RoundTripper must always close the body
+		}
+		return nil, &ShedError{Stats: stats}
+	}
+
+	resp, err := t.next.RoundTrip(r)
+	if err != nil || resp.Body == nil {
+		t.loadshedder.Release(token)
+		return resp, err
+	}
+
+	body := &releasingBody{ReadCloser: resp.Body, release: func() { t.loadshedder.Release(token) }}
+	if conn, ok := resp.Body.(io.ReadWriteCloser); ok && resp.StatusCode == http.StatusSwitchingProtocols {
+		// The body of an upgraded connection is the connection itself: the
+		// slot is held until it is closed, and the writes pass through
+		resp.Body = &releasingConn{releasingBody: body, Writer: conn}
+		return resp, nil
+	}
+	resp.Body = body
+	return resp, nil
+}
+
+// releasingBody releases the slot of a request once its response body is
+// closed or read to the end.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+	once    sync.Once
+}
+
+func (b *releasingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.once.Do(b.release)
+	}
+	return n, err
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// releasingConn is the releasingBody of a 101 Switching Protocols response,
+// keeping the io.ReadWriteCloser of the upgraded connection.
+type releasingConn struct {
+	*releasingBody
+	io.Writer
+}
@@ -0,0 +1,109 @@
+package loadshedder
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTransport(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	ls := New(Config{Limit: 1})
+	client := &http.Client{Transport: NewTransport(ls, nil)}
+
+	resp, err := client.Get(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The slot is held until the body is consumed
+	if stats := ls.Stats(); stats.Running != 1 {
+		t.Errorf("expected the response to hold the slot, got %+v", stats)
+	}
+	if _, err := client.Get(upstream.URL); !errors.Is(err, ErrShed) {
+		t.Errorf("expected ErrShed while the only slot is held, got %v", err)
+	}
+
+	if _, err := io.ReadAll(resp.Body); err != nil {
+		t.Fatal(err)
+	}
+	if stats := ls.Stats(); stats.Running != 0 {
+		t.Errorf("expected the slot released at the end of the body, got %+v", stats)
+	}
+	resp.Body.Close()
+
+	if counters := ls.Counters(); counters != (Counters{Accepted: 1, Rejected: 1, Released: 1}) {
+		t.Errorf("unexpected counters: %+v", counters)
+	}
+}
+
+func TestTransport_ReleasesOnError(t *testing.T) {
+	ls := New(Config{Limit: 1})
+	client := &http.Client{Transport: NewTransport(ls, nil)}
+
+	// Nothing listens on port 1
+	if _, err := client.Get("http://127.0.0.1:1"); err == nil || errors.Is(err, ErrShed) {
+		t.Fatalf("expected a connection error, got %v", err)
+	}
+	if stats := ls.Stats(); stats.Running != 0 {
+		t.Errorf("expected no slot held after a failed request, got %+v", stats)
+	}
+	if counters := ls.Counters(); counters.Accepted != 1 || counters.Released != 1 {
+		t.Errorf("expected the request admitted then released, got %+v", counters)
+	}
+}
+
+func TestTransport_SwitchingProtocols(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+		_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+		_ = rw.Flush()
+		_, _ = io.Copy(conn, rw)
+	}))
+	defer upstream.Close()
+
+	ls := New(Config{Limit: 1})
+	client := &http.Client{Transport: NewTransport(ls, nil)}
+
+	req, _ := http.NewRequest(http.MethodGet, upstream.URL, http.NoBody)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "echo")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected 101, got %d", resp.StatusCode)
+	}
+
+	conn, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		t.Fatalf("expected the body of the upgraded connection to be writable, got %T", resp.Body)
+	}
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	echo := make([]byte, 4)
+	if _, err := io.ReadFull(conn, echo); err != nil || string(echo) != "ping" {
+		t.Fatalf("expected the echo, got %q (%v)", echo, err)
+	}
+
+	if stats := ls.Stats(); stats.Running != 1 {
+		t.Errorf("expected the upgraded connection to hold the slot, got %+v", stats)
+	}
+	conn.Close()
+	if stats := ls.Stats(); stats.Running != 0 {
+		t.Errorf("expected the slot released when the connection is closed, got %+v", stats)
+	}
+}